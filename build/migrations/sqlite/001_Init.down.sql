@@ -0,0 +1,11 @@
+DROP TABLE login_attempts;
+DROP TABLE credentials;
+DROP TABLE groups;
+DROP TABLE permissions;
+DROP TABLE roles;
+DROP TABLE auth_codes;
+DROP TABLE clients;
+DROP TABLE revocations;
+DROP TABLE tokens;
+DROP TABLE keys;
+DROP TABLE users;
@@ -0,0 +1,122 @@
+-- times are unix milliseconds, lists are JSON arrays
+CREATE TABLE users (
+    id             TEXT PRIMARY KEY,
+    login          TEXT    NOT NULL UNIQUE,
+    password       TEXT    NOT NULL DEFAULT '',
+    name           TEXT    NOT NULL DEFAULT '',
+    email          TEXT    NOT NULL DEFAULT '',
+    email_verified INTEGER NOT NULL DEFAULT 0,
+    roles          TEXT    NOT NULL DEFAULT 'null',
+    groups         TEXT    NOT NULL DEFAULT 'null',
+    disabled       INTEGER NOT NULL DEFAULT 0,
+    totp_secret    TEXT    NOT NULL DEFAULT '',
+    totp_enabled   INTEGER NOT NULL DEFAULT 0,
+    totp_step      INTEGER NOT NULL DEFAULT 0,
+    recovery_codes TEXT    NOT NULL DEFAULT 'null'
+);
+
+CREATE TABLE keys (
+    id          TEXT PRIMARY KEY,
+    kid         TEXT    NOT NULL,
+    pem         BLOB    NOT NULL,
+    created_at  INTEGER NOT NULL,
+    activate_at INTEGER NOT NULL,
+    retire_at   INTEGER NOT NULL,
+    exp         INTEGER NOT NULL
+);
+CREATE INDEX keys_exp ON keys (exp);
+
+CREATE TABLE tokens (
+    id          TEXT PRIMARY KEY,
+    hash        TEXT    NOT NULL UNIQUE,
+    user_id     TEXT    NOT NULL,
+    client_id   TEXT    NOT NULL DEFAULT '',
+    scope       TEXT    NOT NULL DEFAULT '',
+    family      TEXT    NOT NULL,
+    auth_time   INTEGER NOT NULL,
+    acr         TEXT    NOT NULL DEFAULT '',
+    create_at   INTEGER NOT NULL,
+    valid_until INTEGER NOT NULL,
+    used        INTEGER NOT NULL DEFAULT 0,
+    used_at     INTEGER,
+    revoked     INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX tokens_family ON tokens (family);
+
+CREATE TABLE revocations (
+    jti        TEXT PRIMARY KEY,
+    revoked_at INTEGER NOT NULL,
+    exp        INTEGER NOT NULL
+);
+CREATE INDEX revocations_revoked_at ON revocations (revoked_at);
+
+CREATE TABLE clients (
+    id            TEXT PRIMARY KEY,
+    client_id     TEXT NOT NULL UNIQUE,
+    secret        TEXT NOT NULL DEFAULT '',
+    name          TEXT NOT NULL DEFAULT '',
+    redirect_uris TEXT NOT NULL DEFAULT 'null',
+    scopes        TEXT NOT NULL DEFAULT 'null'
+);
+
+CREATE TABLE auth_codes (
+    id             TEXT PRIMARY KEY,
+    hash           TEXT    NOT NULL UNIQUE,
+    client_id      TEXT    NOT NULL,
+    user_id        TEXT    NOT NULL,
+    redirect_uri   TEXT    NOT NULL DEFAULT '',
+    scope          TEXT    NOT NULL DEFAULT '',
+    code_challenge TEXT    NOT NULL DEFAULT '',
+    family         TEXT    NOT NULL DEFAULT '',
+    nonce          TEXT    NOT NULL DEFAULT '',
+    auth_time      INTEGER NOT NULL,
+    acr            TEXT    NOT NULL DEFAULT '',
+    create_at      INTEGER NOT NULL,
+    valid_until    INTEGER NOT NULL,
+    used           INTEGER NOT NULL DEFAULT 0,
+    used_at        INTEGER
+);
+
+CREATE TABLE roles (
+    id          TEXT PRIMARY KEY,
+    name        TEXT NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    permissions TEXT NOT NULL DEFAULT 'null'
+);
+
+CREATE TABLE permissions (
+    id          TEXT PRIMARY KEY,
+    name        TEXT NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE groups (
+    id          TEXT PRIMARY KEY,
+    name        TEXT NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    parents     TEXT NOT NULL DEFAULT 'null'
+);
+
+CREATE TABLE credentials (
+    id            TEXT PRIMARY KEY,
+    user_id       TEXT    NOT NULL,
+    credential_id TEXT    NOT NULL UNIQUE,
+    public_key    BLOB    NOT NULL,
+    sign_count    INTEGER NOT NULL DEFAULT 0,
+    aaguid        BLOB,
+    name          TEXT    NOT NULL DEFAULT '',
+    created_at    INTEGER NOT NULL,
+    last_used_at  INTEGER NOT NULL
+);
+CREATE INDEX credentials_user_id ON credentials (user_id);
+
+CREATE TABLE login_attempts (
+    key          TEXT PRIMARY KEY,
+    failures     INTEGER NOT NULL,
+    last_failure INTEGER NOT NULL,
+    locked_until INTEGER NOT NULL,
+    expire_at    INTEGER NOT NULL
+);
+
+INSERT INTO roles (id, name, description, permissions)
+VALUES (lower(hex(randomblob(16))), 'admin', 'Access to the admin API', '[]');
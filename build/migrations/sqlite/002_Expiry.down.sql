@@ -0,0 +1,4 @@
+DROP INDEX login_attempts_expire_at;
+DROP INDEX revocations_exp;
+DROP INDEX auth_codes_valid_until;
+DROP INDEX tokens_valid_until;
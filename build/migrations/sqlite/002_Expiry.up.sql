@@ -0,0 +1,5 @@
+-- expired rows are purged periodically, see internal/storage/sqlite/purge.go
+CREATE INDEX tokens_valid_until ON tokens (valid_until);
+CREATE INDEX auth_codes_valid_until ON auth_codes (valid_until);
+CREATE INDEX revocations_exp ON revocations (exp);
+CREATE INDEX login_attempts_expire_at ON login_attempts (expire_at);
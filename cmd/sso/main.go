@@ -3,13 +3,23 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
+	"go.opentelemetry.io/otel"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	cfg "sso/internal/config"
 	"sso/internal/config/env"
 	"sso/internal/http/handlers"
+	"sso/internal/metrics"
+	"sso/internal/models"
+	"sso/internal/services"
+	"sso/internal/storage"
+	"sso/internal/storage/memory"
 	"sso/internal/storage/mongo"
+	"sso/internal/storage/sqlite"
+	"sso/internal/tracing"
 	"sso/pkg/helpers/slogHelper"
 	"sso/pkg/http/middleware"
 	"sso/pkg/http/routing"
@@ -22,38 +32,137 @@ func main() {
 	log.Info("start SSO service", slog.String("env", config.DebugLevel))
 	log.Debug("debug messages are enabled")
 
-	storage, err := mongo.New(log, mongo.Config{
-		Server:       config.Db.Server,
-		User:         config.Db.User,
-		Password:     config.Db.Password,
-		Database:     config.Db.Database,
-		RootPassword: config.RootPassword,
-	})
+	shutdownTracing, err := tracing.Setup(context.Background(), config.Tracing)
 	if err != nil {
-		log.Error("failed init storage", slogHelper.GetErrAttr(err))
+		log.Error("failed init tracing", slogHelper.GetErrAttr(err))
 		os.Exit(1)
 	}
-	//storage.InitDatabase(log, config)
 
-	/*storage, err := sqlite.New(cfg.Sqlite.Path, cfg.Rsa.Folder)
+	storage, err := newStorage(log, config)
 	if err != nil {
 		log.Error("failed init storage", slogHelper.GetErrAttr(err))
 		os.Exit(1)
 	}
-	//do data migrations
-	if err := storage.Migrations().Migrate(cfg.Init.Password); err != nil {
-		log.Error("failed migrate database", slogHelper.GetErrAttr(err))
-	}*/
+	ctx := context.Background()
+	if uid, err := services.Users(storage).EnsureRoot(ctx, config.RootPassword); err != nil {
+		log.Error("failed create root user", slogHelper.GetErrAttr(err))
+	} else if uid != "" {
+		log.Info("------------------------------------------------------------------------------------------")
+		log.Info("Successfully created user",
+			slog.String("uid", uid),
+			slog.String("login", services.RootLogin),
+			slog.String("password", config.RootPassword),
+		)
+		log.Info("------------------------------------------------------------------------------------------")
+	}
+
+	clients := services.Clients(storage)
+	for _, c := range config.InitClients {
+		added, err := clients.Ensure(ctx, &models.Client{
+			ClientId:     c.ClientId,
+			Name:         c.Name,
+			RedirectUris: c.RedirectUris,
+			Scopes:       c.Scopes,
+		}, c.Secret)
+		if err != nil {
+			log.Error("failed register client", slog.String("client_id", c.ClientId), slogHelper.GetErrAttr(err))
+		} else if added {
+			log.Info("registered client", slog.String("client_id", c.ClientId))
+		}
+	}
+
+	keys := services.Keys(storage, config.Keys)
+	groups := services.Groups(storage, config.Groups)
+	tokens := services.Tokens(storage, keys, config.Tokens, groups)
+	revocations := services.Revocations(storage, keys, config.Tokens)
+	codes := services.Codes(storage, config.Tokens)
+	lockout := services.Lockout(storage, config.Lockout)
+	mfa := services.Mfa(storage, tokens, keys, revocations, lockout, config.Mfa)
+	webauthn := services.Webauthn(storage, tokens, keys, revocations, mfa, config.Webauthn)
+	users := services.Users(storage)
+	roles := services.Roles(storage)
+	health := services.Health(storage, keys)
+	trusted, err := middleware.ParseTrustedProxies(config.Server.TrustedProxies)
+	if err != nil {
+		log.Error("failed parse trusted proxies", slogHelper.GetErrAttr(err))
+		os.Exit(1)
+	}
+	limits := make([]middleware.RateLimit, 0, len(config.RateLimits))
+	for _, l := range config.RateLimits {
+		limit := middleware.RateLimit{Pattern: l.Pattern, Rate: l.Rate, Burst: l.Burst, Key: middleware.ByIp}
+		if l.Key == cfg.RateLimitKeyClient {
+			limit.Key = handlers.ClientKey(keys, revocations)
+		}
+		limits = append(limits, limit)
+	}
+	admin := handlers.RequireRole(log, keys, revocations, models.RoleAdmin)
+	authenticated := handlers.RequireAuth(log, keys, revocations)
+	reauthenticated := handlers.RequireRecentAuth(log, keys, revocations, config.Tokens.ReauthMaxAge)
 
 	//configure routes
-	routes := routing.New().
-		Handle("POST /{$}", handlers.Auth(log, storage)).
+	routes := routing.New()
+	routes.
+		Handle("POST /{$}", handlers.Auth(log, lockout, tokens, mfa)).
+		Handle("POST /token/refresh", handlers.Refresh(log, tokens)).
+		Handle("POST /token", handlers.Token(log, clients, tokens, codes, mfa)).
+		Handle("GET /authorize", handlers.Authorize(log, lockout, codes, mfa, webauthn)).
+		Handle("POST /authorize", handlers.Authorize(log, lockout, codes, mfa, webauthn)).
+		Handle("POST /mfa/verify", handlers.MfaVerify(log, tokens, mfa)).
+		Handle("POST /mfa/totp/enroll", reauthenticated(handlers.MfaEnroll(log, mfa))).
+		Handle("GET /mfa/totp/qr", reauthenticated(handlers.MfaQr(log, mfa))).
+		Handle("POST /mfa/totp/confirm", reauthenticated(handlers.MfaConfirm(log, mfa))).
+		Handle("POST /webauthn/register/begin", reauthenticated(handlers.WebauthnRegisterBegin(log, webauthn))).
+		Handle("POST /webauthn/register/finish", reauthenticated(handlers.WebauthnRegisterFinish(log, webauthn))).
+		Handle("POST /webauthn/login/begin", handlers.WebauthnLoginBegin(log, webauthn)).
+		Handle("POST /webauthn/login/finish", handlers.WebauthnLoginFinish(log, tokens, webauthn)).
+		Handle("GET /webauthn/credentials", authenticated(handlers.WebauthnCredentials(log, webauthn))).
+		Handle("DELETE /webauthn/credentials/{id}", authenticated(handlers.WebauthnDeleteCredential(log, webauthn))).
 		Handle("GET /status", handlers.Status(log)).
-		Handle("GET /key", handlers.Key(log, storage)).
-		Handle("POST /check", handlers.Check(log, storage)).
+		Handle("GET /healthz", handlers.Status(log)).
+		Handle("GET /readyz", handlers.Ready(log, health)).
+		Handle("GET /metrics", metrics.Handler().ServeHTTP).
+		Handle("GET /key", handlers.Key(log, keys)).
+		Handle("GET /.well-known/jwks.json", handlers.Jwks(log, keys)).
+		Handle("GET /.well-known/openid-configuration", handlers.Discovery(log, config.Tokens.Issuer)).
+		Handle("POST /check", handlers.Check(log, keys, revocations)).
+		Handle("POST /revoke", handlers.Revoke(log, clients, revocations)).
+		Handle("POST /introspect", handlers.Introspect(log, storage, clients, keys, revocations)).
+		Handle("GET /userinfo", handlers.Userinfo(log, storage, keys, revocations)).
+		Handle("POST /userinfo", handlers.Userinfo(log, storage, keys, revocations)).
+		Handle("GET /admin/users", admin(handlers.AdminListUsers(log, users))).
+		Handle("POST /admin/users", admin(handlers.AdminCreateUser(log, users))).
+		Handle("GET /admin/users/{id}", admin(handlers.AdminGetUser(log, users))).
+		Handle("PATCH /admin/users/{id}", admin(handlers.AdminUpdateUser(log, users))).
+		Handle("DELETE /admin/users/{id}", admin(handlers.AdminDeleteUser(log, users))).
+		Handle("POST /admin/users/{id}/disable", admin(handlers.AdminSetUserDisabled(log, users, true))).
+		Handle("POST /admin/users/{id}/enable", admin(handlers.AdminSetUserDisabled(log, users, false))).
+		Handle("DELETE /admin/users/{id}/mfa", admin(handlers.AdminResetMfa(log, mfa))).
+		Handle("DELETE /admin/users/{id}/lockout", admin(handlers.AdminUnlockUser(log, lockout))).
+		Handle("PUT /admin/users/{id}/roles/{role}", admin(handlers.AdminAssignRole(log, roles, true))).
+		Handle("DELETE /admin/users/{id}/roles/{role}", admin(handlers.AdminAssignRole(log, roles, false))).
+		Handle("GET /admin/users/{id}/groups", admin(handlers.AdminUserGroups(log, users, groups))).
+		Handle("GET /admin/groups", admin(handlers.AdminListGroups(log, groups))).
+		Handle("POST /admin/groups", admin(handlers.AdminCreateGroup(log, groups))).
+		Handle("GET /admin/groups/{id}", admin(handlers.AdminGetGroup(log, groups))).
+		Handle("PATCH /admin/groups/{id}", admin(handlers.AdminUpdateGroup(log, groups))).
+		Handle("DELETE /admin/groups/{id}", admin(handlers.AdminDeleteGroup(log, groups))).
+		Handle("PUT /admin/groups/{id}/members/{userId}", admin(handlers.AdminGroupMember(log, groups, true))).
+		Handle("DELETE /admin/groups/{id}/members/{userId}", admin(handlers.AdminGroupMember(log, groups, false))).
+		Handle("GET /admin/roles", admin(handlers.AdminListRoles(log, roles))).
+		Handle("POST /admin/roles", admin(handlers.AdminCreateRole(log, roles))).
+		Handle("GET /admin/roles/{name}", admin(handlers.AdminGetRole(log, roles))).
+		Handle("PATCH /admin/roles/{name}", admin(handlers.AdminUpdateRole(log, roles))).
+		Handle("DELETE /admin/roles/{name}", admin(handlers.AdminDeleteRole(log, roles))).
+		Handle("GET /admin/permissions", admin(handlers.AdminListPermissions(log, roles))).
+		Handle("POST /admin/permissions", admin(handlers.AdminCreatePermission(log, roles))).
+		Handle("DELETE /admin/permissions/{name}", admin(handlers.AdminDeletePermission(log, roles))).
+		UseMiddleware(middleware.RateLimiter(log, limits...)).
 		UseMiddleware(middleware.Logging(log)).
 		UseMiddleware(middleware.Recovery(log, config.DebugLevel)).
-		UseMiddleware(middleware.RequestId(log))
+		UseMiddleware(middleware.Metrics(log, metrics.Registry, routes.Pattern)).
+		UseMiddleware(middleware.Tracing(log, otel.GetTracerProvider(), routes.Pattern)).
+		UseMiddleware(middleware.RequestId(log)).
+		UseMiddleware(middleware.RealIp(log, trusted))
 
 	//configure http server
 	srv := &http.Server{
@@ -79,11 +188,43 @@ func main() {
 	signal.Notify(channel, os.Kill)
 	sig := <-channel
 	log.Info("Stop signal received", slog.String("signal", sig.String()))
-	ctx, cancelFunc := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancelFunc := context.WithTimeout(ctx, 5*time.Second)
 	defer cancelFunc()
 	if err := storage.Shutdown(ctx); err != nil {
 		log.Error("Storage Graceful shutdown failed", slogHelper.GetErrAttr(err))
 	}
+	if err := shutdownTracing(ctx); err != nil {
+		log.Error("Tracing Graceful shutdown failed", slogHelper.GetErrAttr(err))
+	}
 	log.Info("Server shutdown successfully")
 
 }
+
+// backend is a storage reporting its health with connections to close on shutdown
+type backend interface {
+	storage.Storage
+	storage.Health
+	Shutdown(ctx context.Context) error
+}
+
+// newStorage opens storage backend selected by config
+func newStorage(log *slog.Logger, config *cfg.Config) (backend, error) {
+	switch config.Db.Backend {
+	case cfg.DbBackendMongo:
+		return mongo.New(log, mongo.Config{
+			Server:   config.Db.Server,
+			User:     config.Db.User,
+			Password: config.Db.Password,
+			Database: config.Db.Database,
+			Timeout:  config.Db.Timeout,
+			Monitor:  mongo.Monitors(metrics.MongoMonitor(), tracing.MongoMonitor()),
+		})
+	case cfg.DbBackendSqlite:
+		return sqlite.New(log, sqlite.Config{Path: config.Db.Path, Timeout: config.Db.Timeout})
+	case cfg.DbBackendMemory:
+		log.Warn("In-memory storage is used, all data will be lost on shutdown")
+		return memory.New(), nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", config.Db.Backend)
+	}
+}
@@ -1,23 +1,136 @@
 package config
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 type Config struct {
 	DebugLevel   string
 	RootPassword string
 	Server       ServerConfig
 	Db           DbConfig
+	Keys         KeysConfig
+	Tokens       TokensConfig
+	Groups       GroupsConfig
+	Mfa          MfaConfig
+	Webauthn     WebauthnConfig
+	Lockout      LockoutConfig
+	RateLimits   []RateLimitConfig
+	InitClients  []ClientConfig
+	Tracing      TracingConfig
+}
+
+// Validate checks constraints between settings
+func (c *Config) Validate() error {
+	//tokens signed just before key retirement must stay verifiable until they expire
+	if c.Keys.GracePeriod < c.Tokens.AccessTTL {
+		return fmt.Errorf("keys grace period %s is shorter than access token lifetime %s", c.Keys.GracePeriod, c.Tokens.AccessTTL)
+	}
+	return nil
 }
 
 type ServerConfig struct {
-	Address      string
-	ReadTimeout  time.Duration
-	WriteTimeout time.Duration
+	Address        string
+	ReadTimeout    time.Duration
+	WriteTimeout   time.Duration
+	TrustedProxies []string //addresses and CIDR prefixes whose X-Forwarded-For and X-Real-IP headers are honoured
 }
 
+// Storage backends
+const (
+	DbBackendMongo  = "mongo"
+	DbBackendSqlite = "sqlite" //single file database, no external services needed
+	DbBackendMemory = "memory" //data is lost on restart, for development only
+)
+
 type DbConfig struct {
-	Server   string
+	Backend  string
+	Server   string //Mongo server address
 	User     string
 	Password string
 	Database string
+	Path     string        //SQLite database file
+	Timeout  time.Duration //deadline of a single storage operation, 0 disables it
+}
+
+type KeysConfig struct {
+	RotationInterval time.Duration //how long a key is used for signing
+	PublishAhead     time.Duration //how long a next key is published before activation
+	GracePeriod      time.Duration //how long a retired key is used for verification, must be >= access token lifetime
+}
+
+type TokensConfig struct {
+	Issuer         string //issuer URL, base of all published endpoint URLs
+	AccessTTL      time.Duration
+	RefreshTTL     time.Duration
+	CodeTTL        time.Duration //authorization code lifetime
+	RevocationSync time.Duration //how often revoked token ids are loaded from storage into local cache
+	ReauthMaxAge   time.Duration //how long after login the user may register second factors and passkeys
+}
+
+// Overflow behaviours of the groups claim
+const (
+	GroupsOverflowTruncate = "truncate" //first ClaimLimit groups by name are emitted
+	GroupsOverflowOmit     = "omit"     //groups claim is omitted
+)
+
+type GroupsConfig struct {
+	ClaimLimit int    //max number of groups in the groups claim, 0 means no limit
+	Overflow   string //what to do when user is in more groups than ClaimLimit, groups_overflow claim is set in both cases
+}
+
+type MfaConfig struct {
+	Issuer       string        //issuer name shown by authenticator apps
+	ChallengeTTL time.Duration //how long a user has to enter second factor after password check
+	MaxAttempts  int           //wrong codes entered for one challenge before it is revoked
+}
+
+type WebauthnConfig struct {
+	RpId         string        //relying party id, the domain credentials are scoped to
+	RpName       string        //relying party name shown by authenticators
+	Origins      []string      //allowed origins of pages calling WebAuthn API
+	Attestation  string        //attestation conveyance preference: none or direct
+	ChallengeTTL time.Duration //how long a registration or login ceremony may take
+}
+
+// LockoutConfig limits password guessing. After threshold failures within Window the account or address
+// is locked for BaseDelay, each next failure doubles the delay up to MaxDelay.
+type LockoutConfig struct {
+	AccountThreshold int //failed logins of one account before lockout, 0 disables account lockout
+	IpThreshold      int //failed logins from one address before lockout, 0 disables address lockout
+	BaseDelay        time.Duration
+	MaxDelay         time.Duration
+	Window           time.Duration //how long failures are remembered after the last one
+}
+
+// Rate limit keys
+const (
+	RateLimitKeyIp     = "ip"     //client address
+	RateLimitKeyClient = "client" //client id or subject of bearer token, address for anonymous requests
+)
+
+// RateLimitConfig is token bucket limit of routes matching Pattern
+type RateLimitConfig struct {
+	Pattern string  //ServeMux pattern, like "POST /token" or "/" for all other routes
+	Rate    float64 //requests per second
+	Burst   int
+	Key     string
+}
+
+// TracingConfig configures OpenTelemetry tracing, spans are exported to OTLP/HTTP collector.
+// Standard OTEL_EXPORTER_OTLP_* variables, like headers or timeout, are honoured by the exporter.
+type TracingConfig struct {
+	Endpoint    string  //collector URL like http://collector:4318, spans are not exported if empty
+	ServiceName string  //service.name resource attribute
+	SampleRatio float64 //share of sampled traces started here, decisions of propagated traces are kept
+}
+
+// ClientConfig is a client registered on startup if missing
+type ClientConfig struct {
+	ClientId     string   `json:"client_id"`
+	Secret       string   `json:"client_secret"`
+	Name         string   `json:"name"`
+	RedirectUris []string `json:"redirect_uris"`
+	Scopes       []string `json:"scopes"`
 }
@@ -0,0 +1,17 @@
+package config
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	c := &Config{
+		Keys:   KeysConfig{GracePeriod: 2 * time.Hour},
+		Tokens: TokensConfig{AccessTTL: 2 * time.Hour},
+	}
+	require.NoError(t, c.Validate())
+	c.Tokens.AccessTTL = 3 * time.Hour
+	require.Error(t, c.Validate())
+}
@@ -1,9 +1,12 @@
 package env
 
 import (
+	"encoding/json"
+	"errors"
 	"github.com/joho/godotenv"
 	"log"
 	"math/rand"
+	"net/url"
 	"os"
 	"regexp"
 	"sso/internal/config"
@@ -34,25 +37,105 @@ func genPassword(n int) string {
 	return string(b)
 }
 
+// defaultRateLimits protect password checks, they are expensive and are the target of guessing
+var defaultRateLimits = []config.RateLimitConfig{
+	{Pattern: "POST /{$}", Rate: 1, Burst: 10, Key: config.RateLimitKeyIp},
+	{Pattern: "POST /token", Rate: 5, Burst: 20, Key: config.RateLimitKeyIp},
+	{Pattern: "POST /authorize", Rate: 1, Burst: 10, Key: config.RateLimitKeyIp},
+	{Pattern: "POST /mfa/verify", Rate: 1, Burst: 5, Key: config.RateLimitKeyIp},
+	{Pattern: "POST /webauthn/login/", Rate: 1, Burst: 10, Key: config.RateLimitKeyIp},
+	{Pattern: "/", Rate: 20, Burst: 100, Key: config.RateLimitKeyClient},
+}
+
 func New() *config.Config {
 	if err := godotenv.Load(); err != nil {
 		log.Print("Load config: No .env file found")
 	}
-	return &config.Config{
+	issuer := strings.TrimSuffix(getEnv("ISSUER", "http://localhost:8085"), "/")
+	cfg := &config.Config{
 		DebugLevel:   getEnv("DEBUG_LEVEL", "local"),
 		RootPassword: getEnv("INIT_ROOT_PASSWORD", genPassword(16)),
 		Server: config.ServerConfig{
-			Address:      getEnv("SERVER_ADDRESS", "0.0.0.0:8085"),
-			ReadTimeout:  getEnvDuration("SERVER_TIMEOUT_READ", 5*time.Second),
-			WriteTimeout: getEnvDuration("SERVER_TIMEOUT_WRITE", 5*time.Second),
+			Address:        getEnv("SERVER_ADDRESS", "0.0.0.0:8085"),
+			ReadTimeout:    getEnvDuration("SERVER_TIMEOUT_READ", 5*time.Second),
+			WriteTimeout:   getEnvDuration("SERVER_TIMEOUT_WRITE", 5*time.Second),
+			TrustedProxies: getEnvSlice("TRUSTED_PROXIES", nil, ","),
 		},
 		Db: config.DbConfig{
+			Backend:  getEnv("DB_BACKEND", config.DbBackendMongo),
 			Server:   getEnv("DB_SERVER", "localhost:27017"),
 			User:     getEnv("DB_USER", "root"),
 			Password: getEnv("DB_PASSWORD", ""),
 			Database: getEnv("DB_DATABASE", "SSO"),
+			Path:     getEnv("DB_PATH", "sso.db"),
+			Timeout:  getEnvDuration("DB_TIMEOUT", 2*time.Second),
+		},
+		Keys: config.KeysConfig{
+			RotationInterval: getEnvDuration("KEYS_ROTATION_INTERVAL", 24*time.Hour),
+			PublishAhead:     getEnvDuration("KEYS_PUBLISH_AHEAD", 1*time.Hour),
+			GracePeriod:      getEnvDuration("KEYS_GRACE_PERIOD", 2*time.Hour),
+		},
+		InitClients: append(getEnvClients("INIT_CLIENTS"), getEnvClientsFile("INIT_CLIENTS_FILE")...),
+		Tokens: config.TokensConfig{
+			Issuer:         issuer,
+			AccessTTL:      getEnvDuration("TOKENS_ACCESS_TTL", 2*time.Hour),
+			RefreshTTL:     getEnvDuration("TOKENS_REFRESH_TTL", 30*24*time.Hour),
+			CodeTTL:        getEnvDuration("TOKENS_CODE_TTL", 1*time.Minute),
+			RevocationSync: getEnvDuration("TOKENS_REVOCATION_SYNC", 10*time.Second),
+			ReauthMaxAge:   getEnvDuration("TOKENS_REAUTH_MAX_AGE", 15*time.Minute),
+		},
+		Groups: config.GroupsConfig{
+			ClaimLimit: getEnvInt("GROUPS_CLAIM_LIMIT", 100),
+			Overflow:   getEnv("GROUPS_OVERFLOW", config.GroupsOverflowTruncate),
+		},
+		Mfa: config.MfaConfig{
+			Issuer:       getEnv("MFA_ISSUER", "DM SSO"),
+			ChallengeTTL: getEnvDuration("MFA_CHALLENGE_TTL", 5*time.Minute),
+			MaxAttempts:  getEnvInt("MFA_MAX_ATTEMPTS", 5),
+		},
+		Webauthn: config.WebauthnConfig{
+			RpId:         getEnv("WEBAUTHN_RP_ID", issuerHost(issuer)),
+			RpName:       getEnv("WEBAUTHN_RP_NAME", getEnv("MFA_ISSUER", "DM SSO")),
+			Origins:      getEnvSlice("WEBAUTHN_ORIGINS", []string{issuerOrigin(issuer)}, ","),
+			Attestation:  getEnv("WEBAUTHN_ATTESTATION", "none"),
+			ChallengeTTL: getEnvDuration("WEBAUTHN_CHALLENGE_TTL", 5*time.Minute),
+		},
+		Lockout: config.LockoutConfig{
+			AccountThreshold: getEnvInt("LOCKOUT_ACCOUNT_THRESHOLD", 5),
+			IpThreshold:      getEnvInt("LOCKOUT_IP_THRESHOLD", 20),
+			BaseDelay:        getEnvDuration("LOCKOUT_BASE_DELAY", 30*time.Second),
+			MaxDelay:         getEnvDuration("LOCKOUT_MAX_DELAY", 1*time.Hour),
+			Window:           getEnvDuration("LOCKOUT_WINDOW", 15*time.Minute),
+		},
+		RateLimits: getEnvRateLimits("RATE_LIMITS", defaultRateLimits),
+		Tracing: config.TracingConfig{
+			Endpoint:    getEnv("TRACING_ENDPOINT", ""),
+			ServiceName: getEnv("TRACING_SERVICE_NAME", "sso"),
+			SampleRatio: getEnvFloat("TRACING_SAMPLE_RATIO", 1),
 		},
 	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatal("Load config: ", err)
+	}
+	return cfg
+}
+
+// issuerHost is host name of issuer URL, default WebAuthn relying party id
+func issuerHost(issuer string) string {
+	u, err := url.Parse(issuer)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// issuerOrigin is scheme and host of issuer URL
+func issuerOrigin(issuer string) string {
+	u, err := url.Parse(issuer)
+	if err != nil {
+		return issuer
+	}
+	return u.Scheme + "://" + u.Host
 }
 
 func getEnv(key string, defaultVal string) string {
@@ -70,6 +153,14 @@ func getEnvInt(name string, defaultVal int) int {
 	return defaultVal
 }
 
+func getEnvFloat(name string, defaultVal float64) float64 {
+	valueStr := getEnv(name, "")
+	if value, err := strconv.ParseFloat(valueStr, 64); err == nil {
+		return value
+	}
+	return defaultVal
+}
+
 func getEnvBool(name string, defaultVal bool) bool {
 	valStr := getEnv(name, "")
 	if val, err := strconv.ParseBool(valStr); err == nil {
@@ -87,13 +178,86 @@ func getEnvSlice(name string, defaultVal []string, sep string) []string {
 	return val
 }
 
+// getEnvClients parses comma separated list of client_id:secret pairs
+func getEnvClients(name string) []config.ClientConfig {
+	var clients []config.ClientConfig
+	for _, pair := range getEnvSlice(name, nil, ",") {
+		id, secret, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok || id == "" || secret == "" {
+			log.Print("Load config: bad client in ", name, ": ", id)
+			continue
+		}
+		clients = append(clients, config.ClientConfig{ClientId: id, Secret: secret})
+	}
+	return clients
+}
+
+// getEnvClientsFile reads JSON array of clients from the file
+func getEnvClientsFile(name string) []config.ClientConfig {
+	path := getEnv(name, "")
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Print("Load config: can't read ", name, ": ", err)
+		return nil
+	}
+	var clients []config.ClientConfig
+	if err := json.Unmarshal(data, &clients); err != nil {
+		log.Print("Load config: can't parse ", name, ": ", err)
+		return nil
+	}
+	return clients
+}
+
+// getEnvRateLimits parses semicolon separated list of pattern=rate:burst[:key], "none" disables rate limiting
+func getEnvRateLimits(name string, defaultVal []config.RateLimitConfig) []config.RateLimitConfig {
+	valStr := strings.TrimSpace(getEnv(name, ""))
+	if valStr == "" {
+		return defaultVal
+	}
+	if valStr == "none" {
+		return nil
+	}
+	var limits []config.RateLimitConfig
+	for _, item := range strings.Split(valStr, ";") {
+		item = strings.TrimSpace(item)
+		i := strings.LastIndex(item, "=")
+		if i <= 0 {
+			log.Print("Load config: bad rate limit in ", name, ": ", item)
+			continue
+		}
+		params := strings.Split(item[i+1:], ":")
+		limit := config.RateLimitConfig{Pattern: strings.TrimSpace(item[:i]), Key: config.RateLimitKeyIp}
+		rate, errRate := strconv.ParseFloat(params[0], 64)
+		burst, errBurst := 0, errors.New("no burst")
+		if len(params) > 1 {
+			burst, errBurst = strconv.Atoi(params[1])
+		}
+		if len(params) > 2 {
+			limit.Key = params[2]
+		}
+		if errRate != nil || errBurst != nil || rate <= 0 || burst <= 0 ||
+			(limit.Key != config.RateLimitKeyIp && limit.Key != config.RateLimitKeyClient) {
+			log.Print("Load config: bad rate limit in ", name, ": ", item)
+			continue
+		}
+		limit.Rate, limit.Burst = rate, burst
+		limits = append(limits, limit)
+	}
+	return limits
+}
+
 func getEnvDuration(name string, defaultVal time.Duration) time.Duration {
 	valStr := getEnv(name, "")
 	if valStr != "" {
-		rResult := regexp.MustCompile(`^([0-9]*)(m|s|ms|us|ns)?$`).FindStringSubmatch(valStr)
+		rResult := regexp.MustCompile(`^([0-9]*)(h|m|s|ms|us|ns)?$`).FindStringSubmatch(valStr)
 		if len(rResult) != 0 {
 			t, _ := strconv.Atoi(rResult[1]) //regex гарантирует что будет число при совпадении и будет минимум 3 подстроки
 			switch rResult[2] {
+			case "h":
+				t *= 1000000000 * 60 * 60
 			case "m":
 				t *= 1000000000 * 60
 			case "s":
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"sso/internal/http/requests"
+	"sso/internal/http/responses"
+	"sso/internal/models"
+	"sso/internal/services"
+	"sso/pkg/helpers/jsonHelper"
+)
+
+const (
+	MsgGroupCreated  = "Group created"
+	MsgGroupUpdated  = "Group updated"
+	MsgGroupDeleted  = "Group deleted"
+	MsgMemberAdded   = "Group member added"
+	MsgMemberRemoved = "Group member removed"
+)
+
+// groupCodes are problem codes of groups admin API
+var groupCodes = resourceCodes{
+	notFound: responses.CodeGroupNotFound,
+	exists:   responses.CodeGroupExists,
+	invalid:  responses.CodeInvalidGroup,
+}
+
+func AdminListGroups(logger *slog.Logger, groups *services.GroupsService) http.HandlerFunc {
+	return api(logger, "http.handlers.AdminListGroups()", func(log *slog.Logger, w http.ResponseWriter, r *http.Request) error {
+		list, err := groups.List(r.Context())
+		if err != nil {
+			return serviceError(err, groupCodes)
+		}
+		writeGroups(log, w, list)
+		return nil
+	})
+}
+
+// AdminUserGroups returns all groups the user is direct or transitive member of
+func AdminUserGroups(logger *slog.Logger, users *services.UsersService, groups *services.GroupsService) http.HandlerFunc {
+	return api(logger, "http.handlers.AdminUserGroups()", func(log *slog.Logger, w http.ResponseWriter, r *http.Request) error {
+		user, err := users.Get(r.Context(), r.PathValue("id"))
+		if err != nil {
+			return serviceError(err, userCodes)
+		}
+		list, err := groups.Resolve(r.Context(), user)
+		if err != nil {
+			return serviceError(err, groupCodes)
+		}
+		writeGroups(log, w, list)
+		return nil
+	})
+}
+
+func AdminCreateGroup(logger *slog.Logger, groups *services.GroupsService) http.HandlerFunc {
+	return api(logger, "http.handlers.AdminCreateGroup()", func(log *slog.Logger, w http.ResponseWriter, r *http.Request) error {
+		params, err := jsonHelper.Decode(&requests.CreateGroup{}, r.Body)
+		if err != nil {
+			return badRequest(err)
+		}
+		group := &models.Group{
+			Name:        params.Name,
+			Description: params.Description,
+			Parents:     params.Parents,
+		}
+		if group.Id, err = groups.Add(r.Context(), group); err != nil {
+			return serviceError(err, groupCodes)
+		}
+		log.Info(MsgGroupCreated, slog.String("group_id", group.Id), slog.Any("by", callerClaims(r)["sub"]))
+		writeGroup(log, w, http.StatusCreated, group)
+		return nil
+	})
+}
+
+func AdminGetGroup(logger *slog.Logger, groups *services.GroupsService) http.HandlerFunc {
+	return api(logger, "http.handlers.AdminGetGroup()", func(log *slog.Logger, w http.ResponseWriter, r *http.Request) error {
+		group, err := groups.Get(r.Context(), r.PathValue("id"))
+		if err != nil {
+			return serviceError(err, groupCodes)
+		}
+		writeGroup(log, w, http.StatusOK, group)
+		return nil
+	})
+}
+
+func AdminUpdateGroup(logger *slog.Logger, groups *services.GroupsService) http.HandlerFunc {
+	return api(logger, "http.handlers.AdminUpdateGroup()", func(log *slog.Logger, w http.ResponseWriter, r *http.Request) error {
+		params, err := jsonHelper.Decode(&requests.UpdateGroup{}, r.Body)
+		if err != nil {
+			return badRequest(err)
+		}
+		group, err := groups.Update(r.Context(), r.PathValue("id"), params.Name, params.Description, params.Parents)
+		if err != nil {
+			return serviceError(err, groupCodes)
+		}
+		log.Info(MsgGroupUpdated, slog.String("group_id", group.Id), slog.Any("by", callerClaims(r)["sub"]))
+		writeGroup(log, w, http.StatusOK, group)
+		return nil
+	})
+}
+
+func AdminDeleteGroup(logger *slog.Logger, groups *services.GroupsService) http.HandlerFunc {
+	return api(logger, "http.handlers.AdminDeleteGroup()", func(log *slog.Logger, w http.ResponseWriter, r *http.Request) error {
+		id := r.PathValue("id")
+		if err := groups.Delete(r.Context(), id); err != nil {
+			return serviceError(err, groupCodes)
+		}
+		log.Info(MsgGroupDeleted, slog.String("group_id", id), slog.Any("by", callerClaims(r)["sub"]))
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+}
+
+// AdminGroupMember adds or, with add false, removes direct member of the group
+func AdminGroupMember(logger *slog.Logger, groups *services.GroupsService, add bool) http.HandlerFunc {
+	return api(logger, "http.handlers.AdminGroupMember()", func(log *slog.Logger, w http.ResponseWriter, r *http.Request) error {
+		groupId, userId := r.PathValue("id"), r.PathValue("userId")
+		var user *models.User
+		var err error
+		msg := MsgMemberAdded
+		if add {
+			user, err = groups.AddMember(r.Context(), groupId, userId)
+		} else {
+			user, err = groups.RemoveMember(r.Context(), groupId, userId)
+			msg = MsgMemberRemoved
+		}
+		if err != nil {
+			return serviceError(err, resourceCodes{
+				notFound: responses.CodeMemberNotFound,
+				exists:   responses.CodeGroupExists,
+				invalid:  responses.CodeInvalidGroup,
+			})
+		}
+		log.Info(msg, slog.String("group_id", groupId), slog.String("user_id", userId), slog.Any("by", callerClaims(r)["sub"]))
+		writeUser(log, w, http.StatusOK, user)
+		return nil
+	})
+}
+
+func groupResponse(group *models.Group) *responses.Group {
+	parents := group.Parents
+	if parents == nil {
+		parents = []string{}
+	}
+	return &responses.Group{
+		Id:          group.Id,
+		Name:        group.Name,
+		Description: group.Description,
+		Parents:     parents,
+	}
+}
+
+func writeGroup(log *slog.Logger, w http.ResponseWriter, code int, group *models.Group) {
+	writeJson(log, w, code, &responses.AdminGroup{
+		Response: responses.Response{Status: responses.StatusOk},
+		Group:    groupResponse(group),
+	})
+}
+
+func writeGroups(log *slog.Logger, w http.ResponseWriter, list []*models.Group) {
+	resp := &responses.AdminGroups{
+		Response: responses.Response{Status: responses.StatusOk},
+		Groups:   make([]responses.Group, 0, len(list)),
+	}
+	for _, group := range list {
+		resp.Groups = append(resp.Groups, *groupResponse(group))
+	}
+	writeJson(log, w, http.StatusOK, resp)
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"sso/internal/http/requests"
+	"sso/internal/http/responses"
+	"sso/internal/models"
+	"sso/internal/services"
+	"sso/pkg/helpers/jsonHelper"
+)
+
+const (
+	MsgRoleCreated       = "Role created"
+	MsgRoleUpdated       = "Role updated"
+	MsgRoleDeleted       = "Role deleted"
+	MsgRoleAssigned      = "Role assigned"
+	MsgRoleUnassigned    = "Role unassigned"
+	MsgPermissionCreated = "Permission created"
+	MsgPermissionDeleted = "Permission deleted"
+)
+
+// roleCodes are problem codes of roles admin API
+var roleCodes = resourceCodes{
+	notFound: responses.CodeRoleNotFound,
+	exists:   responses.CodeRoleExists,
+	invalid:  responses.CodeInvalidRole,
+}
+
+// permissionCodes are problem codes of permissions admin API
+var permissionCodes = resourceCodes{
+	notFound: responses.CodePermissionNotFound,
+	exists:   responses.CodePermissionExists,
+	invalid:  responses.CodeInvalidPermission,
+}
+
+func AdminListRoles(logger *slog.Logger, roles *services.RolesService) http.HandlerFunc {
+	return api(logger, "http.handlers.AdminListRoles()", func(log *slog.Logger, w http.ResponseWriter, r *http.Request) error {
+		list, err := roles.List(r.Context())
+		if err != nil {
+			return serviceError(err, roleCodes)
+		}
+		resp := &responses.AdminRoles{
+			Response: responses.Response{Status: responses.StatusOk},
+			Roles:    make([]responses.Role, 0, len(list)),
+		}
+		for _, role := range list {
+			resp.Roles = append(resp.Roles, *roleResponse(role))
+		}
+		writeJson(log, w, http.StatusOK, resp)
+		return nil
+	})
+}
+
+func AdminCreateRole(logger *slog.Logger, roles *services.RolesService) http.HandlerFunc {
+	return api(logger, "http.handlers.AdminCreateRole()", func(log *slog.Logger, w http.ResponseWriter, r *http.Request) error {
+		params, err := jsonHelper.Decode(&requests.CreateRole{}, r.Body)
+		if err != nil {
+			return badRequest(err)
+		}
+		role := &models.Role{
+			Name:        params.Name,
+			Description: params.Description,
+			Permissions: params.Permissions,
+		}
+		if role.Id, err = roles.Add(r.Context(), role); err != nil {
+			return serviceError(err, roleCodes)
+		}
+		log.Info(MsgRoleCreated, slog.String("role", role.Name), slog.Any("by", callerClaims(r)["sub"]))
+		writeRole(log, w, http.StatusCreated, role)
+		return nil
+	})
+}
+
+func AdminGetRole(logger *slog.Logger, roles *services.RolesService) http.HandlerFunc {
+	return api(logger, "http.handlers.AdminGetRole()", func(log *slog.Logger, w http.ResponseWriter, r *http.Request) error {
+		role, err := roles.Get(r.Context(), r.PathValue("name"))
+		if err != nil {
+			return serviceError(err, roleCodes)
+		}
+		writeRole(log, w, http.StatusOK, role)
+		return nil
+	})
+}
+
+func AdminUpdateRole(logger *slog.Logger, roles *services.RolesService) http.HandlerFunc {
+	return api(logger, "http.handlers.AdminUpdateRole()", func(log *slog.Logger, w http.ResponseWriter, r *http.Request) error {
+		params, err := jsonHelper.Decode(&requests.UpdateRole{}, r.Body)
+		if err != nil {
+			return badRequest(err)
+		}
+		role, err := roles.Update(r.Context(), r.PathValue("name"), params.Description, params.Permissions)
+		if err != nil {
+			return serviceError(err, roleCodes)
+		}
+		log.Info(MsgRoleUpdated, slog.String("role", role.Name), slog.Any("by", callerClaims(r)["sub"]))
+		writeRole(log, w, http.StatusOK, role)
+		return nil
+	})
+}
+
+func AdminDeleteRole(logger *slog.Logger, roles *services.RolesService) http.HandlerFunc {
+	return api(logger, "http.handlers.AdminDeleteRole()", func(log *slog.Logger, w http.ResponseWriter, r *http.Request) error {
+		name := r.PathValue("name")
+		if err := roles.Delete(r.Context(), name); err != nil {
+			return serviceError(err, roleCodes)
+		}
+		log.Info(MsgRoleDeleted, slog.String("role", name), slog.Any("by", callerClaims(r)["sub"]))
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+}
+
+// AdminAssignRole adds or, with assign false, removes role of the user
+func AdminAssignRole(logger *slog.Logger, roles *services.RolesService, assign bool) http.HandlerFunc {
+	return api(logger, "http.handlers.AdminAssignRole()", func(log *slog.Logger, w http.ResponseWriter, r *http.Request) error {
+		id, role := r.PathValue("id"), r.PathValue("role")
+		var user *models.User
+		var err error
+		msg := MsgRoleAssigned
+		if assign {
+			user, err = roles.Assign(r.Context(), id, role)
+		} else {
+			user, err = roles.Unassign(r.Context(), id, role)
+			msg = MsgRoleUnassigned
+		}
+		if err != nil {
+			return serviceError(err, resourceCodes{
+				notFound: responses.CodeUserNotFound,
+				exists:   responses.CodeRoleExists,
+				invalid:  responses.CodeInvalidRole,
+			})
+		}
+		log.Info(msg, slog.String("user_id", id), slog.String("role", role), slog.Any("by", callerClaims(r)["sub"]))
+		writeUser(log, w, http.StatusOK, user)
+		return nil
+	})
+}
+
+func AdminListPermissions(logger *slog.Logger, roles *services.RolesService) http.HandlerFunc {
+	return api(logger, "http.handlers.AdminListPermissions()", func(log *slog.Logger, w http.ResponseWriter, r *http.Request) error {
+		list, err := roles.ListPermissions(r.Context())
+		if err != nil {
+			return serviceError(err, permissionCodes)
+		}
+		resp := &responses.AdminPermissions{
+			Response:    responses.Response{Status: responses.StatusOk},
+			Permissions: make([]responses.Permission, 0, len(list)),
+		}
+		for _, p := range list {
+			resp.Permissions = append(resp.Permissions, responses.Permission{Name: p.Name, Description: p.Description})
+		}
+		writeJson(log, w, http.StatusOK, resp)
+		return nil
+	})
+}
+
+func AdminCreatePermission(logger *slog.Logger, roles *services.RolesService) http.HandlerFunc {
+	return api(logger, "http.handlers.AdminCreatePermission()", func(log *slog.Logger, w http.ResponseWriter, r *http.Request) error {
+		params, err := jsonHelper.Decode(&requests.CreatePermission{}, r.Body)
+		if err != nil {
+			return badRequest(err)
+		}
+		permission := &models.Permission{Name: params.Name, Description: params.Description}
+		if permission.Id, err = roles.AddPermission(r.Context(), permission); err != nil {
+			return serviceError(err, permissionCodes)
+		}
+		log.Info(MsgPermissionCreated, slog.String("permission", permission.Name), slog.Any("by", callerClaims(r)["sub"]))
+		writeJson(log, w, http.StatusCreated, &responses.AdminPermission{
+			Response:   responses.Response{Status: responses.StatusOk},
+			Permission: &responses.Permission{Name: permission.Name, Description: permission.Description},
+		})
+		return nil
+	})
+}
+
+func AdminDeletePermission(logger *slog.Logger, roles *services.RolesService) http.HandlerFunc {
+	return api(logger, "http.handlers.AdminDeletePermission()", func(log *slog.Logger, w http.ResponseWriter, r *http.Request) error {
+		name := r.PathValue("name")
+		if err := roles.DeletePermission(r.Context(), name); err != nil {
+			return serviceError(err, permissionCodes)
+		}
+		log.Info(MsgPermissionDeleted, slog.String("permission", name), slog.Any("by", callerClaims(r)["sub"]))
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+}
+
+func roleResponse(role *models.Role) *responses.Role {
+	permissions := role.Permissions
+	if permissions == nil {
+		permissions = []string{}
+	}
+	return &responses.Role{
+		Name:        role.Name,
+		Description: role.Description,
+		Permissions: permissions,
+	}
+}
+
+func writeRole(log *slog.Logger, w http.ResponseWriter, code int, role *models.Role) {
+	writeJson(log, w, code, &responses.AdminRole{
+		Response: responses.Response{Status: responses.StatusOk},
+		Role:     roleResponse(role),
+	})
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"sso/internal/http/requests"
+	"sso/internal/http/responses"
+	"sso/internal/models"
+	"sso/internal/services"
+	"sso/pkg/helpers/jsonHelper"
+	"strconv"
+)
+
+const (
+	MsgUserCreated  = "User created"
+	MsgUserUpdated  = "User updated"
+	MsgUserDeleted  = "User deleted"
+	MsgUserUnlocked = "User unlocked"
+)
+
+// userCodes are problem codes of users admin API
+var userCodes = resourceCodes{
+	notFound: responses.CodeUserNotFound,
+	exists:   responses.CodeUserExists,
+	invalid:  responses.CodeInvalidUser,
+}
+
+// AdminListUsers returns a page of users, query parameters: search, offset, limit
+func AdminListUsers(logger *slog.Logger, users *services.UsersService) http.HandlerFunc {
+	return api(logger, "http.handlers.AdminListUsers()", func(log *slog.Logger, w http.ResponseWriter, r *http.Request) error {
+		query := r.URL.Query()
+		offset, err1 := queryInt(query.Get("offset"))
+		limit, err2 := queryInt(query.Get("limit"))
+		if err := errors.Join(err1, err2); err != nil {
+			return badRequest(err)
+		}
+		filter := models.UserFilter{Search: query.Get("search"), Offset: offset, Limit: limit}
+		list, total, err := users.List(r.Context(), &filter)
+		if err != nil {
+			return serviceError(err, userCodes)
+		}
+		resp := &responses.AdminUsers{
+			Response: responses.Response{Status: responses.StatusOk},
+			Users:    make([]responses.User, 0, len(list)),
+			Total:    total,
+			Offset:   filter.Offset,
+			Limit:    filter.Limit,
+		}
+		for _, u := range list {
+			resp.Users = append(resp.Users, *userResponse(u))
+		}
+		writeJson(log, w, http.StatusOK, resp)
+		return nil
+	})
+}
+
+func AdminCreateUser(logger *slog.Logger, users *services.UsersService) http.HandlerFunc {
+	return api(logger, "http.handlers.AdminCreateUser()", func(log *slog.Logger, w http.ResponseWriter, r *http.Request) error {
+		params, err := jsonHelper.Decode(&requests.CreateUser{}, r.Body)
+		if err != nil {
+			return badRequest(err)
+		}
+		user := &models.User{
+			Login:         params.Login,
+			Password:      params.Password,
+			Name:          params.Name,
+			Email:         params.Email,
+			EmailVerified: params.EmailVerified,
+			Roles:         params.Roles,
+		}
+		if user.Id, err = users.Add(r.Context(), user); err != nil {
+			return serviceError(err, userCodes)
+		}
+		log.Info(MsgUserCreated, slog.String("user_id", user.Id), slog.Any("by", callerClaims(r)["sub"]))
+		writeUser(log, w, http.StatusCreated, user)
+		return nil
+	})
+}
+
+func AdminGetUser(logger *slog.Logger, users *services.UsersService) http.HandlerFunc {
+	return api(logger, "http.handlers.AdminGetUser()", func(log *slog.Logger, w http.ResponseWriter, r *http.Request) error {
+		user, err := users.Get(r.Context(), r.PathValue("id"))
+		if err != nil {
+			return serviceError(err, userCodes)
+		}
+		writeUser(log, w, http.StatusOK, user)
+		return nil
+	})
+}
+
+func AdminUpdateUser(logger *slog.Logger, users *services.UsersService) http.HandlerFunc {
+	return api(logger, "http.handlers.AdminUpdateUser()", func(log *slog.Logger, w http.ResponseWriter, r *http.Request) error {
+		params, err := jsonHelper.Decode(&requests.UpdateUser{}, r.Body)
+		if err != nil {
+			return badRequest(err)
+		}
+		user, err := users.Update(r.Context(), r.PathValue("id"), services.UserPatch{
+			Login:         params.Login,
+			Password:      params.Password,
+			Name:          params.Name,
+			Email:         params.Email,
+			EmailVerified: params.EmailVerified,
+			Roles:         params.Roles,
+		})
+		if err != nil {
+			return serviceError(err, userCodes)
+		}
+		log.Info(MsgUserUpdated, slog.String("user_id", user.Id), slog.Any("by", callerClaims(r)["sub"]))
+		writeUser(log, w, http.StatusOK, user)
+		return nil
+	})
+}
+
+// AdminSetUserDisabled disables or enables user, callers can not disable themselves
+func AdminSetUserDisabled(logger *slog.Logger, users *services.UsersService, disabled bool) http.HandlerFunc {
+	return api(logger, "http.handlers.AdminSetUserDisabled()", func(log *slog.Logger, w http.ResponseWriter, r *http.Request) error {
+		id := r.PathValue("id")
+		if disabled && isCaller(r, id) {
+			return responses.NewError(responses.CodeSelfModify, nil)
+		}
+		user, err := users.SetDisabled(r.Context(), id, disabled)
+		if err != nil {
+			return serviceError(err, userCodes)
+		}
+		log.Info(MsgUserUpdated, slog.String("user_id", user.Id), slog.Bool("disabled", disabled), slog.Any("by", callerClaims(r)["sub"]))
+		writeUser(log, w, http.StatusOK, user)
+		return nil
+	})
+}
+
+// AdminDeleteUser deletes user, callers can not delete themselves
+func AdminDeleteUser(logger *slog.Logger, users *services.UsersService) http.HandlerFunc {
+	return api(logger, "http.handlers.AdminDeleteUser()", func(log *slog.Logger, w http.ResponseWriter, r *http.Request) error {
+		id := r.PathValue("id")
+		if isCaller(r, id) {
+			return responses.NewError(responses.CodeSelfModify, nil)
+		}
+		if err := users.Delete(r.Context(), id); err != nil {
+			return serviceError(err, userCodes)
+		}
+		log.Info(MsgUserDeleted, slog.String("user_id", id), slog.Any("by", callerClaims(r)["sub"]))
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+}
+
+// AdminUnlockUser removes lockout of the user account after failed logins
+func AdminUnlockUser(logger *slog.Logger, lockout *services.LockoutService) http.HandlerFunc {
+	return api(logger, "http.handlers.AdminUnlockUser()", func(log *slog.Logger, w http.ResponseWriter, r *http.Request) error {
+		id := r.PathValue("id")
+		if err := lockout.Unlock(r.Context(), id); err != nil {
+			return serviceError(err, userCodes)
+		}
+		log.Info(MsgUserUnlocked, slog.String("user_id", id), slog.Any("by", callerClaims(r)["sub"]))
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+}
+
+func isCaller(r *http.Request, id string) bool {
+	return callerSubject(r) == id
+}
+
+func queryInt(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+func userResponse(u *models.User) *responses.User {
+	roles, groups := u.Roles, u.Groups
+	if roles == nil {
+		roles = []string{}
+	}
+	if groups == nil {
+		groups = []string{}
+	}
+	return &responses.User{
+		Id:            u.Id,
+		Login:         u.Login,
+		Name:          u.Name,
+		Email:         u.Email,
+		EmailVerified: u.EmailVerified,
+		Roles:         roles,
+		Groups:        groups,
+		Disabled:      u.Disabled,
+	}
+}
+
+func writeUser(log *slog.Logger, w http.ResponseWriter, code int, user *models.User) {
+	writeJson(log, w, code, &responses.AdminUser{
+		Response: responses.Response{Status: responses.StatusOk},
+		User:     userResponse(user),
+	})
+}
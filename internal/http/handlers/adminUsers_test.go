@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sso/internal/http/responses"
+	"sso/internal/models"
+	"sso/internal/services"
+	"sso/internal/storage/memory"
+	"strings"
+	"testing"
+	"time"
+)
+
+func adminUsersMux() *http.ServeMux {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	users := services.Users(memory.New())
+	mux := http.NewServeMux()
+	mux.Handle("POST /admin/users", AdminCreateUser(log, users))
+	mux.Handle("GET /admin/users/{id}", AdminGetUser(log, users))
+	mux.Handle("DELETE /admin/users/{id}", AdminDeleteUser(log, users))
+	return mux
+}
+
+func serve(t *testing.T, h http.Handler, method string, target string, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return w
+}
+
+func requireProblem(t *testing.T, w *httptest.ResponseRecorder, status int, code string) {
+	t.Helper()
+	require.Equal(t, status, w.Code)
+	require.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	problem := responses.Problem{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	require.Equal(t, status, problem.Status)
+	require.Equal(t, code, problem.Code)
+}
+
+func TestAdminUsers(t *testing.T) {
+	mux := adminUsersMux()
+
+	w := serve(t, mux, http.MethodPost, "/admin/users", `{"login":"alice","password":"Passw0rd!","roles":["admin"]}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	created := responses.AdminUser{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	require.NotEmpty(t, created.User.Id)
+	require.Equal(t, []string{"admin"}, created.User.Roles)
+
+	w = serve(t, mux, http.MethodGet, "/admin/users/"+created.User.Id, "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = serve(t, mux, http.MethodPost, "/admin/users", `{"login":"alice","password":"Passw0rd!"}`)
+	requireProblem(t, w, http.StatusConflict, responses.CodeUserExists)
+	w = serve(t, mux, http.MethodPost, "/admin/users", `{"login":"bob","password":"weak"}`)
+	requireProblem(t, w, http.StatusBadRequest, responses.CodeInvalidUser)
+	w = serve(t, mux, http.MethodPost, "/admin/users", `{"login":`)
+	requireProblem(t, w, http.StatusBadRequest, responses.CodeBadRequest)
+
+	w = serve(t, mux, http.MethodDelete, "/admin/users/"+created.User.Id, "")
+	require.Equal(t, http.StatusNoContent, w.Code)
+	w = serve(t, mux, http.MethodGet, "/admin/users/"+created.User.Id, "")
+	requireProblem(t, w, http.StatusNotFound, responses.CodeUserNotFound)
+}
+
+func TestAdminUsersCanceled(t *testing.T) {
+	mux := adminUsersMux()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/users/id", nil).WithContext(ctx))
+	requireProblem(t, w, http.StatusRequestTimeout, responses.CodeCanceled)
+
+	ctx, cancel = context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/users/id", nil).WithContext(ctx))
+	requireProblem(t, w, http.StatusServiceUnavailable, responses.CodeTimeout)
+}
+
+// serveAs is serve with bearer token
+func serveAs(t *testing.T, h http.Handler, token string, method string, target string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(method, target, nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestAdminUsersRole(t *testing.T) {
+	ctx := context.Background()
+	env := newOAuthEnv(t)
+	users := services.Users(env.storage)
+	adminId, err := users.Add(ctx, &models.User{Login: "root", Password: "Passw0rd!", Roles: []string{models.RoleAdmin}})
+	require.NoError(t, err)
+	admin := RequireRole(env.log, env.keys, env.revocations, models.RoleAdmin)
+	mux := http.NewServeMux()
+	mux.Handle("GET /admin/users/{id}", admin(AdminGetUser(env.log, users)))
+	mux.Handle("DELETE /admin/users/{id}", admin(AdminDeleteUser(env.log, users)))
+	mux.Handle("POST /admin/users/{id}/disable", admin(AdminSetUserDisabled(env.log, users, true)))
+	mux.Handle("POST /admin/users/{id}/enable", admin(AdminSetUserDisabled(env.log, users, false)))
+	adminPair, err := env.tokens.Issue(ctx, services.Grant{UserId: adminId})
+	require.NoError(t, err)
+	userPair, err := env.tokens.Issue(ctx, services.Grant{UserId: env.userId})
+	require.NoError(t, err)
+	//roles are copied into tokens of OAuth clients, such tokens are not accepted
+	clientPair, err := env.tokens.Issue(ctx, services.Grant{UserId: adminId, ClientId: "app", Scope: "openid"})
+	require.NoError(t, err)
+
+	w := serve(t, mux, http.MethodGet, "/admin/users/"+env.userId, "")
+	requireProblem(t, w, http.StatusUnauthorized, responses.CodeUnauthorized)
+	w = serveAs(t, mux, "invalid", http.MethodGet, "/admin/users/"+env.userId)
+	requireProblem(t, w, http.StatusUnauthorized, responses.CodeInvalidToken)
+	w = serveAs(t, mux, userPair.AccessToken, http.MethodGet, "/admin/users/"+env.userId)
+	requireProblem(t, w, http.StatusForbidden, responses.CodeForbidden)
+	w = serveAs(t, mux, clientPair.AccessToken, http.MethodGet, "/admin/users/"+env.userId)
+	requireProblem(t, w, http.StatusForbidden, responses.CodeForbidden)
+	w = serveAs(t, mux, adminPair.AccessToken, http.MethodGet, "/admin/users/"+env.userId)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	//admins can't disable or delete themselves, enabling is allowed
+	w = serveAs(t, mux, adminPair.AccessToken, http.MethodPost, "/admin/users/"+adminId+"/disable")
+	requireProblem(t, w, http.StatusForbidden, responses.CodeSelfModify)
+	w = serveAs(t, mux, adminPair.AccessToken, http.MethodDelete, "/admin/users/"+adminId)
+	requireProblem(t, w, http.StatusForbidden, responses.CodeSelfModify)
+	w = serveAs(t, mux, adminPair.AccessToken, http.MethodPost, "/admin/users/"+adminId+"/enable")
+	require.Equal(t, http.StatusOK, w.Code)
+	user, err := users.Get(ctx, adminId)
+	require.NoError(t, err)
+	require.False(t, user.Disabled)
+
+	w = serveAs(t, mux, adminPair.AccessToken, http.MethodPost, "/admin/users/"+env.userId+"/disable")
+	require.Equal(t, http.StatusOK, w.Code)
+	w = serveAs(t, mux, adminPair.AccessToken, http.MethodDelete, "/admin/users/"+env.userId)
+	require.Equal(t, http.StatusNoContent, w.Code)
+}
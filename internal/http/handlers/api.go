@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"sso/internal/http/responses"
+	"sso/internal/tracing"
+	"sso/pkg/helpers/errorHelper"
+	"sso/pkg/helpers/jsonHelper"
+	"sso/pkg/helpers/slogHelper"
+)
+
+const (
+	ErrorApi = "API error"
+)
+
+// apiFunc is JSON API handler, the returned error is written as RFC 7807 problem response
+type apiFunc func(log *slog.Logger, w http.ResponseWriter, r *http.Request) error
+
+// api adapts apiFunc to http.HandlerFunc, the logger gets operation and request id
+func api(logger *slog.Logger, operation string, f apiFunc) http.HandlerFunc {
+	logger = slogHelper.AddOperation(logger, operation)
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := slogHelper.AddRequestId(logger, r.Context())
+		if err := f(log, w, r); err != nil {
+			writeProblem(log, w, r, err)
+		}
+	}
+}
+
+// writeProblem writes error as problem response, errors other than responses.ApiError are internal errors.
+// Canceled and timed out operations are reported as such whatever code the handler has chosen.
+func writeProblem(log *slog.Logger, w http.ResponseWriter, r *http.Request, err error) {
+	var apiErr *responses.ApiError
+	switch errorHelper.KindOf(err) {
+	case errorHelper.Timeout:
+		apiErr = responses.NewError(responses.CodeTimeout, err)
+	case errorHelper.Canceled:
+		apiErr = responses.NewError(responses.CodeCanceled, err)
+	default:
+		if !errors.As(err, &apiErr) {
+			apiErr = responses.NewError(responses.CodeInternal, err)
+		}
+	}
+	problem := responses.NewProblem(apiErr.Code, apiErr.Detail, r.URL.Path)
+	tracing.RecordError(r.Context(), err)
+	attrs := []any{slog.String("code", problem.Code), slogHelper.GetErrAttr(err), slog.Any("stack", errorHelper.Stack(err))}
+	if problem.Status >= http.StatusInternalServerError {
+		log.Error(ErrorApi, attrs...)
+	} else {
+		log.Warn(ErrorApi, attrs...)
+	}
+	if err := jsonHelper.WriteProblem(problem, problem.Status, w); err != nil {
+		log.Error(ErrorWriteResponse, slogHelper.GetErrAttr(err))
+	}
+}
+
+// writeJson writes successful response
+func writeJson(log *slog.Logger, w http.ResponseWriter, code int, resp any) {
+	if err := jsonHelper.WriteResponseCode(resp, code, w); err != nil {
+		log.Error(ErrorWriteResponse, slogHelper.GetErrAttr(err))
+	}
+}
+
+// badRequest is the error of not decodable request body or parameters
+func badRequest(err error) error {
+	return responses.NewError(responses.CodeBadRequest, err)
+}
+
+// resourceCodes are problem codes of an API resource
+type resourceCodes struct {
+	notFound string
+	exists   string
+	invalid  string
+}
+
+// serviceError maps service error kinds to problem codes of the resource
+func serviceError(err error, codes resourceCodes) error {
+	switch errorHelper.KindOf(err) {
+	case errorHelper.NotFound:
+		return responses.NewError(codes.notFound, err)
+	case errorHelper.Conflict:
+		return responses.NewError(codes.exists, err)
+	case errorHelper.Invalid:
+		return responses.NewError(codes.invalid, err)
+	default:
+		return responses.NewError(responses.CodeInternal, err)
+	}
+}
+
+// rejected reports whether the error is a refusal of the presented credentials, token or code
+// rather than a failure to check them
+func rejected(err error) bool {
+	switch errorHelper.KindOf(err) {
+	case errorHelper.NotFound, errorHelper.InvalidCredentials, errorHelper.Expired:
+		return true
+	default:
+		return false
+	}
+}
+
+// credentialsError maps rejected credentials to the problem code, other errors are returned as is
+func credentialsError(err error, code string) error {
+	if rejected(err) {
+		return responses.NewError(code, err)
+	}
+	return err
+}
@@ -1,53 +1,76 @@
 package handlers
 
 import (
+	"errors"
 	"log/slog"
+	"math"
+	"net"
 	"net/http"
 	"sso/internal/http/requests"
 	"sso/internal/http/responses"
 	"sso/internal/services"
-	"sso/internal/storage"
+	"sso/pkg/helpers/errorHelper"
 	"sso/pkg/helpers/jsonHelper"
-	"sso/pkg/helpers/slogHelper"
+	"strconv"
+	"time"
 )
 
 const (
 	ErrorAuth          = "Auth error"
 	ErrorWriteResponse = "Error on write json response"
 	MsgIssuedToken     = "The user has been issued a token"
+	MsgMfaRequired     = "The user has been issued an mfa challenge"
 )
 
-func Auth(logger *slog.Logger, storage storage.Storage) http.HandlerFunc {
-	logger = slogHelper.AddOperation(logger, "http.handlers.auth()")
-	return func(w http.ResponseWriter, r *http.Request) {
-		resp := &responses.Auth{
-			Response: responses.Response{
-				Status: responses.StatusError,
-			},
-		}
-		log := slogHelper.AddRequestId(logger, r.Context())
+func Auth(logger *slog.Logger, lockout *services.LockoutService, tokens *services.TokensService, mfa *services.MfaService) http.HandlerFunc {
+	return api(logger, "http.handlers.auth()", func(log *slog.Logger, w http.ResponseWriter, r *http.Request) error {
 		params, err := jsonHelper.Decode(&requests.Auth{}, r.Body)
 		if err != nil {
-			resp.Response.Error = responses.ErrorBadRequest
-			log.Error(resp.Response.Error, slogHelper.GetErrAttr(err))
-		} else if params.Login == "" || params.Password == "" {
-			resp.Response.Error = responses.ErrorEmptyLoginPassword
-			log.Warn(resp.Response.Error)
-		} else {
-			token, err := services.Auth(params.Login, params.Password, storage)
-			if err != nil {
-				resp.Response.Error = responses.ErrorUserNotFound
-				log.Error(ErrorAuth, slogHelper.GetErrAttr(err))
-			} else {
-				resp.Status = responses.StatusOk
-				resp.Token = token
-				log.Info(MsgIssuedToken, slog.String("user_login", params.Login))
-			}
+			return badRequest(err)
 		}
-		err = jsonHelper.WriteResponse(resp, w)
-		if err != nil {
-			log.Error(ErrorWriteResponse, slogHelper.GetErrAttr(err))
+		if params.Login == "" || params.Password == "" {
+			return responses.NewError(responses.CodeMissingCredentials, nil)
+		}
+		pair, challenge, err := services.Auth(r.Context(), params.Login, params.Password, clientIp(r), lockout, tokens, mfa)
+		if retryAfter(w, err) {
+			return responses.NewError(responses.CodeLocked, err)
+		} else if errorHelper.KindOf(err) == errorHelper.InvalidCredentials {
+			return responses.NewError(responses.CodeInvalidCredentials, err)
+		} else if err != nil {
+			return err
+		}
+		resp := &responses.Auth{Response: responses.Response{Status: responses.StatusOk}}
+		if challenge != "" {
+			resp.Status = responses.StatusMfaRequired
+			resp.MfaToken = challenge
+			log.Info(MsgMfaRequired, slog.String("user_login", params.Login))
+		} else {
+			resp.Token = pair.AccessToken
+			resp.RefreshToken = pair.RefreshToken
+			resp.ExpiresIn = pair.ExpiresIn
+			log.Info(MsgIssuedToken, slog.String("user_login", params.Login))
 		}
+		writeJson(log, w, http.StatusOK, resp)
+		return nil
+	})
+}
+
+// clientIp is the address failed logins are counted for
+func clientIp(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
 
+// retryAfter sets Retry-After header and returns true if the error is lockout after failed logins
+func retryAfter(w http.ResponseWriter, err error) bool {
+	var locked *services.LockedError
+	if !errors.As(err, &locked) {
+		return false
 	}
+	seconds := math.Ceil(time.Until(locked.Until).Seconds())
+	w.Header().Set("Retry-After", strconv.Itoa(max(int(seconds), 1)))
+	return true
 }
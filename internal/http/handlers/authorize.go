@@ -0,0 +1,311 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sso/internal/http/requests"
+	"sso/internal/models"
+	"sso/internal/services"
+	"sso/pkg/helpers/slogHelper"
+	"sso/pkg/helpers/tokenHelper"
+)
+
+const (
+	ErrorAuthorize    = "Authorization request error"
+	ErrorRenderPage   = "Error on render login page"
+	MsgIssuedCode     = "The user has been issued an authorization code"
+	MsgBadCredentials = "Wrong login or password"
+	MsgBadMfaCode     = "Wrong code"
+	MsgBadSecurityKey = "Security key is not recognized"
+	MsgLocked         = "Too many failed attempts, try again later"
+	MsgUnknownClient  = "The application is not registered"
+	MsgBadRedirect    = "The application redirect address is not registered"
+	MsgBadAuthorize   = "The sign in request is invalid"
+	MsgCsrf           = "The sign in form has expired, open it again"
+	ErrorCsrf         = "CSRF token of the login form does not match"
+)
+
+const (
+	csrfCookie = "sso_csrf"
+	csrfField  = "csrf_token"
+)
+
+// fatalMessages are shown instead of authorization request errors that can't be redirected to the client
+var fatalMessages = map[string]string{
+	services.ErrorUnknownClient:  MsgUnknownClient,
+	services.ErrorBadRedirectUri: MsgBadRedirect,
+}
+
+var loginPage = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Sign in</title></head>
+<body>
+{{if .Fatal}}
+<p>{{.Fatal}}</p>
+{{else}}
+<form method="POST" action="authorize" id="login">
+<p>Sign in to continue to {{.ClientName}}</p>
+{{if .Error}}<p>{{.Error}}</p>{{end}}
+<input type="hidden" name="response_type" value="{{.Req.ResponseType}}">
+<input type="hidden" name="client_id" value="{{.Req.ClientId}}">
+<input type="hidden" name="redirect_uri" value="{{.Req.RedirectUri}}">
+<input type="hidden" name="scope" value="{{.Req.Scope}}">
+<input type="hidden" name="state" value="{{.Req.State}}">
+<input type="hidden" name="code_challenge" value="{{.Req.CodeChallenge}}">
+<input type="hidden" name="code_challenge_method" value="{{.Req.CodeChallengeMethod}}">
+<input type="hidden" name="nonce" value="{{.Req.Nonce}}">
+<input type="hidden" name="csrf_token" value="{{.Csrf}}">
+<input type="hidden" name="ceremony_token">
+<input type="hidden" name="credential">
+{{if .MfaToken}}
+<input type="hidden" name="mfa_token" value="{{.MfaToken}}">
+<p><input name="otp" placeholder="Code from authenticator app" autocomplete="one-time-code" inputmode="numeric"></p>
+<p><input name="recovery_code" placeholder="Or recovery code" autocomplete="off"></p>
+<p><button type="submit">Verify</button></p>
+<p><button type="button" onclick="passkey()">Use security key</button></p>
+{{else}}
+<p><input name="login" placeholder="Login" autocomplete="username" required></p>
+<p><input name="password" type="password" placeholder="Password" autocomplete="current-password" required></p>
+<p><button type="submit">Sign in</button></p>
+<p><button type="button" onclick="passkey()">Sign in with a passkey</button></p>
+{{end}}
+</form>
+<script>
+function b64u(buf) {
+	return btoa(String.fromCharCode(...new Uint8Array(buf))).replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "");
+}
+function unb64u(s) {
+	return Uint8Array.from(atob(s.replace(/-/g, "+").replace(/_/g, "/")), c => c.charCodeAt(0));
+}
+async function passkey() {
+	const form = document.getElementById("login");
+	const mfa = form.elements["mfa_token"];
+	const begin = await fetch("webauthn/login/begin", {
+		method: "POST",
+		headers: {"Content-Type": "application/json"},
+		body: JSON.stringify({mfa_token: mfa ? mfa.value : ""})
+	}).then(r => r.json());
+	if (begin.status !== "ok") {
+		return;
+	}
+	const options = begin.publicKey;
+	options.challenge = unb64u(options.challenge);
+	options.allowCredentials = options.allowCredentials.map(c => ({...c, id: unb64u(c.id)}));
+	const c = await navigator.credentials.get({publicKey: options});
+	form.elements["ceremony_token"].value = begin.ceremony_token;
+	form.elements["credential"].value = JSON.stringify({
+		id: c.id,
+		type: c.type,
+		response: {
+			clientDataJSON: b64u(c.response.clientDataJSON),
+			authenticatorData: b64u(c.response.authenticatorData),
+			signature: b64u(c.response.signature),
+			userHandle: c.response.userHandle ? b64u(c.response.userHandle) : ""
+		}
+	});
+	form.submit();
+}
+</script>
+{{end}}
+</body>
+</html>`))
+
+type loginPageData struct {
+	Req        *services.AuthorizeRequest
+	ClientName string
+	MfaToken   string //set on the second step for users with MFA
+	Csrf       string
+	Error      string
+	Fatal      string
+}
+
+// Authorize is RFC 6749 authorization endpoint for the code flow with PKCE.
+// GET renders login page, POST checks credentials and redirects back to the client with the code.
+func Authorize(logger *slog.Logger, lockout *services.LockoutService, codes *services.CodesService, mfa *services.MfaService, webauthn *services.WebauthnService) http.HandlerFunc {
+	//setup logger
+	logger = slogHelper.AddOperation(logger, "http.handlers.authorize()")
+	//return function
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := slogHelper.AddRequestId(logger, r.Context())
+		//login page must not be framed by other sites
+		w.Header().Set("X-Frame-Options", "DENY")
+		w.Header().Set("Cache-Control", "no-store")
+		req := &services.AuthorizeRequest{
+			ResponseType:        r.FormValue("response_type"),
+			ClientId:            r.FormValue("client_id"),
+			RedirectUri:         r.FormValue("redirect_uri"),
+			Scope:               r.FormValue("scope"),
+			State:               r.FormValue("state"),
+			CodeChallenge:       r.FormValue("code_challenge"),
+			CodeChallengeMethod: r.FormValue("code_challenge_method"),
+			Nonce:               r.FormValue("nonce"),
+		}
+		client, err := codes.Validate(r.Context(), req)
+		if err != nil {
+			log.Warn(ErrorAuthorize, slogHelper.GetErrAttr(err))
+			var authErr *services.AuthorizeError
+			if errors.As(err, &authErr) && authErr.Redirect {
+				redirect(w, r, req, url.Values{"error": {authErr.Code}, "error_description": {authErr.Description}})
+				return
+			}
+			fatal := MsgBadAuthorize
+			if errors.As(err, &authErr) && fatalMessages[authErr.Description] != "" {
+				fatal = fatalMessages[authErr.Description]
+			}
+			renderLoginPage(log, w, http.StatusBadRequest, &loginPageData{Req: req, Fatal: fatal})
+			return
+		}
+		data := &loginPageData{Req: req, ClientName: client.Name}
+		if data.ClientName == "" {
+			data.ClientName = client.ClientId
+		}
+		secret := ""
+		if cookie, err := r.Cookie(csrfCookie); err == nil {
+			secret = cookie.Value
+		}
+		if r.Method != http.MethodPost {
+			if secret == "" {
+				if secret, err = tokenHelper.Generate(32); err != nil {
+					log.Error(ErrorAuthorize, slogHelper.GetErrAttr(err))
+					redirect(w, r, req, url.Values{"error": {services.AuthorizeServerError}})
+					return
+				}
+				http.SetCookie(w, &http.Cookie{
+					Name:     csrfCookie,
+					Value:    secret,
+					Path:     "/",
+					Secure:   r.TLS != nil,
+					HttpOnly: true,
+					SameSite: http.SameSiteLaxMode,
+				})
+			}
+			data.Csrf = csrfToken(secret, req)
+			renderLoginPage(log, w, http.StatusOK, data)
+			return
+		}
+		//the form must have been rendered for this browser and this authorization request
+		data.Csrf = csrfToken(secret, req)
+		if secret == "" || subtle.ConstantTimeCompare([]byte(data.Csrf), []byte(r.PostFormValue(csrfField))) != 1 {
+			log.Warn(ErrorCsrf)
+			renderLoginPage(log, w, http.StatusForbidden, &loginPageData{Req: req, Fatal: MsgCsrf})
+			return
+		}
+		var user *models.User
+		acr := services.AcrPassword
+		if credential := r.PostFormValue("credential"); credential != "" {
+			//security key as the second factor or passwordless passkey
+			var assertion *services.Assertion
+			params := &requests.PublicKeyCredential{}
+			err := json.Unmarshal([]byte(credential), params)
+			if err == nil {
+				assertion, err = assertionResponse(params)
+			}
+			if err == nil {
+				user, acr, err = webauthn.FinishLogin(r.Context(), r.PostFormValue("ceremony_token"), assertion)
+			}
+			if err != nil {
+				log.Warn(ErrorWebauthn, slogHelper.GetErrAttr(err))
+				data.MfaToken = r.PostFormValue("mfa_token")
+				data.Error = MsgBadSecurityKey
+				renderLoginPage(log, w, http.StatusUnauthorized, data)
+				return
+			}
+		} else if challenge := r.PostFormValue("mfa_token"); challenge != "" {
+			//second step, the password has been checked already
+			user, err = mfa.Verify(r.Context(), challenge, r.PostFormValue("otp"), r.PostFormValue("recovery_code"), clientIp(r))
+			if retryAfter(w, err) {
+				log.Warn(ErrorMfa, slogHelper.GetErrAttr(err))
+				data.Error = MsgLocked
+				renderLoginPage(log, w, http.StatusTooManyRequests, data)
+				return
+			} else if err != nil {
+				log.Warn(ErrorMfa, slogHelper.GetErrAttr(err))
+				data.MfaToken = challenge
+				data.Error = MsgBadMfaCode
+				renderLoginPage(log, w, http.StatusUnauthorized, data)
+				return
+			}
+			acr = services.AcrMfa
+		} else {
+			user, err = lockout.Login(r.Context(), r.PostFormValue("login"), r.PostFormValue("password"), clientIp(r))
+			if retryAfter(w, err) {
+				log.Warn(ErrorAuth, slogHelper.GetErrAttr(err))
+				data.Error = MsgLocked
+				renderLoginPage(log, w, http.StatusTooManyRequests, data)
+				return
+			} else if err != nil {
+				log.Warn(ErrorAuth, slogHelper.GetErrAttr(err))
+				data.Error = MsgBadCredentials
+				renderLoginPage(log, w, http.StatusUnauthorized, data)
+				return
+			}
+			required, err := mfa.Required(r.Context(), user)
+			if err == nil && required {
+				data.MfaToken, err = mfa.Challenge(r.Context(), user)
+			}
+			if err != nil {
+				log.Error(ErrorAuthorize, slogHelper.GetErrAttr(err))
+				redirect(w, r, req, url.Values{"error": {services.AuthorizeServerError}})
+				return
+			}
+			if required {
+				log.Info(MsgMfaRequired, slog.String("user_login", user.Login))
+				renderLoginPage(log, w, http.StatusOK, data)
+				return
+			}
+			if err := lockout.Success(r.Context(), user.Login); err != nil {
+				log.Error(ErrorAuthorize, slogHelper.GetErrAttr(err))
+				redirect(w, r, req, url.Values{"error": {services.AuthorizeServerError}})
+				return
+			}
+		}
+		code, err := codes.Issue(r.Context(), req, user.Id, acr)
+		if err != nil {
+			log.Error(ErrorAuthorize, slogHelper.GetErrAttr(err))
+			redirect(w, r, req, url.Values{"error": {services.AuthorizeServerError}})
+			return
+		}
+		log.Info(MsgIssuedCode, slog.String("user_login", user.Login), slog.String("client_id", req.ClientId))
+		redirect(w, r, req, url.Values{"code": {code}})
+	}
+}
+
+// redirect sends authorization response to the validated redirect_uri
+func redirect(w http.ResponseWriter, r *http.Request, req *services.AuthorizeRequest, params url.Values) {
+	u, _ := url.Parse(req.RedirectUri)
+	q := u.Query()
+	for k, v := range params {
+		q[k] = v
+	}
+	if req.State != "" {
+		q.Set("state", req.State)
+	}
+	u.RawQuery = q.Encode()
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+// csrfToken binds the login form to the browser cookie secret and to the authorization request,
+// a form posted by another site knows neither the secret nor the token
+func csrfToken(secret string, req *services.AuthorizeRequest) string {
+	h := sha256.New()
+	for _, v := range []string{secret, req.ClientId, req.RedirectUri, req.CodeChallenge, req.State} {
+		h.Write([]byte(v))
+		h.Write([]byte{0})
+	}
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+func renderLoginPage(log *slog.Logger, w http.ResponseWriter, code int, data *loginPageData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(code)
+	if err := loginPage.Execute(w, data); err != nil {
+		log.Error(ErrorRenderPage, slogHelper.GetErrAttr(err))
+	}
+}
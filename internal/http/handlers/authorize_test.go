@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sso/internal/services"
+	"strings"
+	"testing"
+)
+
+var csrfInput = regexp.MustCompile(`name="csrf_token" value="([^"]*)"`)
+
+func authorizeParams(state string) url.Values {
+	return url.Values{
+		"response_type":         {"code"},
+		"client_id":             {"app"},
+		"redirect_uri":          {"https://app.example.com/callback"},
+		"scope":                 {"openid"},
+		"state":                 {state},
+		"code_challenge":        {"E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"},
+		"code_challenge_method": {"S256"},
+	}
+}
+
+func TestAuthorizeFatalError(t *testing.T) {
+	env := newOAuthEnv(t)
+	h := Authorize(env.log, env.lockout, env.codes, env.mfa, nil)
+	params := authorizeParams("xyz")
+	params.Set("client_id", "unknown")
+	w := serve(t, h, http.MethodGet, "/authorize?"+params.Encode(), "")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), MsgUnknownClient)
+	require.NotContains(t, w.Body.String(), services.ErrorUnknownClient)
+
+	params = authorizeParams("xyz")
+	params.Set("redirect_uri", "https://evil.example.com/callback")
+	w = serve(t, h, http.MethodGet, "/authorize?"+params.Encode(), "")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), MsgBadRedirect)
+}
+
+func TestAuthorizeCsrf(t *testing.T) {
+	env := newOAuthEnv(t)
+	h := Authorize(env.log, env.lockout, env.codes, env.mfa, nil)
+
+	w := serve(t, h, http.MethodGet, "/authorize?"+authorizeParams("xyz").Encode(), "")
+	require.Equal(t, http.StatusOK, w.Code)
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	require.Equal(t, csrfCookie, cookies[0].Name)
+	require.True(t, cookies[0].HttpOnly)
+	match := csrfInput.FindStringSubmatch(w.Body.String())
+	require.Len(t, match, 2)
+	token := match[1]
+	require.NotEmpty(t, token)
+
+	post := func(form url.Values, cookie *http.Cookie) *httptest.ResponseRecorder {
+		form.Set("login", "alice")
+		form.Set("password", "Passw0rd!")
+		r := httptest.NewRequest(http.MethodPost, "/authorize", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if cookie != nil {
+			r.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	withToken := func(state string, token string) url.Values {
+		form := authorizeParams(state)
+		form.Set(csrfField, token)
+		return form
+	}
+
+	//a form posted by another site has neither the cookie nor the token
+	w = post(authorizeParams("xyz"), nil)
+	require.Equal(t, http.StatusForbidden, w.Code)
+	require.Contains(t, w.Body.String(), MsgCsrf)
+	w = post(withToken("xyz", token), nil)
+	require.Equal(t, http.StatusForbidden, w.Code)
+	w = post(withToken("xyz", "forged"), cookies[0])
+	require.Equal(t, http.StatusForbidden, w.Code)
+	//the token is bound to the authorization request it was rendered for
+	w = post(withToken("other", token), cookies[0])
+	require.Equal(t, http.StatusForbidden, w.Code)
+
+	w = post(withToken("xyz", token), cookies[0])
+	require.Equal(t, http.StatusFound, w.Code)
+	location, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+	require.NotEmpty(t, location.Query().Get("code"))
+	require.Equal(t, "xyz", location.Query().Get("state"))
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sso/internal/http/responses"
+	"sso/internal/services"
+	"sso/pkg/helpers/slogHelper"
+	"sso/pkg/http/middleware"
+	"strings"
+	"time"
+)
+
+const (
+	MsgNoRole        = "Caller has no required role"
+	MsgNotFirstParty = "Token issued to OAuth client is not accepted"
+	MsgStaleAuth     = "Caller logged in too long ago"
+)
+
+// bearerToken returns token from "Authorization: Bearer" header, RFC 6750 section 2.1
+func bearerToken(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// bearerChallenge sets WWW-Authenticate header of request with missing or invalid bearer token, RFC 6750 section 3
+func bearerChallenge(w http.ResponseWriter, bearerError string) {
+	challenge := `Bearer realm="sso"`
+	if bearerError != "" {
+		challenge += `, error="` + bearerError + `"`
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+}
+
+// writeBearerError answers request with missing or invalid bearer token in RFC 6749 error format
+func writeBearerError(log *slog.Logger, w http.ResponseWriter, code int, bearerError string) {
+	bearerChallenge(w, bearerError)
+	writeOAuthError(log, w, code, bearerError, "")
+}
+
+type claimsKey struct{}
+
+// ClientKey is rate limit key of the caller: client_id or subject of a valid bearer token, the address otherwise.
+// Client ids from request parameters are not used, anyone could exhaust the limit of another client with them.
+func ClientKey(keys services.Keyring, revocations services.Denylist) middleware.KeyFunc {
+	return func(r *http.Request) string {
+		token := bearerToken(r)
+		if token == "" {
+			return middleware.ByIp(r)
+		}
+		claims, err := services.Claims(r.Context(), token, keys, revocations)
+		if err != nil {
+			return middleware.ByIp(r)
+		}
+		if clientId, _ := claims["client_id"].(string); clientId != "" {
+			return "client:" + clientId
+		}
+		if sub, _ := claims.GetSubject(); sub != "" {
+			return "sub:" + sub
+		}
+		return middleware.ByIp(r)
+	}
+}
+
+// tokenPolicy is what a bearer token must satisfy besides being valid
+type tokenPolicy struct {
+	role       string        //required role, any if empty
+	firstParty bool          //tokens issued to OAuth clients are rejected
+	maxAge     time.Duration //max time since login by auth_time claim, any if 0
+}
+
+// RequireAuth allows request only with a valid bearer access token.
+// Verified claims are put into request context, see callerClaims
+func RequireAuth(logger *slog.Logger, keys services.Keyring, revocations services.Denylist) func(http.HandlerFunc) http.HandlerFunc {
+	return requireToken(slogHelper.AddOperation(logger, "http.handlers.RequireAuth()"), keys, revocations, tokenPolicy{})
+}
+
+// RequireRole allows request only with a valid first party bearer access token carrying role in its roles claim.
+// Roles are copied into tokens of OAuth clients too, a client must not use the admin rights of its user.
+func RequireRole(logger *slog.Logger, keys services.Keyring, revocations services.Denylist, role string) func(http.HandlerFunc) http.HandlerFunc {
+	return requireToken(slogHelper.AddOperation(logger, "http.handlers.RequireRole()"), keys, revocations, tokenPolicy{role: role, firstParty: true})
+}
+
+// RequireRecentAuth allows request only with a valid first party bearer access token of a login not older than maxAge.
+// It protects adding authentication methods: a stolen or third party token must not register a passkey or TOTP secret.
+func RequireRecentAuth(logger *slog.Logger, keys services.Keyring, revocations services.Denylist, maxAge time.Duration) func(http.HandlerFunc) http.HandlerFunc {
+	return requireToken(slogHelper.AddOperation(logger, "http.handlers.RequireRecentAuth()"), keys, revocations, tokenPolicy{firstParty: true, maxAge: maxAge})
+}
+
+func requireToken(logger *slog.Logger, keys services.Keyring, revocations services.Denylist, policy tokenPolicy) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			log := slogHelper.AddRequestId(logger, r.Context())
+			token := bearerToken(r)
+			if token == "" {
+				bearerChallenge(w, responses.OAuthInvalidRequest)
+				writeProblem(log, w, r, responses.NewError(responses.CodeUnauthorized, nil))
+				return
+			}
+			claims, err := services.Claims(r.Context(), token, keys, revocations)
+			if err != nil {
+				if rejected(err) {
+					bearerChallenge(w, responses.OAuthInvalidToken)
+				}
+				writeProblem(log, w, r, credentialsError(err, responses.CodeInvalidToken))
+				return
+			}
+			if policy.firstParty && !services.FirstParty(claims) {
+				log.Warn(MsgNotFirstParty, slog.Any("sub", claims["sub"]), slog.Any("client_id", claims["client_id"]))
+				writeProblem(log, w, r, responses.NewError(responses.CodeForbidden, nil))
+				return
+			}
+			if policy.maxAge > 0 && !recentAuth(claims, policy.maxAge) {
+				log.Warn(MsgStaleAuth, slog.Any("sub", claims["sub"]), slog.Any("auth_time", claims["auth_time"]))
+				bearerChallenge(w, responses.OAuthInsufficientUserAuthentication)
+				writeProblem(log, w, r, responses.NewError(responses.CodeLoginRequired, nil))
+				return
+			}
+			if policy.role != "" && !slices.Contains(claimRoles(claims), policy.role) {
+				log.Warn(MsgNoRole, slog.Any("sub", claims["sub"]), slog.String("role", policy.role))
+				writeProblem(log, w, r, responses.NewError(responses.CodeForbidden, nil))
+				return
+			}
+			//stored as plain map, jwt.MapClaims would not match the type assertion in callerClaims
+			next(w, r.WithContext(context.WithValue(r.Context(), claimsKey{}, map[string]any(claims))))
+		}
+	}
+}
+
+// recentAuth reports whether the user logged in within maxAge, tokens without auth_time are never recent
+func recentAuth(claims map[string]any, maxAge time.Duration) bool {
+	//after json decoding numbers are float64
+	authTime, ok := claims["auth_time"].(float64)
+	return ok && time.Since(time.Unix(int64(authTime), 0)) <= maxAge
+}
+
+// claimRoles reads roles claim, after json decoding it is a slice of any
+func claimRoles(claims map[string]any) []string {
+	list, _ := claims["roles"].([]any)
+	roles := make([]string, 0, len(list))
+	for _, v := range list {
+		if s, ok := v.(string); ok {
+			roles = append(roles, s)
+		}
+	}
+	return roles
+}
+
+// callerClaims returns claims of the token verified by RequireRole
+func callerClaims(r *http.Request) map[string]any {
+	claims, _ := r.Context().Value(claimsKey{}).(map[string]any)
+	return claims
+}
+
+// callerSubject returns subject of the token verified by RequireAuth or RequireRole
+func callerSubject(r *http.Request) string {
+	sub, _ := callerClaims(r)["sub"].(string)
+	return sub
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"sso/internal/config"
+	"sso/internal/http/responses"
+	"sso/internal/services"
+	"testing"
+	"time"
+)
+
+func TestRequireRecentAuth(t *testing.T) {
+	ctx := context.Background()
+	env := newOAuthEnv(t)
+	webauthn := services.Webauthn(env.storage, env.tokens, env.keys, env.revocations, env.mfa, config.WebauthnConfig{
+		RpId:         "sso.example.com",
+		RpName:       "sso",
+		Origins:      []string{"https://sso.example.com"},
+		Attestation:  "none",
+		ChallengeTTL: time.Minute,
+	})
+	h := RequireRecentAuth(env.log, env.keys, env.revocations, 15*time.Minute)(WebauthnRegisterBegin(env.log, webauthn))
+	issue := func(grant services.Grant) string {
+		grant.UserId = env.userId
+		pair, err := env.tokens.Issue(ctx, grant)
+		require.NoError(t, err)
+		return pair.AccessToken
+	}
+
+	w := serveAs(t, h, issue(services.Grant{AuthTime: time.Now()}), http.MethodPost, "/webauthn/register/begin")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	//a third party client could register an authenticator it controls
+	w = serveAs(t, h, issue(services.Grant{ClientId: "app", Scope: "openid", AuthTime: time.Now()}), http.MethodPost, "/webauthn/register/begin")
+	requireProblem(t, w, http.StatusForbidden, responses.CodeForbidden)
+
+	//old and unknown logins must be repeated
+	for _, authTime := range []time.Time{time.Now().Add(-time.Hour), {}} {
+		w = serveAs(t, h, issue(services.Grant{AuthTime: authTime}), http.MethodPost, "/webauthn/register/begin")
+		requireProblem(t, w, http.StatusUnauthorized, responses.CodeLoginRequired)
+		require.Contains(t, w.Header().Get("WWW-Authenticate"), responses.OAuthInsufficientUserAuthentication)
+	}
+}
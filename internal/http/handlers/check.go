@@ -6,37 +6,19 @@ import (
 	"sso/internal/http/requests"
 	"sso/internal/http/responses"
 	"sso/internal/services"
-	"sso/internal/storage"
 	"sso/pkg/helpers/jsonHelper"
-	"sso/pkg/helpers/slogHelper"
 )
 
-func Check(logger *slog.Logger, storage storage.Storage) http.HandlerFunc {
-	//setup logger
-	logger = slogHelper.AddOperation(logger, "http.handlers.auth.check()")
-	//return function
-	return func(w http.ResponseWriter, r *http.Request) {
-		resp := &responses.Auth{
-			Response: responses.Response{
-				Status: responses.StatusOk,
-			},
-		}
-		log := slogHelper.AddRequestId(logger, r.Context())
+func Check(logger *slog.Logger, keys *services.KeysService, revocations *services.RevocationsService) http.HandlerFunc {
+	return api(logger, "http.handlers.auth.check()", func(log *slog.Logger, w http.ResponseWriter, r *http.Request) error {
 		params, err := jsonHelper.Decode(&requests.Check{}, r.Body)
 		if err != nil {
-			resp.Response.Error = responses.ErrorBadRequest
-			log.Error(resp.Response.Error, slogHelper.GetErrAttr(err))
-		} else {
-			if err := services.Check(params.Token, storage); err != nil {
-				log.Error("Error on validate token", slogHelper.GetErrAttr(err))
-				resp.Status = responses.StatusError
-				resp.Error = responses.ErrorTokenNotValid
-			}
-			err = jsonHelper.WriteResponse(resp, w)
-			if err != nil {
-				log.Error(ErrorWriteResponse, slogHelper.GetErrAttr(err))
-			}
+			return badRequest(err)
 		}
-
-	}
+		if err := services.Check(r.Context(), params.Token, keys, revocations); err != nil {
+			return credentialsError(err, responses.CodeInvalidToken)
+		}
+		writeJson(log, w, http.StatusOK, &responses.Auth{Response: responses.Response{Status: responses.StatusOk}})
+		return nil
+	})
 }
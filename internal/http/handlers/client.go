@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"sso/internal/models"
+	"sso/internal/services"
+)
+
+const (
+	ErrorNoClientCredentials = "client credentials are not provided"
+)
+
+// clientCredentials returns credentials passed by HTTP Basic (client_secret_basic)
+// or in the form body (client_secret_post), RFC 6749 section 2.3.1
+func clientCredentials(r *http.Request) (string, string) {
+	id, secret, ok := r.BasicAuth()
+	if ok {
+		//credentials are form encoded before base64 encoding
+		if v, err := url.QueryUnescape(id); err == nil {
+			id = v
+		}
+		if v, err := url.QueryUnescape(secret); err == nil {
+			secret = v
+		}
+		return id, secret
+	}
+	return r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+}
+
+// authenticateClient checks confidential client credentials
+func authenticateClient(r *http.Request, clients *services.ClientsService) (*models.Client, error) {
+	id, secret := clientCredentials(r)
+	if id == "" || secret == "" {
+		return nil, errors.New(ErrorNoClientCredentials)
+	}
+	return clients.Authenticate(r.Context(), id, secret)
+}
+
+// tokenClient identifies the client on the token endpoint: confidential clients must
+// authenticate, public clients only pass client_id. Requests without client_id are first party.
+func tokenClient(r *http.Request, clients *services.ClientsService) (*models.Client, error) {
+	id, _ := clientCredentials(r)
+	if id == "" {
+		return nil, nil
+	}
+	client, err := clients.Get(r.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+	if client.IsPublic() {
+		return client, nil
+	}
+	return authenticateClient(r, clients)
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"sso/internal/http/responses"
+	"sso/internal/services"
+	"sso/pkg/helpers/jsonHelper"
+	"sso/pkg/helpers/slogHelper"
+)
+
+// Discovery serves OpenID Connect provider metadata, all endpoint URLs are relative to issuer
+func Discovery(logger *slog.Logger, issuer string) http.HandlerFunc {
+	//setup logger
+	logger = slogHelper.AddOperation(logger, "http.handlers.discovery()")
+	doc := &responses.Discovery{
+		Issuer:                                    issuer,
+		AuthorizationEndpoint:                     issuer + "/authorize",
+		TokenEndpoint:                             issuer + "/token",
+		UserinfoEndpoint:                          issuer + "/userinfo",
+		JwksUri:                                   issuer + "/.well-known/jwks.json",
+		IntrospectionEndpoint:                     issuer + "/introspect",
+		RevocationEndpoint:                        issuer + "/revoke",
+		ResponseTypesSupported:                    []string{services.ResponseTypeCode},
+		GrantTypesSupported:                       []string{GrantTypeAuthorizationCode, GrantTypeClientCredentials, GrantTypeRefreshToken},
+		CodeChallengeMethodsSupported:             []string{services.PkceMethodS256},
+		SubjectTypesSupported:                     []string{"public"},
+		IdTokenSigningAlgValuesSupported:          []string{"RS256"},
+		TokenEndpointAuthMethodsSupported:         []string{"none", "client_secret_basic", "client_secret_post"},
+		IntrospectionEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post"},
+		RevocationEndpointAuthMethodsSupported:    []string{"none", "client_secret_basic", "client_secret_post"},
+		ScopesSupported:                           []string{services.ScopeOpenId, services.ScopeProfile, services.ScopeEmail},
+		AcrValuesSupported:                        []string{services.AcrPassword, services.AcrMfa, services.AcrPasskey},
+		ClaimsSupported: []string{"iss", "sub", "aud", "exp", "nbf", "iat", "jti", "azp", "nonce", "at_hash", "auth_time", "acr",
+			"name", "preferred_username", "email", "email_verified", "roles", "permissions", "groups", "groups_overflow"},
+	}
+	//return function
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := slogHelper.AddRequestId(logger, r.Context())
+		if err := jsonHelper.WriteResponse(doc, w); err != nil {
+			log.Error(ErrorWriteResponse, slogHelper.GetErrAttr(err))
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"sso/internal/http/responses"
+	"sso/internal/services"
+	"sso/internal/storage"
+	"sso/pkg/helpers/jsonHelper"
+	"sso/pkg/helpers/slogHelper"
+)
+
+const (
+	ErrorClientAuth = "Client authentication error"
+)
+
+// Introspect implements RFC 7662 token introspection, callers authenticate with client credentials
+func Introspect(logger *slog.Logger, storage storage.Storage, clients *services.ClientsService, keys *services.KeysService, revocations *services.RevocationsService) http.HandlerFunc {
+	//setup logger
+	logger = slogHelper.AddOperation(logger, "http.handlers.introspect()")
+	//return function
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := slogHelper.AddRequestId(logger, r.Context())
+		client, err := authenticateClient(r, clients)
+		if err != nil {
+			log.Warn(ErrorClientAuth, slogHelper.GetErrAttr(err))
+			w.Header().Set("WWW-Authenticate", `Basic realm="introspect"`)
+			writeOAuthError(log, w, http.StatusUnauthorized, responses.OAuthInvalidClient, "")
+			return
+		}
+		token := r.PostFormValue("token")
+		if token == "" {
+			writeOAuthError(log, w, http.StatusBadRequest, responses.OAuthInvalidRequest, "empty token")
+			return
+		}
+		res := services.Introspect(r.Context(), token, r.PostFormValue("token_type_hint"), storage, keys, revocations)
+		log.Info("Token introspected", slog.String("client_id", client.ClientId), slog.Any("active", res["active"]))
+		w.Header().Set("Cache-Control", "no-store")
+		if err := jsonHelper.WriteResponse(res, w); err != nil {
+			log.Error(ErrorWriteResponse, slogHelper.GetErrAttr(err))
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/url"
+	"sso/internal/http/responses"
+	"sso/internal/services"
+	"testing"
+)
+
+func TestIntrospect(t *testing.T) {
+	env := newOAuthEnv(t)
+	h := Introspect(env.log, env.storage, env.clients, env.keys, env.revocations)
+	pair, err := env.tokens.Issue(context.Background(), services.Grant{UserId: env.userId, ClientId: "spa", Scope: "openid"})
+	require.NoError(t, err)
+	form := url.Values{"token": {pair.RefreshToken}, "token_type_hint": {services.TokenTypeRefresh}}
+
+	w := postForm(t, h, "/introspect", form, "app", "wrong")
+	requireOAuthError(t, w, http.StatusUnauthorized, responses.OAuthInvalidClient)
+	//public clients can't introspect
+	w = postForm(t, h, "/introspect", url.Values{"token": {pair.RefreshToken}, "client_id": {"spa"}}, "", "")
+	requireOAuthError(t, w, http.StatusUnauthorized, responses.OAuthInvalidClient)
+	w = postForm(t, h, "/introspect", url.Values{}, "app", testClientSecret)
+	requireOAuthError(t, w, http.StatusBadRequest, responses.OAuthInvalidRequest)
+
+	w = postForm(t, h, "/introspect", form, "app", testClientSecret)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	res := map[string]any{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	require.Equal(t, true, res["active"])
+	require.Equal(t, services.TokenTypeRefresh, res["token_type"])
+	require.Equal(t, env.userId, res["sub"])
+	require.Equal(t, "spa", res["client_id"])
+	require.Equal(t, "openid", res["scope"])
+
+	w = postForm(t, h, "/introspect", url.Values{"token": {"unknown"}}, "app", testClientSecret)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"active":false}`, w.Body.String())
+}
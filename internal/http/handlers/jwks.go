@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"sso/internal/services"
+	"sso/pkg/helpers/jsonHelper"
+	"sso/pkg/helpers/jwkHelper"
+	"sso/pkg/helpers/slogHelper"
+)
+
+const (
+	ErrorGetKeys = "Error on get public keys"
+)
+
+// Jwks publishes all not retired public keys (next, active and retiring) as RFC 7517 JWK Set
+func Jwks(logger *slog.Logger, keys *services.KeysService) http.HandlerFunc {
+	//setup logger
+	logger = slogHelper.AddOperation(logger, "http.handlers.jwks()")
+	//return function
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := slogHelper.AddRequestId(logger, r.Context())
+		list, err := keys.Verification(r.Context())
+		if err != nil {
+			log.Error(ErrorGetKeys, slogHelper.GetErrAttr(err))
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		set := &jwkHelper.Set{
+			Keys: make([]jwkHelper.Key, 0, len(list)),
+		}
+		for _, k := range list {
+			set.Keys = append(set.Keys, jwkHelper.FromPublicKey(&k.PrivateKey.PublicKey))
+		}
+		if err := jsonHelper.WriteResponse(set, w); err != nil {
+			log.Error(ErrorWriteResponse, slogHelper.GetErrAttr(err))
+		}
+	}
+}
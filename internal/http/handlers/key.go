@@ -5,21 +5,26 @@ import (
 	"encoding/pem"
 	"log/slog"
 	"net/http"
-	"sso/internal/storage"
+	"sso/internal/services"
 	slogHelper "sso/pkg/helpers/slogHelper"
 )
 
-func Key(logger *slog.Logger, storage storage.Storage) http.HandlerFunc {
+func Key(logger *slog.Logger, keys *services.KeysService) http.HandlerFunc {
 	//setup logger
 	logger = slogHelper.AddOperation(logger, "http.handlers.auth.key()")
 	//return function
 	return func(w http.ResponseWriter, r *http.Request) {
 		log := slogHelper.AddRequestId(logger, r.Context())
-		k, _ := storage.GetRsaKey()
+		k, err := keys.Signing(r.Context())
+		if err != nil {
+			log.Error(ErrorGetKeys, slogHelper.GetErrAttr(err))
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
 		keyPem := pem.EncodeToMemory(
 			&pem.Block{
 				Type:  "RSA PUBLIC KEY",
-				Bytes: x509.MarshalPKCS1PublicKey(&k.PublicKey),
+				Bytes: x509.MarshalPKCS1PublicKey(&k.PrivateKey.PublicKey),
 			},
 		)
 		w.Header().Set("Content-Type", "application/octet-stream")
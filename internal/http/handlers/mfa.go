@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"sso/internal/http/requests"
+	"sso/internal/http/responses"
+	"sso/internal/services"
+	"sso/pkg/helpers/errorHelper"
+	"sso/pkg/helpers/jsonHelper"
+	"sso/pkg/helpers/slogHelper"
+	"sso/pkg/helpers/totpHelper"
+	"time"
+)
+
+const (
+	ErrorMfa        = "MFA error"
+	MsgMfaEnrolled  = "The user has started mfa enrollment"
+	MsgMfaConfirmed = "The user has enabled mfa"
+	MsgMfaReset     = "Mfa has been reset"
+	qrSize          = 256
+)
+
+// mfaCodes are problem codes of MFA self-service API
+var mfaCodes = resourceCodes{
+	notFound: responses.CodeUserNotFound,
+	exists:   responses.CodeMfaEnabled,
+	invalid:  responses.CodeMfaNotPending,
+}
+
+// MfaEnroll starts TOTP enrollment of the caller, returns the secret and otpauth URI
+func MfaEnroll(logger *slog.Logger, mfa *services.MfaService) http.HandlerFunc {
+	return api(logger, "http.handlers.MfaEnroll()", func(log *slog.Logger, w http.ResponseWriter, r *http.Request) error {
+		userId := callerSubject(r)
+		secret, uri, err := mfa.Enroll(r.Context(), userId)
+		if err != nil {
+			return mfaError(err)
+		}
+		log.Info(MsgMfaEnrolled, slog.String("user_id", userId))
+		w.Header().Set("Cache-Control", "no-store")
+		writeJson(log, w, http.StatusOK, &responses.MfaEnroll{
+			Response: responses.Response{Status: responses.StatusOk},
+			Secret:   secret,
+			Uri:      uri,
+		})
+		return nil
+	})
+}
+
+// MfaQr renders otpauth URI of not confirmed enrollment as PNG QR code
+func MfaQr(logger *slog.Logger, mfa *services.MfaService) http.HandlerFunc {
+	return api(logger, "http.handlers.MfaQr()", func(log *slog.Logger, w http.ResponseWriter, r *http.Request) error {
+		uri, err := mfa.PendingURI(r.Context(), callerSubject(r))
+		if err != nil {
+			return mfaError(err)
+		}
+		png, err := totpHelper.QR(uri, qrSize)
+		if err != nil {
+			return err
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Cache-Control", "no-store")
+		if _, err := w.Write(png); err != nil {
+			log.Error(ErrorWriteResponse, slogHelper.GetErrAttr(err))
+		}
+		return nil
+	})
+}
+
+// MfaConfirm enables MFA with the first code from authenticator app, returns recovery codes shown only once
+func MfaConfirm(logger *slog.Logger, mfa *services.MfaService) http.HandlerFunc {
+	return api(logger, "http.handlers.MfaConfirm()", func(log *slog.Logger, w http.ResponseWriter, r *http.Request) error {
+		params, err := jsonHelper.Decode(&requests.MfaConfirm{}, r.Body)
+		if err != nil {
+			return badRequest(err)
+		}
+		userId := callerSubject(r)
+		codes, err := mfa.Confirm(r.Context(), userId, params.Code)
+		if err != nil {
+			return mfaError(err)
+		}
+		log.Info(MsgMfaConfirmed, slog.String("user_id", userId))
+		w.Header().Set("Cache-Control", "no-store")
+		writeJson(log, w, http.StatusOK, &responses.MfaConfirm{
+			Response:      responses.Response{Status: responses.StatusOk},
+			RecoveryCodes: codes,
+		})
+		return nil
+	})
+}
+
+// MfaVerify is the second step of POST / login, exchanges MFA challenge token and code for tokens
+func MfaVerify(logger *slog.Logger, tokens *services.TokensService, mfa *services.MfaService) http.HandlerFunc {
+	return api(logger, "http.handlers.MfaVerify()", func(log *slog.Logger, w http.ResponseWriter, r *http.Request) error {
+		params, err := jsonHelper.Decode(&requests.MfaVerify{}, r.Body)
+		if err != nil {
+			return badRequest(err)
+		}
+		if params.MfaToken == "" || (params.Code == "" && params.RecoveryCode == "") {
+			return responses.NewError(responses.CodeMissingMfa, nil)
+		}
+		user, err := mfa.Verify(r.Context(), params.MfaToken, params.Code, params.RecoveryCode, clientIp(r))
+		if retryAfter(w, err) {
+			return responses.NewError(responses.CodeLocked, err)
+		} else if err != nil {
+			return credentialsError(err, responses.CodeMfaFailed)
+		}
+		pair, err := tokens.Issue(r.Context(), services.Grant{UserId: user.Id, AuthTime: time.Now(), Acr: services.AcrMfa})
+		if err != nil {
+			return err
+		}
+		log.Info(MsgIssuedToken, slog.String("user_login", user.Login))
+		writeJson(log, w, http.StatusOK, &responses.Auth{
+			Response:     responses.Response{Status: responses.StatusOk},
+			Token:        pair.AccessToken,
+			RefreshToken: pair.RefreshToken,
+			ExpiresIn:    pair.ExpiresIn,
+		})
+		return nil
+	})
+}
+
+// AdminResetMfa disables MFA of the user, for example when the device is lost together with recovery codes
+func AdminResetMfa(logger *slog.Logger, mfa *services.MfaService) http.HandlerFunc {
+	return api(logger, "http.handlers.AdminResetMfa()", func(log *slog.Logger, w http.ResponseWriter, r *http.Request) error {
+		id := r.PathValue("id")
+		if err := mfa.Reset(r.Context(), id); err != nil {
+			return serviceError(err, userCodes)
+		}
+		log.Info(MsgMfaReset, slog.String("user_id", id), slog.Any("by", callerClaims(r)["sub"]))
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+}
+
+// mfaError maps MFA service errors to problem codes
+func mfaError(err error) error {
+	if errorHelper.KindOf(err) == errorHelper.InvalidCredentials {
+		return responses.NewError(responses.CodeInvalidOtp, err)
+	}
+	return serviceError(err, mfaCodes)
+}
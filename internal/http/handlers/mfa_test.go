@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base32"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/url"
+	"sso/internal/http/responses"
+	"sso/internal/services"
+	"sso/pkg/helpers/totpHelper"
+	"testing"
+	"time"
+)
+
+// enrollTotp enables TOTP of the env user and returns a code of the current step
+func enrollTotp(t *testing.T, env *oauthEnv) string {
+	t.Helper()
+	ctx := context.Background()
+	secret, _, err := env.mfa.Enroll(ctx, env.userId)
+	require.NoError(t, err)
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	require.NoError(t, err)
+	step := totpHelper.Step(time.Now())
+	_, err = env.mfa.Confirm(ctx, env.userId, totpHelper.Code(key, step-1, totpHelper.Digits))
+	require.NoError(t, err)
+	return totpHelper.Code(key, step, totpHelper.Digits)
+}
+
+func (e *oauthEnv) challenge(t *testing.T) string {
+	t.Helper()
+	user, err := services.Users(e.storage).Get(context.Background(), e.userId)
+	require.NoError(t, err)
+	token, err := e.mfa.Challenge(context.Background(), user)
+	require.NoError(t, err)
+	return token
+}
+
+func TestMfaFailuresLockAccount(t *testing.T) {
+	env := newOAuthEnv(t)
+	code := enrollTotp(t, env)
+	verify := MfaVerify(env.log, env.tokens, env.mfa)
+	token := Token(env.log, env.clients, env.tokens, env.codes, env.mfa)
+
+	//wrong codes on both endpoints are failed logins of the same account
+	challenge := env.challenge(t)
+	for i := 0; i < 2; i++ {
+		w := serve(t, verify, http.MethodPost, "/mfa/verify", `{"mfa_token":"`+challenge+`","code":"000000"}`)
+		requireProblem(t, w, http.StatusUnauthorized, responses.CodeMfaFailed)
+	}
+	challenge = env.challenge(t)
+	form := url.Values{"grant_type": {GrantTypeMfaOtp}, "mfa_token": {challenge}, "otp": {"000000"}}
+	for i := 0; i < 3; i++ {
+		w := postForm(t, token, "/token", form, "", "")
+		requireOAuthError(t, w, http.StatusBadRequest, responses.OAuthInvalidGrant)
+	}
+
+	//locked after AccountThreshold failures, the right code is not accepted
+	challenge = env.challenge(t)
+	w := postForm(t, token, "/token", url.Values{"grant_type": {GrantTypeMfaOtp}, "mfa_token": {challenge}, "otp": {code}}, "", "")
+	requireOAuthError(t, w, http.StatusTooManyRequests, responses.OAuthInvalidGrant)
+	require.NotEmpty(t, w.Header().Get("Retry-After"))
+	w = serve(t, verify, http.MethodPost, "/mfa/verify", `{"mfa_token":"`+challenge+`","code":"`+code+`"}`)
+	requireProblem(t, w, http.StatusTooManyRequests, responses.CodeLocked)
+}
+
+func TestMfaEnrollRequiresRecentAuth(t *testing.T) {
+	ctx := context.Background()
+	env := newOAuthEnv(t)
+	enroll := RequireRecentAuth(env.log, env.keys, env.revocations, 15*time.Minute)(MfaEnroll(env.log, env.mfa))
+	issue := func(grant services.Grant) string {
+		grant.UserId = env.userId
+		pair, err := env.tokens.Issue(ctx, grant)
+		require.NoError(t, err)
+		return pair.AccessToken
+	}
+
+	//tokens of OAuth clients and old logins can't replace the TOTP secret
+	w := serveAs(t, enroll, issue(services.Grant{ClientId: "app", Scope: "openid", AuthTime: time.Now()}), http.MethodPost, "/mfa/totp/enroll")
+	requireProblem(t, w, http.StatusForbidden, responses.CodeForbidden)
+	w = serveAs(t, enroll, issue(services.Grant{AuthTime: time.Now().Add(-time.Hour)}), http.MethodPost, "/mfa/totp/enroll")
+	requireProblem(t, w, http.StatusUnauthorized, responses.CodeLoginRequired)
+	user, err := services.Users(env.storage).Get(ctx, env.userId)
+	require.NoError(t, err)
+	require.Empty(t, user.Mfa.TotpSecret)
+
+	w = serveAs(t, enroll, issue(services.Grant{AuthTime: time.Now()}), http.MethodPost, "/mfa/totp/enroll")
+	require.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"sso/internal/http/requests"
+	"sso/internal/http/responses"
+	"sso/internal/services"
+	"sso/pkg/helpers/jsonHelper"
+)
+
+const (
+	ErrorRefresh      = "Refresh error"
+	MsgRefreshedToken = "The token has been refreshed"
+)
+
+func Refresh(logger *slog.Logger, tokens *services.TokensService) http.HandlerFunc {
+	return api(logger, "http.handlers.refresh()", func(log *slog.Logger, w http.ResponseWriter, r *http.Request) error {
+		params, err := jsonHelper.Decode(&requests.Refresh{}, r.Body)
+		if err != nil {
+			return badRequest(err)
+		}
+		if params.RefreshToken == "" {
+			return responses.NewError(responses.CodeMissingRefreshToken, nil)
+		}
+		pair, err := tokens.Refresh(r.Context(), params.RefreshToken, "")
+		if err != nil {
+			return credentialsError(err, responses.CodeInvalidRefreshToken)
+		}
+		log.Info(MsgRefreshedToken)
+		writeJson(log, w, http.StatusOK, &responses.Auth{
+			Response:     responses.Response{Status: responses.StatusOk},
+			Token:        pair.AccessToken,
+			RefreshToken: pair.RefreshToken,
+			ExpiresIn:    pair.ExpiresIn,
+		})
+		return nil
+	})
+}
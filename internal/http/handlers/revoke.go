@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"sso/internal/http/responses"
+	"sso/internal/services"
+	"sso/pkg/helpers/errorHelper"
+	"sso/pkg/helpers/jsonHelper"
+	"sso/pkg/helpers/slogHelper"
+)
+
+const (
+	ErrorRevoke     = "Revoke error"
+	MsgRevokedToken = "The token has been revoked"
+)
+
+// Revoke implements RFC 7009 token revocation, parameters are form encoded.
+// Confidential clients authenticate, public clients pass client_id, requests without client_id are first party.
+// Only tokens issued to the caller are revoked.
+func Revoke(logger *slog.Logger, clients *services.ClientsService, revocations *services.RevocationsService) http.HandlerFunc {
+	//setup logger
+	logger = slogHelper.AddOperation(logger, "http.handlers.revoke()")
+	//return function
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := slogHelper.AddRequestId(logger, r.Context())
+		client, err := tokenClient(r, clients)
+		if err != nil {
+			log.Warn(ErrorClientAuth, slogHelper.GetErrAttr(err))
+			w.Header().Set("WWW-Authenticate", `Basic realm="revoke"`)
+			writeOAuthError(log, w, http.StatusUnauthorized, responses.OAuthInvalidClient, "")
+			return
+		}
+		clientId := ""
+		if client != nil {
+			clientId = client.ClientId
+		}
+		token := r.PostFormValue("token")
+		hint := r.PostFormValue("token_type_hint")
+		if token == "" {
+			writeOAuthError(log, w, http.StatusBadRequest, responses.OAuthInvalidRequest, "empty token")
+			return
+		}
+		switch hint {
+		case "", services.TokenTypeAccess, services.TokenTypeRefresh:
+		default:
+			writeOAuthError(log, w, http.StatusBadRequest, responses.OAuthUnsupportedTokenType, hint)
+			return
+		}
+		err = revocations.Revoke(r.Context(), token, hint, clientId)
+		if errorHelper.KindOf(err) == errorHelper.InvalidCredentials {
+			writeOAuthError(log, w, http.StatusBadRequest, responses.OAuthUnauthorizedClient, services.ErrorForeignToken)
+			return
+		} else if err != nil {
+			log.Error(ErrorRevoke, slogHelper.GetErrAttr(err))
+			writeOAuthError(log, w, http.StatusServiceUnavailable, responses.OAuthServerError, "")
+			return
+		}
+		log.Info(MsgRevokedToken)
+		//invalid and unknown tokens are also answered with 200, RFC 7009 section 2.2
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func writeOAuthError(log *slog.Logger, w http.ResponseWriter, code int, oauthError string, description string) {
+	log.Warn(oauthError, slog.String("description", description))
+	w.Header().Set("Cache-Control", "no-store")
+	err := jsonHelper.WriteResponseCode(&responses.OAuthError{
+		Error:            oauthError,
+		ErrorDescription: description,
+	}, code, w)
+	if err != nil {
+		log.Error(ErrorWriteResponse, slogHelper.GetErrAttr(err))
+	}
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sso/internal/config"
+	"sso/internal/http/responses"
+	"sso/internal/models"
+	"sso/internal/services"
+	"sso/internal/storage/memory"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testClientSecret = "s3cret-s3cret"
+
+// oauthEnv is a set of services sharing one memory storage with a confidential client "app",
+// a public client "spa" and a user "alice"
+type oauthEnv struct {
+	log         *slog.Logger
+	storage     *memory.Storage
+	keys        *services.KeysService
+	tokens      *services.TokensService
+	revocations *services.RevocationsService
+	clients     *services.ClientsService
+	codes       *services.CodesService
+	mfa         *services.MfaService
+	lockout     *services.LockoutService
+	userId      string
+}
+
+func newOAuthEnv(t *testing.T) *oauthEnv {
+	t.Helper()
+	ctx := context.Background()
+	s := memory.New()
+	tokensConfig := config.TokensConfig{
+		Issuer:         "https://sso.example.com",
+		AccessTTL:      time.Hour,
+		RefreshTTL:     24 * time.Hour,
+		CodeTTL:        time.Minute,
+		RevocationSync: time.Minute,
+	}
+	keys := services.Keys(s, config.KeysConfig{RotationInterval: 24 * time.Hour, PublishAhead: time.Hour, GracePeriod: 2 * time.Hour})
+	env := &oauthEnv{
+		log:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+		storage:     s,
+		keys:        keys,
+		tokens:      services.Tokens(s, keys, tokensConfig, services.Groups(s, config.GroupsConfig{})),
+		revocations: services.Revocations(s, keys, tokensConfig),
+		clients:     services.Clients(s),
+		codes:       services.Codes(s, tokensConfig),
+		lockout:     services.Lockout(s, config.LockoutConfig{AccountThreshold: 5, IpThreshold: 20, BaseDelay: time.Second, MaxDelay: time.Minute, Window: time.Hour}),
+	}
+	env.mfa = services.Mfa(s, env.tokens, keys, env.revocations, env.lockout, config.MfaConfig{Issuer: "sso", ChallengeTTL: 5 * time.Minute, MaxAttempts: 3})
+	for clientId, secret := range map[string]string{"app": testClientSecret, "spa": ""} {
+		_, err := env.clients.Add(ctx, &models.Client{
+			ClientId:     clientId,
+			Name:         clientId,
+			RedirectUris: []string{"https://" + clientId + ".example.com/callback"},
+			Scopes:       []string{"openid", "profile"},
+		}, secret)
+		require.NoError(t, err)
+	}
+	var err error
+	env.userId, err = services.Users(s).Add(ctx, &models.User{Login: "alice", Password: "Passw0rd!"})
+	require.NoError(t, err)
+	return env
+}
+
+// postForm sends form to h, with HTTP Basic client credentials when clientId and secret are set
+func postForm(t *testing.T, h http.Handler, target string, form url.Values, clientId string, secret string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, target, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if clientId != "" && secret != "" {
+		r.SetBasicAuth(clientId, secret)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func requireOAuthError(t *testing.T, w *httptest.ResponseRecorder, status int, code string) {
+	t.Helper()
+	require.Equal(t, status, w.Code)
+	oauthError := responses.OAuthError{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &oauthError))
+	require.Equal(t, code, oauthError.Error)
+}
+
+func TestRevoke(t *testing.T) {
+	ctx := context.Background()
+	env := newOAuthEnv(t)
+	h := Revoke(env.log, env.clients, env.revocations)
+	pair, err := env.tokens.Issue(ctx, services.Grant{UserId: env.userId, ClientId: "app"})
+	require.NoError(t, err)
+	form := url.Values{"token": {pair.RefreshToken}}
+
+	w := postForm(t, h, "/revoke", form, "app", "wrong")
+	requireOAuthError(t, w, http.StatusUnauthorized, responses.OAuthInvalidClient)
+	require.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+	w = postForm(t, h, "/revoke", url.Values{"token": {pair.RefreshToken}, "client_id": {"unknown"}}, "", "")
+	requireOAuthError(t, w, http.StatusUnauthorized, responses.OAuthInvalidClient)
+	//confidential client can't identify itself by client_id only
+	w = postForm(t, h, "/revoke", url.Values{"token": {pair.RefreshToken}, "client_id": {"app"}}, "", "")
+	requireOAuthError(t, w, http.StatusUnauthorized, responses.OAuthInvalidClient)
+
+	//tokens of app are foreign to the public client and to the first party
+	w = postForm(t, h, "/revoke", url.Values{"token": {pair.RefreshToken}, "client_id": {"spa"}}, "", "")
+	requireOAuthError(t, w, http.StatusBadRequest, responses.OAuthUnauthorizedClient)
+	w = postForm(t, h, "/revoke", url.Values{"token": {pair.AccessToken}}, "", "")
+	requireOAuthError(t, w, http.StatusBadRequest, responses.OAuthUnauthorizedClient)
+
+	w = postForm(t, h, "/revoke", form, "app", testClientSecret)
+	require.Equal(t, http.StatusOK, w.Code)
+	_, err = env.tokens.Refresh(ctx, pair.RefreshToken, "app")
+	require.Error(t, err)
+	//unknown tokens are answered with 200
+	w = postForm(t, h, "/revoke", url.Values{"token": {"unknown"}, "client_id": {"spa"}}, "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+}
@@ -4,10 +4,17 @@ import (
 	"log/slog"
 	"net/http"
 	"sso/internal/http/responses"
+	"sso/internal/services"
+	"sso/pkg/helpers/errorHelper"
 	jsonHelper "sso/pkg/helpers/jsonHelper"
 	slogHelper "sso/pkg/helpers/slogHelper"
 )
 
+const (
+	ErrorNotReady = "Service is not ready"
+)
+
+// Status reports the process is alive, it is the liveness probe and doesn't depend on storage
 func Status(logger *slog.Logger) http.HandlerFunc {
 	//setup logger
 	logger = slogHelper.AddOperation(logger, "http.handlers.test.status()")
@@ -23,3 +30,34 @@ func Status(logger *slog.Logger) http.HandlerFunc {
 		}
 	}
 }
+
+// Ready is the readiness probe, it responds 503 with the failed components until the service can serve requests
+func Ready(logger *slog.Logger, health *services.HealthService) http.HandlerFunc {
+	//setup logger
+	logger = slogHelper.AddOperation(logger, "http.handlers.status.Ready()")
+	//return function
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := slogHelper.AddRequestId(logger, r.Context())
+		components, ready := health.Ready(r.Context())
+		resp := &responses.Readiness{
+			Response:   responses.Response{Status: responses.StatusOk},
+			Components: make(map[string]responses.Component, len(components)),
+		}
+		for _, c := range components {
+			component := responses.Component{Status: responses.StatusOk, DurationMs: c.Duration.Milliseconds()}
+			if c.Err != nil {
+				//only the kind is exposed, details are logged
+				component.Status = responses.StatusUnavailable
+				component.Error = errorHelper.KindOf(c.Err).String()
+				log.Warn(ErrorNotReady, slog.String("component", c.Component), slogHelper.GetErrAttr(c.Err))
+			}
+			resp.Components[c.Component] = component
+		}
+		code := http.StatusOK
+		if !ready {
+			resp.Status = responses.StatusUnavailable
+			code = http.StatusServiceUnavailable
+		}
+		writeJson(log, w, code, resp)
+	}
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"sso/internal/http/responses"
+	"sso/internal/models"
+	"sso/internal/services"
+	"sso/pkg/helpers/errorHelper"
+	"sso/pkg/helpers/jsonHelper"
+	"sso/pkg/helpers/slogHelper"
+	"time"
+)
+
+const (
+	GrantTypeRefreshToken      = "refresh_token"
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeClientCredentials = "client_credentials"
+	GrantTypeMfaOtp            = "urn:sso:params:oauth:grant-type:mfa-otp" //second step of first party login for users with MFA
+)
+
+const (
+	ErrorExchangeCode = "Authorization code exchange error"
+	ErrorFirstParty   = "grant type is only allowed for first party login"
+	MsgExchangedCode  = "The authorization code has been exchanged for a token"
+	MsgIssuedClient   = "The client has been issued a token"
+)
+
+// Token is RFC 6749 token endpoint, parameters are form encoded
+func Token(logger *slog.Logger, clients *services.ClientsService, tokens *services.TokensService, codes *services.CodesService, mfa *services.MfaService) http.HandlerFunc {
+	//setup logger
+	logger = slogHelper.AddOperation(logger, "http.handlers.token()")
+	//return function
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := slogHelper.AddRequestId(logger, r.Context())
+		client, err := tokenClient(r, clients)
+		if err != nil {
+			log.Warn(ErrorClientAuth, slogHelper.GetErrAttr(err))
+			writeOAuthError(log, w, http.StatusUnauthorized, responses.OAuthInvalidClient, "")
+			return
+		}
+		clientId := ""
+		if client != nil {
+			clientId = client.ClientId
+		}
+		var pair *services.TokenPair
+		switch grant := r.PostFormValue("grant_type"); grant {
+		case GrantTypeMfaOtp:
+			//the challenge comes from first party login, its tokens have no client and would not refresh for one
+			if client != nil {
+				writeOAuthError(log, w, http.StatusBadRequest, responses.OAuthUnauthorizedClient, ErrorFirstParty)
+				return
+			}
+			challenge, otp, recovery := r.PostFormValue("mfa_token"), r.PostFormValue("otp"), r.PostFormValue("recovery_code")
+			if challenge == "" || (otp == "" && recovery == "") {
+				writeOAuthError(log, w, http.StatusBadRequest, responses.OAuthInvalidRequest, responses.Title(responses.CodeMissingMfa))
+				return
+			}
+			var user *models.User
+			if user, err = mfa.Verify(r.Context(), challenge, otp, recovery, clientIp(r)); retryAfter(w, err) {
+				log.Warn(ErrorMfa, slogHelper.GetErrAttr(err))
+				writeOAuthError(log, w, http.StatusTooManyRequests, responses.OAuthInvalidGrant, responses.Title(responses.CodeLocked))
+				return
+			} else if err != nil {
+				writeGrantError(log, w, ErrorMfa, err, responses.Title(responses.CodeMfaFailed))
+				return
+			}
+			if pair, err = tokens.Issue(r.Context(), services.Grant{UserId: user.Id, AuthTime: time.Now(), Acr: services.AcrMfa}); err != nil {
+				log.Error(ErrorAuth, slogHelper.GetErrAttr(err))
+				writeOAuthError(log, w, http.StatusInternalServerError, responses.OAuthServerError, "")
+				return
+			}
+			log.Info(MsgIssuedToken, slog.String("user_login", user.Login))
+		case GrantTypeRefreshToken:
+			refresh := r.PostFormValue("refresh_token")
+			if refresh == "" {
+				writeOAuthError(log, w, http.StatusBadRequest, responses.OAuthInvalidRequest, responses.Title(responses.CodeMissingRefreshToken))
+				return
+			}
+			if pair, err = tokens.Refresh(r.Context(), refresh, clientId); err != nil {
+				writeGrantError(log, w, ErrorRefresh, err, responses.Title(responses.CodeInvalidRefreshToken))
+				return
+			}
+			log.Info(MsgRefreshedToken)
+		case GrantTypeAuthorizationCode:
+			if client == nil {
+				writeOAuthError(log, w, http.StatusUnauthorized, responses.OAuthInvalidClient, "")
+				return
+			}
+			var code *models.AuthCode
+			code, err = codes.Exchange(r.Context(), r.PostFormValue("code"), clientId, r.PostFormValue("redirect_uri"), r.PostFormValue("code_verifier"))
+			if err != nil {
+				writeGrantError(log, w, ErrorExchangeCode, err, "")
+				return
+			}
+			if pair, err = tokens.Issue(r.Context(), services.Grant{
+				UserId:   code.User,
+				ClientId: code.ClientId,
+				Scope:    code.Scope,
+				Family:   code.Family,
+				Nonce:    code.Nonce,
+				AuthTime: code.AuthTime,
+				Acr:      code.Acr,
+			}); err != nil {
+				log.Error(ErrorExchangeCode, slogHelper.GetErrAttr(err))
+				writeOAuthError(log, w, http.StatusInternalServerError, responses.OAuthServerError, "")
+				return
+			}
+			log.Info(MsgExchangedCode, slog.String("client_id", clientId))
+		case GrantTypeClientCredentials:
+			//only confidential clients, tokenClient has already checked the secret
+			if client == nil || client.IsPublic() {
+				writeOAuthError(log, w, http.StatusUnauthorized, responses.OAuthInvalidClient, "")
+				return
+			}
+			var scope string
+			if scope, err = clients.Scope(client, r.PostFormValue("scope")); err != nil {
+				writeOAuthError(log, w, http.StatusBadRequest, responses.OAuthInvalidScope, services.ErrorScopeNotAllowed)
+				return
+			}
+			if pair, err = tokens.IssueClient(r.Context(), clientId, scope); err != nil {
+				log.Error(ErrorAuth, slogHelper.GetErrAttr(err))
+				writeOAuthError(log, w, http.StatusInternalServerError, responses.OAuthServerError, "")
+				return
+			}
+			log.Info(MsgIssuedClient, slog.String("client_id", clientId))
+		default:
+			writeOAuthError(log, w, http.StatusBadRequest, responses.OAuthUnsupportedGrantType, grant)
+			return
+		}
+		writeTokenResponse(log, w, pair)
+	}
+}
+
+// writeGrantError answers rejected grant with invalid_grant, failures to check it are server errors
+func writeGrantError(log *slog.Logger, w http.ResponseWriter, msg string, err error, description string) {
+	if !rejected(err) {
+		log.Error(msg, slogHelper.GetErrAttr(err), slog.Any("stack", errorHelper.Stack(err)))
+		writeOAuthError(log, w, http.StatusInternalServerError, responses.OAuthServerError, "")
+		return
+	}
+	log.Warn(msg, slogHelper.GetErrAttr(err))
+	writeOAuthError(log, w, http.StatusBadRequest, responses.OAuthInvalidGrant, description)
+}
+
+func writeTokenResponse(log *slog.Logger, w http.ResponseWriter, pair *services.TokenPair) {
+	w.Header().Set("Cache-Control", "no-store")
+	err := jsonHelper.WriteResponse(&responses.Token{
+		AccessToken:  pair.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    pair.ExpiresIn,
+		RefreshToken: pair.RefreshToken,
+		IdToken:      pair.IdToken,
+	}, w)
+	if err != nil {
+		log.Error(ErrorWriteResponse, slogHelper.GetErrAttr(err))
+	}
+}
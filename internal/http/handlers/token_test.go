@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/url"
+	"sso/internal/http/responses"
+	"testing"
+)
+
+func TestTokenClientCredentials(t *testing.T) {
+	env := newOAuthEnv(t)
+	h := Token(env.log, env.clients, env.tokens, env.codes, env.mfa)
+	form := url.Values{"grant_type": {GrantTypeClientCredentials}}
+
+	w := postForm(t, h, "/token", form, "app", "wrong")
+	requireOAuthError(t, w, http.StatusUnauthorized, responses.OAuthInvalidClient)
+	//neither public nor first party callers have credentials
+	w = postForm(t, h, "/token", url.Values{"grant_type": {GrantTypeClientCredentials}, "client_id": {"spa"}}, "", "")
+	requireOAuthError(t, w, http.StatusUnauthorized, responses.OAuthInvalidClient)
+	w = postForm(t, h, "/token", form, "", "")
+	requireOAuthError(t, w, http.StatusUnauthorized, responses.OAuthInvalidClient)
+	w = postForm(t, h, "/token", url.Values{"grant_type": {GrantTypeClientCredentials}, "scope": {"openid admin"}}, "app", testClientSecret)
+	requireOAuthError(t, w, http.StatusBadRequest, responses.OAuthInvalidScope)
+
+	w = postForm(t, h, "/token", url.Values{"grant_type": {GrantTypeClientCredentials}, "scope": {"profile"}}, "app", testClientSecret)
+	require.Equal(t, http.StatusOK, w.Code)
+	res := map[string]any{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	require.NotEmpty(t, res["access_token"])
+	require.NotContains(t, res, "refresh_token")
+}
+
+func TestTokenFirstPartyGrants(t *testing.T) {
+	env := newOAuthEnv(t)
+	h := Token(env.log, env.clients, env.tokens, env.codes, env.mfa)
+	code := enrollTotp(t, env)
+
+	w := postForm(t, h, "/token", url.Values{"grant_type": {"password"}, "username": {"alice"}, "password": {"Passw0rd!"}}, "", "")
+	requireOAuthError(t, w, http.StatusBadRequest, responses.OAuthUnsupportedGrantType)
+
+	//clients would get a first party token, the challenge stays usable
+	challenge := env.challenge(t)
+	form := url.Values{"grant_type": {GrantTypeMfaOtp}, "mfa_token": {challenge}, "otp": {code}}
+	w = postForm(t, h, "/token", form, "app", testClientSecret)
+	requireOAuthError(t, w, http.StatusBadRequest, responses.OAuthUnauthorizedClient)
+	form.Set("client_id", "spa")
+	w = postForm(t, h, "/token", form, "", "")
+	requireOAuthError(t, w, http.StatusBadRequest, responses.OAuthUnauthorizedClient)
+
+	form.Del("client_id")
+	w = postForm(t, h, "/token", form, "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	res := map[string]any{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	refresh, _ := res["refresh_token"].(string)
+	require.NotEmpty(t, refresh)
+	//the first party token is refreshed without client
+	w = postForm(t, h, "/token", url.Values{"grant_type": {GrantTypeRefreshToken}, "refresh_token": {refresh}}, "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+}
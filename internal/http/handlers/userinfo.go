@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"sso/internal/http/responses"
+	"sso/internal/services"
+	"sso/internal/storage"
+	"sso/pkg/helpers/jsonHelper"
+	"sso/pkg/helpers/slogHelper"
+)
+
+const (
+	ErrorUserinfo = "Userinfo error"
+)
+
+// Userinfo is OIDC userinfo endpoint protected by bearer access token
+func Userinfo(logger *slog.Logger, storage storage.Storage, keys *services.KeysService, revocations *services.RevocationsService) http.HandlerFunc {
+	//setup logger
+	logger = slogHelper.AddOperation(logger, "http.handlers.userinfo()")
+	//return function
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := slogHelper.AddRequestId(logger, r.Context())
+		token := bearerToken(r)
+		if token == "" {
+			writeBearerError(log, w, http.StatusUnauthorized, responses.OAuthInvalidRequest)
+			return
+		}
+		claims, err := services.Claims(r.Context(), token, keys, revocations)
+		if err != nil {
+			log.Warn(ErrorUserinfo, slogHelper.GetErrAttr(err))
+			writeBearerError(log, w, http.StatusUnauthorized, responses.OAuthInvalidToken)
+			return
+		}
+		info, err := services.Userinfo(r.Context(), claims, storage)
+		if err != nil {
+			log.Warn(ErrorUserinfo, slogHelper.GetErrAttr(err))
+			writeBearerError(log, w, http.StatusUnauthorized, responses.OAuthInvalidToken)
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		if err := jsonHelper.WriteResponse(info, w); err != nil {
+			log.Error(ErrorWriteResponse, slogHelper.GetErrAttr(err))
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sso/internal/config"
+	"sso/internal/http/responses"
+	"sso/internal/services"
+	"testing"
+	"time"
+)
+
+func getUserinfo(t *testing.T, h http.Handler, token string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestUserinfo(t *testing.T) {
+	ctx := context.Background()
+	env := newOAuthEnv(t)
+	h := Userinfo(env.log, env.storage, env.keys, env.revocations)
+	pair, err := env.tokens.Issue(ctx, services.Grant{UserId: env.userId, ClientId: "app", Scope: "openid profile"})
+	require.NoError(t, err)
+
+	w := getUserinfo(t, h, pair.AccessToken)
+	require.Equal(t, http.StatusOK, w.Code)
+	info := map[string]any{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &info))
+	require.Equal(t, env.userId, info["sub"])
+	require.Equal(t, "alice", info["preferred_username"])
+
+	w = getUserinfo(t, h, "")
+	requireOAuthError(t, w, http.StatusUnauthorized, responses.OAuthInvalidRequest)
+	require.Contains(t, w.Header().Get("WWW-Authenticate"), "Bearer")
+	//ID token is not an access token
+	w = getUserinfo(t, h, pair.IdToken)
+	requireOAuthError(t, w, http.StatusUnauthorized, responses.OAuthInvalidToken)
+
+	require.NoError(t, env.revocations.Revoke(ctx, pair.AccessToken, services.TokenTypeAccess, "app"))
+	w = getUserinfo(t, h, pair.AccessToken)
+	requireOAuthError(t, w, http.StatusUnauthorized, responses.OAuthInvalidToken)
+	require.Contains(t, w.Header().Get("WWW-Authenticate"), responses.OAuthInvalidToken)
+
+	//token signed by a valid key but past its exp
+	expired := services.Tokens(env.storage, env.keys, config.TokensConfig{Issuer: "https://sso.example.com", AccessTTL: -time.Minute}, services.Groups(env.storage, config.GroupsConfig{}))
+	pair, err = expired.Issue(ctx, services.Grant{UserId: env.userId, ClientId: "app"})
+	require.NoError(t, err)
+	w = getUserinfo(t, h, pair.AccessToken)
+	requireOAuthError(t, w, http.StatusUnauthorized, responses.OAuthInvalidToken)
+}
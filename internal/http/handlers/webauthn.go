@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"sso/internal/http/requests"
+	"sso/internal/http/responses"
+	"sso/internal/models"
+	"sso/internal/services"
+	"sso/pkg/helpers/errorHelper"
+	"sso/pkg/helpers/jsonHelper"
+	"sso/pkg/helpers/webauthnHelper"
+	"time"
+)
+
+const (
+	ErrorWebauthn           = "WebAuthn error"
+	MsgCredentialRegistered = "The user has registered a security key"
+	MsgCredentialDeleted    = "The user has deleted a security key"
+)
+
+// webauthnCodes are problem codes of WebAuthn API
+var webauthnCodes = resourceCodes{
+	notFound: responses.CodeCredentialNotFound,
+	exists:   responses.CodeCredentialExists,
+	invalid:  responses.CodeNoCredentials,
+}
+
+// WebauthnRegisterBegin returns credential creation options for the caller
+func WebauthnRegisterBegin(logger *slog.Logger, webauthn *services.WebauthnService) http.HandlerFunc {
+	return api(logger, "http.handlers.WebauthnRegisterBegin()", func(log *slog.Logger, w http.ResponseWriter, r *http.Request) error {
+		options, ceremony, err := webauthn.BeginRegistration(r.Context(), callerSubject(r))
+		if err != nil {
+			return webauthnError(err, responses.CodeInvalidAttestation)
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		writeJson(log, w, http.StatusOK, &responses.WebauthnCreation{
+			Response:      responses.Response{Status: responses.StatusOk},
+			Options:       options,
+			CeremonyToken: ceremony,
+		})
+		return nil
+	})
+}
+
+// WebauthnRegisterFinish verifies the new credential of the caller and stores it
+func WebauthnRegisterFinish(logger *slog.Logger, webauthn *services.WebauthnService) http.HandlerFunc {
+	return api(logger, "http.handlers.WebauthnRegisterFinish()", func(log *slog.Logger, w http.ResponseWriter, r *http.Request) error {
+		params, err := jsonHelper.Decode(&requests.WebauthnRegister{}, r.Body)
+		var attestation *services.Attestation
+		if err == nil {
+			attestation, err = attestationResponse(&params.Credential)
+		}
+		if err != nil {
+			return badRequest(err)
+		}
+		userId := callerSubject(r)
+		credential, err := webauthn.FinishRegistration(r.Context(), userId, params.CeremonyToken, params.Name, attestation)
+		if err != nil {
+			return webauthnError(err, responses.CodeInvalidAttestation)
+		}
+		log.Info(MsgCredentialRegistered, slog.String("user_id", userId), slog.String("credential_id", credential.Id))
+		writeJson(log, w, http.StatusCreated, &responses.WebauthnCredential{
+			Response:   responses.Response{Status: responses.StatusOk},
+			Credential: credentialResponse(credential),
+		})
+		return nil
+	})
+}
+
+// WebauthnLoginBegin returns credential request options, for passwordless login or for the second factor with mfa_token
+func WebauthnLoginBegin(logger *slog.Logger, webauthn *services.WebauthnService) http.HandlerFunc {
+	return api(logger, "http.handlers.WebauthnLoginBegin()", func(log *slog.Logger, w http.ResponseWriter, r *http.Request) error {
+		params, err := jsonHelper.Decode(&requests.WebauthnLoginBegin{}, r.Body)
+		if err != nil {
+			return badRequest(err)
+		}
+		options, ceremony, err := webauthn.BeginLogin(r.Context(), params.MfaToken)
+		if err != nil {
+			return webauthnError(err, responses.CodeWebauthnFailed)
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		writeJson(log, w, http.StatusOK, &responses.WebauthnRequest{
+			Response:      responses.Response{Status: responses.StatusOk},
+			Options:       options,
+			CeremonyToken: ceremony,
+		})
+		return nil
+	})
+}
+
+// WebauthnLoginFinish verifies the assertion and issues tokens
+func WebauthnLoginFinish(logger *slog.Logger, tokens *services.TokensService, webauthn *services.WebauthnService) http.HandlerFunc {
+	return api(logger, "http.handlers.WebauthnLoginFinish()", func(log *slog.Logger, w http.ResponseWriter, r *http.Request) error {
+		params, err := jsonHelper.Decode(&requests.WebauthnLogin{}, r.Body)
+		var assertion *services.Assertion
+		if err == nil {
+			assertion, err = assertionResponse(&params.Credential)
+		}
+		if err != nil {
+			return badRequest(err)
+		}
+		user, acr, err := webauthn.FinishLogin(r.Context(), params.CeremonyToken, assertion)
+		if err != nil {
+			return credentialsError(err, responses.CodeWebauthnFailed)
+		}
+		pair, err := tokens.Issue(r.Context(), services.Grant{UserId: user.Id, AuthTime: time.Now(), Acr: acr})
+		if err != nil {
+			return err
+		}
+		log.Info(MsgIssuedToken, slog.String("user_login", user.Login), slog.String("acr", acr))
+		writeJson(log, w, http.StatusOK, &responses.Auth{
+			Response:     responses.Response{Status: responses.StatusOk},
+			Token:        pair.AccessToken,
+			RefreshToken: pair.RefreshToken,
+			ExpiresIn:    pair.ExpiresIn,
+		})
+		return nil
+	})
+}
+
+// WebauthnCredentials lists security keys of the caller
+func WebauthnCredentials(logger *slog.Logger, webauthn *services.WebauthnService) http.HandlerFunc {
+	return api(logger, "http.handlers.WebauthnCredentials()", func(log *slog.Logger, w http.ResponseWriter, r *http.Request) error {
+		list, err := webauthn.Credentials(r.Context(), callerSubject(r))
+		if err != nil {
+			return serviceError(err, webauthnCodes)
+		}
+		resp := &responses.WebauthnCredentials{
+			Response:    responses.Response{Status: responses.StatusOk},
+			Credentials: make([]responses.Credential, 0, len(list)),
+		}
+		for _, c := range list {
+			resp.Credentials = append(resp.Credentials, *credentialResponse(c))
+		}
+		writeJson(log, w, http.StatusOK, resp)
+		return nil
+	})
+}
+
+// WebauthnDeleteCredential removes security key of the caller
+func WebauthnDeleteCredential(logger *slog.Logger, webauthn *services.WebauthnService) http.HandlerFunc {
+	return api(logger, "http.handlers.WebauthnDeleteCredential()", func(log *slog.Logger, w http.ResponseWriter, r *http.Request) error {
+		userId, id := callerSubject(r), r.PathValue("id")
+		if err := webauthn.DeleteCredential(r.Context(), userId, id); err != nil {
+			return serviceError(err, webauthnCodes)
+		}
+		log.Info(MsgCredentialDeleted, slog.String("user_id", userId), slog.String("credential_id", id))
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+}
+
+func credentialResponse(c *models.Credential) *responses.Credential {
+	return &responses.Credential{
+		Id:         c.Id,
+		Name:       c.Name,
+		CreatedAt:  c.CreatedAt,
+		LastUsedAt: c.LastUsedAt,
+	}
+}
+
+// attestationResponse decodes base64url fields of registration result
+func attestationResponse(c *requests.PublicKeyCredential) (*services.Attestation, error) {
+	clientData, err := webauthnHelper.Decode(c.Response.ClientDataJSON)
+	if err != nil {
+		return nil, err
+	}
+	attestation, err := webauthnHelper.Decode(c.Response.AttestationObject)
+	if err != nil {
+		return nil, err
+	}
+	return &services.Attestation{
+		CredentialId:      c.Id,
+		ClientDataJSON:    clientData,
+		AttestationObject: attestation,
+	}, nil
+}
+
+// assertionResponse decodes base64url fields of login result
+func assertionResponse(c *requests.PublicKeyCredential) (*services.Assertion, error) {
+	res := &services.Assertion{CredentialId: c.Id}
+	fields := []struct {
+		src string
+		dst *[]byte
+	}{
+		{c.Response.ClientDataJSON, &res.ClientDataJSON},
+		{c.Response.AuthenticatorData, &res.AuthenticatorData},
+		{c.Response.Signature, &res.Signature},
+		{c.Response.UserHandle, &res.UserHandle},
+	}
+	for _, f := range fields {
+		b, err := webauthnHelper.Decode(f.src)
+		if err != nil {
+			return nil, err
+		}
+		*f.dst = b
+	}
+	return res, nil
+}
+
+// webauthnError maps WebAuthn service errors to problem codes, failed verification gets the given code
+func webauthnError(err error, failed string) error {
+	if kind := errorHelper.KindOf(err); kind == errorHelper.InvalidCredentials || kind == errorHelper.Expired {
+		return responses.NewError(failed, err)
+	}
+	return serviceError(err, webauthnCodes)
+}
@@ -8,3 +8,96 @@ type Auth struct {
 type Check struct {
 	Token string `json:"token"`
 }
+
+type Refresh struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type CreateUser struct {
+	Login         string   `json:"login"`
+	Password      string   `json:"password"`
+	Name          string   `json:"name"`
+	Email         string   `json:"email"`
+	EmailVerified bool     `json:"email_verified"`
+	Roles         []string `json:"roles"`
+}
+
+// UpdateUser is a partial update, omitted fields are left as is
+type UpdateUser struct {
+	Login         *string   `json:"login"`
+	Password      *string   `json:"password"`
+	Name          *string   `json:"name"`
+	Email         *string   `json:"email"`
+	EmailVerified *bool     `json:"email_verified"`
+	Roles         *[]string `json:"roles"`
+}
+
+type CreateRole struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// UpdateRole is a partial update, omitted fields are left as is
+type UpdateRole struct {
+	Description *string   `json:"description"`
+	Permissions *[]string `json:"permissions"`
+}
+
+type CreatePermission struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type CreateGroup struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Parents     []string `json:"parents"`
+}
+
+// UpdateGroup is a partial update, omitted fields are left as is
+type UpdateGroup struct {
+	Name        *string   `json:"name"`
+	Description *string   `json:"description"`
+	Parents     *[]string `json:"parents"`
+}
+
+type MfaConfirm struct {
+	Code string `json:"code"`
+}
+
+// MfaVerify is the second login step, either code or recovery_code is required
+type MfaVerify struct {
+	MfaToken     string `json:"mfa_token"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// PublicKeyCredential is WebAuthn credential serialized as by PublicKeyCredential.toJSON(), binary fields are base64url
+type PublicKeyCredential struct {
+	Id       string `json:"id"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON"`
+		AttestationObject string `json:"attestationObject"` //registration only
+		AuthenticatorData string `json:"authenticatorData"` //login only
+		Signature         string `json:"signature"`         //login only
+		UserHandle        string `json:"userHandle"`        //login only
+	} `json:"response"`
+}
+
+type WebauthnRegister struct {
+	CeremonyToken string              `json:"ceremony_token"`
+	Name          string              `json:"name"`
+	Credential    PublicKeyCredential `json:"credential"`
+}
+
+// WebauthnLoginBegin starts passwordless login, or second factor check if mfa_token is set
+type WebauthnLoginBegin struct {
+	MfaToken string `json:"mfa_token"`
+}
+
+type WebauthnLogin struct {
+	CeremonyToken string              `json:"ceremony_token"`
+	Credential    PublicKeyCredential `json:"credential"`
+}
@@ -0,0 +1,156 @@
+package responses
+
+import "net/http"
+
+// ProblemTypePrefix is the base of problem type URIs, the code is appended to it
+const ProblemTypePrefix = "urn:sso:problem:"
+
+// Problem codes are stable machine readable error identifiers, clients should rely on them and not on titles
+const (
+	CodeBadRequest          = "bad_request"
+	CodeMissingCredentials  = "missing_credentials"
+	CodeInvalidCredentials  = "invalid_credentials"
+	CodeLocked              = "locked"
+	CodeUnauthorized        = "unauthorized"
+	CodeInvalidToken        = "invalid_token"
+	CodeForbidden           = "forbidden"
+	CodeLoginRequired       = "login_required"
+	CodeMissingRefreshToken = "missing_refresh_token"
+	CodeInvalidRefreshToken = "invalid_refresh_token"
+	CodeSelfModify          = "self_modify"
+	CodeUserNotFound        = "user_not_found"
+	CodeUserExists          = "user_exists"
+	CodeInvalidUser         = "invalid_user"
+	CodeRoleNotFound        = "role_not_found"
+	CodeRoleExists          = "role_exists"
+	CodeInvalidRole         = "invalid_role"
+	CodePermissionNotFound  = "permission_not_found"
+	CodePermissionExists    = "permission_exists"
+	CodeInvalidPermission   = "invalid_permission"
+	CodeGroupNotFound       = "group_not_found"
+	CodeGroupExists         = "group_exists"
+	CodeInvalidGroup        = "invalid_group"
+	CodeMemberNotFound      = "member_not_found"
+	CodeMfaEnabled          = "mfa_enabled"
+	CodeMfaNotPending       = "mfa_not_pending"
+	CodeInvalidOtp          = "invalid_otp"
+	CodeMissingMfa          = "missing_mfa"
+	CodeMfaFailed           = "mfa_failed"
+	CodeWebauthnFailed      = "webauthn_failed"
+	CodeInvalidAttestation  = "invalid_attestation"
+	CodeCredentialNotFound  = "credential_not_found"
+	CodeCredentialExists    = "credential_exists"
+	CodeNoCredentials       = "no_credentials"
+	CodeTimeout             = "storage_timeout"
+	CodeCanceled            = "request_canceled"
+	CodeInternal            = "internal_error"
+)
+
+type problemType struct {
+	status int
+	title  string
+}
+
+var problemTypes = map[string]problemType{
+	CodeBadRequest:          {http.StatusBadRequest, "Malformed request"},
+	CodeMissingCredentials:  {http.StatusBadRequest, "Login and password are required"},
+	CodeInvalidCredentials:  {http.StatusUnauthorized, "Wrong login or password"},
+	CodeLocked:              {http.StatusTooManyRequests, "Too many failed logins, try again later"},
+	CodeUnauthorized:        {http.StatusUnauthorized, "Bearer token is required"},
+	CodeInvalidToken:        {http.StatusUnauthorized, "Token is invalid, expired or revoked"},
+	CodeForbidden:           {http.StatusForbidden, "Access denied"},
+	CodeLoginRequired:       {http.StatusUnauthorized, "Log in again to change security settings"},
+	CodeMissingRefreshToken: {http.StatusBadRequest, "Refresh token is required"},
+	CodeInvalidRefreshToken: {http.StatusUnauthorized, "Refresh token is invalid, expired or revoked"},
+	CodeSelfModify:          {http.StatusForbidden, "Can not disable or delete yourself"},
+	CodeUserNotFound:        {http.StatusNotFound, "User not found"},
+	CodeUserExists:          {http.StatusConflict, "User already exists"},
+	CodeInvalidUser:         {http.StatusBadRequest, "Invalid user data"},
+	CodeRoleNotFound:        {http.StatusNotFound, "Role not found"},
+	CodeRoleExists:          {http.StatusConflict, "Role already exists"},
+	CodeInvalidRole:         {http.StatusBadRequest, "Invalid role data"},
+	CodePermissionNotFound:  {http.StatusNotFound, "Permission not found"},
+	CodePermissionExists:    {http.StatusConflict, "Permission already exists"},
+	CodeInvalidPermission:   {http.StatusBadRequest, "Invalid permission data"},
+	CodeGroupNotFound:       {http.StatusNotFound, "Group not found"},
+	CodeGroupExists:         {http.StatusConflict, "Group already exists"},
+	CodeInvalidGroup:        {http.StatusBadRequest, "Invalid group data"},
+	CodeMemberNotFound:      {http.StatusNotFound, "Group or user not found"},
+	CodeMfaEnabled:          {http.StatusConflict, "MFA is already enabled"},
+	CodeMfaNotPending:       {http.StatusConflict, "MFA enrollment is not started"},
+	CodeInvalidOtp:          {http.StatusBadRequest, "Wrong code"},
+	CodeMissingMfa:          {http.StatusBadRequest, "MFA token and code are required"},
+	CodeMfaFailed:           {http.StatusUnauthorized, "Wrong or already used code or expired MFA token"},
+	CodeWebauthnFailed:      {http.StatusUnauthorized, "Security key verification failed"},
+	CodeInvalidAttestation:  {http.StatusBadRequest, "Security key registration failed"},
+	CodeCredentialNotFound:  {http.StatusNotFound, "Credential not found"},
+	CodeCredentialExists:    {http.StatusConflict, "Credential already registered"},
+	CodeNoCredentials:       {http.StatusBadRequest, "User has no security keys"},
+	CodeTimeout:             {http.StatusServiceUnavailable, "Storage did not respond in time, try again later"},
+	CodeCanceled:            {http.StatusRequestTimeout, "Request canceled"},
+	CodeInternal:            {http.StatusInternalServerError, "Internal error"},
+}
+
+// Problem is RFC 7807 problem details object, Code is an extension member with the problem code
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+}
+
+// NewProblem creates problem of the code, unknown codes are internal errors
+func NewProblem(code string, detail string, instance string) *Problem {
+	if _, ok := problemTypes[code]; !ok {
+		code = CodeInternal
+	}
+	return &Problem{
+		Type:     ProblemTypePrefix + code,
+		Title:    Title(code),
+		Status:   Status(code),
+		Detail:   detail,
+		Instance: instance,
+		Code:     code,
+	}
+}
+
+// Status is HTTP status of the problem code
+func Status(code string) int {
+	if t, ok := problemTypes[code]; ok {
+		return t.status
+	}
+	return http.StatusInternalServerError
+}
+
+// Title is short human readable summary of the problem code
+func Title(code string) string {
+	if t, ok := problemTypes[code]; ok {
+		return t.title
+	}
+	return problemTypes[CodeInternal].title
+}
+
+// ApiError is returned by API handlers and written as problem response with the status of its code.
+// The cause is only logged, Detail is sent to the client.
+type ApiError struct {
+	Code   string
+	Detail string
+	Err    error
+}
+
+func NewError(code string, err error) *ApiError {
+	return &ApiError{Code: code, Err: err}
+}
+
+func (e *ApiError) Error() string {
+	if e.Err == nil {
+		return e.Code
+	}
+	return e.Code + ": " + e.Err.Error()
+}
+
+func (e *ApiError) Unwrap() error {
+	return e.Err
+}
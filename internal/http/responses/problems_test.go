@@ -0,0 +1,32 @@
+package responses
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestProblemTypes(t *testing.T) {
+	for code, pt := range problemTypes {
+		if pt.status < 400 || pt.status > 599 || pt.title == "" {
+			t.Errorf("%s: bad problem type %+v", code, pt)
+		}
+	}
+	p := NewProblem("no_such_code", "", "/x")
+	if p.Code != CodeInternal || p.Status != http.StatusInternalServerError || p.Type != ProblemTypePrefix+CodeInternal {
+		t.Errorf("unknown code: %+v", p)
+	}
+}
+
+func TestApiError(t *testing.T) {
+	cause := errors.New("cause")
+	err := fmt.Errorf("wrapped: %w", NewError(CodeUserNotFound, cause))
+	var apiErr *ApiError
+	if !errors.As(err, &apiErr) || apiErr.Code != CodeUserNotFound {
+		t.Fatal("ApiError must be found in wrapped error")
+	}
+	if !errors.Is(err, cause) {
+		t.Fatal("ApiError must unwrap to its cause")
+	}
+}
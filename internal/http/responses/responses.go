@@ -1,23 +1,204 @@
 package responses
 
-const (
-	StatusOk    = "ok"
-	StatusError = "error"
+import (
+	"sso/pkg/helpers/webauthnHelper"
+	"time"
 )
 
+// Statuses of successful responses, errors are problem responses, see Problem
 const (
-	ErrorBadRequest         = "bad request"
-	ErrorEmptyLoginPassword = "empty login or password"
-	ErrorUserNotFound       = "user not found"
-	ErrorTokenNotValid      = "token signature is invalid"
+	StatusOk          = "ok"
+	StatusMfaRequired = "mfa_required"
+	StatusUnavailable = "unavailable"
 )
 
 type Response struct {
 	Status string `json:"status"`
-	Error  string `json:"error,omitempty"`
+}
+
+// Readiness is the readiness probe response, status is ok only if all components are ok
+type Readiness struct {
+	Response
+	Components map[string]Component `json:"components"`
+}
+
+// Component is the state of one component, error is the kind of its failure
+type Component struct {
+	Status     string `json:"status"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
 }
 
 type Auth struct {
 	Response
-	Token string `json:"token,omitempty"`
+	Token        string `json:"token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"`
+	MfaToken     string `json:"mfa_token,omitempty"` //set with StatusMfaRequired, pass it to /mfa/verify with the code
+}
+
+type MfaEnroll struct {
+	Response
+	Secret string `json:"secret,omitempty"`
+	Uri    string `json:"otpauth_uri,omitempty"`
+}
+
+type MfaConfirm struct {
+	Response
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+// WebauthnCreation holds options for navigator.credentials.create(), pass ceremony_token back with the result
+type WebauthnCreation struct {
+	Response
+	Options       *webauthnHelper.CreationOptions `json:"publicKey,omitempty"`
+	CeremonyToken string                          `json:"ceremony_token,omitempty"`
+}
+
+// WebauthnRequest holds options for navigator.credentials.get(), pass ceremony_token back with the result
+type WebauthnRequest struct {
+	Response
+	Options       *webauthnHelper.RequestOptions `json:"publicKey,omitempty"`
+	CeremonyToken string                         `json:"ceremony_token,omitempty"`
+}
+
+type Credential struct {
+	Id         string    `json:"id"`
+	Name       string    `json:"name"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
+type WebauthnCredential struct {
+	Response
+	Credential *Credential `json:"credential,omitempty"`
+}
+
+type WebauthnCredentials struct {
+	Response
+	Credentials []Credential `json:"credentials"`
+}
+
+// User is user representation for admin API, password hash is never exposed
+type User struct {
+	Id            string   `json:"id"`
+	Login         string   `json:"login"`
+	Name          string   `json:"name,omitempty"`
+	Email         string   `json:"email,omitempty"`
+	EmailVerified bool     `json:"email_verified"`
+	Roles         []string `json:"roles"`
+	Groups        []string `json:"groups"`
+	Disabled      bool     `json:"disabled"`
+}
+
+type AdminUser struct {
+	Response
+	User *User `json:"user,omitempty"`
+}
+
+type AdminUsers struct {
+	Response
+	Users  []User `json:"users"`
+	Total  int64  `json:"total"`
+	Offset int64  `json:"offset"`
+	Limit  int64  `json:"limit"`
+}
+
+type Role struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Permissions []string `json:"permissions"`
+}
+
+type AdminRole struct {
+	Response
+	Role *Role `json:"role,omitempty"`
+}
+
+type AdminRoles struct {
+	Response
+	Roles []Role `json:"roles"`
+}
+
+type Permission struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+type AdminPermission struct {
+	Response
+	Permission *Permission `json:"permission,omitempty"`
+}
+
+type AdminPermissions struct {
+	Response
+	Permissions []Permission `json:"permissions"`
+}
+
+type Group struct {
+	Id          string   `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Parents     []string `json:"parents"`
+}
+
+type AdminGroup struct {
+	Response
+	Group *Group `json:"group,omitempty"`
+}
+
+type AdminGroups struct {
+	Response
+	Groups []Group `json:"groups"`
+}
+
+// OAuth error codes, RFC 6749 section 5.2, RFC 6750 section 3.1 and RFC 7009 section 2.2.1
+const (
+	OAuthInvalidRequest       = "invalid_request"
+	OAuthInvalidClient        = "invalid_client"
+	OAuthUnauthorizedClient   = "unauthorized_client"
+	OAuthInvalidGrant         = "invalid_grant"
+	OAuthUnsupportedGrantType = "unsupported_grant_type"
+	OAuthInvalidScope         = "invalid_scope"
+	OAuthInvalidToken         = "invalid_token"
+	OAuthUnsupportedTokenType = "unsupported_token_type"
+	OAuthServerError          = "server_error"
+	//RFC 9470 step-up authentication, the user must log in again
+	OAuthInsufficientUserAuthentication = "insufficient_user_authentication"
+)
+
+type OAuthError struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// Token is RFC 6749 section 5.1 access token response
+type Token struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IdToken      string `json:"id_token,omitempty"`
+}
+
+// Discovery is OpenID Connect Discovery 1.0 provider metadata
+type Discovery struct {
+	Issuer                                    string   `json:"issuer"`
+	AuthorizationEndpoint                     string   `json:"authorization_endpoint,omitempty"`
+	TokenEndpoint                             string   `json:"token_endpoint"`
+	UserinfoEndpoint                          string   `json:"userinfo_endpoint"`
+	JwksUri                                   string   `json:"jwks_uri"`
+	IntrospectionEndpoint                     string   `json:"introspection_endpoint"`
+	RevocationEndpoint                        string   `json:"revocation_endpoint"`
+	ResponseTypesSupported                    []string `json:"response_types_supported"`
+	GrantTypesSupported                       []string `json:"grant_types_supported"`
+	CodeChallengeMethodsSupported             []string `json:"code_challenge_methods_supported,omitempty"`
+	SubjectTypesSupported                     []string `json:"subject_types_supported"`
+	IdTokenSigningAlgValuesSupported          []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported         []string `json:"token_endpoint_auth_methods_supported"`
+	IntrospectionEndpointAuthMethodsSupported []string `json:"introspection_endpoint_auth_methods_supported"`
+	RevocationEndpointAuthMethodsSupported    []string `json:"revocation_endpoint_auth_methods_supported"`
+	ScopesSupported                           []string `json:"scopes_supported"`
+	AcrValuesSupported                        []string `json:"acr_values_supported"`
+	ClaimsSupported                           []string `json:"claims_supported"`
 }
@@ -0,0 +1,152 @@
+// Package metrics holds Prometheus collectors of the service and the instrumentation hooks services
+// and storage call. Collectors are registered in Registry which is exposed by Handler.
+package metrics
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.mongodb.org/mongo-driver/event"
+	"net/http"
+	"sso/pkg/helpers/errorHelper"
+	"time"
+)
+
+const namespace = "sso"
+
+// Login failure reasons
+const (
+	ReasonUnknownUser   = "unknown_user"
+	ReasonWrongPassword = "wrong_password"
+	ReasonDisabled      = "disabled"
+	ReasonLocked        = "locked"
+	ReasonError         = "error"
+)
+
+// Token types besides token_use claim values of special purpose tokens
+const (
+	TokenAccess  = "access"
+	TokenRefresh = "refresh"
+)
+
+// Key rotation events
+const (
+	KeyActivated = "activated" //there was no active key and a new one became active at once
+	KeyPublished = "published" //next key was published ahead of its activation
+)
+
+// Bcrypt operations
+const (
+	BcryptHash    = "hash"
+	BcryptCompare = "compare"
+)
+
+// Registry has all collectors of the service, HTTP middleware registers its collectors here too
+var Registry = prometheus.NewRegistry()
+
+var (
+	logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logins_total",
+		Help:      "Password checks by result and failure reason.",
+	}, []string{"result", "reason"})
+	tokensIssued = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tokens_issued_total",
+		Help:      "Issued tokens by type.",
+	}, []string{"type"})
+	tokenVerifications = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "token_verifications_total",
+		Help:      "Token signature verifications by result.",
+	}, []string{"result"})
+	keyRotations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "key_rotations_total",
+		Help:      "Generated signing keys by rotation event.",
+	}, []string{"event"})
+	bcryptDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "bcrypt_duration_seconds",
+		Help:      "Duration of password hashing and comparison.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 10),
+	}, []string{"operation"})
+	mongoDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "mongo_command_duration_seconds",
+		Help:      "Duration of MongoDB commands by command name and result.",
+		Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 14),
+	}, []string{"command", "result"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		logins,
+		tokensIssued,
+		tokenVerifications,
+		keyRotations,
+		bcryptDuration,
+		mongoDuration,
+	)
+}
+
+// Handler serves Registry in Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// LoginSucceeded counts successful password check
+func LoginSucceeded() {
+	logins.WithLabelValues("success", "").Inc()
+}
+
+// LoginFailed counts failed password check by one of Reason* constants
+func LoginFailed(reason string) {
+	logins.WithLabelValues("failure", reason).Inc()
+}
+
+// TokenIssued counts issued token of the type
+func TokenIssued(typ string) {
+	tokensIssued.WithLabelValues(typ).Inc()
+}
+
+// TokenVerified counts token verification by the kind of its error
+func TokenVerified(err error) {
+	result := "valid"
+	if err != nil {
+		switch errorHelper.KindOf(err) {
+		case errorHelper.Expired:
+			result = "expired"
+		case errorHelper.InvalidCredentials, errorHelper.NotFound:
+			result = "invalid"
+		default:
+			result = "error"
+		}
+	}
+	tokenVerifications.WithLabelValues(result).Inc()
+}
+
+// KeyRotated counts key rotation event
+func KeyRotated(event string) {
+	keyRotations.WithLabelValues(event).Inc()
+}
+
+// ObserveBcrypt observes duration of bcrypt operation started at start
+func ObserveBcrypt(operation string, start time.Time) {
+	bcryptDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+// MongoMonitor observes durations of MongoDB commands
+func MongoMonitor() *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			mongoDuration.WithLabelValues(e.CommandName, "success").Observe(e.Duration.Seconds())
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			mongoDuration.WithLabelValues(e.CommandName, "failure").Observe(e.Duration.Seconds())
+		},
+	}
+}
@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sso/pkg/helpers/errorHelper"
+	"testing"
+)
+
+func TestTokenVerified(t *testing.T) {
+	for _, c := range []struct {
+		err    error
+		result string
+	}{
+		{nil, "valid"},
+		{errorHelper.New(errorHelper.Expired, "verify()", "token is expired"), "expired"},
+		{errorHelper.New(errorHelper.InvalidCredentials, "verify()", "bad signature"), "invalid"},
+		{errorHelper.WrapError("verify()", "failed get keys", errors.New("driver error")), "error"},
+	} {
+		before := testutil.ToFloat64(tokenVerifications.WithLabelValues(c.result))
+		TokenVerified(c.err)
+		require.Equal(t, before+1, testutil.ToFloat64(tokenVerifications.WithLabelValues(c.result)), c.result)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	LoginFailed(ReasonWrongPassword)
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `sso_logins_total{reason="wrong_password",result="failure"}`)
+	require.Contains(t, w.Body.String(), "go_goroutines")
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// Attempts counts failed logins of an account or from an address
+type Attempts struct {
+	Key         string //account:<login> or ip:<address>
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+	ExpireAt    time.Time //the counter is forgotten after this time
+}
@@ -0,0 +1,16 @@
+package models
+
+// Client is a registered OAuth client. Secret is a bcrypt hash,
+// clients without secret are public and must use PKCE.
+type Client struct {
+	Id           string `bson:"_id, omitempty"`
+	ClientId     string
+	Secret       string
+	Name         string
+	RedirectUris []string //exact match, no wildcards
+	Scopes       []string //scopes the client is allowed to request
+}
+
+func (c *Client) IsPublic() bool {
+	return c.Secret == ""
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// AuthCode is a single use OAuth authorization code, only hash of the code is stored
+type AuthCode struct {
+	Id            string `bson:"_id, omitempty"`
+	Hash          string
+	ClientId      string
+	User          string
+	RedirectUri   string
+	Scope         string
+	CodeChallenge string //PKCE S256 challenge
+	Family        string //refresh token family issued for the code, revoked on code replay
+	Nonce         string
+	AuthTime      time.Time
+	Acr           string
+	CreateAt      time.Time
+	ValidUntil    time.Time
+	Used          bool
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// Credential is a WebAuthn public key credential (passkey or security key) registered by a user
+type Credential struct {
+	Id           string `bson:"_id, omitempty"`
+	UserId       string
+	CredentialId string //base64url credential id chosen by the authenticator
+	PublicKey    []byte //COSE_Key
+	SignCount    int64
+	Aaguid       []byte
+	Name         string
+	CreatedAt    time.Time
+	LastUsedAt   time.Time
+}
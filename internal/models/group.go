@@ -0,0 +1,10 @@
+package models
+
+// Group is a team or department. Groups are referenced by id, so they can be renamed freely.
+// Members of a group are transitively members of all its parents.
+type Group struct {
+	Id          string `bson:"_id, omitempty"`
+	Name        string
+	Description string
+	Parents     []string //ids of groups this group is a member of
+}
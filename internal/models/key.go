@@ -2,8 +2,33 @@ package models
 
 import "time"
 
+const (
+	KeyStateNext     = "next"     //published, not used for signing yet
+	KeyStateActive   = "active"   //used for signing new tokens
+	KeyStateRetiring = "retiring" //not used for signing, still valid for verification
+	KeyStateRetired  = "retired"  //all tokens signed by the key have expired
+)
+
 type Key struct {
-	Id  string `bson:"_id, omitempty"`
-	PEM []byte
-	Exp time.Time
+	Id         string `bson:"_id, omitempty"`
+	Kid        string
+	PEM        []byte
+	CreatedAt  time.Time
+	ActivateAt time.Time //key becomes active
+	RetireAt   time.Time //key stops signing
+	Exp        time.Time //key stops verifying
+}
+
+// State returns key lifecycle state at the moment now
+func (k *Key) State(now time.Time) string {
+	switch {
+	case !now.Before(k.Exp):
+		return KeyStateRetired
+	case !now.Before(k.RetireAt):
+		return KeyStateRetiring
+	case !now.Before(k.ActivateAt):
+		return KeyStateActive
+	default:
+		return KeyStateNext
+	}
 }
@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// Revocation is a revoked access token id, kept until the token expires
+type Revocation struct {
+	Jti       string
+	RevokedAt time.Time
+	Exp       time.Time
+}
@@ -0,0 +1,16 @@
+package models
+
+// Role is a named set of permissions assigned to users, User.Roles holds role names
+type Role struct {
+	Id          string `bson:"_id, omitempty"`
+	Name        string
+	Description string
+	Permissions []string
+}
+
+// Permission is a named right checked by downstream services
+type Permission struct {
+	Id          string `bson:"_id, omitempty"`
+	Name        string
+	Description string
+}
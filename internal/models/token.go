@@ -2,9 +2,18 @@ package models
 
 import "time"
 
+// Token is an opaque refresh token, only hash of the token is stored
 type Token struct {
-	Id         string
+	Id         string `bson:"_id, omitempty"`
+	Hash       string
 	User       string
+	ClientId   string
+	Scope      string
+	Family     string //all tokens rotated from the same login share a family
+	AuthTime   time.Time
+	Acr        string
 	CreateAt   time.Time
 	ValidUntil time.Time
+	Used       bool
+	Revoked    bool
 }
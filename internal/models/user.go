@@ -1,7 +1,46 @@
 package models
 
+// RoleAdmin grants access to the admin API
+const RoleAdmin = "admin"
+
 type User struct {
-	Id       string `bson:"_id, omitempty"`
-	Login    string
-	Password string
+	Id            string `bson:"_id, omitempty"`
+	Login         string
+	Password      string
+	Name          string
+	Email         string
+	EmailVerified bool
+	Roles         []string
+	Groups        []string //ids of groups the user is direct member of
+	Disabled      bool
+	Mfa           Mfa
+}
+
+// Mfa is user second factor state. TOTP secret is set on enrollment and enabled after confirmation.
+type Mfa struct {
+	TotpSecret    string
+	TotpEnabled   bool
+	TotpStep      int64    //last accepted time step, codes of this or earlier steps are rejected
+	RecoveryCodes []string //hashes of not used recovery codes
+}
+
+// UserUpdate is a partial update of a user, only not nil fields are written.
+// Fields changed concurrently by others, like used MFA codes, are kept.
+type UserUpdate struct {
+	Login         *string
+	Password      *string
+	Name          *string
+	Email         *string
+	EmailVerified *bool
+	Roles         *[]string
+	Groups        *[]string
+	Disabled      *bool
+	Mfa           *Mfa
+}
+
+// UserFilter selects a page of users, Search matches login, name or email
+type UserFilter struct {
+	Search string
+	Offset int64
+	Limit  int64
 }
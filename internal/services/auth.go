@@ -1,11 +1,12 @@
 package services
 
 import (
-	"errors"
+	"context"
+	"sso/internal/metrics"
+	"sso/internal/models"
 	"sso/internal/storage"
+	"sso/internal/tracing"
 	"sso/pkg/helpers/errorHelper"
-	"sso/pkg/helpers/jwtHelper"
-	"sso/pkg/helpers/passwdHelper"
 	"time"
 )
 
@@ -14,35 +15,73 @@ const (
 	ErrorGetRsaKey    = "failed get rsa key"
 	ErrorUserNotFound = "user not found (bad login)"
 	ErrorCreateToken  = "failed to create token"
+	ErrorUserDisabled = "user is disabled"
 )
 
-func Auth(login string, password string, storage storage.Storage) (string, error) {
+// dummyPasswordHash is compared on unknown logins, so they take as long as a wrong password of a known user.
+// Its cost is bcrypt.DefaultCost that HashPassword uses.
+const dummyPasswordHash = "$2a$10$UhFztreHpsSWGIiJIOez/uyYILxfj8ljYiRo7asPrQ/.Skq1WCd0C"
+
+// Auth checks user credentials and issues tokens. Users with second factor get MFA challenge token instead,
+// tokens are issued after MfaService.Verify. Failed logins are counted per account and client address.
+func Auth(ctx context.Context, login string, password string, ip string, lockout *LockoutService, tokens *TokensService, mfa *MfaService) (*TokenPair, string, error) {
+	ctx, span := tracing.Start(ctx, "internal.services.auth")
+	pair, challenge, err := auth(ctx, login, password, ip, lockout, tokens, mfa)
+	tracing.End(span, err)
+	return pair, challenge, err
+}
+
+func auth(ctx context.Context, login string, password string, ip string, lockout *LockoutService, tokens *TokensService, mfa *MfaService) (*TokenPair, string, error) {
 	const op = "internal.services.auth"
-	if u, err := storage.Users().GetUser(login); err != nil {
-		return "", errorHelper.WrapError(op, ErrorQueryUser, err)
+	u, err := lockout.Login(ctx, login, password, ip)
+	if err != nil {
+		return nil, "", errorHelper.WrapError(op, ErrorCreateToken, err)
+	}
+	required, err := mfa.Required(ctx, u)
+	if err != nil {
+		return nil, "", errorHelper.WrapError(op, ErrorCreateToken, err)
+	}
+	if required {
+		challenge, err := mfa.Challenge(ctx, u)
+		if err != nil {
+			return nil, "", errorHelper.WrapError(op, ErrorCreateToken, err)
+		}
+		return nil, challenge, nil
+	}
+	pair, err := tokens.Issue(ctx, Grant{UserId: u.Id, AuthTime: time.Now(), Acr: AcrPassword})
+	if err != nil {
+		return nil, "", errorHelper.WrapError(op, ErrorCreateToken, err)
+	}
+	if err := lockout.Success(ctx, login); err != nil {
+		return nil, "", errorHelper.WrapError(op, ErrorCreateToken, err)
+	}
+	return pair, "", nil
+}
+
+// Login checks user credentials
+func Login(ctx context.Context, login string, password string, store storage.Storage) (*models.User, error) {
+	const op = "internal.services.login"
+	if u, err := store.Users().GetUser(ctx, login); errorHelper.KindOf(err) == errorHelper.NotFound {
+		_ = comparePassword(ctx, password, dummyPasswordHash)
+		metrics.LoginFailed(metrics.ReasonUnknownUser)
+		return nil, errorHelper.Wrap(errorHelper.InvalidCredentials, op, ErrorUserNotFound, err)
+	} else if err != nil {
+		metrics.LoginFailed(metrics.ReasonError)
+		return nil, errorHelper.WrapError(op, ErrorQueryUser, err)
 	} else if u != nil {
-		if err := passwdHelper.ComparePassword(password, u.Password); err == nil {
-			key, err := storage.GetRsaKey()
-			if err != nil {
-				return "", errorHelper.WrapError(op, ErrorQueryUser, err)
-			}
-			if token, err := jwtHelper.Create(key, map[string]any{
-				"iss": "DM SSO",
-				"sub": "auth",
-				"aud": u.Id,
-				"exp": time.Now().Add(time.Hour * 2).Unix(),
-				"nbf": time.Now().Unix(),
-				"iat": time.Now().Unix(),
-				"jti": "none", //token id
-			}); err != nil {
-				return "", errorHelper.WrapError(op, ErrorCreateToken, err)
-			} else {
-				return token, nil
-			}
-		} else {
-			return "", errorHelper.WrapError(op, ErrorCreateToken, err)
+		if err := comparePassword(ctx, password, u.Password); err != nil {
+			metrics.LoginFailed(metrics.ReasonWrongPassword)
+			return nil, errorHelper.Wrap(errorHelper.InvalidCredentials, op, ErrorCreateToken, err)
+		}
+		if u.Disabled {
+			metrics.LoginFailed(metrics.ReasonDisabled)
+			return nil, errorHelper.New(errorHelper.InvalidCredentials, op, ErrorUserDisabled)
 		}
+		metrics.LoginSucceeded()
+		return u, nil
 	} else {
-		return "", errorHelper.WrapError(op, ErrorCreateToken, errors.New(ErrorUserNotFound))
+		_ = comparePassword(ctx, password, dummyPasswordHash)
+		metrics.LoginFailed(metrics.ReasonUnknownUser)
+		return nil, errorHelper.New(errorHelper.InvalidCredentials, op, ErrorUserNotFound)
 	}
 }
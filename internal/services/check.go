@@ -1,21 +1,111 @@
 package services
 
 import (
-	"crypto/rsa"
+	"context"
+	"github.com/golang-jwt/jwt/v5"
+	"sso/internal/metrics"
+	"sso/internal/tracing"
 	"sso/pkg/helpers/errorHelper"
 	"sso/pkg/helpers/jwtHelper"
 )
 
-type Storage interface {
-	GetRsaKey() (*rsa.PrivateKey, error)
+const (
+	ErrorTokenInvalid = "token is invalid"
+	ErrorTokenRevoked = "token is revoked"
+	ErrorIdToken      = "id and mfa challenge tokens can't be used as access token"
+)
+
+type Keyring interface {
+	Find(ctx context.Context, kid string) (*SigningKey, error)
+	Verification(ctx context.Context) ([]*SigningKey, error)
+}
+
+type Denylist interface {
+	IsRevoked(ctx context.Context, jti string) (bool, error)
 }
 
-func Check(token string, storage Storage) error {
+func Check(ctx context.Context, token string, keys Keyring, denylist Denylist) error {
 	const op = "internal.services.check"
-	key, _ := storage.GetRsaKey()
-	err := jwtHelper.Check(&key.PublicKey, token)
-	if err != nil {
-		return errorHelper.WrapError(op, "token is invalid", err)
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+	if _, err := Claims(ctx, token, keys, denylist); err != nil {
+		err = errorHelper.WrapError(op, ErrorTokenInvalid, err)
+		tracing.Fail(span, err)
+		return err
 	}
 	return nil
 }
+
+// Claims verifies access token signature and revocation and returns token claims
+func Claims(ctx context.Context, token string, keys Keyring, denylist Denylist) (jwt.MapClaims, error) {
+	const op = "internal.services.claims"
+	claims, err := verify(ctx, token, keys)
+	if err != nil {
+		return nil, errorHelper.WrapError(op, ErrorTokenInvalid, err)
+	}
+	//only special purpose tokens have token_use claim
+	if _, ok := claims["token_use"]; ok {
+		return nil, errorHelper.New(errorHelper.InvalidCredentials, op, ErrorIdToken)
+	}
+	//tokens issued before jti was introduced can't be revoked
+	if jti, ok := claims["jti"].(string); ok && jti != "" {
+		if err := notRevoked(ctx, denylist, jti); err != nil {
+			return nil, errorHelper.WrapError(op, ErrorTokenInvalid, err)
+		}
+	}
+	return claims, nil
+}
+
+// FirstParty reports whether the access token was issued by login endpoints of this service itself.
+// Tokens of OAuth clients carry client_id or aud, they act for the user only within granted scopes.
+func FirstParty(claims map[string]any) bool {
+	_, client := claims["client_id"]
+	_, aud := claims["aud"]
+	return !client && !aud
+}
+
+// verify checks token signature with the key referenced by kid, counts and traces the result
+func verify(ctx context.Context, token string, keys Keyring) (jwt.MapClaims, error) {
+	ctx, span := tracing.Start(ctx, "internal.services.verify")
+	claims, err := verifySignature(ctx, token, keys)
+	metrics.TokenVerified(err)
+	tracing.End(span, err)
+	return claims, err
+}
+
+func verifySignature(ctx context.Context, token string, keys Keyring) (jwt.MapClaims, error) {
+	const op = "internal.services.verify"
+	kid, err := jwtHelper.GetKid(token)
+	if err != nil {
+		return nil, errorHelper.WrapError(op, ErrorTokenInvalid, err)
+	}
+	if kid != "" {
+		key, err := keys.Find(ctx, kid)
+		if errorHelper.KindOf(err) == errorHelper.NotFound {
+			return nil, errorHelper.Wrap(errorHelper.InvalidCredentials, op, ErrorTokenInvalid, err)
+		} else if err != nil {
+			return nil, errorHelper.WrapError(op, ErrorTokenInvalid, err)
+		}
+		claims, err := jwtHelper.GetClaim(&key.PrivateKey.PublicKey, token)
+		if err != nil {
+			return nil, errorHelper.WrapError(op, ErrorTokenInvalid, err)
+		}
+		return *claims, nil
+	}
+	//tokens issued before kid was introduced, try every not retired key
+	list, err := keys.Verification(ctx)
+	if err != nil {
+		return nil, errorHelper.WrapError(op, ErrorTokenInvalid, err)
+	}
+	for _, key := range list {
+		claims, e := jwtHelper.GetClaim(&key.PrivateKey.PublicKey, token)
+		if e == nil {
+			return *claims, nil
+		}
+		err = e
+	}
+	if err == nil {
+		err = errorHelper.New(errorHelper.InvalidCredentials, op, ErrorKeyNotFound)
+	}
+	return nil, errorHelper.WrapError(op, ErrorTokenInvalid, err)
+}
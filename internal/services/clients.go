@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sso/internal/models"
+	"sso/internal/storage"
+	"sso/pkg/helpers/errorHelper"
+	"strings"
+)
+
+const (
+	ErrorAddClient          = "Error on add client"
+	ErrorClientNotFound     = "client not found"
+	ErrorClientAuthenticate = "client authentication failed"
+	ErrorPublicClient       = "public client can't authenticate with secret"
+)
+
+type ClientsService struct {
+	storage storage.Storage
+}
+
+func Clients(storage storage.Storage) *ClientsService {
+	return &ClientsService{
+		storage: storage,
+	}
+}
+
+// Add registers a client, secret is stored hashed. Client without secret is public.
+func (c *ClientsService) Add(ctx context.Context, client *models.Client, secret string) (string, error) {
+	const operation = "internal.services.clients.Add()"
+	client.Secret = ""
+	if secret != "" {
+		hash, err := hashPassword(ctx, secret)
+		if err != nil {
+			return "", errorHelper.WrapError(operation, ErrorCreatePassword, err)
+		}
+		client.Secret = hash
+	}
+	id, err := c.storage.Clients().InsertClient(ctx, client)
+	if err != nil {
+		return "", errorHelper.WrapError(operation, ErrorAddClient, err)
+	}
+	return id, nil
+}
+
+// Ensure registers a client if it is not registered yet
+func (c *ClientsService) Ensure(ctx context.Context, client *models.Client, secret string) (bool, error) {
+	const operation = "internal.services.clients.Ensure()"
+	if _, err := c.storage.Clients().GetClient(ctx, client.ClientId); err == nil {
+		return false, nil
+	}
+	if _, err := c.Add(ctx, client, secret); err != nil {
+		return false, errorHelper.WrapError(operation, ErrorAddClient, err)
+	}
+	return true, nil
+}
+
+// Get returns registered client
+func (c *ClientsService) Get(ctx context.Context, clientId string) (*models.Client, error) {
+	const operation = "internal.services.clients.Get()"
+	client, err := c.storage.Clients().GetClient(ctx, clientId)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorClientNotFound, err)
+	}
+	return client, nil
+}
+
+// Authenticate checks confidential client credentials
+func (c *ClientsService) Authenticate(ctx context.Context, clientId string, secret string) (*models.Client, error) {
+	const operation = "internal.services.clients.Authenticate()"
+	client, err := c.storage.Clients().GetClient(ctx, clientId)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorClientNotFound, err)
+	}
+	if client.IsPublic() {
+		return nil, errorHelper.Wrap(errorHelper.InvalidCredentials, operation, ErrorClientAuthenticate, errors.New(ErrorPublicClient))
+	}
+	if err := comparePassword(ctx, secret, client.Secret); err != nil {
+		return nil, errorHelper.Wrap(errorHelper.InvalidCredentials, operation, ErrorClientAuthenticate, err)
+	}
+	return client, nil
+}
+
+// Scope returns scope granted to the client: requested scope narrowed to the registered ones,
+// or all registered scopes when nothing is requested
+func (c *ClientsService) Scope(client *models.Client, scope string) (string, error) {
+	const operation = "internal.services.clients.Scope()"
+	if scope == "" {
+		return strings.Join(client.Scopes, " "), nil
+	}
+	if !ScopeAllowed(scope, client.Scopes) {
+		return "", errorHelper.New(errorHelper.Invalid, operation, ErrorScopeNotAllowed)
+	}
+	return scope, nil
+}
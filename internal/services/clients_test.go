@@ -0,0 +1,45 @@
+package services
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"sso/pkg/helpers/errorHelper"
+	"testing"
+)
+
+func TestClientCredentials(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	env.addClient(t, "service", testPassword, "read", "write")
+	env.addClient(t, "spa", "", "read")
+
+	_, err := env.clients.Authenticate(ctx, "unknown", testPassword)
+	require.Equal(t, errorHelper.NotFound, errorHelper.KindOf(err))
+	_, err = env.clients.Authenticate(ctx, "service", "wrong")
+	require.Equal(t, errorHelper.InvalidCredentials, errorHelper.KindOf(err))
+	//public client has no secret to present
+	_, err = env.clients.Authenticate(ctx, "spa", "")
+	require.Equal(t, errorHelper.InvalidCredentials, errorHelper.KindOf(err))
+	_, err = env.clients.Authenticate(ctx, "spa", testPassword)
+	require.Equal(t, errorHelper.InvalidCredentials, errorHelper.KindOf(err))
+
+	client, err := env.clients.Authenticate(ctx, "service", testPassword)
+	require.NoError(t, err)
+	scope, err := env.clients.Scope(client, "")
+	require.NoError(t, err)
+	require.Equal(t, "read write", scope)
+	scope, err = env.clients.Scope(client, "read")
+	require.NoError(t, err)
+	require.Equal(t, "read", scope)
+	_, err = env.clients.Scope(client, "read admin")
+	require.Equal(t, errorHelper.Invalid, errorHelper.KindOf(err))
+
+	pair, err := env.tokens.IssueClient(ctx, client.ClientId, scope)
+	require.NoError(t, err)
+	require.Empty(t, pair.RefreshToken)
+	claims, err := Claims(ctx, pair.AccessToken, env.keys, env.revocations)
+	require.NoError(t, err)
+	require.Equal(t, "service", claims["sub"])
+	require.Equal(t, "service", claims["client_id"])
+	require.Equal(t, "read", claims["scope"])
+}
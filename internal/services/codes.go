@@ -0,0 +1,199 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"slices"
+	"sso/internal/config"
+	"sso/internal/models"
+	"sso/internal/storage"
+	"sso/pkg/helpers/errorHelper"
+	"sso/pkg/helpers/tokenHelper"
+	"strings"
+	"time"
+)
+
+const (
+	ResponseTypeCode = "code"
+	PkceMethodS256   = "S256"
+)
+
+const (
+	ErrorCreateCode      = "failed to create authorization code"
+	ErrorCodeNotFound    = "authorization code not found"
+	ErrorCodeExpired     = "authorization code expired"
+	ErrorCodeReused      = "authorization code reuse detected, issued tokens revoked"
+	ErrorCodeClient      = "authorization code was issued to another client"
+	ErrorCodeRedirectUri = "redirect_uri does not match authorization request"
+	ErrorCodeVerifier    = "PKCE code_verifier does not match code_challenge"
+	ErrorUseCode         = "failed to use authorization code"
+	ErrorUnknownClient   = "unknown client_id"
+	ErrorBadRedirectUri  = "redirect_uri is not registered for the client"
+	ErrorBadResponseType = "only response_type=code is supported"
+	ErrorNoCodeChallenge = "code_challenge is required"
+	ErrorBadPkceMethod   = "only S256 code_challenge_method is supported"
+	ErrorScopeNotAllowed = "requested scope is not allowed for the client"
+)
+
+// OAuth error codes returned in authorization error redirects, RFC 6749 section 4.1.2.1
+const (
+	AuthorizeInvalidRequest          = "invalid_request"
+	AuthorizeUnsupportedResponseType = "unsupported_response_type"
+	AuthorizeInvalidScope            = "invalid_scope"
+	AuthorizeAccessDenied            = "access_denied"
+	AuthorizeServerError             = "server_error"
+)
+
+// AuthorizeError is an authorization request error. Errors in client_id or redirect_uri
+// must not be redirected to the client, all other errors are reported to redirect_uri.
+type AuthorizeError struct {
+	Code        string
+	Description string
+	Redirect    bool
+}
+
+func (e *AuthorizeError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+type AuthorizeRequest struct {
+	ResponseType        string
+	ClientId            string
+	RedirectUri         string
+	Scope               string
+	State               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	Nonce               string
+}
+
+type CodesService struct {
+	storage storage.Storage
+	config  config.TokensConfig
+}
+
+func Codes(storage storage.Storage, config config.TokensConfig) *CodesService {
+	return &CodesService{
+		storage: storage,
+		config:  config,
+	}
+}
+
+// Validate checks authorization request against the registered client
+func (c *CodesService) Validate(ctx context.Context, req *AuthorizeRequest) (*models.Client, error) {
+	client, err := c.storage.Clients().GetClient(ctx, req.ClientId)
+	if err != nil {
+		return nil, &AuthorizeError{Code: AuthorizeInvalidRequest, Description: ErrorUnknownClient}
+	}
+	//strict comparison, RFC 6749 section 3.1.2.3
+	if req.RedirectUri == "" || !slices.Contains(client.RedirectUris, req.RedirectUri) {
+		return nil, &AuthorizeError{Code: AuthorizeInvalidRequest, Description: ErrorBadRedirectUri}
+	}
+	if req.ResponseType != ResponseTypeCode {
+		return nil, &AuthorizeError{Code: AuthorizeUnsupportedResponseType, Description: ErrorBadResponseType, Redirect: true}
+	}
+	if req.CodeChallenge == "" {
+		return nil, &AuthorizeError{Code: AuthorizeInvalidRequest, Description: ErrorNoCodeChallenge, Redirect: true}
+	}
+	if req.CodeChallengeMethod != PkceMethodS256 {
+		return nil, &AuthorizeError{Code: AuthorizeInvalidRequest, Description: ErrorBadPkceMethod, Redirect: true}
+	}
+	if !ScopeAllowed(req.Scope, client.Scopes) {
+		return nil, &AuthorizeError{Code: AuthorizeInvalidScope, Description: ErrorScopeNotAllowed, Redirect: true}
+	}
+	return client, nil
+}
+
+// Issue creates authorization code for validated request and user authenticated with acr method
+func (c *CodesService) Issue(ctx context.Context, req *AuthorizeRequest, userId string, acr string) (string, error) {
+	const operation = "internal.services.codes.Issue()"
+	code, err := tokenHelper.Generate(32)
+	if err != nil {
+		return "", errorHelper.WrapError(operation, ErrorCreateCode, err)
+	}
+	family, err := NewFamily()
+	if err != nil {
+		return "", errorHelper.WrapError(operation, ErrorCreateCode, err)
+	}
+	now := time.Now()
+	if _, err := c.storage.Codes().InsertCode(ctx, &models.AuthCode{
+		Hash:          tokenHelper.Hash(code),
+		ClientId:      req.ClientId,
+		User:          userId,
+		RedirectUri:   req.RedirectUri,
+		Scope:         req.Scope,
+		CodeChallenge: req.CodeChallenge,
+		Family:        family,
+		Nonce:         req.Nonce,
+		AuthTime:      now,
+		Acr:           acr,
+		CreateAt:      now,
+		ValidUntil:    now.Add(c.config.CodeTTL),
+	}); err != nil {
+		return "", errorHelper.WrapError(operation, ErrorCreateCode, err)
+	}
+	return code, nil
+}
+
+// Exchange checks and uses authorization code. A replayed code revokes tokens issued for it,
+// RFC 6749 section 4.1.2.
+func (c *CodesService) Exchange(ctx context.Context, code string, clientId string, redirectUri string, verifier string) (*models.AuthCode, error) {
+	const operation = "internal.services.codes.Exchange()"
+	auth, err := c.storage.Codes().GetCode(ctx, tokenHelper.Hash(code))
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorCodeNotFound, err)
+	}
+	if auth.Used {
+		return nil, c.replay(ctx, operation, auth)
+	}
+	if time.Now().After(auth.ValidUntil) {
+		return nil, errorHelper.Wrap(errorHelper.Expired, operation, ErrorCodeExpired, errors.New(auth.ValidUntil.String()))
+	}
+	if auth.ClientId != clientId {
+		return nil, errorHelper.Wrap(errorHelper.InvalidCredentials, operation, ErrorCodeClient, errors.New(clientId))
+	}
+	if auth.RedirectUri != redirectUri {
+		return nil, errorHelper.Wrap(errorHelper.InvalidCredentials, operation, ErrorCodeRedirectUri, errors.New(redirectUri))
+	}
+	if !VerifyPkce(verifier, auth.CodeChallenge) {
+		return nil, errorHelper.Wrap(errorHelper.InvalidCredentials, operation, ErrorCodeVerifier, errors.New(verifier))
+	}
+	ok, err := c.storage.Codes().UseCode(ctx, auth.Id)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorUseCode, err)
+	}
+	if !ok {
+		return nil, c.replay(ctx, operation, auth)
+	}
+	return auth, nil
+}
+
+func (c *CodesService) replay(ctx context.Context, operation string, auth *models.AuthCode) error {
+	if err := c.storage.Tokens().RevokeFamily(ctx, auth.Family); err != nil {
+		return errorHelper.WrapError(operation, ErrorRevokeFamily, err)
+	}
+	return errorHelper.Wrap(errorHelper.InvalidCredentials, operation, ErrorCodeReused, errors.New(auth.ClientId))
+}
+
+// VerifyPkce checks code_verifier against S256 code_challenge, RFC 7636 section 4.6
+func VerifyPkce(verifier string, challenge string) bool {
+	if verifier == "" || challenge == "" {
+		return false
+	}
+	hash := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(hash[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+// ScopeAllowed reports whether every space separated scope is in allowed list
+func ScopeAllowed(scope string, allowed []string) bool {
+	for _, s := range strings.Fields(scope) {
+		if !slices.Contains(allowed, s) {
+			return false
+		}
+	}
+	return true
+}
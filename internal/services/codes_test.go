@@ -0,0 +1,24 @@
+package services
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestVerifyPkce(t *testing.T) {
+	//example from RFC 7636 appendix B
+	const verifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	const challenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	require.True(t, VerifyPkce(verifier, challenge))
+	require.False(t, VerifyPkce(verifier+"x", challenge))
+	require.False(t, VerifyPkce("", challenge))
+	require.False(t, VerifyPkce(verifier, ""))
+}
+
+func TestScopeAllowed(t *testing.T) {
+	allowed := []string{"openid", "profile"}
+	require.True(t, ScopeAllowed("", allowed))
+	require.True(t, ScopeAllowed("openid profile", allowed))
+	require.False(t, ScopeAllowed("openid email", allowed))
+	require.False(t, ScopeAllowed("openid", nil))
+}
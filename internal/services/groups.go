@@ -0,0 +1,227 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sso/internal/config"
+	"sso/internal/models"
+	"sso/internal/storage"
+	"sso/pkg/helpers/errorHelper"
+	"strings"
+)
+
+const (
+	ErrorGetGroups     = "Error on get groups"
+	ErrorAddGroup      = "Error on add group"
+	ErrorUpdateGroup   = "Error on update group"
+	ErrorDeleteGroup   = "Error on delete group"
+	ErrorUnknownGroups = "Unknown parent groups"
+	ErrorGroupCycle    = "Group can't be a member of itself or of its members"
+	ErrorResolveGroups = "Error on resolve group membership"
+)
+
+type GroupsService struct {
+	storage storage.Storage
+	config  config.GroupsConfig
+}
+
+func Groups(storage storage.Storage, config config.GroupsConfig) *GroupsService {
+	return &GroupsService{
+		storage: storage,
+		config:  config,
+	}
+}
+
+func (g *GroupsService) List(ctx context.Context) ([]*models.Group, error) {
+	const operation = "internal.services.groups.List()"
+	groups, err := g.storage.Groups().ListGroups(ctx)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorGetGroups, err)
+	}
+	return groups, nil
+}
+
+func (g *GroupsService) Get(ctx context.Context, id string) (*models.Group, error) {
+	const operation = "internal.services.groups.Get()"
+	group, err := g.storage.Groups().GetGroup(ctx, id)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorGetGroups, err)
+	}
+	return group, nil
+}
+
+// Add creates group, all its parents must exist
+func (g *GroupsService) Add(ctx context.Context, group *models.Group) (string, error) {
+	const operation = "internal.services.groups.Add()"
+	if strings.TrimSpace(group.Name) == "" {
+		return "", errorHelper.Wrap(errorHelper.Invalid, operation, ErrorAddGroup, errors.New(ErrorEmptyName))
+	}
+	if err := g.checkParents(ctx, "", group.Parents); err != nil {
+		return "", errorHelper.WrapError(operation, ErrorAddGroup, err)
+	}
+	id, err := g.storage.Groups().InsertGroup(ctx, group)
+	if err != nil {
+		return "", errorHelper.WrapError(operation, ErrorAddGroup, err)
+	}
+	return id, nil
+}
+
+// Update renames group or changes its description or parents, nil values are left as is
+func (g *GroupsService) Update(ctx context.Context, id string, name *string, description *string, parents *[]string) (*models.Group, error) {
+	const operation = "internal.services.groups.Update()"
+	group, err := g.storage.Groups().GetGroup(ctx, id)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorGetGroups, err)
+	}
+	if name != nil {
+		if strings.TrimSpace(*name) == "" {
+			return nil, errorHelper.Wrap(errorHelper.Invalid, operation, ErrorUpdateGroup, errors.New(ErrorEmptyName))
+		}
+		group.Name = *name
+	}
+	if description != nil {
+		group.Description = *description
+	}
+	if parents != nil {
+		if err := g.checkParents(ctx, id, *parents); err != nil {
+			return nil, errorHelper.WrapError(operation, ErrorUpdateGroup, err)
+		}
+		group.Parents = *parents
+	}
+	if err := g.storage.Groups().UpdateGroup(ctx, group); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorUpdateGroup, err)
+	}
+	return group, nil
+}
+
+// Delete deletes group, its members and child groups lose membership in it
+func (g *GroupsService) Delete(ctx context.Context, id string) error {
+	const operation = "internal.services.groups.Delete()"
+	if err := g.storage.Groups().DeleteGroup(ctx, id); err != nil {
+		return errorHelper.WrapError(operation, ErrorDeleteGroup, err)
+	}
+	if err := g.storage.Groups().RemoveParent(ctx, id); err != nil {
+		return errorHelper.WrapError(operation, ErrorDeleteGroup, err)
+	}
+	if err := g.storage.Users().RemoveGroup(ctx, id); err != nil {
+		return errorHelper.WrapError(operation, ErrorDeleteGroup, err)
+	}
+	return nil
+}
+
+// AddMember makes the user a direct member of the group
+func (g *GroupsService) AddMember(ctx context.Context, groupId string, userId string) (*models.User, error) {
+	const operation = "internal.services.groups.AddMember()"
+	if _, err := g.storage.Groups().GetGroup(ctx, groupId); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorGetGroups, err)
+	}
+	user, err := g.storage.Users().GetUserById(ctx, userId)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorGetUser, err)
+	}
+	if slices.Contains(user.Groups, groupId) {
+		return user, nil
+	}
+	user.Groups = append(user.Groups, groupId)
+	if err := g.storage.Users().UpdateUser(ctx, userId, models.UserUpdate{Groups: &user.Groups}); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorUpdateUser, err)
+	}
+	return user, nil
+}
+
+// RemoveMember removes direct membership of the user in the group
+func (g *GroupsService) RemoveMember(ctx context.Context, groupId string, userId string) (*models.User, error) {
+	const operation = "internal.services.groups.RemoveMember()"
+	user, err := g.storage.Users().GetUserById(ctx, userId)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorGetUser, err)
+	}
+	if !slices.Contains(user.Groups, groupId) {
+		return user, nil
+	}
+	user.Groups = slices.DeleteFunc(user.Groups, func(s string) bool { return s == groupId })
+	if err := g.storage.Users().UpdateUser(ctx, userId, models.UserUpdate{Groups: &user.Groups}); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorUpdateUser, err)
+	}
+	return user, nil
+}
+
+// Resolve returns all groups the user is direct or transitive member of
+func (g *GroupsService) Resolve(ctx context.Context, user *models.User) ([]*models.Group, error) {
+	const operation = "internal.services.groups.Resolve()"
+	groups, err := Ancestors(ctx, g.storage.Groups(), user.Groups)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorResolveGroups, err)
+	}
+	return groups, nil
+}
+
+// Claim returns sorted group names for the groups claim and whether they exceed the configured limit.
+// On overflow names are truncated or omitted depending on config.
+func (g *GroupsService) Claim(groups []*models.Group) ([]string, bool) {
+	names := make([]string, 0, len(groups))
+	for _, group := range groups {
+		names = append(names, group.Name)
+	}
+	slices.Sort(names)
+	if g.config.ClaimLimit <= 0 || len(names) <= g.config.ClaimLimit {
+		return names, false
+	}
+	if g.config.Overflow == config.GroupsOverflowOmit {
+		return nil, true
+	}
+	return names[:g.config.ClaimLimit], true
+}
+
+// checkParents makes sure all parents exist and group id does not become its own ancestor
+func (g *GroupsService) checkParents(ctx context.Context, id string, parents []string) error {
+	const operation = "internal.services.groups.checkParents()"
+	found, err := g.storage.Groups().GetGroups(ctx, parents)
+	if err != nil {
+		return errorHelper.WrapError(operation, ErrorGetGroups, err)
+	}
+	if unknown := missing(parents, found, func(g *models.Group) string { return g.Id }); len(unknown) > 0 {
+		return errorHelper.Wrap(errorHelper.Invalid, operation, ErrorUnknownGroups, errors.New(strings.Join(unknown, ", ")))
+	}
+	if id == "" {
+		return nil
+	}
+	ancestors, err := Ancestors(ctx, g.storage.Groups(), parents)
+	if err != nil {
+		return errorHelper.WrapError(operation, ErrorResolveGroups, err)
+	}
+	if slices.ContainsFunc(ancestors, func(g *models.Group) bool { return g.Id == id }) {
+		return errorHelper.Wrap(errorHelper.Invalid, operation, ErrorUpdateGroup, errors.New(ErrorGroupCycle))
+	}
+	return nil
+}
+
+// Ancestors returns groups ids and all their transitive parents, loading one nesting level per query.
+// Cycles in stored data are tolerated.
+func Ancestors(ctx context.Context, groups storage.Groups, ids []string) ([]*models.Group, error) {
+	const operation = "internal.services.groups.Ancestors()"
+	seen := make(map[string]bool)
+	res := make([]*models.Group, 0)
+	level := ids
+	for len(level) > 0 {
+		found, err := groups.GetGroups(ctx, level)
+		if err != nil {
+			return nil, errorHelper.WrapError(operation, ErrorGetGroups, err)
+		}
+		level = nil
+		for _, group := range found {
+			if seen[group.Id] {
+				continue
+			}
+			seen[group.Id] = true
+			res = append(res, group)
+			for _, parent := range group.Parents {
+				if !seen[parent] {
+					level = append(level, parent)
+				}
+			}
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"slices"
+	"sso/internal/config"
+	"sso/internal/models"
+	"sso/internal/storage"
+	"testing"
+)
+
+// groupsStub serves GetGroups from a map, other methods are not used by the tests
+type groupsStub struct {
+	storage.Groups
+	groups map[string]*models.Group
+}
+
+func (s *groupsStub) GetGroups(ctx context.Context, ids []string) ([]*models.Group, error) {
+	res := make([]*models.Group, 0)
+	for _, id := range ids {
+		if g, ok := s.groups[id]; ok {
+			res = append(res, g)
+		}
+	}
+	return res, nil
+}
+
+func TestAncestors(t *testing.T) {
+	ctx := context.Background()
+	stub := &groupsStub{groups: map[string]*models.Group{
+		"company": {Id: "company", Name: "Company"},
+		"it":      {Id: "it", Name: "IT", Parents: []string{"company"}},
+		"dev":     {Id: "dev", Name: "Dev", Parents: []string{"it", "company"}},
+		"ops":     {Id: "ops", Name: "Ops", Parents: []string{"it"}},
+		//cycle in stored data must not hang resolution
+		"a": {Id: "a", Name: "A", Parents: []string{"b"}},
+		"b": {Id: "b", Name: "B", Parents: []string{"a"}},
+	}}
+	ids := func(groups []*models.Group) []string {
+		res := make([]string, 0, len(groups))
+		for _, g := range groups {
+			res = append(res, g.Id)
+		}
+		slices.Sort(res)
+		return res
+	}
+	groups, err := Ancestors(ctx, stub, []string{"dev"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"company", "dev", "it"}, ids(groups))
+
+	groups, err = Ancestors(ctx, stub, []string{"dev", "ops", "unknown"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"company", "dev", "it", "ops"}, ids(groups))
+
+	groups, err = Ancestors(ctx, stub, []string{"a"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b"}, ids(groups))
+
+	groups, err = Ancestors(ctx, stub, nil)
+	require.NoError(t, err)
+	require.Empty(t, groups)
+}
+
+func TestGroupsClaim(t *testing.T) {
+	groups := []*models.Group{{Name: "c"}, {Name: "a"}, {Name: "b"}}
+	names, overflow := Groups(nil, config.GroupsConfig{}).Claim(groups)
+	require.Equal(t, []string{"a", "b", "c"}, names)
+	require.False(t, overflow)
+
+	names, overflow = Groups(nil, config.GroupsConfig{ClaimLimit: 3}).Claim(groups)
+	require.Equal(t, []string{"a", "b", "c"}, names)
+	require.False(t, overflow)
+
+	names, overflow = Groups(nil, config.GroupsConfig{ClaimLimit: 2, Overflow: config.GroupsOverflowTruncate}).Claim(groups)
+	require.Equal(t, []string{"a", "b"}, names)
+	require.True(t, overflow)
+
+	names, overflow = Groups(nil, config.GroupsConfig{ClaimLimit: 2, Overflow: config.GroupsOverflowOmit}).Claim(groups)
+	require.Nil(t, names)
+	require.True(t, overflow)
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"sso/internal/storage"
+	"sso/pkg/helpers/errorHelper"
+	"time"
+)
+
+const (
+	ErrorStorageUnavailable = "Storage is unavailable"
+	ErrorNotMigrated        = "Storage migrations are not applied"
+	ErrorNoSigningKey       = "No active signing key"
+)
+
+// Components checked by the readiness probe
+const (
+	ComponentStorage    = "storage"
+	ComponentMigrations = "migrations"
+	ComponentSigningKey = "signing_key"
+)
+
+// readyTimeout limits every readiness check, so a hanging database fails the probe instead of blocking it
+const readyTimeout = 2 * time.Second
+
+type HealthService struct {
+	health storage.Health
+	keys   *KeysService
+}
+
+// ComponentHealth is the result of the readiness check of one component, Err is nil if the component is ok
+type ComponentHealth struct {
+	Component string
+	Duration  time.Duration
+	Err       error
+}
+
+func Health(health storage.Health, keys *KeysService) *HealthService {
+	return &HealthService{
+		health: health,
+		keys:   keys,
+	}
+}
+
+// Ready checks that storage is reachable and migrated and there is an active signing key,
+// the service is ready only if all components are ok
+func (h *HealthService) Ready(ctx context.Context) ([]ComponentHealth, bool) {
+	const operation = "internal.services.health.Ready()"
+	checks := []struct {
+		component string
+		message   string
+		check     func(ctx context.Context) error
+	}{
+		{ComponentStorage, ErrorStorageUnavailable, h.health.Ping},
+		{ComponentMigrations, ErrorNotMigrated, h.health.Migrated},
+		{ComponentSigningKey, ErrorNoSigningKey, func(ctx context.Context) error {
+			_, err := h.keys.Signing(ctx)
+			return err
+		}},
+	}
+	res := make([]ComponentHealth, 0, len(checks))
+	ready := true
+	for _, c := range checks {
+		start := time.Now()
+		checkCtx, cancel := context.WithTimeout(ctx, readyTimeout)
+		err := c.check(checkCtx)
+		cancel()
+		if err != nil {
+			err = errorHelper.WrapError(operation, c.message, err)
+			ready = false
+		}
+		res = append(res, ComponentHealth{Component: c.component, Duration: time.Since(start), Err: err})
+	}
+	return res, ready
+}
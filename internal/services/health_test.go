@@ -0,0 +1,47 @@
+package services
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"sso/internal/config"
+	"sso/internal/storage/memory"
+	"sso/pkg/helpers/errorHelper"
+	"testing"
+	"time"
+)
+
+// brokenStorage is reachable storage whose migrations failed
+type brokenStorage struct {
+	*memory.Storage
+}
+
+func (s brokenStorage) Migrated(ctx context.Context) error {
+	return errorHelper.New(errorHelper.Internal, "test", "dirty database")
+}
+
+func TestReady(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.KeysConfig{RotationInterval: time.Hour, PublishAhead: time.Minute, GracePeriod: time.Minute}
+	s := memory.New()
+	components, ready := Health(s, Keys(s, cfg)).Ready(ctx)
+	require.True(t, ready)
+	require.Len(t, components, 3)
+	for _, c := range components {
+		require.NoError(t, c.Err, c.Component)
+	}
+
+	broken := brokenStorage{memory.New()}
+	components, ready = Health(broken, Keys(broken, cfg)).Ready(ctx)
+	require.False(t, ready)
+	require.NoError(t, components[0].Err)
+	require.Equal(t, ComponentMigrations, components[1].Component)
+	require.Error(t, components[1].Err)
+	require.NoError(t, components[2].Err)
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	components, ready = Health(s, Keys(memory.New(), cfg)).Ready(canceled)
+	require.False(t, ready)
+	require.Equal(t, errorHelper.Canceled, errorHelper.KindOf(components[0].Err))
+	require.Equal(t, errorHelper.Canceled, errorHelper.KindOf(components[2].Err))
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"context"
+	"sso/internal/storage"
+	"sso/pkg/helpers/tokenHelper"
+	"time"
+)
+
+// Introspect returns RFC 7662 introspection response for access or refresh token.
+// Any invalid, expired, revoked or unknown token is reported as {"active": false}.
+func Introspect(ctx context.Context, token string, hint string, storage storage.Storage, keys Keyring, denylist Denylist) map[string]any {
+	//the hint only defines lookup order
+	if hint == TokenTypeRefresh {
+		if res := introspectRefresh(ctx, token, storage); res != nil {
+			return res
+		}
+		if res := introspectAccess(ctx, token, keys, denylist); res != nil {
+			return res
+		}
+	} else {
+		if res := introspectAccess(ctx, token, keys, denylist); res != nil {
+			return res
+		}
+		if res := introspectRefresh(ctx, token, storage); res != nil {
+			return res
+		}
+	}
+	return map[string]any{"active": false}
+}
+
+func introspectAccess(ctx context.Context, token string, keys Keyring, denylist Denylist) map[string]any {
+	claims, err := Claims(ctx, token, keys, denylist)
+	if err != nil {
+		return nil
+	}
+	//custom claims are passed as is
+	res := make(map[string]any, len(claims)+2)
+	for k, v := range claims {
+		res[k] = v
+	}
+	res["active"] = true
+	res["token_type"] = "Bearer"
+	return res
+}
+
+func introspectRefresh(ctx context.Context, token string, storage storage.Storage) map[string]any {
+	t, err := storage.Tokens().GetToken(ctx, tokenHelper.Hash(token))
+	if err != nil || t.Used || t.Revoked || time.Now().After(t.ValidUntil) {
+		return nil
+	}
+	res := map[string]any{
+		"active":     true,
+		"token_type": TokenTypeRefresh,
+		"sub":        t.User,
+		"iat":        t.CreateAt.Unix(),
+		"exp":        t.ValidUntil.Unix(),
+	}
+	//same claims as the access token of the pair
+	if t.ClientId != "" {
+		res["client_id"] = t.ClientId
+	}
+	if t.Scope != "" {
+		res["scope"] = t.Scope
+	}
+	return res
+}
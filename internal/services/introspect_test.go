@@ -0,0 +1,39 @@
+package services
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestIntrospect(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	uid := env.addUser(t, "alice")
+	pair, err := env.tokens.Issue(ctx, Grant{UserId: uid, ClientId: "app", Scope: "openid profile"})
+	require.NoError(t, err)
+
+	for _, hint := range []string{"", TokenTypeAccess, TokenTypeRefresh} {
+		for token, tokenType := range map[string]string{pair.AccessToken: "Bearer", pair.RefreshToken: TokenTypeRefresh} {
+			res := Introspect(ctx, token, hint, env.storage, env.keys, env.revocations)
+			require.Equal(t, true, res["active"])
+			require.Equal(t, tokenType, res["token_type"])
+			require.Equal(t, uid, res["sub"])
+			require.Equal(t, "app", res["client_id"])
+			require.Equal(t, "openid profile", res["scope"])
+			require.NotEmpty(t, res["exp"])
+		}
+	}
+
+	require.NoError(t, env.revocations.Revoke(ctx, pair.RefreshToken, TokenTypeRefresh, "app"))
+	require.Equal(t, map[string]any{"active": false}, Introspect(ctx, pair.RefreshToken, "", env.storage, env.keys, env.revocations))
+	require.Equal(t, map[string]any{"active": false}, Introspect(ctx, "unknown", "", env.storage, env.keys, env.revocations))
+
+	//first party refresh tokens have no client
+	pair, err = env.tokens.Issue(ctx, Grant{UserId: uid})
+	require.NoError(t, err)
+	res := Introspect(ctx, pair.RefreshToken, TokenTypeRefresh, env.storage, env.keys, env.revocations)
+	require.Equal(t, true, res["active"])
+	require.NotContains(t, res, "client_id")
+	require.NotContains(t, res, "scope")
+}
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"sso/internal/config"
+	"sso/internal/metrics"
+	"sso/internal/models"
+	"sso/internal/storage"
+	"sso/pkg/helpers/errorHelper"
+	"sso/pkg/helpers/jwkHelper"
+	"sync"
+	"time"
+)
+
+const (
+	ErrorGenRsaKey       = "error on generating rsa key"
+	ErrorGetKeys         = "error on get keys"
+	ErrorSaveKey         = "error on save key"
+	ErrorParsePrivateKey = "error on parsing private key"
+	ErrorKeyNotFound     = "key not found"
+)
+
+// KeysService manages signing keys lifecycle:
+// next -> active -> retiring -> retired.
+// Key state is derived from key timestamps, so all replicas agree on it without coordination.
+type KeysService struct {
+	storage storage.Storage
+	config  config.KeysConfig
+	mu      sync.Mutex
+	//parsed keys are cached until the next state change, but not longer than keysCacheTTL,
+	//so keys generated by other replicas are picked up
+	cache      []*SigningKey
+	cacheUntil time.Time
+	reloadedAt time.Time //last reload caused by unknown kid
+}
+
+const keysCacheTTL = time.Minute
+
+// keysReloadInterval limits reloads caused by unknown kids, so tokens with made up kids can't flood storage
+const keysReloadInterval = 5 * time.Second
+
+// SigningKey is a parsed key ready to use
+type SigningKey struct {
+	Kid        string
+	State      string
+	PrivateKey *rsa.PrivateKey
+}
+
+func Keys(storage storage.Storage, config config.KeysConfig) *KeysService {
+	return &KeysService{
+		storage: storage,
+		config:  config,
+	}
+}
+
+// Signing returns the active key, rotating keys if needed
+func (k *KeysService) Signing(ctx context.Context) (*SigningKey, error) {
+	const operation = "internal.services.keys.Signing()"
+	keys, err := k.rotate(ctx)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorGetKeys, err)
+	}
+	//keys are ordered by activation, so the last active one is the newest
+	for i := len(keys) - 1; i >= 0; i-- {
+		if keys[i].State == models.KeyStateActive {
+			return keys[i], nil
+		}
+	}
+	return nil, errorHelper.WrapError(operation, ErrorGetKeys, errors.New(ErrorKeyNotFound))
+}
+
+// Verification returns all not retired keys (next, active and retiring)
+func (k *KeysService) Verification(ctx context.Context) ([]*SigningKey, error) {
+	const operation = "internal.services.keys.Verification()"
+	keys, err := k.rotate(ctx)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorGetKeys, err)
+	}
+	return keys, nil
+}
+
+// Find returns not retired key by kid
+func (k *KeysService) Find(ctx context.Context, kid string) (*SigningKey, error) {
+	const operation = "internal.services.keys.Find()"
+	keys, err := k.Verification(ctx)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorGetKeys, err)
+	}
+	if key := findKid(keys, kid); key != nil {
+		return key, nil
+	}
+	//the key may be created by another replica after the cache was filled
+	if k.expire() {
+		keys, err = k.Verification(ctx)
+		if err != nil {
+			return nil, errorHelper.WrapError(operation, ErrorGetKeys, err)
+		}
+		if key := findKid(keys, kid); key != nil {
+			return key, nil
+		}
+	}
+	return nil, errorHelper.Wrap(errorHelper.NotFound, operation, ErrorKeyNotFound, errors.New(kid))
+}
+
+func findKid(keys []*SigningKey, kid string) *SigningKey {
+	for _, key := range keys {
+		if key.Kid == kid {
+			return key
+		}
+	}
+	return nil
+}
+
+// expire drops cached keys unless they were dropped less than keysReloadInterval ago, reports whether they were dropped
+func (k *KeysService) expire() bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	now := time.Now()
+	if now.Sub(k.reloadedAt) < keysReloadInterval {
+		return false
+	}
+	k.reloadedAt = now
+	k.cacheUntil = time.Time{}
+	return true
+}
+
+// rotate makes sure there are an active key and, close to its retirement, a next key
+func (k *KeysService) rotate(ctx context.Context) ([]*SigningKey, error) {
+	const operation = "internal.services.keys.rotate()"
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	now := time.Now()
+	if now.Before(k.cacheUntil) {
+		return k.cache, nil
+	}
+	keys, err := k.storage.Keys().GetKeys(ctx)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorGetKeys, err)
+	}
+	var active, next *models.Key
+	for _, key := range keys {
+		switch key.State(now) {
+		case models.KeyStateActive:
+			active = key
+		case models.KeyStateNext:
+			next = key
+		}
+	}
+	if active == nil {
+		//no active key, for example on first start or after long downtime
+		key, err := k.generate(ctx, now)
+		if err != nil {
+			return nil, errorHelper.WrapError(operation, ErrorGenRsaKey, err)
+		}
+		metrics.KeyRotated(metrics.KeyActivated)
+		keys = append(keys, key)
+		active = key
+	}
+	publishAt := active.RetireAt.Add(-k.config.PublishAhead)
+	if next == nil && !now.Before(publishAt) {
+		//publish next key in advance so that clients can cache it before it signs anything
+		key, err := k.generate(ctx, active.RetireAt)
+		if err != nil {
+			return nil, errorHelper.WrapError(operation, ErrorGenRsaKey, err)
+		}
+		metrics.KeyRotated(metrics.KeyPublished)
+		keys = append(keys, key)
+	}
+	cacheUntil := now.Add(keysCacheTTL)
+	if next == nil && publishAt.After(now) && publishAt.Before(cacheUntil) {
+		cacheUntil = publishAt
+	}
+	res := make([]*SigningKey, 0, len(keys))
+	for _, key := range keys {
+		for _, t := range []time.Time{key.ActivateAt, key.RetireAt, key.Exp} {
+			if t.After(now) && t.Before(cacheUntil) {
+				cacheUntil = t
+			}
+		}
+		privateKey, err := x509.ParsePKCS1PrivateKey(key.PEM)
+		if err != nil {
+			return nil, errorHelper.WrapError(operation, ErrorParsePrivateKey, err)
+		}
+		kid := key.Kid
+		if kid == "" {
+			kid = jwkHelper.Thumbprint(&privateKey.PublicKey)
+		}
+		res = append(res, &SigningKey{
+			Kid:        kid,
+			State:      key.State(now),
+			PrivateKey: privateKey,
+		})
+	}
+	k.cache = res
+	k.cacheUntil = cacheUntil
+	return res, nil
+}
+
+// generate creates and saves a new key which becomes active at activateAt
+func (k *KeysService) generate(ctx context.Context, activateAt time.Time) (*models.Key, error) {
+	const operation = "internal.services.keys.generate()"
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorGenRsaKey, err)
+	}
+	key := &models.Key{
+		Kid:        jwkHelper.Thumbprint(&privateKey.PublicKey),
+		PEM:        x509.MarshalPKCS1PrivateKey(privateKey),
+		CreatedAt:  time.Now(),
+		ActivateAt: activateAt,
+		RetireAt:   activateAt.Add(k.config.RotationInterval),
+		Exp:        activateAt.Add(k.config.RotationInterval + k.config.GracePeriod),
+	}
+	key.Id, err = k.storage.Keys().InsertKey(ctx, key)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorSaveKey, err)
+	}
+	return key, nil
+}
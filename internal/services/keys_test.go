@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"github.com/stretchr/testify/require"
+	"sso/internal/config"
+	"sso/internal/models"
+	"sso/internal/storage/memory"
+	"sso/pkg/helpers/errorHelper"
+	"sso/pkg/helpers/jwkHelper"
+	"testing"
+	"time"
+)
+
+// insertKey saves active key the way another replica would, returns its kid
+func insertKey(t *testing.T, s *memory.Storage) string {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	now := time.Now()
+	kid := jwkHelper.Thumbprint(&privateKey.PublicKey)
+	_, err = s.Keys().InsertKey(context.Background(), &models.Key{
+		Kid:        kid,
+		PEM:        x509.MarshalPKCS1PrivateKey(privateKey),
+		CreatedAt:  now,
+		ActivateAt: now,
+		RetireAt:   now.Add(time.Hour),
+		Exp:        now.Add(2 * time.Hour),
+	})
+	require.NoError(t, err)
+	return kid
+}
+
+func TestFindReloads(t *testing.T) {
+	ctx := context.Background()
+	s := memory.New()
+	keys := Keys(s, config.KeysConfig{RotationInterval: time.Hour, PublishAhead: time.Minute, GracePeriod: time.Hour})
+	_, err := keys.Signing(ctx)
+	require.NoError(t, err)
+
+	//a key created by another replica after the cache was filled is found at once
+	kid := insertKey(t, s)
+	key, err := keys.Find(ctx, kid)
+	require.NoError(t, err)
+	require.Equal(t, kid, key.Kid)
+
+	//misses right after a reload don't reload again
+	kid = insertKey(t, s)
+	_, err = keys.Find(ctx, kid)
+	require.Equal(t, errorHelper.NotFound, errorHelper.KindOf(err))
+	keys.reloadedAt = time.Now().Add(-keysReloadInterval)
+	_, err = keys.Find(ctx, kid)
+	require.NoError(t, err)
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sso/internal/config"
+	"sso/internal/metrics"
+	"sso/internal/models"
+	"sso/internal/storage"
+	"sso/pkg/helpers/errorHelper"
+	"time"
+)
+
+const (
+	ErrorLockoutCheck   = "Error on check login lockout"
+	ErrorLockoutFailure = "Error on count failed login"
+	ErrorLockoutReset   = "Error on reset failed logins"
+)
+
+// ErrorLocked is the message of errorHelper.Locked errors returned while the account or the client address
+// is locked after failed logins. Only errorHelper.InvalidCredentials failures are counted.
+const ErrorLocked = "too many failed logins, temporarily locked"
+
+// LockedError tells when the lock expires, it is the cause of errorHelper.Locked errors
+type LockedError struct {
+	Until time.Time
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("%s until %s", ErrorLocked, e.Until.Format(time.RFC3339))
+}
+
+func (e *LockedError) Is(target error) bool {
+	return target == errorHelper.Locked
+}
+
+// LockoutService counts failed logins per account and per client address in storage,
+// so lockouts survive restarts and are shared by all replicas
+type LockoutService struct {
+	storage storage.Storage
+	config  config.LockoutConfig
+}
+
+func Lockout(storage storage.Storage, config config.LockoutConfig) *LockoutService {
+	return &LockoutService{
+		storage: storage,
+		config:  config,
+	}
+}
+
+// Login checks that neither the account nor the address is locked before the password is hashed,
+// then checks credentials and counts failures. The account counter is not reset here: a password
+// is only a part of the login, callers call Success when the second factor is passed too.
+func (l *LockoutService) Login(ctx context.Context, login string, password string, ip string) (*models.User, error) {
+	const operation = "internal.services.lockout.Login()"
+	if err := l.Check(ctx, login, ip); err != nil {
+		if errorHelper.KindOf(err) == errorHelper.Locked {
+			metrics.LoginFailed(metrics.ReasonLocked)
+		} else {
+			metrics.LoginFailed(metrics.ReasonError)
+		}
+		return nil, errorHelper.WrapError(operation, ErrorLockoutCheck, err)
+	}
+	user, err := Login(ctx, login, password, l.storage)
+	if errorHelper.KindOf(err) == errorHelper.InvalidCredentials {
+		if e := l.Failure(ctx, login, ip); e != nil {
+			return nil, errorHelper.WrapError(operation, ErrorLockoutFailure, errors.Join(e, err))
+		}
+	}
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorCreateToken, err)
+	}
+	return user, nil
+}
+
+// Check returns LockedError if the account or the address is locked
+func (l *LockoutService) Check(ctx context.Context, login string, ip string) error {
+	const operation = "internal.services.lockout.Check()"
+	now := time.Now()
+	for _, key := range l.keys(login, ip) {
+		attempts, err := l.storage.Attempts().GetAttempts(ctx, key)
+		if errorHelper.KindOf(err) == errorHelper.NotFound {
+			continue
+		}
+		if err != nil {
+			return errorHelper.WrapError(operation, ErrorLockoutCheck, err)
+		}
+		if attempts.LockedUntil.After(now) {
+			return errorHelper.Wrap(errorHelper.Locked, operation, ErrorLocked, &LockedError{Until: attempts.LockedUntil})
+		}
+	}
+	return nil
+}
+
+// Failure counts failed login and locks the account or the address when its threshold is reached
+func (l *LockoutService) Failure(ctx context.Context, login string, ip string) error {
+	const operation = "internal.services.lockout.Failure()"
+	now := time.Now()
+	thresholds := map[string]int{accountKey(login): l.config.AccountThreshold, ipKey(ip): l.config.IpThreshold}
+	for _, key := range l.keys(login, ip) {
+		attempts, err := l.storage.Attempts().AddFailure(ctx, key, now, now.Add(l.config.Window))
+		if err != nil {
+			return errorHelper.WrapError(operation, ErrorLockoutFailure, err)
+		}
+		delay := LockDelay(attempts.Failures, thresholds[key], l.config.BaseDelay, l.config.MaxDelay)
+		if delay == 0 {
+			continue
+		}
+		until := now.Add(delay)
+		if err := l.storage.Attempts().LockAttempts(ctx, key, until, until.Add(l.config.Window)); err != nil {
+			return errorHelper.WrapError(operation, ErrorLockoutFailure, err)
+		}
+	}
+	return nil
+}
+
+// Success forgets failed logins of the account after the whole login succeeded,
+// the address counter is kept to slow down password spraying
+func (l *LockoutService) Success(ctx context.Context, login string) error {
+	const operation = "internal.services.lockout.Success()"
+	if l.config.AccountThreshold <= 0 {
+		return nil
+	}
+	if err := l.storage.Attempts().ResetAttempts(ctx, accountKey(login)); err != nil {
+		return errorHelper.WrapError(operation, ErrorLockoutReset, err)
+	}
+	return nil
+}
+
+// Unlock removes lockout of the user account
+func (l *LockoutService) Unlock(ctx context.Context, userId string) error {
+	const operation = "internal.services.lockout.Unlock()"
+	user, err := l.storage.Users().GetUserById(ctx, userId)
+	if err != nil {
+		return errorHelper.WrapError(operation, ErrorGetUser, err)
+	}
+	if err := l.storage.Attempts().ResetAttempts(ctx, accountKey(user.Login)); err != nil {
+		return errorHelper.WrapError(operation, ErrorLockoutReset, err)
+	}
+	return nil
+}
+
+// keys returns counters enabled by configuration
+func (l *LockoutService) keys(login string, ip string) []string {
+	keys := make([]string, 0, 2)
+	if l.config.AccountThreshold > 0 {
+		keys = append(keys, accountKey(login))
+	}
+	if l.config.IpThreshold > 0 && ip != "" {
+		keys = append(keys, ipKey(ip))
+	}
+	return keys
+}
+
+func accountKey(login string) string {
+	return "account:" + login
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// LockDelay is the lock duration after failures, zero below threshold then base doubled on each failure up to max
+func LockDelay(failures int, threshold int, base time.Duration, max time.Duration) time.Duration {
+	if threshold <= 0 || failures < threshold {
+		return 0
+	}
+	delay := base
+	for i := threshold; i < failures && delay < max; i++ {
+		delay *= 2
+	}
+	return min(delay, max)
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"sso/internal/config"
+	"sso/internal/models"
+	"sso/internal/storage/memory"
+	"sso/pkg/helpers/errorHelper"
+	"sso/pkg/helpers/totpHelper"
+	"testing"
+	"time"
+)
+
+func TestLockDelay(t *testing.T) {
+	base, max := 30*time.Second, 10*time.Minute
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{4, 0},
+		{5, 30 * time.Second},
+		{6, time.Minute},
+		{7, 2 * time.Minute},
+		{9, 8 * time.Minute},
+		{10, max},
+		{1000, max},
+	}
+	for _, tt := range tests {
+		if got := LockDelay(tt.failures, 5, base, max); got != tt.want {
+			t.Errorf("LockDelay(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+	if got := LockDelay(100, 0, base, max); got != 0 {
+		t.Errorf("disabled threshold: got %v", got)
+	}
+}
+
+func TestLockedError(t *testing.T) {
+	var err error = &LockedError{Until: time.Now()}
+	if !errors.Is(err, errorHelper.Locked) {
+		t.Fatal("LockedError must match errorHelper.Locked")
+	}
+	var locked *LockedError
+	if !errors.As(errors.Join(errors.New("wrapped"), err), &locked) {
+		t.Fatal("LockedError must be found in joined error")
+	}
+}
+
+func TestLockoutLogin(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	id, err := Users(store).Add(ctx, &models.User{Login: "alice", Password: testPassword})
+	require.NoError(t, err)
+	lockout := Lockout(store, config.LockoutConfig{
+		AccountThreshold: 2,
+		BaseDelay:        time.Minute,
+		MaxDelay:         time.Hour,
+		Window:           time.Hour,
+	})
+
+	_, err = lockout.Login(ctx, "alice", "wrong", "10.0.0.1")
+	require.ErrorIs(t, err, errorHelper.InvalidCredentials)
+	_, err = lockout.Login(ctx, "alice", "wrong", "10.0.0.1")
+	require.ErrorIs(t, err, errorHelper.InvalidCredentials)
+	//the right password is not even checked while locked
+	_, err = lockout.Login(ctx, "alice", testPassword, "10.0.0.2")
+	require.Equal(t, errorHelper.Locked, errorHelper.KindOf(err))
+	var locked *LockedError
+	require.ErrorAs(t, err, &locked)
+	require.WithinDuration(t, time.Now().Add(time.Minute), locked.Until, 5*time.Second)
+
+	require.NoError(t, lockout.Unlock(ctx, id))
+	_, err = lockout.Login(ctx, "alice", testPassword, "10.0.0.2")
+	require.NoError(t, err)
+}
+
+func TestLockoutSecondFactor(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	uid := env.addUser(t, "alice")
+	secret, _ := enrollMfa(t, env, uid)
+
+	_, _, err := Auth(ctx, "alice", "wrong", "10.0.0.1", env.lockout, env.tokens, env.mfa)
+	require.ErrorIs(t, err, errorHelper.InvalidCredentials)
+	//the right password alone does not reset the account counter
+	_, challenge, err := Auth(ctx, "alice", testPassword, "10.0.0.1", env.lockout, env.tokens, env.mfa)
+	require.NoError(t, err)
+	require.NotEmpty(t, challenge)
+	_, err = env.mfa.Verify(ctx, challenge, "000000", "", "10.0.0.1")
+	require.ErrorIs(t, err, errorHelper.InvalidCredentials)
+	attempts, err := env.storage.Attempts().GetAttempts(ctx, accountKey("alice"))
+	require.NoError(t, err)
+	require.Equal(t, 2, attempts.Failures)
+
+	_, err = env.mfa.Verify(ctx, challenge, totpCode(t, secret, totpHelper.Step(time.Now())), "", "10.0.0.1")
+	require.NoError(t, err)
+	_, err = env.storage.Attempts().GetAttempts(ctx, accountKey("alice"))
+	require.ErrorIs(t, err, errorHelper.NotFound)
+}
@@ -0,0 +1,327 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+	"sso/internal/config"
+	"sso/internal/models"
+	"sso/internal/storage"
+	"sso/pkg/helpers/errorHelper"
+	"sso/pkg/helpers/tokenHelper"
+	"sso/pkg/helpers/totpHelper"
+	"strings"
+	"time"
+)
+
+const (
+	ErrorMfaEnroll        = "Error on mfa enrollment"
+	ErrorMfaEnabled       = "mfa is already enabled"
+	ErrorMfaNotPending    = "mfa enrollment is not started"
+	ErrorMfaNotEnabled    = "mfa is not enabled"
+	ErrorMfaConfirm       = "Error on mfa confirmation"
+	ErrorMfaChallenge     = "Error on create mfa challenge"
+	ErrorMfaVerify        = "Error on mfa verification"
+	ErrorMfaReset         = "Error on mfa reset"
+	ErrorMfaToken         = "not an mfa challenge token"
+	ErrorMfaCode          = "wrong or already used code"
+	ErrorRecoveryGenerate = "error on generate recovery codes"
+)
+
+// TokenUseMfa marks MFA challenge tokens, they only prove that the password was checked
+const TokenUseMfa = "mfa"
+
+const (
+	recoveryCodesCount    = 10
+	recoveryCodeLength    = 10
+	recoveryCodeAlphabet  = "abcdefghjkmnpqrstuvwxyz23456789" //no look-alike characters
+	recoveryCodeGroupSize = 5
+)
+
+// MfaService manages TOTP second factor: enrollment, MFA challenge after password check and its verification
+type MfaService struct {
+	storage     storage.Storage
+	tokens      *TokensService
+	keys        Keyring
+	revocations *RevocationsService
+	lockout     *LockoutService
+	config      config.MfaConfig
+}
+
+func Mfa(storage storage.Storage, tokens *TokensService, keys Keyring, revocations *RevocationsService, lockout *LockoutService, config config.MfaConfig) *MfaService {
+	return &MfaService{
+		storage:     storage,
+		tokens:      tokens,
+		keys:        keys,
+		revocations: revocations,
+		lockout:     lockout,
+		config:      config,
+	}
+}
+
+// Enroll generates a new TOTP secret, it has no effect until confirmed. Returns the secret and its otpauth URI.
+func (m *MfaService) Enroll(ctx context.Context, userId string) (string, string, error) {
+	const operation = "internal.services.mfa.Enroll()"
+	user, err := m.storage.Users().GetUserById(ctx, userId)
+	if err != nil {
+		return "", "", errorHelper.WrapError(operation, ErrorGetUser, err)
+	}
+	if user.Mfa.TotpEnabled {
+		return "", "", errorHelper.Wrap(errorHelper.Conflict, operation, ErrorMfaEnroll, errors.New(ErrorMfaEnabled))
+	}
+	secret, err := totpHelper.GenerateSecret()
+	if err != nil {
+		return "", "", errorHelper.WrapError(operation, ErrorMfaEnroll, err)
+	}
+	user.Mfa = models.Mfa{TotpSecret: secret}
+	if err := m.storage.Users().UpdateUser(ctx, userId, models.UserUpdate{Mfa: &user.Mfa}); err != nil {
+		return "", "", errorHelper.WrapError(operation, ErrorMfaEnroll, err)
+	}
+	return secret, totpHelper.URI(m.config.Issuer, user.Login, secret), nil
+}
+
+// PendingURI returns otpauth URI of not confirmed enrollment, confirmed secrets are never shown again
+func (m *MfaService) PendingURI(ctx context.Context, userId string) (string, error) {
+	const operation = "internal.services.mfa.PendingURI()"
+	user, err := m.storage.Users().GetUserById(ctx, userId)
+	if err != nil {
+		return "", errorHelper.WrapError(operation, ErrorGetUser, err)
+	}
+	if user.Mfa.TotpSecret == "" || user.Mfa.TotpEnabled {
+		return "", errorHelper.Wrap(errorHelper.Invalid, operation, ErrorMfaEnroll, errors.New(ErrorMfaNotPending))
+	}
+	return totpHelper.URI(m.config.Issuer, user.Login, user.Mfa.TotpSecret), nil
+}
+
+// Confirm enables MFA if the code matches the enrolled secret and returns one-time recovery codes
+func (m *MfaService) Confirm(ctx context.Context, userId string, code string) ([]string, error) {
+	const operation = "internal.services.mfa.Confirm()"
+	user, err := m.storage.Users().GetUserById(ctx, userId)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorGetUser, err)
+	}
+	if user.Mfa.TotpSecret == "" || user.Mfa.TotpEnabled {
+		return nil, errorHelper.Wrap(errorHelper.Invalid, operation, ErrorMfaConfirm, errors.New(ErrorMfaNotPending))
+	}
+	step, ok, err := totpHelper.Validate(user.Mfa.TotpSecret, code, time.Now())
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorMfaConfirm, err)
+	}
+	if !ok {
+		return nil, errorHelper.New(errorHelper.InvalidCredentials, operation, ErrorMfaCode)
+	}
+	codes, hashes, err := recoveryCodes()
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorMfaConfirm, err)
+	}
+	user.Mfa.TotpEnabled = true
+	user.Mfa.TotpStep = step
+	user.Mfa.RecoveryCodes = hashes
+	if err := m.storage.Users().UpdateUser(ctx, userId, models.UserUpdate{Mfa: &user.Mfa}); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorMfaConfirm, err)
+	}
+	return codes, nil
+}
+
+// Reset disables MFA of the user and drops the secret and recovery codes
+func (m *MfaService) Reset(ctx context.Context, userId string) error {
+	const operation = "internal.services.mfa.Reset()"
+	if err := m.storage.Users().UpdateUser(ctx, userId, models.UserUpdate{Mfa: &models.Mfa{}}); err != nil {
+		return errorHelper.WrapError(operation, ErrorMfaReset, err)
+	}
+	if err := m.storage.Credentials().DeleteUserCredentials(ctx, userId); err != nil {
+		return errorHelper.WrapError(operation, ErrorMfaReset, err)
+	}
+	return nil
+}
+
+// Required tells if the user has to pass second factor after password check: TOTP or a security key
+func (m *MfaService) Required(ctx context.Context, user *models.User) (bool, error) {
+	const operation = "internal.services.mfa.Required()"
+	if user.Mfa.TotpEnabled {
+		return true, nil
+	}
+	credentials, err := m.storage.Credentials().GetUserCredentials(ctx, user.Id)
+	if err != nil {
+		return false, errorHelper.WrapError(operation, ErrorGetCredentials, err)
+	}
+	return len(credentials) > 0, nil
+}
+
+// Challenge creates short-lived token proving that the user passed password check
+func (m *MfaService) Challenge(ctx context.Context, user *models.User) (string, error) {
+	const operation = "internal.services.mfa.Challenge()"
+	token, err := m.tokens.sign(ctx, map[string]any{
+		"sub":       user.Id,
+		"token_use": TokenUseMfa,
+		"auth_time": time.Now().Unix(),
+	}, m.config.ChallengeTTL)
+	if err != nil {
+		return "", errorHelper.WrapError(operation, ErrorMfaChallenge, err)
+	}
+	return token, nil
+}
+
+// Verify checks MFA challenge token and TOTP or recovery code. The challenge token and the code are single use.
+// Wrong codes are counted as failed logins of the account, the challenge is revoked after MaxAttempts wrong codes.
+func (m *MfaService) Verify(ctx context.Context, challenge string, code string, recoveryCode string, ip string) (*models.User, error) {
+	const operation = "internal.services.mfa.Verify()"
+	claims, user, err := m.challengeUser(ctx, challenge)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorMfaVerify, err)
+	}
+	if err := m.lockout.Check(ctx, user.Login, ip); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorMfaVerify, err)
+	}
+	if !user.Mfa.TotpEnabled {
+		return nil, errorHelper.New(errorHelper.InvalidCredentials, operation, ErrorMfaNotEnabled)
+	}
+	ok, err := m.useCode(ctx, user, code, recoveryCode)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorMfaVerify, err)
+	}
+	if !ok {
+		if err := m.failure(ctx, claims, user, ip); err != nil {
+			return nil, errorHelper.WrapError(operation, ErrorMfaVerify, err)
+		}
+		return nil, errorHelper.New(errorHelper.InvalidCredentials, operation, ErrorMfaCode)
+	}
+	if err := consumeToken(ctx, m.revocations, claims); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorMfaVerify, err)
+	}
+	if err := m.lockout.Success(ctx, user.Login); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorMfaVerify, err)
+	}
+	return user, nil
+}
+
+// useCode checks TOTP or recovery code and marks it used in one conditional write,
+// returns false for wrong codes and for codes used by a concurrent request
+func (m *MfaService) useCode(ctx context.Context, user *models.User, code string, recoveryCode string) (bool, error) {
+	const operation = "internal.services.mfa.useCode()"
+	var ok bool
+	var err error
+	if recoveryCode != "" {
+		ok, err = m.storage.Users().UseMfaCode(ctx, user.Id, 0, tokenHelper.Hash(normalizeRecoveryCode(recoveryCode)))
+	} else {
+		var step int64
+		step, ok, err = totpHelper.Validate(user.Mfa.TotpSecret, code, time.Now())
+		if err == nil && ok {
+			ok, err = m.storage.Users().UseMfaCode(ctx, user.Id, step, "")
+		}
+	}
+	if err != nil {
+		return false, errorHelper.WrapError(operation, ErrorMfaVerify, err)
+	}
+	return ok, nil
+}
+
+// failure counts wrong code against the account and the challenge, the challenge is revoked after MaxAttempts wrong codes
+func (m *MfaService) failure(ctx context.Context, claims jwt.MapClaims, user *models.User, ip string) error {
+	const operation = "internal.services.mfa.failure()"
+	if err := m.lockout.Failure(ctx, user.Login, ip); err != nil {
+		return errorHelper.WrapError(operation, ErrorMfaVerify, err)
+	}
+	if m.config.MaxAttempts <= 0 {
+		return nil
+	}
+	jti, _ := claims["jti"].(string)
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return errorHelper.Wrap(errorHelper.InvalidCredentials, operation, ErrorMfaToken, err)
+	}
+	attempts, err := m.storage.Attempts().AddFailure(ctx, challengeKey(jti), time.Now(), exp.Time)
+	if err != nil {
+		return errorHelper.WrapError(operation, ErrorMfaVerify, err)
+	}
+	if attempts.Failures < m.config.MaxAttempts {
+		return nil
+	}
+	if err := consumeToken(ctx, m.revocations, claims); err != nil {
+		return errorHelper.WrapError(operation, ErrorMfaVerify, err)
+	}
+	return nil
+}
+
+// Consume checks MFA challenge token and revokes it, the second factor is checked by the caller
+func (m *MfaService) Consume(ctx context.Context, challenge string) (*models.User, error) {
+	const operation = "internal.services.mfa.Consume()"
+	claims, user, err := m.challengeUser(ctx, challenge)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorMfaVerify, err)
+	}
+	if err := consumeToken(ctx, m.revocations, claims); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorMfaVerify, err)
+	}
+	return user, nil
+}
+
+// challengeUser verifies not used MFA challenge token and returns its claims and user
+func (m *MfaService) challengeUser(ctx context.Context, challenge string) (jwt.MapClaims, *models.User, error) {
+	const operation = "internal.services.mfa.challengeUser()"
+	claims, err := verify(ctx, challenge, m.keys)
+	if err != nil {
+		return nil, nil, errorHelper.WrapError(operation, ErrorMfaVerify, err)
+	}
+	jti, _ := claims["jti"].(string)
+	if claims["token_use"] != TokenUseMfa || jti == "" {
+		return nil, nil, errorHelper.New(errorHelper.InvalidCredentials, operation, ErrorMfaToken)
+	}
+	if err := notRevoked(ctx, m.revocations, jti); err != nil {
+		return nil, nil, errorHelper.WrapError(operation, ErrorMfaVerify, err)
+	}
+	userId, _ := claims.GetSubject()
+	user, err := m.storage.Users().GetUserById(ctx, userId)
+	if errorHelper.KindOf(err) == errorHelper.NotFound {
+		return nil, nil, errorHelper.Wrap(errorHelper.InvalidCredentials, operation, ErrorGetUser, err)
+	} else if err != nil {
+		return nil, nil, errorHelper.WrapError(operation, ErrorGetUser, err)
+	}
+	return claims, user, nil
+}
+
+// consumeToken revokes single use token until it expires
+func consumeToken(ctx context.Context, revocations *RevocationsService, claims jwt.MapClaims) error {
+	jti, _ := claims["jti"].(string)
+	exp, err := claims.GetExpirationTime()
+	if err == nil && exp != nil {
+		err = revocations.RevokeJti(ctx, jti, exp.Time)
+	}
+	return err
+}
+
+// challengeKey is the attempts counter of one MFA challenge
+func challengeKey(jti string) string {
+	return "mfa:" + jti
+}
+
+// recoveryCodes generates recovery codes and their hashes to store
+func recoveryCodes() ([]string, []string, error) {
+	const operation = "internal.services.mfa.recoveryCodes()"
+	codes := make([]string, 0, recoveryCodesCount)
+	hashes := make([]string, 0, recoveryCodesCount)
+	max := big.NewInt(int64(len(recoveryCodeAlphabet)))
+	for i := 0; i < recoveryCodesCount; i++ {
+		b := strings.Builder{}
+		for j := 0; j < recoveryCodeLength; j++ {
+			if j > 0 && j%recoveryCodeGroupSize == 0 {
+				b.WriteByte('-')
+			}
+			n, err := rand.Int(rand.Reader, max)
+			if err != nil {
+				return nil, nil, errorHelper.WrapError(operation, ErrorRecoveryGenerate, err)
+			}
+			b.WriteByte(recoveryCodeAlphabet[n.Int64()])
+		}
+		codes = append(codes, b.String())
+		hashes = append(hashes, tokenHelper.Hash(normalizeRecoveryCode(b.String())))
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode makes codes typed with dashes, spaces or upper case comparable
+func normalizeRecoveryCode(code string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(code))
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"encoding/base32"
+	"github.com/stretchr/testify/require"
+	"regexp"
+	"sso/pkg/helpers/errorHelper"
+	"sso/pkg/helpers/tokenHelper"
+	"sso/pkg/helpers/totpHelper"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, hashes, err := recoveryCodes()
+	require.NoError(t, err)
+	require.Len(t, codes, recoveryCodesCount)
+	require.Len(t, hashes, recoveryCodesCount)
+	format := regexp.MustCompile(`^[a-z2-9]{5}-[a-z2-9]{5}$`)
+	for i, code := range codes {
+		require.Regexp(t, format, code)
+		//codes are accepted regardless of case, dashes and spaces
+		typed := strings.ToUpper(strings.ReplaceAll(code, "-", " "))
+		require.Equal(t, hashes[i], tokenHelper.Hash(normalizeRecoveryCode(typed)))
+	}
+}
+
+// enrollMfa enables TOTP of the user and returns the secret and recovery codes
+func enrollMfa(t *testing.T, env *testEnv, userId string) (string, []string) {
+	t.Helper()
+	ctx := context.Background()
+	secret, _, err := env.mfa.Enroll(ctx, userId)
+	require.NoError(t, err)
+	//confirmed with the previous step, so the current one is still unused
+	codes, err := env.mfa.Confirm(ctx, userId, totpCode(t, secret, totpHelper.Step(time.Now())-1))
+	require.NoError(t, err)
+	return secret, codes
+}
+
+func totpCode(t *testing.T, secret string, step int64) string {
+	t.Helper()
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	require.NoError(t, err)
+	return totpHelper.Code(key, step, totpHelper.Digits)
+}
+
+func challenge(t *testing.T, env *testEnv, userId string) string {
+	t.Helper()
+	user, err := env.users.Get(context.Background(), userId)
+	require.NoError(t, err)
+	token, err := env.mfa.Challenge(context.Background(), user)
+	require.NoError(t, err)
+	return token
+}
+
+func TestMfaVerifyParallel(t *testing.T) {
+	env := newTestEnv(t)
+	uid := env.addUser(t, "alice")
+	secret, recovery := enrollMfa(t, env, uid)
+
+	//the same code sent twice at once is accepted only once
+	verify := func(code string, recoveryCode string) []error {
+		errs := make([]error, 2)
+		challenges := []string{challenge(t, env, uid), challenge(t, env, uid)}
+		wg := sync.WaitGroup{}
+		for i := range errs {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, errs[i] = env.mfa.Verify(context.Background(), challenges[i], code, recoveryCode, "")
+			}()
+		}
+		wg.Wait()
+		return errs
+	}
+	for _, errs := range [][]error{
+		verify(totpCode(t, secret, totpHelper.Step(time.Now())), ""),
+		verify("", recovery[0]),
+	} {
+		if errs[0] != nil {
+			errs[0], errs[1] = errs[1], errs[0]
+		}
+		require.NoError(t, errs[0])
+		require.ErrorIs(t, errs[1], errorHelper.InvalidCredentials)
+	}
+	user, err := env.users.Get(context.Background(), uid)
+	require.NoError(t, err)
+	require.Len(t, user.Mfa.RecoveryCodes, len(recovery)-1)
+}
+
+func TestMfaVerifyFailures(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	uid := env.addUser(t, "alice")
+	secret, _ := enrollMfa(t, env, uid)
+	code := totpCode(t, secret, totpHelper.Step(time.Now()))
+
+	//the challenge is revoked after MaxAttempts wrong codes
+	token := challenge(t, env, uid)
+	for i := 0; i < 3; i++ {
+		_, err := env.mfa.Verify(ctx, token, "000000", "", "10.0.0.1")
+		require.ErrorIs(t, err, errorHelper.InvalidCredentials)
+	}
+	_, err := env.mfa.Verify(ctx, token, code, "", "10.0.0.1")
+	require.ErrorIs(t, err, errorHelper.InvalidCredentials)
+
+	//wrong codes are failed logins of the account, the account is locked at the threshold
+	attempts, err := env.storage.Attempts().GetAttempts(ctx, accountKey("alice"))
+	require.NoError(t, err)
+	require.Equal(t, 3, attempts.Failures)
+	token = challenge(t, env, uid)
+	for i := 0; i < 2; i++ {
+		_, err = env.mfa.Verify(ctx, token, "", "wrong-code", "10.0.0.1")
+		require.ErrorIs(t, err, errorHelper.InvalidCredentials)
+	}
+	_, err = env.mfa.Verify(ctx, token, code, "", "10.0.0.1")
+	require.ErrorIs(t, err, errorHelper.Locked)
+	_, err = env.lockout.Login(ctx, "alice", testPassword, "10.0.0.2")
+	require.ErrorIs(t, err, errorHelper.Locked)
+
+	require.NoError(t, env.lockout.Unlock(ctx, uid))
+	user, err := env.mfa.Verify(ctx, token, code, "", "10.0.0.1")
+	require.NoError(t, err)
+	require.Equal(t, uid, user.Id)
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sso/internal/config"
+	"sso/internal/storage"
+	"sso/pkg/helpers/errorHelper"
+	"sso/pkg/helpers/tokenHelper"
+	"sync"
+	"time"
+)
+
+const (
+	TokenTypeAccess  = "access_token"
+	TokenTypeRefresh = "refresh_token"
+)
+
+const (
+	ErrorRevoke               = "failed to revoke token"
+	ErrorSyncRevocations      = "failed to load revoked tokens"
+	ErrorUnsupportedTokenType = "unsupported token type"
+	ErrorCheckRevoked         = "failed to check token revocation"
+	ErrorForeignToken         = "token was issued to another client"
+)
+
+// revocationsOverlap covers clock skew between replicas on incremental sync
+const revocationsOverlap = time.Minute
+
+// RevocationsService keeps revoked access token ids. Checks are served from a local copy
+// of the denylist which is synchronized incrementally with storage every config.RevocationSync,
+// so revocations made on other replicas are visible after at most that interval.
+type RevocationsService struct {
+	storage  storage.Storage
+	keys     Keyring
+	config   config.TokensConfig
+	mu       sync.Mutex
+	revoked  map[string]time.Time //jti -> token expiration
+	syncedAt time.Time
+}
+
+func Revocations(storage storage.Storage, keys Keyring, config config.TokensConfig) *RevocationsService {
+	return &RevocationsService{
+		storage: storage,
+		keys:    keys,
+		config:  config,
+		revoked: make(map[string]time.Time),
+	}
+}
+
+// IsRevoked reports whether access token id is in the denylist
+func (r *RevocationsService) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	const operation = "internal.services.revocations.IsRevoked()"
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(r.syncedAt) >= r.config.RevocationSync {
+		if err := r.sync(ctx); err != nil {
+			return false, errorHelper.WrapError(operation, ErrorSyncRevocations, err)
+		}
+	}
+	exp, ok := r.revoked[jti]
+	return ok && time.Now().Before(exp), nil
+}
+
+// notRevoked returns InvalidCredentials error if the token id is in the denylist
+func notRevoked(ctx context.Context, denylist Denylist, jti string) error {
+	const operation = "internal.services.revocations.notRevoked()"
+	revoked, err := denylist.IsRevoked(ctx, jti)
+	if err != nil {
+		return errorHelper.WrapError(operation, ErrorCheckRevoked, err)
+	}
+	if revoked {
+		return errorHelper.New(errorHelper.InvalidCredentials, operation, ErrorTokenRevoked)
+	}
+	return nil
+}
+
+// Revoke implements RFC 7009 semantics: unknown, invalid and expired tokens are not an error.
+// Revoking a refresh token revokes its whole family. Only tokens issued to clientId are revoked,
+// tokens of other clients are rejected with InvalidCredentials error. Empty clientId is the first party.
+func (r *RevocationsService) Revoke(ctx context.Context, token string, hint string, clientId string) error {
+	const operation = "internal.services.revocations.Revoke()"
+	switch hint {
+	case "", TokenTypeAccess, TokenTypeRefresh:
+	default:
+		return errorHelper.Wrap(errorHelper.Invalid, operation, ErrorUnsupportedTokenType, errors.New(hint))
+	}
+	//the hint only defines lookup order
+	if hint == TokenTypeAccess {
+		if ok, err := r.revokeAccess(ctx, token, clientId); ok || err != nil {
+			return err
+		}
+		_, err := r.revokeRefresh(ctx, token, clientId)
+		return err
+	}
+	if ok, err := r.revokeRefresh(ctx, token, clientId); ok || err != nil {
+		return err
+	}
+	_, err := r.revokeAccess(ctx, token, clientId)
+	return err
+}
+
+func (r *RevocationsService) revokeRefresh(ctx context.Context, token string, clientId string) (bool, error) {
+	const operation = "internal.services.revocations.revokeRefresh()"
+	t, err := r.storage.Tokens().GetToken(ctx, tokenHelper.Hash(token))
+	if errorHelper.KindOf(err) == errorHelper.NotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, errorHelper.WrapError(operation, ErrorRevoke, err)
+	}
+	if t.ClientId != clientId {
+		return false, errorHelper.New(errorHelper.InvalidCredentials, operation, ErrorForeignToken)
+	}
+	if err := r.storage.Tokens().RevokeFamily(ctx, t.Family); err != nil {
+		return false, errorHelper.WrapError(operation, ErrorRevoke, err)
+	}
+	return true, nil
+}
+
+func (r *RevocationsService) revokeAccess(ctx context.Context, token string, clientId string) (bool, error) {
+	const operation = "internal.services.revocations.revokeAccess()"
+	claims, err := verify(ctx, token, r.keys)
+	if err != nil {
+		return false, nil
+	}
+	jti, ok := claims["jti"].(string)
+	if !ok || jti == "" {
+		return false, nil
+	}
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return false, nil
+	}
+	//first party tokens have no audience
+	aud, _ := claims.GetAudience()
+	if (clientId == "" && len(aud) > 0) || (clientId != "" && !slices.Contains(aud, clientId)) {
+		return false, errorHelper.New(errorHelper.InvalidCredentials, operation, ErrorForeignToken)
+	}
+	if err := r.RevokeJti(ctx, jti, exp.Time); err != nil {
+		return false, errorHelper.WrapError(operation, ErrorRevoke, err)
+	}
+	return true, nil
+}
+
+// RevokeJti adds token id to the denylist until exp
+func (r *RevocationsService) RevokeJti(ctx context.Context, jti string, exp time.Time) error {
+	const operation = "internal.services.revocations.RevokeJti()"
+	if err := r.storage.Revocations().Revoke(ctx, jti, exp); err != nil {
+		return errorHelper.WrapError(operation, ErrorRevoke, err)
+	}
+	r.mu.Lock()
+	r.revoked[jti] = exp
+	r.mu.Unlock()
+	return nil
+}
+
+// sync loads revocations made since the last sync, must be called under lock
+func (r *RevocationsService) sync(ctx context.Context) error {
+	const operation = "internal.services.revocations.sync()"
+	now := time.Now()
+	since := time.Time{}
+	if !r.syncedAt.IsZero() {
+		since = r.syncedAt.Add(-revocationsOverlap)
+	}
+	list, err := r.storage.Revocations().GetRevoked(ctx, since)
+	if err != nil {
+		return errorHelper.WrapError(operation, ErrorSyncRevocations, err)
+	}
+	for jti, exp := range r.revoked {
+		if now.After(exp) {
+			delete(r.revoked, jti)
+		}
+	}
+	for _, item := range list {
+		r.revoked[item.Jti] = item.Exp
+	}
+	r.syncedAt = now
+	return nil
+}
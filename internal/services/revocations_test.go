@@ -0,0 +1,51 @@
+package services
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"sso/pkg/helpers/errorHelper"
+	"testing"
+)
+
+func TestRevokeOwnTokensOnly(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	uid := env.addUser(t, "alice")
+	pair, err := env.tokens.Issue(ctx, Grant{UserId: uid, ClientId: "app"})
+	require.NoError(t, err)
+
+	//other clients and the first party can't revoke tokens of app
+	for _, caller := range []string{"other", ""} {
+		for _, token := range []string{pair.AccessToken, pair.RefreshToken} {
+			err = env.revocations.Revoke(ctx, token, "", caller)
+			require.Equal(t, errorHelper.InvalidCredentials, errorHelper.KindOf(err), caller)
+		}
+	}
+	_, err = Claims(ctx, pair.AccessToken, env.keys, env.revocations)
+	require.NoError(t, err)
+
+	require.NoError(t, env.revocations.Revoke(ctx, pair.AccessToken, TokenTypeAccess, "app"))
+	_, err = Claims(ctx, pair.AccessToken, env.keys, env.revocations)
+	require.Equal(t, errorHelper.InvalidCredentials, errorHelper.KindOf(err))
+	require.NoError(t, env.revocations.Revoke(ctx, pair.RefreshToken, TokenTypeRefresh, "app"))
+	_, err = env.tokens.Refresh(ctx, pair.RefreshToken, "app")
+	require.Equal(t, errorHelper.InvalidCredentials, errorHelper.KindOf(err))
+
+	//first party tokens are revoked by the first party
+	pair, err = env.tokens.Issue(ctx, Grant{UserId: uid})
+	require.NoError(t, err)
+	err = env.revocations.Revoke(ctx, pair.RefreshToken, "", "app")
+	require.Equal(t, errorHelper.InvalidCredentials, errorHelper.KindOf(err))
+	require.NoError(t, env.revocations.Revoke(ctx, pair.RefreshToken, "", ""))
+	require.NoError(t, env.revocations.Revoke(ctx, pair.AccessToken, "", ""))
+
+	//client credentials tokens are revoked by their client
+	pair, err = env.tokens.IssueClient(ctx, "service", "")
+	require.NoError(t, err)
+	err = env.revocations.Revoke(ctx, pair.AccessToken, "", "app")
+	require.Equal(t, errorHelper.InvalidCredentials, errorHelper.KindOf(err))
+	require.NoError(t, env.revocations.Revoke(ctx, pair.AccessToken, "", "service"))
+
+	//unknown tokens are not an error
+	require.NoError(t, env.revocations.Revoke(ctx, "unknown", "", "app"))
+}
@@ -0,0 +1,229 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sso/internal/models"
+	"sso/internal/storage"
+	"sso/pkg/helpers/errorHelper"
+	"strings"
+)
+
+const (
+	ErrorGetRoles          = "Error on get roles"
+	ErrorAddRole           = "Error on add role"
+	ErrorUpdateRole        = "Error on update role"
+	ErrorDeleteRole        = "Error on delete role"
+	ErrorGetPermissions    = "Error on get permissions"
+	ErrorAddPermission     = "Error on add permission"
+	ErrorDeletePermission  = "Error on delete permission"
+	ErrorEmptyName         = "Empty name"
+	ErrorUnknownRoles      = "Unknown roles"
+	ErrorUnknownPermission = "Unknown permissions"
+	ErrorDeleteAdminRole   = "Admin role can not be deleted"
+)
+
+type RolesService struct {
+	storage storage.Storage
+}
+
+func Roles(storage storage.Storage) *RolesService {
+	return &RolesService{
+		storage: storage,
+	}
+}
+
+func (r *RolesService) List(ctx context.Context) ([]*models.Role, error) {
+	const operation = "internal.services.roles.List()"
+	roles, err := r.storage.Roles().ListRoles(ctx)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorGetRoles, err)
+	}
+	return roles, nil
+}
+
+func (r *RolesService) Get(ctx context.Context, name string) (*models.Role, error) {
+	const operation = "internal.services.roles.Get()"
+	role, err := r.storage.Roles().GetRole(ctx, name)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorGetRoles, err)
+	}
+	return role, nil
+}
+
+// Add creates role, all its permissions must exist
+func (r *RolesService) Add(ctx context.Context, role *models.Role) (string, error) {
+	const operation = "internal.services.roles.Add()"
+	if strings.TrimSpace(role.Name) == "" {
+		return "", errorHelper.Wrap(errorHelper.Invalid, operation, ErrorAddRole, errors.New(ErrorEmptyName))
+	}
+	if err := checkPermissions(ctx, r.storage, role.Permissions); err != nil {
+		return "", errorHelper.WrapError(operation, ErrorAddRole, err)
+	}
+	id, err := r.storage.Roles().InsertRole(ctx, role)
+	if err != nil {
+		return "", errorHelper.WrapError(operation, ErrorAddRole, err)
+	}
+	return id, nil
+}
+
+// Update changes role description and permissions, nil values are left as is
+func (r *RolesService) Update(ctx context.Context, name string, description *string, permissions *[]string) (*models.Role, error) {
+	const operation = "internal.services.roles.Update()"
+	role, err := r.storage.Roles().GetRole(ctx, name)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorGetRoles, err)
+	}
+	if description != nil {
+		role.Description = *description
+	}
+	if permissions != nil {
+		if err := checkPermissions(ctx, r.storage, *permissions); err != nil {
+			return nil, errorHelper.WrapError(operation, ErrorUpdateRole, err)
+		}
+		role.Permissions = *permissions
+	}
+	if err := r.storage.Roles().UpdateRole(ctx, role); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorUpdateRole, err)
+	}
+	return role, nil
+}
+
+// Delete deletes role and unassigns it from users. Admin role is protected so the admin API stays reachable.
+func (r *RolesService) Delete(ctx context.Context, name string) error {
+	const operation = "internal.services.roles.Delete()"
+	if name == models.RoleAdmin {
+		return errorHelper.Wrap(errorHelper.Invalid, operation, ErrorDeleteRole, errors.New(ErrorDeleteAdminRole))
+	}
+	if err := r.storage.Roles().DeleteRole(ctx, name); err != nil {
+		return errorHelper.WrapError(operation, ErrorDeleteRole, err)
+	}
+	if err := r.storage.Users().RemoveRole(ctx, name); err != nil {
+		return errorHelper.WrapError(operation, ErrorDeleteRole, err)
+	}
+	return nil
+}
+
+// Assign adds role to the user
+func (r *RolesService) Assign(ctx context.Context, userId string, role string) (*models.User, error) {
+	const operation = "internal.services.roles.Assign()"
+	user, err := r.storage.Users().GetUserById(ctx, userId)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorGetUser, err)
+	}
+	if err := checkRoles(ctx, r.storage, []string{role}); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorUpdateUser, err)
+	}
+	if slices.Contains(user.Roles, role) {
+		return user, nil
+	}
+	user.Roles = append(user.Roles, role)
+	if err := r.storage.Users().UpdateUser(ctx, userId, models.UserUpdate{Roles: &user.Roles}); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorUpdateUser, err)
+	}
+	return user, nil
+}
+
+// Unassign removes role from the user
+func (r *RolesService) Unassign(ctx context.Context, userId string, role string) (*models.User, error) {
+	const operation = "internal.services.roles.Unassign()"
+	user, err := r.storage.Users().GetUserById(ctx, userId)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorGetUser, err)
+	}
+	if !slices.Contains(user.Roles, role) {
+		return user, nil
+	}
+	user.Roles = slices.DeleteFunc(user.Roles, func(s string) bool { return s == role })
+	if err := r.storage.Users().UpdateUser(ctx, userId, models.UserUpdate{Roles: &user.Roles}); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorUpdateUser, err)
+	}
+	return user, nil
+}
+
+func (r *RolesService) ListPermissions(ctx context.Context) ([]*models.Permission, error) {
+	const operation = "internal.services.roles.ListPermissions()"
+	permissions, err := r.storage.Permissions().ListPermissions(ctx)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorGetPermissions, err)
+	}
+	return permissions, nil
+}
+
+func (r *RolesService) AddPermission(ctx context.Context, permission *models.Permission) (string, error) {
+	const operation = "internal.services.roles.AddPermission()"
+	if strings.TrimSpace(permission.Name) == "" {
+		return "", errorHelper.Wrap(errorHelper.Invalid, operation, ErrorAddPermission, errors.New(ErrorEmptyName))
+	}
+	id, err := r.storage.Permissions().InsertPermission(ctx, permission)
+	if err != nil {
+		return "", errorHelper.WrapError(operation, ErrorAddPermission, err)
+	}
+	return id, nil
+}
+
+// DeletePermission deletes permission and removes it from roles
+func (r *RolesService) DeletePermission(ctx context.Context, name string) error {
+	const operation = "internal.services.roles.DeletePermission()"
+	if err := r.storage.Permissions().DeletePermission(ctx, name); err != nil {
+		return errorHelper.WrapError(operation, ErrorDeletePermission, err)
+	}
+	if err := r.storage.Roles().RemovePermission(ctx, name); err != nil {
+		return errorHelper.WrapError(operation, ErrorDeletePermission, err)
+	}
+	return nil
+}
+
+// Permissions returns sorted union of permissions granted by roles, unknown roles are ignored
+func Permissions(ctx context.Context, storage storage.Storage, roles []string) ([]string, error) {
+	const operation = "internal.services.roles.Permissions()"
+	list, err := storage.Roles().GetRoles(ctx, roles)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorGetRoles, err)
+	}
+	res := make([]string, 0)
+	for _, role := range list {
+		res = append(res, role.Permissions...)
+	}
+	slices.Sort(res)
+	return slices.Compact(res), nil
+}
+
+// checkRoles makes sure all roles exist
+func checkRoles(ctx context.Context, storage storage.Storage, names []string) error {
+	const operation = "internal.services.roles.checkRoles()"
+	roles, err := storage.Roles().GetRoles(ctx, names)
+	if err != nil {
+		return errorHelper.WrapError(operation, ErrorGetRoles, err)
+	}
+	if unknown := missing(names, roles, func(r *models.Role) string { return r.Name }); len(unknown) > 0 {
+		return errorHelper.Wrap(errorHelper.Invalid, operation, ErrorUnknownRoles, fmt.Errorf("%v", unknown))
+	}
+	return nil
+}
+
+// checkPermissions makes sure all permissions exist
+func checkPermissions(ctx context.Context, storage storage.Storage, names []string) error {
+	const operation = "internal.services.roles.checkPermissions()"
+	permissions, err := storage.Permissions().GetPermissions(ctx, names)
+	if err != nil {
+		return errorHelper.WrapError(operation, ErrorGetPermissions, err)
+	}
+	if unknown := missing(names, permissions, func(p *models.Permission) string { return p.Name }); len(unknown) > 0 {
+		return errorHelper.Wrap(errorHelper.Invalid, operation, ErrorUnknownPermission, fmt.Errorf("%v", unknown))
+	}
+	return nil
+}
+
+// missing returns names not found in items
+func missing[T any](names []string, items []T, name func(T) string) []string {
+	res := make([]string, 0)
+	for _, n := range names {
+		if !slices.ContainsFunc(items, func(item T) bool { return name(item) == n }) {
+			res = append(res, n)
+		}
+	}
+	return res
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"sso/internal/models"
+	"sso/internal/storage/memory"
+	"sso/pkg/helpers/errorHelper"
+	"testing"
+)
+
+func TestPermissions(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	roles := Roles(env.storage)
+	for _, name := range []string{"users:read", "users:write", "reports:read"} {
+		_, err := roles.AddPermission(ctx, &models.Permission{Name: name})
+		require.NoError(t, err)
+	}
+	_, err := roles.Add(ctx, &models.Role{Name: "editor", Permissions: []string{"users:write", "users:read"}})
+	require.NoError(t, err)
+	_, err = roles.Add(ctx, &models.Role{Name: "viewer", Permissions: []string{"users:read", "reports:read"}})
+	require.NoError(t, err)
+
+	//union is sorted and without duplicates, unknown roles are skipped
+	permissions, err := Permissions(ctx, env.storage, []string{"viewer", "unknown", "editor"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"reports:read", "users:read", "users:write"}, permissions)
+	permissions, err = Permissions(ctx, env.storage, []string{"unknown"})
+	require.NoError(t, err)
+	require.Empty(t, permissions)
+
+	//deleted permission is removed from roles
+	require.NoError(t, roles.DeletePermission(ctx, "users:read"))
+	permissions, err = Permissions(ctx, env.storage, []string{"viewer", "editor"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"reports:read", "users:write"}, permissions)
+
+	_, err = roles.Add(ctx, &models.Role{Name: "broken", Permissions: []string{"unknown"}})
+	require.ErrorIs(t, err, errorHelper.Invalid)
+}
+
+func TestDeleteRole(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	roles := Roles(store)
+	_, err := roles.Add(ctx, &models.Role{Name: "editor"})
+	require.NoError(t, err)
+	id, err := Users(store).Add(ctx, &models.User{Login: "alice", Password: testPassword, Roles: []string{"editor", models.RoleAdmin}})
+	require.NoError(t, err)
+
+	require.ErrorIs(t, roles.Delete(ctx, models.RoleAdmin), errorHelper.Invalid)
+	_, err = roles.Get(ctx, models.RoleAdmin)
+	require.NoError(t, err)
+	require.NoError(t, roles.Delete(ctx, "editor"))
+	require.ErrorIs(t, roles.Delete(ctx, "editor"), errorHelper.NotFound)
+	user, err := Users(store).Get(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, []string{models.RoleAdmin}, user.Roles, "deleted role must be unassigned")
+}
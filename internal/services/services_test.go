@@ -0,0 +1,76 @@
+package services
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"sso/internal/config"
+	"sso/internal/models"
+	"sso/internal/storage/memory"
+	"testing"
+	"time"
+)
+
+// testEnv is a set of services sharing one memory storage
+type testEnv struct {
+	storage     *memory.Storage
+	keys        *KeysService
+	tokens      *TokensService
+	revocations *RevocationsService
+	users       *UsersService
+	clients     *ClientsService
+	lockout     *LockoutService
+	mfa         *MfaService
+}
+
+var testTokensConfig = config.TokensConfig{
+	Issuer:         "https://sso.example.com",
+	AccessTTL:      time.Hour,
+	RefreshTTL:     24 * time.Hour,
+	CodeTTL:        time.Minute,
+	RevocationSync: time.Minute,
+}
+
+var testLockoutConfig = config.LockoutConfig{
+	AccountThreshold: 5,
+	IpThreshold:      20,
+	BaseDelay:        time.Minute,
+	MaxDelay:         time.Hour,
+	Window:           time.Hour,
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	s := memory.New()
+	keys := Keys(s, config.KeysConfig{RotationInterval: 24 * time.Hour, PublishAhead: time.Hour, GracePeriod: 2 * time.Hour})
+	env := &testEnv{
+		storage:     s,
+		keys:        keys,
+		tokens:      Tokens(s, keys, testTokensConfig, Groups(s, config.GroupsConfig{})),
+		revocations: Revocations(s, keys, testTokensConfig),
+		users:       Users(s),
+		clients:     Clients(s),
+		lockout:     Lockout(s, testLockoutConfig),
+	}
+	env.mfa = Mfa(s, env.tokens, keys, env.revocations, env.lockout, config.MfaConfig{Issuer: "sso", ChallengeTTL: 5 * time.Minute, MaxAttempts: 3})
+	return env
+}
+
+// addUser registers user with testPassword and returns its id
+func (e *testEnv) addUser(t *testing.T, login string, roles ...string) string {
+	t.Helper()
+	id, err := e.users.Add(context.Background(), &models.User{Login: login, Password: testPassword, Roles: roles})
+	require.NoError(t, err)
+	return id
+}
+
+// addClient registers client allowed to request scopes, empty secret makes it public
+func (e *testEnv) addClient(t *testing.T, clientId string, secret string, scopes ...string) {
+	t.Helper()
+	_, err := e.clients.Add(context.Background(), &models.Client{
+		ClientId:     clientId,
+		Name:         clientId,
+		RedirectUris: []string{"https://" + clientId + ".example.com/callback"},
+		Scopes:       scopes,
+	}, secret)
+	require.NoError(t, err)
+}
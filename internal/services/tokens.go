@@ -0,0 +1,318 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"slices"
+	"sso/internal/config"
+	"sso/internal/metrics"
+	"sso/internal/models"
+	"sso/internal/storage"
+	"sso/internal/tracing"
+	"sso/pkg/helpers/errorHelper"
+	"sso/pkg/helpers/jwtHelper"
+	"sso/pkg/helpers/tokenHelper"
+	"strings"
+	"time"
+)
+
+const (
+	ErrorCreateRefreshToken = "failed to create refresh token"
+	ErrorRefreshNotFound    = "refresh token not found"
+	ErrorRefreshExpired     = "refresh token expired"
+	ErrorRefreshRevoked     = "refresh token revoked"
+	ErrorRefreshReused      = "refresh token reuse detected, token family revoked"
+	ErrorRevokeFamily       = "failed to revoke token family"
+	ErrorRefreshClient      = "refresh token was issued to another client"
+	ErrorCreateIdToken      = "failed to create id token"
+)
+
+// refreshTokenBytes is the entropy of opaque refresh tokens
+const refreshTokenBytes = 32
+
+type TokensService struct {
+	storage storage.Storage
+	keys    *KeysService
+	config  config.TokensConfig
+	groups  *GroupsService
+}
+
+// Grant describes what a token pair is issued for
+type Grant struct {
+	UserId   string
+	ClientId string //empty for first party password login
+	Scope    string //space separated
+	Family   string //refresh token family, a new one is generated if empty
+	Nonce    string //OIDC nonce from authorization request
+	AuthTime time.Time
+	Acr      string
+}
+
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	IdToken      string //only for clients which requested openid scope
+	ExpiresIn    int64  //access token lifetime in seconds
+}
+
+const (
+	ScopeOpenId  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+// TokenUseId marks ID tokens, they must not be accepted as access tokens
+const TokenUseId = "id"
+
+// Authentication context classes
+const (
+	AcrPassword = "urn:sso:acr:pwd" //single factor password login
+	AcrMfa      = "urn:sso:acr:mfa" //password and second factor
+)
+
+func Tokens(storage storage.Storage, keys *KeysService, config config.TokensConfig, groups *GroupsService) *TokensService {
+	return &TokensService{
+		storage: storage,
+		keys:    keys,
+		config:  config,
+		groups:  groups,
+	}
+}
+
+// Issue creates access token and refresh token for the grant
+func (t *TokensService) Issue(ctx context.Context, grant Grant) (*TokenPair, error) {
+	const operation = "internal.services.tokens.Issue()"
+	if grant.Family == "" {
+		family, err := NewFamily()
+		if err != nil {
+			return nil, errorHelper.WrapError(operation, ErrorCreateRefreshToken, err)
+		}
+		grant.Family = family
+	}
+	return t.issue(ctx, grant)
+}
+
+// NewFamily generates id of a refresh token family
+func NewFamily() (string, error) {
+	return tokenHelper.Generate(16)
+}
+
+// Refresh exchanges refresh token for a new token pair. Each refresh token is single use:
+// presenting an already used token revokes the whole family, because either the client
+// or an attacker holds a stolen copy. Tokens issued to a client can be refreshed only by that client.
+func (t *TokensService) Refresh(ctx context.Context, refreshToken string, clientId string) (*TokenPair, error) {
+	const operation = "internal.services.tokens.Refresh()"
+	token, err := t.storage.Tokens().GetToken(ctx, tokenHelper.Hash(refreshToken))
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorRefreshNotFound, err)
+	}
+	if token.Revoked {
+		return nil, errorHelper.Wrap(errorHelper.InvalidCredentials, operation, ErrorRefreshRevoked, errors.New(token.Family))
+	}
+	if time.Now().After(token.ValidUntil) {
+		return nil, errorHelper.Wrap(errorHelper.Expired, operation, ErrorRefreshExpired, errors.New(token.ValidUntil.String()))
+	}
+	if token.ClientId != clientId {
+		return nil, errorHelper.Wrap(errorHelper.InvalidCredentials, operation, ErrorRefreshClient, errors.New(clientId))
+	}
+	ok := false
+	if !token.Used {
+		if ok, err = t.storage.Tokens().UseToken(ctx, token.Id); err != nil {
+			return nil, errorHelper.WrapError(operation, ErrorCreateRefreshToken, err)
+		}
+	}
+	if !ok {
+		if err := t.storage.Tokens().RevokeFamily(ctx, token.Family); err != nil {
+			return nil, errorHelper.WrapError(operation, ErrorRevokeFamily, err)
+		}
+		return nil, errorHelper.Wrap(errorHelper.InvalidCredentials, operation, ErrorRefreshReused, errors.New(token.Family))
+	}
+	//nonce is bound to the original authentication and is not repeated in refreshed ID tokens
+	return t.issue(ctx, Grant{
+		UserId:   token.User,
+		ClientId: token.ClientId,
+		Scope:    token.Scope,
+		Family:   token.Family,
+		AuthTime: token.AuthTime,
+		Acr:      token.Acr,
+	})
+}
+
+// IssueClient creates access token for client credentials grant, the client itself is the subject.
+// Refresh token is not issued, the client can always authenticate again (RFC 6749 section 4.4.3).
+func (t *TokensService) IssueClient(ctx context.Context, clientId string, scope string) (*TokenPair, error) {
+	const operation = "internal.services.tokens.IssueClient()"
+	claims := map[string]any{
+		"sub":       clientId,
+		"aud":       clientId,
+		"client_id": clientId,
+	}
+	if scope != "" {
+		claims["scope"] = scope
+	}
+	access, err := t.access(ctx, claims)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorCreateToken, err)
+	}
+	return &TokenPair{
+		AccessToken: access,
+		ExpiresIn:   int64(t.config.AccessTTL.Seconds()),
+	}, nil
+}
+
+func (t *TokensService) issue(ctx context.Context, grant Grant) (*TokenPair, error) {
+	const operation = "internal.services.tokens.issue()"
+	user, err := t.storage.Users().GetUserById(ctx, grant.UserId)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorQueryUser, err)
+	}
+	if user.Disabled {
+		return nil, errorHelper.New(errorHelper.InvalidCredentials, operation, ErrorUserDisabled)
+	}
+	claims := map[string]any{
+		"sub": grant.UserId,
+	}
+	if len(user.Roles) > 0 {
+		permissions, err := Permissions(ctx, t.storage, user.Roles)
+		if err != nil {
+			return nil, errorHelper.WrapError(operation, ErrorCreateToken, err)
+		}
+		claims["roles"] = user.Roles
+		if len(permissions) > 0 {
+			claims["permissions"] = permissions
+		}
+	}
+	if len(user.Groups) > 0 {
+		groups, err := t.groups.Resolve(ctx, user)
+		if err != nil {
+			return nil, errorHelper.WrapError(operation, ErrorCreateToken, err)
+		}
+		names, overflow := t.groups.Claim(groups)
+		if len(names) > 0 {
+			claims["groups"] = names
+		}
+		if overflow {
+			claims["groups_overflow"] = true
+		}
+	}
+	if grant.ClientId != "" {
+		claims["aud"] = grant.ClientId
+		claims["client_id"] = grant.ClientId
+	}
+	if grant.Scope != "" {
+		claims["scope"] = grant.Scope
+	}
+	//refreshed tokens keep the time of the original login
+	if !grant.AuthTime.IsZero() {
+		claims["auth_time"] = grant.AuthTime.Unix()
+	}
+	access, err := t.access(ctx, claims)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorCreateToken, err)
+	}
+	idToken := ""
+	if grant.ClientId != "" && HasScope(grant.Scope, ScopeOpenId) {
+		if idToken, err = t.idToken(ctx, grant, access); err != nil {
+			return nil, errorHelper.WrapError(operation, ErrorCreateIdToken, err)
+		}
+	}
+	refresh, err := tokenHelper.Generate(refreshTokenBytes)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorCreateRefreshToken, err)
+	}
+	now := time.Now()
+	if _, err := t.storage.Tokens().InsertToken(ctx, &models.Token{
+		Hash:       tokenHelper.Hash(refresh),
+		User:       grant.UserId,
+		ClientId:   grant.ClientId,
+		Scope:      grant.Scope,
+		Family:     grant.Family,
+		AuthTime:   grant.AuthTime,
+		Acr:        grant.Acr,
+		CreateAt:   now,
+		ValidUntil: now.Add(t.config.RefreshTTL),
+	}); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorCreateRefreshToken, err)
+	}
+	metrics.TokenIssued(metrics.TokenRefresh)
+	return &TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		IdToken:      idToken,
+		ExpiresIn:    int64(t.config.AccessTTL.Seconds()),
+	}, nil
+}
+
+// idToken creates OpenID Connect ID token, OIDC Core section 2
+func (t *TokensService) idToken(ctx context.Context, grant Grant, access string) (string, error) {
+	const operation = "internal.services.tokens.idToken()"
+	claims := map[string]any{
+		"sub":       grant.UserId,
+		"aud":       grant.ClientId,
+		"azp":       grant.ClientId,
+		"at_hash":   AtHash(access),
+		"token_use": TokenUseId,
+	}
+	if grant.Nonce != "" {
+		claims["nonce"] = grant.Nonce
+	}
+	if !grant.AuthTime.IsZero() {
+		claims["auth_time"] = grant.AuthTime.Unix()
+	}
+	if grant.Acr != "" {
+		claims["acr"] = grant.Acr
+	}
+	token, err := t.access(ctx, claims)
+	if err != nil {
+		return "", errorHelper.WrapError(operation, ErrorCreateToken, err)
+	}
+	return token, nil
+}
+
+// AtHash is the access token hash of ID token: left half of SHA-256 of the token, base64url encoded (OIDC Core 3.1.3.6)
+func AtHash(access string) string {
+	hash := sha256.Sum256([]byte(access))
+	return base64.RawURLEncoding.EncodeToString(hash[:len(hash)/2])
+}
+
+// HasScope reports whether space separated scope list contains the scope
+func HasScope(scope string, name string) bool {
+	return slices.Contains(strings.Fields(scope), name)
+}
+
+// access signs JWT with the active key, registered claims are added to the given ones
+func (t *TokensService) access(ctx context.Context, claims map[string]any) (string, error) {
+	return t.sign(ctx, claims, t.config.AccessTTL)
+}
+
+// sign adds registered claims and signs the token with the active key
+func (t *TokensService) sign(ctx context.Context, claims map[string]any, ttl time.Duration) (string, error) {
+	const operation = "internal.services.tokens.sign()"
+	key, err := t.keys.Signing(ctx)
+	if err != nil {
+		return "", errorHelper.WrapError(operation, ErrorGetRsaKey, err)
+	}
+	now := time.Now()
+	claims["iss"] = t.config.Issuer
+	claims["exp"] = now.Add(ttl).Unix()
+	claims["nbf"] = now.Unix()
+	claims["iat"] = now.Unix()
+	claims["jti"] = uuid.New().String()
+	_, span := tracing.Start(ctx, "jwt.sign", attribute.String("jwt.kid", key.Kid))
+	token, err := jwtHelper.Create(key.PrivateKey, claims)
+	tracing.End(span, err)
+	if err != nil {
+		return "", errorHelper.WrapError(operation, ErrorCreateToken, err)
+	}
+	//special purpose tokens are counted by their token_use
+	typ, _ := claims["token_use"].(string)
+	if typ == "" {
+		typ = metrics.TokenAccess
+	}
+	metrics.TokenIssued(typ)
+	return token, nil
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"sso/pkg/helpers/errorHelper"
+	"testing"
+)
+
+func TestAtHash(t *testing.T) {
+	//left 16 bytes of SHA-256, base64url without padding
+	require.Equal(t, "reSwmhEMjmUso3xzxebX_w", AtHash("jHkWEdUXMU1BwAsC4vtUsZwnNe3UQE1y2Fm7d-jsfpw"))
+}
+
+func TestIdToken(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	uid := env.addUser(t, "alice")
+	env.addClient(t, "app", "", ScopeOpenId, ScopeProfile)
+	const verifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	req := &AuthorizeRequest{
+		ResponseType:        ResponseTypeCode,
+		ClientId:            "app",
+		RedirectUri:         "https://app.example.com/callback",
+		Scope:               "openid profile",
+		CodeChallenge:       "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM",
+		CodeChallengeMethod: PkceMethodS256,
+		Nonce:               "n-0S6_WzA2Mj",
+	}
+	codes := Codes(env.storage, testTokensConfig)
+	_, err := codes.Validate(ctx, req)
+	require.NoError(t, err)
+	code, err := codes.Issue(ctx, req, uid, AcrPassword)
+	require.NoError(t, err)
+	auth, err := codes.Exchange(ctx, code, "app", req.RedirectUri, verifier)
+	require.NoError(t, err)
+	pair, err := env.tokens.Issue(ctx, Grant{
+		UserId:   auth.User,
+		ClientId: auth.ClientId,
+		Scope:    auth.Scope,
+		Family:   auth.Family,
+		Nonce:    auth.Nonce,
+		AuthTime: auth.AuthTime,
+		Acr:      auth.Acr,
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, pair.IdToken)
+
+	claims, err := verify(ctx, pair.IdToken, env.keys)
+	require.NoError(t, err)
+	require.Equal(t, uid, claims["sub"])
+	require.Equal(t, "app", claims["aud"])
+	require.Equal(t, "app", claims["azp"])
+	require.Equal(t, req.Nonce, claims["nonce"])
+	require.Equal(t, AtHash(pair.AccessToken), claims["at_hash"])
+	require.Equal(t, AcrPassword, claims["acr"])
+	require.EqualValues(t, auth.AuthTime.Unix(), claims["auth_time"])
+	//ID token is not accepted as access token
+	_, err = Claims(ctx, pair.IdToken, env.keys, env.revocations)
+	require.Equal(t, errorHelper.InvalidCredentials, errorHelper.KindOf(err))
+
+	//the access token is issued to the same client
+	claims, err = Claims(ctx, pair.AccessToken, env.keys, env.revocations)
+	require.NoError(t, err)
+	aud, err := claims.GetAudience()
+	require.NoError(t, err)
+	require.Equal(t, []string{"app"}, []string(aud))
+
+	//no ID token without openid scope or client
+	pair, err = env.tokens.Issue(ctx, Grant{UserId: uid, ClientId: "app", Scope: ScopeProfile})
+	require.NoError(t, err)
+	require.Empty(t, pair.IdToken)
+	pair, err = env.tokens.Issue(ctx, Grant{UserId: uid})
+	require.NoError(t, err)
+	require.Empty(t, pair.IdToken)
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"sso/internal/storage"
+	"sso/pkg/helpers/errorHelper"
+)
+
+const (
+	ErrorNoSubject = "token has no subject"
+)
+
+// Userinfo returns OIDC standard claims of the token subject. First party tokens get all claims,
+// tokens of OAuth clients get profile and email claims only with openid and the profile or email scope granted.
+func Userinfo(ctx context.Context, claims jwt.MapClaims, storage storage.Storage) (map[string]any, error) {
+	const op = "internal.services.userinfo"
+	sub, err := claims.GetSubject()
+	if err != nil || sub == "" {
+		return nil, errorHelper.Wrap(errorHelper.InvalidCredentials, op, ErrorQueryUser, errors.New(ErrorNoSubject))
+	}
+	user, err := storage.Users().GetUserById(ctx, sub)
+	if err != nil {
+		return nil, errorHelper.WrapError(op, ErrorQueryUser, err)
+	}
+	scope, _ := claims["scope"].(string)
+	firstParty := FirstParty(claims)
+	granted := func(name string) bool {
+		return firstParty || HasScope(scope, ScopeOpenId) && HasScope(scope, name)
+	}
+	res := map[string]any{
+		"sub": user.Id,
+	}
+	if granted(ScopeProfile) {
+		res["preferred_username"] = user.Login
+		if user.Name != "" {
+			res["name"] = user.Name
+		}
+	}
+	if granted(ScopeEmail) && user.Email != "" {
+		res["email"] = user.Email
+		res["email_verified"] = user.EmailVerified
+	}
+	return res, nil
+}
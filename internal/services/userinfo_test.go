@@ -0,0 +1,45 @@
+package services
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"sso/internal/models"
+	"testing"
+)
+
+func TestUserinfo(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	uid, err := env.users.Add(ctx, &models.User{Login: "alice", Password: testPassword, Name: "Alice", Email: "alice@example.com"})
+	require.NoError(t, err)
+	profile := []string{"preferred_username", "name"}
+	email := []string{"email", "email_verified"}
+
+	for _, tc := range []struct {
+		name     string
+		grant    Grant
+		released []string
+	}{
+		{"first party", Grant{}, append(profile, email...)},
+		{"client without scope", Grant{ClientId: "app"}, nil},
+		{"client without openid", Grant{ClientId: "app", Scope: "profile email"}, nil},
+		{"client with openid only", Grant{ClientId: "app", Scope: "openid"}, nil},
+		{"client with profile", Grant{ClientId: "app", Scope: "openid profile"}, profile},
+		{"client with email", Grant{ClientId: "app", Scope: "openid email"}, email},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.grant.UserId = uid
+			pair, err := env.tokens.Issue(ctx, tc.grant)
+			require.NoError(t, err)
+			claims, err := Claims(ctx, pair.AccessToken, env.keys, env.revocations)
+			require.NoError(t, err)
+			info, err := Userinfo(ctx, claims, env.storage)
+			require.NoError(t, err)
+			require.Equal(t, uid, info["sub"])
+			require.Len(t, info, len(tc.released)+1)
+			for _, claim := range tc.released {
+				require.Contains(t, info, claim)
+			}
+		})
+	}
+}
@@ -1,12 +1,16 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"sso/internal/metrics"
 	"sso/internal/models"
 	"sso/internal/storage"
+	"sso/internal/tracing"
 	"sso/pkg/helpers/errorHelper"
 	"sso/pkg/helpers/passwdHelper"
 	"strings"
+	"time"
 	"unicode"
 )
 
@@ -14,8 +18,31 @@ const (
 	ErrorCreatePassword   = "Password hashing error"
 	ErrorPasswordValidate = "Password validation error"
 	ErrorAddUser          = "Error on add user"
+	ErrorGetUser          = "Error on get user"
+	ErrorListUsers        = "Error on list users"
+	ErrorUpdateUser       = "Error on update user"
+	ErrorDeleteUser       = "Error on delete user"
+	ErrorEmptyLogin       = "Empty login"
 )
 
+// RootLogin is the login of the admin user created on the first start
+const RootLogin = "root"
+
+const (
+	DefaultUsersLimit = 20
+	MaxUsersLimit     = 100
+)
+
+// UserPatch holds fields to change, nil fields are left as is
+type UserPatch struct {
+	Login         *string
+	Password      *string
+	Name          *string
+	Email         *string
+	EmailVerified *bool
+	Roles         *[]string
+}
+
 type UsersService struct {
 	storage storage.Storage
 }
@@ -26,17 +53,23 @@ func Users(storage storage.Storage) *UsersService {
 	}
 }
 
-func (u *UsersService) Add(user *models.User) (string, error) {
+func (u *UsersService) Add(ctx context.Context, user *models.User) (string, error) {
 	const operation = "internal.services.users.Add()"
+	if strings.TrimSpace(user.Login) == "" {
+		return "", errorHelper.Wrap(errorHelper.Invalid, operation, ErrorAddUser, errors.New(ErrorEmptyLogin))
+	}
+	if err := checkRoles(ctx, u.storage, user.Roles); err != nil {
+		return "", errorHelper.WrapError(operation, ErrorAddUser, err)
+	}
 	if err := validatePassword(user.Password); err != nil {
-		return "", errorHelper.WrapError(operation, ErrorPasswordValidate, err)
+		return "", errorHelper.Wrap(errorHelper.Invalid, operation, ErrorPasswordValidate, err)
 	} else {
-		password, err := passwdHelper.HashPassword(user.Password)
+		password, err := hashPassword(ctx, user.Password)
 		if err != nil {
 			return "", errorHelper.WrapError(operation, ErrorCreatePassword, err)
 		}
 		user.Password = password
-		uid, err := u.storage.Users().InsertUser(user)
+		uid, err := u.storage.Users().InsertUser(ctx, user)
 		if err != nil {
 			return "", errorHelper.WrapError(operation, ErrorAddUser, err)
 		}
@@ -44,6 +77,132 @@ func (u *UsersService) Add(user *models.User) (string, error) {
 	}
 }
 
+// EnsureRoot creates admin user with root login if there is none, returns id of the created user or empty string
+func (u *UsersService) EnsureRoot(ctx context.Context, password string) (string, error) {
+	const operation = "internal.services.users.EnsureRoot()"
+	_, err := u.storage.Users().GetUser(ctx, RootLogin)
+	if err == nil {
+		return "", nil
+	}
+	if errorHelper.KindOf(err) != errorHelper.NotFound {
+		return "", errorHelper.WrapError(operation, ErrorGetUser, err)
+	}
+	uid, err := u.Add(ctx, &models.User{
+		Login:    RootLogin,
+		Password: password,
+		Roles:    []string{models.RoleAdmin},
+	})
+	if err != nil {
+		return "", errorHelper.WrapError(operation, ErrorAddUser, err)
+	}
+	return uid, nil
+}
+
+// List returns a page of users, filter offset and limit are normalized in place
+func (u *UsersService) List(ctx context.Context, filter *models.UserFilter) ([]*models.User, int64, error) {
+	const operation = "internal.services.users.List()"
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultUsersLimit
+	}
+	if filter.Limit > MaxUsersLimit {
+		filter.Limit = MaxUsersLimit
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+	users, total, err := u.storage.Users().ListUsers(ctx, *filter)
+	if err != nil {
+		return nil, 0, errorHelper.WrapError(operation, ErrorListUsers, err)
+	}
+	return users, total, nil
+}
+
+func (u *UsersService) Get(ctx context.Context, id string) (*models.User, error) {
+	const operation = "internal.services.users.Get()"
+	user, err := u.storage.Users().GetUserById(ctx, id)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorGetUser, err)
+	}
+	return user, nil
+}
+
+// Update applies patch to the user, new password is validated and hashed
+func (u *UsersService) Update(ctx context.Context, id string, patch UserPatch) (*models.User, error) {
+	const operation = "internal.services.users.Update()"
+	user, err := u.storage.Users().GetUserById(ctx, id)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorGetUser, err)
+	}
+	update := models.UserUpdate{}
+	if patch.Login != nil {
+		if strings.TrimSpace(*patch.Login) == "" {
+			return nil, errorHelper.Wrap(errorHelper.Invalid, operation, ErrorUpdateUser, errors.New(ErrorEmptyLogin))
+		}
+		user.Login = *patch.Login
+		update.Login = &user.Login
+	}
+	if patch.Password != nil {
+		if err := validatePassword(*patch.Password); err != nil {
+			return nil, errorHelper.Wrap(errorHelper.Invalid, operation, ErrorPasswordValidate, err)
+		}
+		password, err := hashPassword(ctx, *patch.Password)
+		if err != nil {
+			return nil, errorHelper.WrapError(operation, ErrorCreatePassword, err)
+		}
+		user.Password = password
+		update.Password = &user.Password
+	}
+	if patch.Name != nil {
+		user.Name = *patch.Name
+		update.Name = &user.Name
+	}
+	if patch.Email != nil {
+		if *patch.Email != user.Email && patch.EmailVerified == nil {
+			user.EmailVerified = false
+			update.EmailVerified = &user.EmailVerified
+		}
+		user.Email = *patch.Email
+		update.Email = &user.Email
+	}
+	if patch.EmailVerified != nil {
+		user.EmailVerified = *patch.EmailVerified
+		update.EmailVerified = &user.EmailVerified
+	}
+	if patch.Roles != nil {
+		if err := checkRoles(ctx, u.storage, *patch.Roles); err != nil {
+			return nil, errorHelper.WrapError(operation, ErrorUpdateUser, err)
+		}
+		user.Roles = *patch.Roles
+		update.Roles = &user.Roles
+	}
+	if err := u.storage.Users().UpdateUser(ctx, id, update); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorUpdateUser, err)
+	}
+	return user, nil
+}
+
+// SetDisabled disables or enables the user, disabled users can neither log in nor refresh tokens
+func (u *UsersService) SetDisabled(ctx context.Context, id string, disabled bool) (*models.User, error) {
+	const operation = "internal.services.users.SetDisabled()"
+	user, err := u.storage.Users().GetUserById(ctx, id)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorGetUser, err)
+	}
+	user.Disabled = disabled
+	if err := u.storage.Users().UpdateUser(ctx, id, models.UserUpdate{Disabled: &disabled}); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorUpdateUser, err)
+	}
+	return user, nil
+}
+
+func (u *UsersService) Delete(ctx context.Context, id string) error {
+	const operation = "internal.services.users.Delete()"
+	if err := u.storage.Users().DeleteUser(ctx, id); err != nil {
+		return errorHelper.WrapError(operation, ErrorDeleteUser, err)
+	}
+	return nil
+}
+
 // Password validates plain password against the rules defined below.
 //
 // TODO переписать на нормальные валидаторы
@@ -97,3 +256,21 @@ func validatePassword(pass string) error {
 	}
 	return nil
 }
+
+// hashPassword hashes password with bcrypt, observes its duration and traces it
+func hashPassword(ctx context.Context, password string) (string, error) {
+	defer metrics.ObserveBcrypt(metrics.BcryptHash, time.Now())
+	_, span := tracing.Start(ctx, "bcrypt.hash")
+	hash, err := passwdHelper.HashPassword(password)
+	tracing.End(span, err)
+	return hash, err
+}
+
+// comparePassword checks password against bcrypt hash, observes its duration and traces it.
+// Mismatch is the expected outcome of a wrong password, so it doesn't fail the span.
+func comparePassword(ctx context.Context, password string, hash string) error {
+	defer metrics.ObserveBcrypt(metrics.BcryptCompare, time.Now())
+	_, span := tracing.Start(ctx, "bcrypt.compare")
+	defer span.End()
+	return passwdHelper.ComparePassword(password, hash)
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"sso/internal/models"
+	"sso/internal/storage/memory"
+	"sso/pkg/helpers/errorHelper"
+	"testing"
+)
+
+const testPassword = "Passw0rd!"
+
+func TestUsersService(t *testing.T) {
+	ctx := context.Background()
+	users := Users(memory.New())
+
+	id, err := users.Add(ctx, &models.User{Login: "alice", Password: testPassword, Roles: []string{models.RoleAdmin}})
+	require.NoError(t, err)
+	_, err = users.Add(ctx, &models.User{Login: "alice", Password: testPassword})
+	require.ErrorIs(t, err, errorHelper.Conflict)
+	_, err = users.Add(ctx, &models.User{Login: "bob", Password: "weak"})
+	require.ErrorIs(t, err, errorHelper.Invalid)
+	_, err = users.Add(ctx, &models.User{Login: "bob", Password: testPassword, Roles: []string{"unknown"}})
+	require.ErrorIs(t, err, errorHelper.Invalid)
+
+	user, err := users.Get(ctx, id)
+	require.NoError(t, err)
+	require.NotEqual(t, testPassword, user.Password, "password must be stored hashed")
+
+	email, verified := "alice@example.com", true
+	_, err = users.Update(ctx, id, UserPatch{Email: &email, EmailVerified: &verified})
+	require.NoError(t, err)
+	other := "alice@example.org"
+	user, err = users.Update(ctx, id, UserPatch{Email: &other})
+	require.NoError(t, err)
+	require.False(t, user.EmailVerified, "changed email must be verified again")
+
+	list, total, err := users.List(ctx, &models.UserFilter{Search: "EXAMPLE"})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), total)
+	require.Equal(t, "alice", list[0].Login)
+
+	require.NoError(t, users.Delete(ctx, id))
+	_, err = users.Get(ctx, id)
+	require.ErrorIs(t, err, errorHelper.NotFound)
+	require.ErrorIs(t, users.Delete(ctx, id), errorHelper.NotFound)
+}
+
+func TestEnsureRoot(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	users := Users(store)
+	id, err := users.EnsureRoot(ctx, testPassword)
+	require.NoError(t, err)
+	require.NotEmpty(t, id)
+	id, err = users.EnsureRoot(ctx, testPassword)
+	require.NoError(t, err)
+	require.Empty(t, id, "existing root must be kept")
+
+	user, err := Login(ctx, RootLogin, testPassword, store)
+	require.NoError(t, err)
+	require.Equal(t, []string{models.RoleAdmin}, user.Roles)
+	_, err = Login(ctx, RootLogin, "wrong", store)
+	require.ErrorIs(t, err, errorHelper.InvalidCredentials)
+}
+
+func TestLoginUnknownUser(t *testing.T) {
+	ctx := context.Background()
+	_, err := Login(ctx, "nobody", testPassword, memory.New())
+	require.ErrorIs(t, err, errorHelper.InvalidCredentials)
+	require.NotErrorIs(t, err, errorHelper.NotFound)
+	//unknown logins spend as much time in bcrypt as wrong passwords
+	cost, err := bcrypt.Cost([]byte(dummyPasswordHash))
+	require.NoError(t, err)
+	require.Equal(t, bcrypt.DefaultCost, cost)
+}
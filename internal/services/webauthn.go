@@ -0,0 +1,370 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"sso/internal/config"
+	"sso/internal/models"
+	"sso/internal/storage"
+	"sso/pkg/helpers/errorHelper"
+	"sso/pkg/helpers/webauthnHelper"
+	"time"
+)
+
+const (
+	ErrorWebauthnBegin     = "Error on begin webauthn ceremony"
+	ErrorWebauthnRegister  = "Error on register webauthn credential"
+	ErrorWebauthnLogin     = "Error on webauthn login"
+	ErrorWebauthnCeremony  = "not a webauthn ceremony token"
+	ErrorWebauthnUser      = "ceremony was started by another user"
+	ErrorWebauthnRpId      = "credential is scoped to another relying party"
+	ErrorWebauthnPresence  = "user presence is not confirmed"
+	ErrorWebauthnVerified  = "user verification is required for passwordless login"
+	ErrorWebauthnCounter   = "signature counter did not increase, the authenticator may be cloned"
+	ErrorWebauthnHandle    = "user handle does not match credential owner"
+	ErrorWebauthnMismatch  = "credential id does not match authenticator data"
+	ErrorGetCredentials    = "Error on get webauthn credentials"
+	ErrorDeleteCredential  = "Error on delete webauthn credential"
+	ErrorCredentialUnknown = "credential is not registered"
+)
+
+// TokenUseWebauthn marks tokens holding WebAuthn ceremony state between begin and finish requests
+const TokenUseWebauthn = "webauthn"
+
+// AcrPasskey is passwordless login with user verifying authenticator
+const AcrPasskey = "urn:sso:acr:passkey"
+
+// challengeBytes is the entropy of WebAuthn challenges, at least 16 bytes are required
+const challengeBytes = 32
+
+// Attestation is authenticator response to navigator.credentials.create()
+type Attestation struct {
+	CredentialId      string //base64url
+	ClientDataJSON    []byte
+	AttestationObject []byte
+}
+
+// Assertion is authenticator response to navigator.credentials.get()
+type Assertion struct {
+	CredentialId      string //base64url
+	ClientDataJSON    []byte
+	AuthenticatorData []byte
+	Signature         []byte
+	UserHandle        []byte //set by discoverable credentials
+}
+
+// WebauthnService registers passkeys and security keys and logs users in with them, either
+// as the second factor after password check or passwordless. Ceremony state is kept
+// in short-lived single use signed tokens, so no server side session is needed.
+type WebauthnService struct {
+	storage     storage.Storage
+	tokens      *TokensService
+	keys        Keyring
+	revocations *RevocationsService
+	mfa         *MfaService
+	config      config.WebauthnConfig
+}
+
+func Webauthn(storage storage.Storage, tokens *TokensService, keys Keyring, revocations *RevocationsService, mfa *MfaService, config config.WebauthnConfig) *WebauthnService {
+	return &WebauthnService{
+		storage:     storage,
+		tokens:      tokens,
+		keys:        keys,
+		revocations: revocations,
+		mfa:         mfa,
+		config:      config,
+	}
+}
+
+// BeginRegistration returns options for navigator.credentials.create() and the ceremony token
+func (s *WebauthnService) BeginRegistration(ctx context.Context, userId string) (*webauthnHelper.CreationOptions, string, error) {
+	const operation = "internal.services.webauthn.BeginRegistration()"
+	user, err := s.storage.Users().GetUserById(ctx, userId)
+	if err != nil {
+		return nil, "", errorHelper.WrapError(operation, ErrorGetUser, err)
+	}
+	credentials, err := s.storage.Credentials().GetUserCredentials(ctx, userId)
+	if err != nil {
+		return nil, "", errorHelper.WrapError(operation, ErrorGetCredentials, err)
+	}
+	challenge, ceremony, err := s.ceremony(ctx, webauthnHelper.TypeCreate, userId, nil)
+	if err != nil {
+		return nil, "", errorHelper.WrapError(operation, ErrorWebauthnBegin, err)
+	}
+	name := user.Name
+	if name == "" {
+		name = user.Login
+	}
+	return &webauthnHelper.CreationOptions{
+		Rp:                 webauthnHelper.RelyingParty{Id: s.config.RpId, Name: s.config.RpName},
+		User:               webauthnHelper.UserEntity{Id: webauthnHelper.Encode([]byte(user.Id)), Name: user.Login, DisplayName: name},
+		Challenge:          challenge,
+		PubKeyCredParams:   webauthnHelper.SupportedAlgorithms,
+		Timeout:            s.config.ChallengeTTL.Milliseconds(),
+		ExcludeCredentials: descriptors(credentials),
+		AuthenticatorSelection: webauthnHelper.AuthenticatorSelection{
+			ResidentKey:      webauthnHelper.Preferred,
+			UserVerification: webauthnHelper.Preferred,
+		},
+		Attestation: s.config.Attestation,
+	}, ceremony, nil
+}
+
+// FinishRegistration verifies authenticator attestation and stores the new credential
+func (s *WebauthnService) FinishRegistration(ctx context.Context, userId string, ceremony string, name string, response *Attestation) (*models.Credential, error) {
+	const operation = "internal.services.webauthn.FinishRegistration()"
+	claims, err := s.consume(ctx, ceremony, webauthnHelper.TypeCreate)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorWebauthnRegister, err)
+	}
+	if sub, _ := claims.GetSubject(); sub != userId {
+		return nil, errorHelper.Wrap(errorHelper.InvalidCredentials, operation, ErrorWebauthnRegister, errors.New(ErrorWebauthnUser))
+	}
+	challenge, _ := claims["challenge"].(string)
+	if _, err := webauthnHelper.ParseClientData(response.ClientDataJSON, webauthnHelper.TypeCreate, challenge, s.config.Origins); err != nil {
+		return nil, errorHelper.Wrap(errorHelper.InvalidCredentials, operation, ErrorWebauthnRegister, err)
+	}
+	hash := sha256.Sum256(response.ClientDataJSON)
+	data, err := webauthnHelper.VerifyAttestation(response.AttestationObject, hash[:])
+	if err != nil {
+		return nil, errorHelper.Wrap(errorHelper.InvalidCredentials, operation, ErrorWebauthnRegister, err)
+	}
+	if err := s.checkAuthenticatorData(data, false); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorWebauthnRegister, err)
+	}
+	credentialId := webauthnHelper.Encode(data.CredentialId)
+	if response.CredentialId != "" && response.CredentialId != credentialId {
+		return nil, errorHelper.Wrap(errorHelper.InvalidCredentials, operation, ErrorWebauthnRegister, errors.New(ErrorWebauthnMismatch))
+	}
+	if name == "" {
+		name = "Security key"
+	}
+	now := time.Now()
+	credential := &models.Credential{
+		UserId:       userId,
+		CredentialId: credentialId,
+		PublicKey:    data.PublicKey,
+		SignCount:    int64(data.SignCount),
+		Aaguid:       data.AAGUID,
+		Name:         name,
+		CreatedAt:    now,
+		LastUsedAt:   now,
+	}
+	if credential.Id, err = s.storage.Credentials().InsertCredential(ctx, credential); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorWebauthnRegister, err)
+	}
+	return credential, nil
+}
+
+// BeginLogin returns options for navigator.credentials.get() and the ceremony token. With MFA challenge
+// token the credentials of that user are allowed, without it any discoverable credential is accepted.
+func (s *WebauthnService) BeginLogin(ctx context.Context, mfaToken string) (*webauthnHelper.RequestOptions, string, error) {
+	const operation = "internal.services.webauthn.BeginLogin()"
+	options := &webauthnHelper.RequestOptions{
+		Timeout:          s.config.ChallengeTTL.Milliseconds(),
+		RpId:             s.config.RpId,
+		AllowCredentials: []webauthnHelper.CredentialDescriptor{},
+		UserVerification: webauthnHelper.Required,
+	}
+	userId := ""
+	var mfa jwt.MapClaims
+	if mfaToken != "" {
+		//the challenge token is consumed only on finish, so the user can still fall back to TOTP
+		claims, user, err := s.mfa.challengeUser(ctx, mfaToken)
+		if err != nil {
+			return nil, "", errorHelper.WrapError(operation, ErrorWebauthnBegin, err)
+		}
+		credentials, err := s.storage.Credentials().GetUserCredentials(ctx, user.Id)
+		if err != nil {
+			return nil, "", errorHelper.WrapError(operation, ErrorGetCredentials, err)
+		}
+		if len(credentials) == 0 {
+			return nil, "", errorHelper.Wrap(errorHelper.Invalid, operation, ErrorWebauthnBegin, errors.New(ErrorCredentialUnknown))
+		}
+		userId = user.Id
+		mfa = claims
+		options.AllowCredentials = descriptors(credentials)
+		options.UserVerification = webauthnHelper.Discouraged
+	}
+	challenge, ceremony, err := s.ceremony(ctx, webauthnHelper.TypeGet, userId, mfa)
+	if err != nil {
+		return nil, "", errorHelper.WrapError(operation, ErrorWebauthnBegin, err)
+	}
+	options.Challenge = challenge
+	return options, ceremony, nil
+}
+
+// FinishLogin verifies authenticator assertion and returns the user and authentication context class
+func (s *WebauthnService) FinishLogin(ctx context.Context, ceremony string, response *Assertion) (*models.User, string, error) {
+	const operation = "internal.services.webauthn.FinishLogin()"
+	claims, err := s.consume(ctx, ceremony, webauthnHelper.TypeGet)
+	if err != nil {
+		return nil, "", errorHelper.WrapError(operation, ErrorWebauthnLogin, err)
+	}
+	credential, err := s.storage.Credentials().GetCredential(ctx, response.CredentialId)
+	if err != nil {
+		if errorHelper.KindOf(err) == errorHelper.NotFound {
+			return nil, "", errorHelper.Wrap(errorHelper.InvalidCredentials, operation, ErrorCredentialUnknown, err)
+		}
+		return nil, "", errorHelper.WrapError(operation, ErrorWebauthnLogin, err)
+	}
+	passwordless := true
+	if sub, _ := claims.GetSubject(); sub != "" {
+		passwordless = false
+		if sub != credential.UserId {
+			return nil, "", errorHelper.Wrap(errorHelper.InvalidCredentials, operation, ErrorWebauthnLogin, errors.New(ErrorWebauthnUser))
+		}
+	}
+	if len(response.UserHandle) > 0 && string(response.UserHandle) != credential.UserId {
+		return nil, "", errorHelper.Wrap(errorHelper.InvalidCredentials, operation, ErrorWebauthnLogin, errors.New(ErrorWebauthnHandle))
+	}
+	challenge, _ := claims["challenge"].(string)
+	if _, err := webauthnHelper.ParseClientData(response.ClientDataJSON, webauthnHelper.TypeGet, challenge, s.config.Origins); err != nil {
+		return nil, "", errorHelper.Wrap(errorHelper.InvalidCredentials, operation, ErrorWebauthnLogin, err)
+	}
+	data, err := webauthnHelper.ParseAuthenticatorData(response.AuthenticatorData)
+	if err != nil {
+		return nil, "", errorHelper.Wrap(errorHelper.InvalidCredentials, operation, ErrorWebauthnLogin, err)
+	}
+	if err := s.checkAuthenticatorData(data, passwordless); err != nil {
+		return nil, "", errorHelper.WrapError(operation, ErrorWebauthnLogin, err)
+	}
+	pub, alg, err := webauthnHelper.ParsePublicKey(credential.PublicKey)
+	if err != nil {
+		return nil, "", errorHelper.WrapError(operation, ErrorWebauthnLogin, err)
+	}
+	hash := sha256.Sum256(response.ClientDataJSON)
+	if err := webauthnHelper.VerifySignature(pub, alg, append(response.AuthenticatorData, hash[:]...), response.Signature); err != nil {
+		return nil, "", errorHelper.Wrap(errorHelper.InvalidCredentials, operation, ErrorWebauthnLogin, err)
+	}
+	//authenticators without counter always return 0, otherwise it must grow
+	signCount := int64(data.SignCount)
+	if (signCount != 0 || credential.SignCount != 0) && signCount <= credential.SignCount {
+		return nil, "", errorHelper.Wrap(errorHelper.InvalidCredentials, operation, ErrorWebauthnLogin, errors.New(ErrorWebauthnCounter))
+	}
+	ok, err := s.storage.Credentials().UseCredential(ctx, credential.Id, credential.SignCount, signCount, time.Now())
+	if err != nil {
+		return nil, "", errorHelper.WrapError(operation, ErrorWebauthnLogin, err)
+	}
+	if !ok {
+		return nil, "", errorHelper.Wrap(errorHelper.InvalidCredentials, operation, ErrorWebauthnLogin, errors.New(ErrorWebauthnCounter))
+	}
+	user, err := s.storage.Users().GetUserById(ctx, credential.UserId)
+	if err != nil {
+		return nil, "", errorHelper.WrapError(operation, ErrorGetUser, err)
+	}
+	if user.Disabled {
+		return nil, "", errorHelper.New(errorHelper.InvalidCredentials, operation, ErrorUserDisabled)
+	}
+	if passwordless {
+		return user, AcrPasskey, nil
+	}
+	//password and security key were checked, the MFA challenge can't be used for TOTP anymore
+	mfaJti, _ := claims["mfa_jti"].(string)
+	mfaExp, _ := claims["mfa_exp"].(float64)
+	if err := s.revocations.RevokeJti(ctx, mfaJti, time.Unix(int64(mfaExp), 0)); err != nil {
+		return nil, "", errorHelper.WrapError(operation, ErrorWebauthnLogin, err)
+	}
+	if err := s.mfa.lockout.Success(ctx, user.Login); err != nil {
+		return nil, "", errorHelper.WrapError(operation, ErrorWebauthnLogin, err)
+	}
+	return user, AcrMfa, nil
+}
+
+// Credentials returns credentials registered by the user
+func (s *WebauthnService) Credentials(ctx context.Context, userId string) ([]*models.Credential, error) {
+	const operation = "internal.services.webauthn.Credentials()"
+	credentials, err := s.storage.Credentials().GetUserCredentials(ctx, userId)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorGetCredentials, err)
+	}
+	return credentials, nil
+}
+
+// DeleteCredential removes credential of the user
+func (s *WebauthnService) DeleteCredential(ctx context.Context, userId string, id string) error {
+	const operation = "internal.services.webauthn.DeleteCredential()"
+	if err := s.storage.Credentials().DeleteCredential(ctx, userId, id); err != nil {
+		return errorHelper.WrapError(operation, ErrorDeleteCredential, err)
+	}
+	return nil
+}
+
+// ceremony creates a random challenge and signs it into the ceremony token
+func (s *WebauthnService) ceremony(ctx context.Context, typ string, userId string, mfa jwt.MapClaims) (string, string, error) {
+	const operation = "internal.services.webauthn.ceremony()"
+	random := make([]byte, challengeBytes)
+	if _, err := rand.Read(random); err != nil {
+		return "", "", errorHelper.WrapError(operation, ErrorWebauthnBegin, err)
+	}
+	challenge := webauthnHelper.Encode(random)
+	claims := map[string]any{
+		"token_use": TokenUseWebauthn,
+		"ceremony":  typ,
+		"challenge": challenge,
+	}
+	if userId != "" {
+		claims["sub"] = userId
+	}
+	if mfa != nil {
+		claims["mfa_jti"] = mfa["jti"]
+		claims["mfa_exp"] = mfa["exp"]
+	}
+	token, err := s.tokens.sign(ctx, claims, s.config.ChallengeTTL)
+	if err != nil {
+		return "", "", errorHelper.WrapError(operation, ErrorWebauthnBegin, err)
+	}
+	return challenge, token, nil
+}
+
+// consume verifies ceremony token and revokes it before the response is checked, so each challenge is tried once
+func (s *WebauthnService) consume(ctx context.Context, ceremony string, typ string) (jwt.MapClaims, error) {
+	const operation = "internal.services.webauthn.consume()"
+	claims, err := verify(ctx, ceremony, s.keys)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorWebauthnCeremony, err)
+	}
+	jti, _ := claims["jti"].(string)
+	if claims["token_use"] != TokenUseWebauthn || claims["ceremony"] != typ || jti == "" {
+		return nil, errorHelper.New(errorHelper.InvalidCredentials, operation, ErrorWebauthnCeremony)
+	}
+	if err := notRevoked(ctx, s.revocations, jti); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorWebauthnCeremony, err)
+	}
+	if mfaJti, ok := claims["mfa_jti"].(string); ok {
+		if err := notRevoked(ctx, s.revocations, mfaJti); err != nil {
+			return nil, errorHelper.WrapError(operation, ErrorWebauthnCeremony, err)
+		}
+	}
+	if err := consumeToken(ctx, s.revocations, claims); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorWebauthnCeremony, err)
+	}
+	return claims, nil
+}
+
+// checkAuthenticatorData checks relying party and user presence, verification is required for passwordless login
+func (s *WebauthnService) checkAuthenticatorData(data *webauthnHelper.AuthenticatorData, verification bool) error {
+	const operation = "internal.services.webauthn.checkAuthenticatorData()"
+	if !data.CheckRpId(s.config.RpId) {
+		return errorHelper.New(errorHelper.InvalidCredentials, operation, ErrorWebauthnRpId)
+	}
+	if !data.UserPresent() {
+		return errorHelper.New(errorHelper.InvalidCredentials, operation, ErrorWebauthnPresence)
+	}
+	if verification && !data.UserVerified() {
+		return errorHelper.New(errorHelper.InvalidCredentials, operation, ErrorWebauthnVerified)
+	}
+	return nil
+}
+
+// descriptors lists credentials for allowCredentials and excludeCredentials options
+func descriptors(credentials []*models.Credential) []webauthnHelper.CredentialDescriptor {
+	res := make([]webauthnHelper.CredentialDescriptor, 0, len(credentials))
+	for _, c := range credentials {
+		res = append(res, webauthnHelper.CredentialDescriptor{Type: webauthnHelper.CredentialTypePublicKey, Id: c.CredentialId})
+	}
+	return res
+}
@@ -0,0 +1,210 @@
+package services
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"sso/internal/config"
+	"sso/pkg/helpers/errorHelper"
+	"sso/pkg/helpers/webauthnHelper"
+	"sso/pkg/helpers/webauthnHelper/webauthntest"
+	"testing"
+	"time"
+)
+
+const (
+	testRpId   = "sso.example.com"
+	testOrigin = "https://sso.example.com"
+)
+
+func (e *testEnv) webauthn() *WebauthnService {
+	return Webauthn(e.storage, e.tokens, e.keys, e.revocations, e.mfa, config.WebauthnConfig{
+		RpId:         testRpId,
+		RpName:       "sso",
+		Origins:      []string{testOrigin},
+		Attestation:  webauthnHelper.FormatNone,
+		ChallengeTTL: 5 * time.Minute,
+	})
+}
+
+// attestation is the response of the authenticator to the registration options
+func attestation(a *webauthntest.Authenticator, options *webauthnHelper.CreationOptions) *Attestation {
+	data := webauthntest.ClientData(webauthnHelper.TypeCreate, options.Challenge, testOrigin)
+	return &Attestation{
+		CredentialId:      webauthnHelper.Encode(a.CredentialId),
+		ClientDataJSON:    data,
+		AttestationObject: a.Create(testRpId, data, webauthnHelper.FormatNone, nil),
+	}
+}
+
+// assertion is the response of the authenticator to the login options, userHandle is set by discoverable credentials
+func assertion(a *webauthntest.Authenticator, options *webauthnHelper.RequestOptions, flags byte, userHandle string) *Assertion {
+	data := webauthntest.ClientData(webauthnHelper.TypeGet, options.Challenge, testOrigin)
+	authData, signature := a.Get(testRpId, data, flags)
+	return &Assertion{
+		CredentialId:      webauthnHelper.Encode(a.CredentialId),
+		ClientDataJSON:    data,
+		AuthenticatorData: authData,
+		Signature:         signature,
+		UserHandle:        []byte(userHandle),
+	}
+}
+
+// registerAuthenticator registers a new software authenticator of the user
+func registerAuthenticator(t *testing.T, s *WebauthnService, userId string) *webauthntest.Authenticator {
+	t.Helper()
+	ctx := context.Background()
+	a := webauthntest.New(t)
+	options, ceremony, err := s.BeginRegistration(ctx, userId)
+	require.NoError(t, err)
+	_, err = s.FinishRegistration(ctx, userId, ceremony, "key", attestation(a, options))
+	require.NoError(t, err)
+	return a
+}
+
+func TestWebauthnRegistration(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	s := env.webauthn()
+	uid := env.addUser(t, "alice")
+	bobId := env.addUser(t, "bob")
+	a := webauthntest.New(t)
+
+	options, ceremony, err := s.BeginRegistration(ctx, uid)
+	require.NoError(t, err)
+	require.Equal(t, testRpId, options.Rp.Id)
+	require.Equal(t, webauthnHelper.Encode([]byte(uid)), options.User.Id)
+	require.Empty(t, options.ExcludeCredentials)
+	//the ceremony is bound to the user who started it
+	_, err = s.FinishRegistration(ctx, bobId, ceremony, "key", attestation(a, options))
+	require.ErrorIs(t, err, errorHelper.InvalidCredentials)
+	require.ErrorContains(t, err, ErrorWebauthnUser)
+
+	options, ceremony, err = s.BeginRegistration(ctx, uid)
+	require.NoError(t, err)
+	credential, err := s.FinishRegistration(ctx, uid, ceremony, "laptop", attestation(a, options))
+	require.NoError(t, err)
+	require.Equal(t, uid, credential.UserId)
+	require.Equal(t, webauthnHelper.Encode(a.CredentialId), credential.CredentialId)
+	require.Equal(t, a.CoseKey(), credential.PublicKey)
+	require.Equal(t, "laptop", credential.Name)
+
+	//a ceremony token is used once
+	_, err = s.FinishRegistration(ctx, uid, ceremony, "laptop", attestation(a, options))
+	require.ErrorIs(t, err, errorHelper.InvalidCredentials)
+
+	list, err := s.Credentials(ctx, uid)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	require.Equal(t, credential.Id, list[0].Id)
+	options, _, err = s.BeginRegistration(ctx, uid)
+	require.NoError(t, err)
+	require.Len(t, options.ExcludeCredentials, 1)
+	require.Equal(t, credential.CredentialId, options.ExcludeCredentials[0].Id)
+}
+
+func TestWebauthnPasswordless(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	s := env.webauthn()
+	uid := env.addUser(t, "alice")
+	bobId := env.addUser(t, "bob")
+	a := registerAuthenticator(t, s, uid)
+
+	//user verification replaces the password
+	options, ceremony, err := s.BeginLogin(ctx, "")
+	require.NoError(t, err)
+	require.Equal(t, webauthnHelper.Required, options.UserVerification)
+	_, _, err = s.FinishLogin(ctx, ceremony, assertion(a, options, webauthntest.FlagUserPresent, uid))
+	require.ErrorIs(t, err, errorHelper.InvalidCredentials)
+	require.ErrorContains(t, err, ErrorWebauthnVerified)
+
+	//the user handle must belong to the credential owner
+	options, ceremony, err = s.BeginLogin(ctx, "")
+	require.NoError(t, err)
+	_, _, err = s.FinishLogin(ctx, ceremony, assertion(a, options, webauthntest.FlagUserPresent|webauthntest.FlagUserVerified, bobId))
+	require.ErrorIs(t, err, errorHelper.InvalidCredentials)
+	require.ErrorContains(t, err, ErrorWebauthnHandle)
+
+	options, ceremony, err = s.BeginLogin(ctx, "")
+	require.NoError(t, err)
+	response := assertion(a, options, webauthntest.FlagUserPresent|webauthntest.FlagUserVerified, uid)
+	user, acr, err := s.FinishLogin(ctx, ceremony, response)
+	require.NoError(t, err)
+	require.Equal(t, uid, user.Id)
+	require.Equal(t, AcrPasskey, acr)
+	credential, err := env.storage.Credentials().GetCredential(ctx, response.CredentialId)
+	require.NoError(t, err)
+	require.Equal(t, int64(a.SignCount), credential.SignCount)
+
+	//a ceremony token is used once
+	_, _, err = s.FinishLogin(ctx, ceremony, response)
+	require.ErrorIs(t, err, errorHelper.InvalidCredentials)
+}
+
+func TestWebauthnSignCount(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	s := env.webauthn()
+	uid := env.addUser(t, "alice")
+	a := registerAuthenticator(t, s, uid)
+	flags := byte(webauthntest.FlagUserPresent | webauthntest.FlagUserVerified)
+
+	options, ceremony, err := s.BeginLogin(ctx, "")
+	require.NoError(t, err)
+	_, _, err = s.FinishLogin(ctx, ceremony, assertion(a, options, flags, uid))
+	require.NoError(t, err)
+
+	//a clone of the authenticator repeats the stored counter
+	a.SignCount--
+	options, ceremony, err = s.BeginLogin(ctx, "")
+	require.NoError(t, err)
+	_, _, err = s.FinishLogin(ctx, ceremony, assertion(a, options, flags, uid))
+	require.ErrorIs(t, err, errorHelper.InvalidCredentials)
+	require.ErrorContains(t, err, ErrorWebauthnCounter)
+
+	a.SignCount++
+	options, ceremony, err = s.BeginLogin(ctx, "")
+	require.NoError(t, err)
+	_, _, err = s.FinishLogin(ctx, ceremony, assertion(a, options, flags, uid))
+	require.NoError(t, err)
+}
+
+func TestWebauthnSecondFactor(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	s := env.webauthn()
+	uid := env.addUser(t, "alice")
+	bobId := env.addUser(t, "bob")
+	a := registerAuthenticator(t, s, uid)
+	registerAuthenticator(t, s, bobId)
+
+	//the challenge of another user does not accept the credential of alice
+	options, ceremony, err := s.BeginLogin(ctx, challenge(t, env, bobId))
+	require.NoError(t, err)
+	_, _, err = s.FinishLogin(ctx, ceremony, assertion(a, options, webauthntest.FlagUserPresent, ""))
+	require.ErrorIs(t, err, errorHelper.InvalidCredentials)
+	require.ErrorContains(t, err, ErrorWebauthnUser)
+
+	require.NoError(t, env.lockout.Failure(ctx, "alice", "10.0.0.1"))
+	mfaToken := challenge(t, env, uid)
+	options, ceremony, err = s.BeginLogin(ctx, mfaToken)
+	require.NoError(t, err)
+	require.Len(t, options.AllowCredentials, 1)
+	require.Equal(t, webauthnHelper.Encode(a.CredentialId), options.AllowCredentials[0].Id)
+	//the password was checked, user presence is enough
+	user, acr, err := s.FinishLogin(ctx, ceremony, assertion(a, options, webauthntest.FlagUserPresent, ""))
+	require.NoError(t, err)
+	require.Equal(t, uid, user.Id)
+	require.Equal(t, AcrMfa, acr)
+
+	//the challenge is revoked and the account counter is reset
+	claims, err := verify(ctx, mfaToken, env.keys)
+	require.NoError(t, err)
+	revoked, err := env.revocations.IsRevoked(ctx, claims["jti"].(string))
+	require.NoError(t, err)
+	require.True(t, revoked)
+	_, err = env.mfa.Consume(ctx, mfaToken)
+	require.ErrorIs(t, err, errorHelper.InvalidCredentials)
+	_, err = env.storage.Attempts().GetAttempts(ctx, accountKey("alice"))
+	require.ErrorIs(t, err, errorHelper.NotFound)
+}
@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sso/pkg/helpers/errorHelper"
+	"time"
+)
+
+// Storage implementations classify their errors with errorHelper kinds: errorHelper.NotFound,
+// errorHelper.Conflict for unique constraint violations, errorHelper.Canceled and errorHelper.Timeout
+// for operations stopped by the context. Driver errors are kept only as causes for logs.
+const (
+	ErrorCanceled = "storage operation canceled"
+	ErrorTimeout  = "storage operation timed out"
+)
+
+// Canceled classifies err caused by the context as errorHelper.Timeout or errorHelper.Canceled, otherwise returns nil
+func Canceled(ctx context.Context, err error) error {
+	const operation = "internal.storage.Canceled()"
+	if err == nil {
+		return nil
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return errorHelper.Wrap(errorHelper.Timeout, operation, ErrorTimeout, context.DeadlineExceeded)
+	case errors.Is(err, context.Canceled), errors.Is(ctx.Err(), context.Canceled):
+		return errorHelper.Wrap(errorHelper.Canceled, operation, ErrorCanceled, context.Canceled)
+	default:
+		return nil
+	}
+}
+
+// WithTimeout limits ctx by the operation timeout, zero timeout leaves ctx as is
+func WithTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
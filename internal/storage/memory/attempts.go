@@ -0,0 +1,80 @@
+package memory
+
+import (
+	"context"
+	"sso/internal/models"
+	"sso/pkg/helpers/errorHelper"
+	"time"
+)
+
+type Attempts struct {
+	s *Storage
+}
+
+const (
+	ErrorAttemptsNotFound = "Login attempts not found"
+)
+
+func (a *Attempts) GetAttempts(ctx context.Context, key string) (*models.Attempts, error) {
+	const operation = "internal.storage.memory.GetAttempts()"
+	if err := canceled(ctx); err != nil {
+		return nil, err
+	}
+	a.s.mu.RLock()
+	defer a.s.mu.RUnlock()
+	if attempts, ok := a.s.attempts[key]; ok && attempts.ExpireAt.After(time.Now()) {
+		c := *attempts
+		return &c, nil
+	}
+	return nil, errorHelper.New(errorHelper.NotFound, operation, ErrorAttemptsNotFound)
+}
+
+func (a *Attempts) AddFailure(ctx context.Context, key string, now time.Time, expireAt time.Time) (*models.Attempts, error) {
+	if err := canceled(ctx); err != nil {
+		return nil, err
+	}
+	a.s.mu.Lock()
+	defer a.s.mu.Unlock()
+	attempts, ok := a.s.attempts[key]
+	//expired counter starts from one like a new one
+	if !ok || !attempts.ExpireAt.After(now) {
+		attempts = &models.Attempts{Key: key}
+		a.s.attempts[key] = attempts
+	}
+	attempts.Failures++
+	attempts.LastFailure = now
+	if expireAt.After(attempts.ExpireAt) {
+		attempts.ExpireAt = expireAt
+	}
+	c := *attempts
+	return &c, nil
+}
+
+func (a *Attempts) LockAttempts(ctx context.Context, key string, until time.Time, expireAt time.Time) error {
+	if err := canceled(ctx); err != nil {
+		return err
+	}
+	a.s.mu.Lock()
+	defer a.s.mu.Unlock()
+	attempts, ok := a.s.attempts[key]
+	if !ok {
+		return nil
+	}
+	if until.After(attempts.LockedUntil) {
+		attempts.LockedUntil = until
+	}
+	if expireAt.After(attempts.ExpireAt) {
+		attempts.ExpireAt = expireAt
+	}
+	return nil
+}
+
+func (a *Attempts) ResetAttempts(ctx context.Context, key string) error {
+	if err := canceled(ctx); err != nil {
+		return err
+	}
+	a.s.mu.Lock()
+	defer a.s.mu.Unlock()
+	delete(a.s.attempts, key)
+	return nil
+}
@@ -0,0 +1,62 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sso/internal/models"
+	"sso/pkg/helpers/errorHelper"
+)
+
+type Clients struct {
+	s *Storage
+}
+
+const (
+	ErrorClientNotFound = "Client not found"
+	ErrorClientExists   = "Client with the client id already exists"
+)
+
+func cloneClient(client *models.Client) *models.Client {
+	c := *client
+	c.RedirectUris = slices.Clone(client.RedirectUris)
+	c.Scopes = slices.Clone(client.Scopes)
+	return &c
+}
+
+func (c *Clients) GetClient(ctx context.Context, clientId string) (*models.Client, error) {
+	const operation = "internal.storage.memory.GetClient()"
+	if err := canceled(ctx); err != nil {
+		return nil, err
+	}
+	c.s.mu.RLock()
+	defer c.s.mu.RUnlock()
+	if client := c.byClientId(clientId); client != nil {
+		return cloneClient(client), nil
+	}
+	return nil, errorHelper.New(errorHelper.NotFound, operation, ErrorClientNotFound)
+}
+
+func (c *Clients) byClientId(clientId string) *models.Client {
+	for _, client := range c.s.clients {
+		if client.ClientId == clientId {
+			return client
+		}
+	}
+	return nil
+}
+
+func (c *Clients) InsertClient(ctx context.Context, client *models.Client) (string, error) {
+	const operation = "internal.storage.memory.InsertClient()"
+	if err := canceled(ctx); err != nil {
+		return "", err
+	}
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+	if c.byClientId(client.ClientId) != nil {
+		return "", errorHelper.New(errorHelper.Conflict, operation, ErrorClientExists)
+	}
+	stored := cloneClient(client)
+	stored.Id = newId()
+	c.s.clients[stored.Id] = stored
+	return stored.Id, nil
+}
@@ -0,0 +1,69 @@
+package memory
+
+import (
+	"context"
+	"sso/internal/models"
+	"sso/pkg/helpers/errorHelper"
+)
+
+type Codes struct {
+	s *Storage
+}
+
+const (
+	ErrorCodeNotFound = "Authorization code not found"
+	ErrorCodeExists   = "Authorization code with the hash already exists"
+)
+
+func (c *Codes) InsertCode(ctx context.Context, code *models.AuthCode) (string, error) {
+	const operation = "internal.storage.memory.InsertCode()"
+	if err := canceled(ctx); err != nil {
+		return "", err
+	}
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+	if c.byHash(code.Hash) != nil {
+		return "", errorHelper.New(errorHelper.Conflict, operation, ErrorCodeExists)
+	}
+	stored := *code
+	stored.Id = newId()
+	c.s.codes[stored.Id] = &stored
+	return stored.Id, nil
+}
+
+func (c *Codes) GetCode(ctx context.Context, hash string) (*models.AuthCode, error) {
+	const operation = "internal.storage.memory.GetCode()"
+	if err := canceled(ctx); err != nil {
+		return nil, err
+	}
+	c.s.mu.RLock()
+	defer c.s.mu.RUnlock()
+	if code := c.byHash(hash); code != nil {
+		res := *code
+		return &res, nil
+	}
+	return nil, errorHelper.New(errorHelper.NotFound, operation, ErrorCodeNotFound)
+}
+
+func (c *Codes) byHash(hash string) *models.AuthCode {
+	for _, code := range c.s.codes {
+		if code.Hash == hash {
+			return code
+		}
+	}
+	return nil
+}
+
+func (c *Codes) UseCode(ctx context.Context, id string) (bool, error) {
+	if err := canceled(ctx); err != nil {
+		return false, err
+	}
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+	code, ok := c.s.codes[id]
+	if !ok || code.Used {
+		return false, nil
+	}
+	code.Used = true
+	return true, nil
+}
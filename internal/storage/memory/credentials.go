@@ -0,0 +1,120 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sso/internal/models"
+	"sso/pkg/helpers/errorHelper"
+	"time"
+)
+
+type Credentials struct {
+	s *Storage
+}
+
+const (
+	ErrorCredentialNotFound = "Credential not found"
+	ErrorCredentialExists   = "Credential with the credential id already exists"
+)
+
+func cloneCredential(credential *models.Credential) *models.Credential {
+	c := *credential
+	c.PublicKey = slices.Clone(credential.PublicKey)
+	return &c
+}
+
+func (c *Credentials) InsertCredential(ctx context.Context, credential *models.Credential) (string, error) {
+	const operation = "internal.storage.memory.InsertCredential()"
+	if err := canceled(ctx); err != nil {
+		return "", err
+	}
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+	if c.byCredentialId(credential.CredentialId) != nil {
+		return "", errorHelper.New(errorHelper.Conflict, operation, ErrorCredentialExists)
+	}
+	stored := cloneCredential(credential)
+	stored.Id = newId()
+	c.s.credentials[stored.Id] = stored
+	return stored.Id, nil
+}
+
+func (c *Credentials) GetCredential(ctx context.Context, credentialId string) (*models.Credential, error) {
+	const operation = "internal.storage.memory.GetCredential()"
+	if err := canceled(ctx); err != nil {
+		return nil, err
+	}
+	c.s.mu.RLock()
+	defer c.s.mu.RUnlock()
+	if credential := c.byCredentialId(credentialId); credential != nil {
+		return cloneCredential(credential), nil
+	}
+	return nil, errorHelper.New(errorHelper.NotFound, operation, ErrorCredentialNotFound)
+}
+
+func (c *Credentials) byCredentialId(credentialId string) *models.Credential {
+	for _, credential := range c.s.credentials {
+		if credential.CredentialId == credentialId {
+			return credential
+		}
+	}
+	return nil
+}
+
+func (c *Credentials) GetUserCredentials(ctx context.Context, userId string) ([]*models.Credential, error) {
+	if err := canceled(ctx); err != nil {
+		return nil, err
+	}
+	c.s.mu.RLock()
+	defer c.s.mu.RUnlock()
+	credentials := make([]*models.Credential, 0)
+	for _, credential := range c.s.credentials {
+		if credential.UserId == userId {
+			credentials = append(credentials, cloneCredential(credential))
+		}
+	}
+	slices.SortFunc(credentials, func(a, b *models.Credential) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return credentials, nil
+}
+
+func (c *Credentials) UseCredential(ctx context.Context, id string, signCount int64, newSignCount int64, usedAt time.Time) (bool, error) {
+	if err := canceled(ctx); err != nil {
+		return false, err
+	}
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+	credential, ok := c.s.credentials[id]
+	if !ok || credential.SignCount != signCount {
+		return false, nil
+	}
+	credential.SignCount, credential.LastUsedAt = newSignCount, usedAt
+	return true, nil
+}
+
+func (c *Credentials) DeleteCredential(ctx context.Context, userId string, id string) error {
+	const operation = "internal.storage.memory.DeleteCredential()"
+	if err := canceled(ctx); err != nil {
+		return err
+	}
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+	if credential, ok := c.s.credentials[id]; !ok || credential.UserId != userId {
+		return errorHelper.New(errorHelper.NotFound, operation, ErrorCredentialNotFound)
+	}
+	delete(c.s.credentials, id)
+	return nil
+}
+
+func (c *Credentials) DeleteUserCredentials(ctx context.Context, userId string) error {
+	if err := canceled(ctx); err != nil {
+		return err
+	}
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+	for id, credential := range c.s.credentials {
+		if credential.UserId == userId {
+			delete(c.s.credentials, id)
+		}
+	}
+	return nil
+}
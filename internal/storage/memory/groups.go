@@ -0,0 +1,133 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sso/internal/models"
+	"sso/pkg/helpers/errorHelper"
+	"strings"
+)
+
+type Groups struct {
+	s *Storage
+}
+
+const (
+	ErrorGroupNotFound = "Group not found"
+	ErrorGroupExists   = "Group with the name already exists"
+)
+
+func cloneGroup(group *models.Group) *models.Group {
+	c := *group
+	c.Parents = slices.Clone(group.Parents)
+	return &c
+}
+
+func (g *Groups) GetGroup(ctx context.Context, id string) (*models.Group, error) {
+	const operation = "internal.storage.memory.GetGroup()"
+	if err := canceled(ctx); err != nil {
+		return nil, err
+	}
+	g.s.mu.RLock()
+	defer g.s.mu.RUnlock()
+	if group, ok := g.s.groups[id]; ok {
+		return cloneGroup(group), nil
+	}
+	return nil, errorHelper.New(errorHelper.NotFound, operation, ErrorGroupNotFound)
+}
+
+func (g *Groups) GetGroups(ctx context.Context, ids []string) ([]*models.Group, error) {
+	if err := canceled(ctx); err != nil {
+		return nil, err
+	}
+	return g.find(func(group *models.Group) bool { return slices.Contains(ids, group.Id) }), nil
+}
+
+func (g *Groups) ListGroups(ctx context.Context) ([]*models.Group, error) {
+	if err := canceled(ctx); err != nil {
+		return nil, err
+	}
+	return g.find(func(*models.Group) bool { return true }), nil
+}
+
+func (g *Groups) find(match func(group *models.Group) bool) []*models.Group {
+	g.s.mu.RLock()
+	defer g.s.mu.RUnlock()
+	groups := make([]*models.Group, 0)
+	for _, group := range g.s.groups {
+		if match(group) {
+			groups = append(groups, cloneGroup(group))
+		}
+	}
+	slices.SortFunc(groups, func(a, b *models.Group) int { return strings.Compare(a.Name, b.Name) })
+	return groups
+}
+
+// byName returns the group with the name other than the group with id
+func (g *Groups) byName(name string, id string) *models.Group {
+	for _, group := range g.s.groups {
+		if group.Name == name && group.Id != id {
+			return group
+		}
+	}
+	return nil
+}
+
+func (g *Groups) InsertGroup(ctx context.Context, group *models.Group) (string, error) {
+	const operation = "internal.storage.memory.InsertGroup()"
+	if err := canceled(ctx); err != nil {
+		return "", err
+	}
+	g.s.mu.Lock()
+	defer g.s.mu.Unlock()
+	if g.byName(group.Name, "") != nil {
+		return "", errorHelper.New(errorHelper.Conflict, operation, ErrorGroupExists)
+	}
+	c := cloneGroup(group)
+	c.Id = newId()
+	g.s.groups[c.Id] = c
+	return c.Id, nil
+}
+
+func (g *Groups) UpdateGroup(ctx context.Context, group *models.Group) error {
+	const operation = "internal.storage.memory.UpdateGroup()"
+	if err := canceled(ctx); err != nil {
+		return err
+	}
+	g.s.mu.Lock()
+	defer g.s.mu.Unlock()
+	if _, ok := g.s.groups[group.Id]; !ok {
+		return errorHelper.New(errorHelper.NotFound, operation, ErrorGroupNotFound)
+	}
+	if g.byName(group.Name, group.Id) != nil {
+		return errorHelper.New(errorHelper.Conflict, operation, ErrorGroupExists)
+	}
+	g.s.groups[group.Id] = cloneGroup(group)
+	return nil
+}
+
+func (g *Groups) DeleteGroup(ctx context.Context, id string) error {
+	const operation = "internal.storage.memory.DeleteGroup()"
+	if err := canceled(ctx); err != nil {
+		return err
+	}
+	g.s.mu.Lock()
+	defer g.s.mu.Unlock()
+	if _, ok := g.s.groups[id]; !ok {
+		return errorHelper.New(errorHelper.NotFound, operation, ErrorGroupNotFound)
+	}
+	delete(g.s.groups, id)
+	return nil
+}
+
+func (g *Groups) RemoveParent(ctx context.Context, parentId string) error {
+	if err := canceled(ctx); err != nil {
+		return err
+	}
+	g.s.mu.Lock()
+	defer g.s.mu.Unlock()
+	for _, group := range g.s.groups {
+		group.Parents = pull(group.Parents, parentId)
+	}
+	return nil
+}
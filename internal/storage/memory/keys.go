@@ -0,0 +1,44 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sso/internal/models"
+	"time"
+)
+
+type Keys struct {
+	s *Storage
+}
+
+func (k *Keys) GetKeys(ctx context.Context) ([]*models.Key, error) {
+	if err := canceled(ctx); err != nil {
+		return nil, err
+	}
+	k.s.mu.RLock()
+	defer k.s.mu.RUnlock()
+	now := time.Now()
+	var keys []*models.Key
+	for _, key := range k.s.keys {
+		if key.Exp.After(now) {
+			c := *key
+			c.PEM = slices.Clone(key.PEM)
+			keys = append(keys, &c)
+		}
+	}
+	slices.SortFunc(keys, func(a, b *models.Key) int { return a.ActivateAt.Compare(b.ActivateAt) })
+	return keys, nil
+}
+
+func (k *Keys) InsertKey(ctx context.Context, key *models.Key) (string, error) {
+	if err := canceled(ctx); err != nil {
+		return "", err
+	}
+	k.s.mu.Lock()
+	defer k.s.mu.Unlock()
+	c := *key
+	c.Id = newId()
+	c.PEM = slices.Clone(key.PEM)
+	k.s.keys[c.Id] = &c
+	return c.Id, nil
+}
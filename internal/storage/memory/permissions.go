@@ -0,0 +1,76 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sso/internal/models"
+	"sso/pkg/helpers/errorHelper"
+	"strings"
+)
+
+type Permissions struct {
+	s *Storage
+}
+
+const (
+	ErrorPermissionNotFound = "Permission not found"
+	ErrorPermissionExists   = "Permission with the name already exists"
+)
+
+func (p *Permissions) GetPermissions(ctx context.Context, names []string) ([]*models.Permission, error) {
+	if err := canceled(ctx); err != nil {
+		return nil, err
+	}
+	return p.find(func(permission *models.Permission) bool { return slices.Contains(names, permission.Name) }), nil
+}
+
+func (p *Permissions) ListPermissions(ctx context.Context) ([]*models.Permission, error) {
+	if err := canceled(ctx); err != nil {
+		return nil, err
+	}
+	return p.find(func(*models.Permission) bool { return true }), nil
+}
+
+func (p *Permissions) find(match func(permission *models.Permission) bool) []*models.Permission {
+	p.s.mu.RLock()
+	defer p.s.mu.RUnlock()
+	permissions := make([]*models.Permission, 0)
+	for _, permission := range p.s.permissions {
+		if match(permission) {
+			c := *permission
+			permissions = append(permissions, &c)
+		}
+	}
+	slices.SortFunc(permissions, func(a, b *models.Permission) int { return strings.Compare(a.Name, b.Name) })
+	return permissions
+}
+
+func (p *Permissions) InsertPermission(ctx context.Context, permission *models.Permission) (string, error) {
+	const operation = "internal.storage.memory.InsertPermission()"
+	if err := canceled(ctx); err != nil {
+		return "", err
+	}
+	p.s.mu.Lock()
+	defer p.s.mu.Unlock()
+	if _, ok := p.s.permissions[permission.Name]; ok {
+		return "", errorHelper.New(errorHelper.Conflict, operation, ErrorPermissionExists)
+	}
+	c := *permission
+	c.Id = newId()
+	p.s.permissions[c.Name] = &c
+	return c.Id, nil
+}
+
+func (p *Permissions) DeletePermission(ctx context.Context, name string) error {
+	const operation = "internal.storage.memory.DeletePermission()"
+	if err := canceled(ctx); err != nil {
+		return err
+	}
+	p.s.mu.Lock()
+	defer p.s.mu.Unlock()
+	if _, ok := p.s.permissions[name]; !ok {
+		return errorHelper.New(errorHelper.NotFound, operation, ErrorPermissionNotFound)
+	}
+	delete(p.s.permissions, name)
+	return nil
+}
@@ -0,0 +1,46 @@
+package memory
+
+import (
+	"context"
+	"sso/internal/models"
+	"time"
+)
+
+type Revocations struct {
+	s *Storage
+}
+
+func (r *Revocations) Revoke(ctx context.Context, jti string, exp time.Time) error {
+	if err := canceled(ctx); err != nil {
+		return err
+	}
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	//the first revocation is kept, so revocation is idempotent
+	if _, ok := r.s.revocations[jti]; !ok {
+		r.s.revocations[jti] = &models.Revocation{Jti: jti, RevokedAt: time.Now(), Exp: exp}
+	}
+	return nil
+}
+
+func (r *Revocations) GetRevoked(ctx context.Context, since time.Time) ([]*models.Revocation, error) {
+	if err := canceled(ctx); err != nil {
+		return nil, err
+	}
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	now := time.Now()
+	var list []*models.Revocation
+	for jti, revocation := range r.s.revocations {
+		//expired revocations are dropped here instead of by TTL index
+		if !revocation.Exp.After(now) {
+			delete(r.s.revocations, jti)
+			continue
+		}
+		if !revocation.RevokedAt.Before(since) {
+			c := *revocation
+			list = append(list, &c)
+		}
+	}
+	return list, nil
+}
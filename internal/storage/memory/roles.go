@@ -0,0 +1,123 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sso/internal/models"
+	"sso/pkg/helpers/errorHelper"
+	"strings"
+)
+
+type Roles struct {
+	s *Storage
+}
+
+const (
+	ErrorRoleNotFound = "Role not found"
+	ErrorRoleExists   = "Role with the name already exists"
+)
+
+func cloneRole(role *models.Role) *models.Role {
+	c := *role
+	c.Permissions = slices.Clone(role.Permissions)
+	return &c
+}
+
+func (r *Roles) GetRole(ctx context.Context, name string) (*models.Role, error) {
+	const operation = "internal.storage.memory.GetRole()"
+	if err := canceled(ctx); err != nil {
+		return nil, err
+	}
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+	if role, ok := r.s.roles[name]; ok {
+		return cloneRole(role), nil
+	}
+	return nil, errorHelper.New(errorHelper.NotFound, operation, ErrorRoleNotFound)
+}
+
+func (r *Roles) GetRoles(ctx context.Context, names []string) ([]*models.Role, error) {
+	if err := canceled(ctx); err != nil {
+		return nil, err
+	}
+	return r.find(func(role *models.Role) bool { return slices.Contains(names, role.Name) }), nil
+}
+
+func (r *Roles) ListRoles(ctx context.Context) ([]*models.Role, error) {
+	if err := canceled(ctx); err != nil {
+		return nil, err
+	}
+	return r.find(func(*models.Role) bool { return true }), nil
+}
+
+func (r *Roles) find(match func(role *models.Role) bool) []*models.Role {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+	roles := make([]*models.Role, 0)
+	for _, role := range r.s.roles {
+		if match(role) {
+			roles = append(roles, cloneRole(role))
+		}
+	}
+	slices.SortFunc(roles, func(a, b *models.Role) int { return strings.Compare(a.Name, b.Name) })
+	return roles
+}
+
+func (r *Roles) InsertRole(ctx context.Context, role *models.Role) (string, error) {
+	const operation = "internal.storage.memory.InsertRole()"
+	if err := canceled(ctx); err != nil {
+		return "", err
+	}
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if _, ok := r.s.roles[role.Name]; ok {
+		return "", errorHelper.New(errorHelper.Conflict, operation, ErrorRoleExists)
+	}
+	c := cloneRole(role)
+	c.Id = newId()
+	r.s.roles[c.Name] = c
+	return c.Id, nil
+}
+
+// UpdateRole updates the role found by name, name itself is not changeable
+func (r *Roles) UpdateRole(ctx context.Context, role *models.Role) error {
+	const operation = "internal.storage.memory.UpdateRole()"
+	if err := canceled(ctx); err != nil {
+		return err
+	}
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	stored, ok := r.s.roles[role.Name]
+	if !ok {
+		return errorHelper.New(errorHelper.NotFound, operation, ErrorRoleNotFound)
+	}
+	stored.Description = role.Description
+	stored.Permissions = slices.Clone(role.Permissions)
+	return nil
+}
+
+func (r *Roles) DeleteRole(ctx context.Context, name string) error {
+	const operation = "internal.storage.memory.DeleteRole()"
+	if err := canceled(ctx); err != nil {
+		return err
+	}
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if _, ok := r.s.roles[name]; !ok {
+		return errorHelper.New(errorHelper.NotFound, operation, ErrorRoleNotFound)
+	}
+	delete(r.s.roles, name)
+	return nil
+}
+
+func (r *Roles) RemovePermission(ctx context.Context, permission string) error {
+	if err := canceled(ctx); err != nil {
+		return err
+	}
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, role := range r.s.roles {
+		role.Permissions = pull(role.Permissions, permission)
+	}
+	return nil
+}
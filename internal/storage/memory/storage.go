@@ -0,0 +1,116 @@
+package memory
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"sso/internal/models"
+	"sso/internal/storage"
+	"sync"
+)
+
+// Storage keeps everything in maps guarded by one lock, data is lost on shutdown.
+// Stored and returned models are copies, callers can't change stored data without an update call.
+type Storage struct {
+	mu          sync.RWMutex
+	users       map[string]*models.User       //by id
+	keys        map[string]*models.Key        //by id
+	tokens      map[string]*models.Token      //by id
+	revocations map[string]*models.Revocation //by jti
+	clients     map[string]*models.Client     //by id
+	codes       map[string]*models.AuthCode   //by id
+	roles       map[string]*models.Role       //by name
+	permissions map[string]*models.Permission //by name
+	groups      map[string]*models.Group      //by id
+	credentials map[string]*models.Credential //by id
+	attempts    map[string]*models.Attempts   //by key
+}
+
+// New returns empty storage with the admin role, like a freshly migrated database
+func New() *Storage {
+	s := &Storage{
+		users:       make(map[string]*models.User),
+		keys:        make(map[string]*models.Key),
+		tokens:      make(map[string]*models.Token),
+		revocations: make(map[string]*models.Revocation),
+		clients:     make(map[string]*models.Client),
+		codes:       make(map[string]*models.AuthCode),
+		roles:       make(map[string]*models.Role),
+		permissions: make(map[string]*models.Permission),
+		groups:      make(map[string]*models.Group),
+		credentials: make(map[string]*models.Credential),
+		attempts:    make(map[string]*models.Attempts),
+	}
+	s.roles[models.RoleAdmin] = &models.Role{
+		Id:          newId(),
+		Name:        models.RoleAdmin,
+		Description: "Access to the admin API",
+		Permissions: []string{},
+	}
+	return s
+}
+
+// canceled returns canceled or timed out error if ctx is done, operations don't block so ctx is checked once before them
+func canceled(ctx context.Context) error {
+	return storage.Canceled(ctx, ctx.Err())
+}
+
+func newId() string {
+	return uuid.NewString()
+}
+
+func (s *Storage) Ping(ctx context.Context) error {
+	return canceled(ctx)
+}
+
+// Migrated always succeeds, New creates everything migrations would
+func (s *Storage) Migrated(ctx context.Context) error {
+	return nil
+}
+
+func (s *Storage) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+func (s *Storage) Users() storage.Users {
+	return &Users{s: s}
+}
+
+func (s *Storage) Keys() storage.Keys {
+	return &Keys{s: s}
+}
+
+func (s *Storage) Tokens() storage.Tokens {
+	return &Tokens{s: s}
+}
+
+func (s *Storage) Revocations() storage.Revocations {
+	return &Revocations{s: s}
+}
+
+func (s *Storage) Clients() storage.Clients {
+	return &Clients{s: s}
+}
+
+func (s *Storage) Codes() storage.Codes {
+	return &Codes{s: s}
+}
+
+func (s *Storage) Roles() storage.Roles {
+	return &Roles{s: s}
+}
+
+func (s *Storage) Permissions() storage.Permissions {
+	return &Permissions{s: s}
+}
+
+func (s *Storage) Groups() storage.Groups {
+	return &Groups{s: s}
+}
+
+func (s *Storage) Credentials() storage.Credentials {
+	return &Credentials{s: s}
+}
+
+func (s *Storage) Attempts() storage.Attempts {
+	return &Attempts{s: s}
+}
@@ -0,0 +1,83 @@
+package memory
+
+import (
+	"context"
+	"sso/internal/models"
+	"sso/pkg/helpers/errorHelper"
+)
+
+type Tokens struct {
+	s *Storage
+}
+
+const (
+	ErrorTokenNotFound = "Token not found"
+	ErrorTokenExists   = "Token with the hash already exists"
+)
+
+func (t *Tokens) InsertToken(ctx context.Context, token *models.Token) (string, error) {
+	const operation = "internal.storage.memory.InsertToken()"
+	if err := canceled(ctx); err != nil {
+		return "", err
+	}
+	t.s.mu.Lock()
+	defer t.s.mu.Unlock()
+	if t.byHash(token.Hash) != nil {
+		return "", errorHelper.New(errorHelper.Conflict, operation, ErrorTokenExists)
+	}
+	c := *token
+	c.Id = newId()
+	t.s.tokens[c.Id] = &c
+	return c.Id, nil
+}
+
+func (t *Tokens) GetToken(ctx context.Context, hash string) (*models.Token, error) {
+	const operation = "internal.storage.memory.GetToken()"
+	if err := canceled(ctx); err != nil {
+		return nil, err
+	}
+	t.s.mu.RLock()
+	defer t.s.mu.RUnlock()
+	if token := t.byHash(hash); token != nil {
+		c := *token
+		return &c, nil
+	}
+	return nil, errorHelper.New(errorHelper.NotFound, operation, ErrorTokenNotFound)
+}
+
+func (t *Tokens) byHash(hash string) *models.Token {
+	for _, token := range t.s.tokens {
+		if token.Hash == hash {
+			return token
+		}
+	}
+	return nil
+}
+
+func (t *Tokens) UseToken(ctx context.Context, id string) (bool, error) {
+	if err := canceled(ctx); err != nil {
+		return false, err
+	}
+	t.s.mu.Lock()
+	defer t.s.mu.Unlock()
+	token, ok := t.s.tokens[id]
+	if !ok || token.Used {
+		return false, nil
+	}
+	token.Used = true
+	return true, nil
+}
+
+func (t *Tokens) RevokeFamily(ctx context.Context, family string) error {
+	if err := canceled(ctx); err != nil {
+		return err
+	}
+	t.s.mu.Lock()
+	defer t.s.mu.Unlock()
+	for _, token := range t.s.tokens {
+		if token.Family == family {
+			token.Revoked = true
+		}
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sso/internal/models"
+	"sso/pkg/helpers/errorHelper"
+	"strings"
+)
+
+type Users struct {
+	s *Storage
+}
+
+const (
+	ErrorUserNotFound = "User not found"
+	ErrorUserExists   = "User with the login already exists"
+)
+
+func cloneUser(u *models.User) *models.User {
+	c := *u
+	c.Roles = slices.Clone(u.Roles)
+	c.Groups = slices.Clone(u.Groups)
+	c.Mfa.RecoveryCodes = slices.Clone(u.Mfa.RecoveryCodes)
+	return &c
+}
+
+func (u *Users) GetUser(ctx context.Context, login string) (*models.User, error) {
+	const operation = "internal.storage.memory.GetUser()"
+	if err := canceled(ctx); err != nil {
+		return nil, err
+	}
+	u.s.mu.RLock()
+	defer u.s.mu.RUnlock()
+	if user := u.byLogin(login); user != nil {
+		return cloneUser(user), nil
+	}
+	return nil, errorHelper.New(errorHelper.NotFound, operation, ErrorUserNotFound)
+}
+
+func (u *Users) GetUserById(ctx context.Context, id string) (*models.User, error) {
+	const operation = "internal.storage.memory.GetUserById()"
+	if err := canceled(ctx); err != nil {
+		return nil, err
+	}
+	u.s.mu.RLock()
+	defer u.s.mu.RUnlock()
+	if user, ok := u.s.users[id]; ok {
+		return cloneUser(user), nil
+	}
+	return nil, errorHelper.New(errorHelper.NotFound, operation, ErrorUserNotFound)
+}
+
+func (u *Users) byLogin(login string) *models.User {
+	for _, user := range u.s.users {
+		if user.Login == login {
+			return user
+		}
+	}
+	return nil
+}
+
+func (u *Users) ListUsers(ctx context.Context, filter models.UserFilter) ([]*models.User, int64, error) {
+	if err := canceled(ctx); err != nil {
+		return nil, 0, err
+	}
+	u.s.mu.RLock()
+	defer u.s.mu.RUnlock()
+	search := strings.ToLower(filter.Search)
+	matched := make([]*models.User, 0)
+	for _, user := range u.s.users {
+		if search == "" || strings.Contains(strings.ToLower(user.Login), search) ||
+			strings.Contains(strings.ToLower(user.Name), search) || strings.Contains(strings.ToLower(user.Email), search) {
+			matched = append(matched, user)
+		}
+	}
+	slices.SortFunc(matched, func(a, b *models.User) int { return strings.Compare(a.Login, b.Login) })
+	total := int64(len(matched))
+	//zero limit is no limit like in Mongo
+	from, to := min(max(filter.Offset, 0), total), total
+	if filter.Limit > 0 {
+		to = min(from+filter.Limit, total)
+	}
+	users := make([]*models.User, 0, to-from)
+	for _, user := range matched[from:to] {
+		users = append(users, cloneUser(user))
+	}
+	return users, total, nil
+}
+
+func (u *Users) InsertUser(ctx context.Context, user *models.User) (string, error) {
+	const operation = "internal.storage.memory.InsertUser()"
+	if err := canceled(ctx); err != nil {
+		return "", err
+	}
+	u.s.mu.Lock()
+	defer u.s.mu.Unlock()
+	if u.byLogin(user.Login) != nil {
+		return "", errorHelper.New(errorHelper.Conflict, operation, ErrorUserExists)
+	}
+	c := cloneUser(user)
+	c.Id = newId()
+	u.s.users[c.Id] = c
+	return c.Id, nil
+}
+
+func (u *Users) UpdateUser(ctx context.Context, id string, update models.UserUpdate) error {
+	const operation = "internal.storage.memory.UpdateUser()"
+	if err := canceled(ctx); err != nil {
+		return err
+	}
+	u.s.mu.Lock()
+	defer u.s.mu.Unlock()
+	user, ok := u.s.users[id]
+	if !ok {
+		return errorHelper.New(errorHelper.NotFound, operation, ErrorUserNotFound)
+	}
+	if update.Login != nil {
+		if other := u.byLogin(*update.Login); other != nil && other.Id != id {
+			return errorHelper.New(errorHelper.Conflict, operation, ErrorUserExists)
+		}
+	}
+	c := cloneUser(user)
+	set(&c.Login, update.Login)
+	set(&c.Password, update.Password)
+	set(&c.Name, update.Name)
+	set(&c.Email, update.Email)
+	set(&c.EmailVerified, update.EmailVerified)
+	set(&c.Disabled, update.Disabled)
+	if update.Roles != nil {
+		c.Roles = slices.Clone(*update.Roles)
+	}
+	if update.Groups != nil {
+		c.Groups = slices.Clone(*update.Groups)
+	}
+	if update.Mfa != nil {
+		c.Mfa = *update.Mfa
+		c.Mfa.RecoveryCodes = slices.Clone(update.Mfa.RecoveryCodes)
+	}
+	u.s.users[id] = c
+	return nil
+}
+
+func (u *Users) UseMfaCode(ctx context.Context, id string, step int64, recoveryCode string) (bool, error) {
+	const operation = "internal.storage.memory.UseMfaCode()"
+	if err := canceled(ctx); err != nil {
+		return false, err
+	}
+	u.s.mu.Lock()
+	defer u.s.mu.Unlock()
+	user, ok := u.s.users[id]
+	if !ok {
+		return false, errorHelper.New(errorHelper.NotFound, operation, ErrorUserNotFound)
+	}
+	if recoveryCode != "" {
+		if !slices.Contains(user.Mfa.RecoveryCodes, recoveryCode) {
+			return false, nil
+		}
+		user.Mfa.RecoveryCodes = pull(user.Mfa.RecoveryCodes, recoveryCode)
+		return true, nil
+	}
+	if step <= user.Mfa.TotpStep {
+		return false, nil
+	}
+	user.Mfa.TotpStep = step
+	return true, nil
+}
+
+func (u *Users) DeleteUser(ctx context.Context, id string) error {
+	const operation = "internal.storage.memory.DeleteUser()"
+	if err := canceled(ctx); err != nil {
+		return err
+	}
+	u.s.mu.Lock()
+	defer u.s.mu.Unlock()
+	if _, ok := u.s.users[id]; !ok {
+		return errorHelper.New(errorHelper.NotFound, operation, ErrorUserNotFound)
+	}
+	delete(u.s.users, id)
+	return nil
+}
+
+func (u *Users) RemoveRole(ctx context.Context, role string) error {
+	if err := canceled(ctx); err != nil {
+		return err
+	}
+	u.s.mu.Lock()
+	defer u.s.mu.Unlock()
+	for _, user := range u.s.users {
+		user.Roles = pull(user.Roles, role)
+	}
+	return nil
+}
+
+func (u *Users) RemoveGroup(ctx context.Context, groupId string) error {
+	if err := canceled(ctx); err != nil {
+		return err
+	}
+	u.s.mu.Lock()
+	defer u.s.mu.Unlock()
+	for _, user := range u.s.users {
+		user.Groups = pull(user.Groups, groupId)
+	}
+	return nil
+}
+
+// pull removes all occurrences of value like $pull in Mongo, lists without the value are left as is
+// set assigns the value if it is not nil
+func set[T any](field *T, value *T) {
+	if value != nil {
+		*field = *value
+	}
+}
+
+func pull(list []string, value string) []string {
+	if !slices.Contains(list, value) {
+		return list
+	}
+	return slices.DeleteFunc(slices.Clone(list), func(v string) bool { return v == value })
+}
@@ -0,0 +1,98 @@
+package mongo
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"sso/internal/models"
+	"sso/internal/storage"
+	"sso/pkg/helpers/errorHelper"
+	"time"
+)
+
+type Attempts struct {
+	db      *mongo.Database
+	timeout time.Duration
+}
+
+const (
+	ErrorAttemptsNotFound = "Login attempts not found"
+	ErrorAttemptsDecode   = "Error on decode login attempts document"
+	ErrorUpdateAttempts   = "Error on update login attempts document"
+	ErrorDeleteAttempts   = "Error on delete login attempts document"
+)
+
+func (a *Attempts) GetAttempts(ctx context.Context, key string) (*models.Attempts, error) {
+	const operation = "internal.storage.mongo.GetAttempts()"
+	ctx, cancel := storage.WithTimeout(ctx, a.timeout)
+	defer cancel()
+	//TTL monitor removes expired documents with a delay, so expiration is checked here too
+	find := a.db.Collection("LoginAttempts").FindOne(ctx, bson.M{
+		"key":      key,
+		"expireAt": bson.M{"$gt": time.Now()},
+	})
+	if err := find.Err(); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorAttemptsNotFound, mapError(ctx, err))
+	}
+	attempts := models.Attempts{}
+	if err := find.Decode(&attempts); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorAttemptsDecode, mapError(ctx, err))
+	}
+	return &attempts, nil
+}
+
+func (a *Attempts) AddFailure(ctx context.Context, key string, now time.Time, expireAt time.Time) (*models.Attempts, error) {
+	const operation = "internal.storage.mongo.AddFailure()"
+	ctx, cancel := storage.WithTimeout(ctx, a.timeout)
+	defer cancel()
+	//update pipeline restarts expired counter in the same atomic operation
+	expired := bson.D{{Key: "$lte", Value: bson.A{bson.D{{Key: "$ifNull", Value: bson.A{"$expireAt", now}}}, now}}}
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.D{
+		{Key: "key", Value: key},
+		{Key: "failures", Value: bson.D{{Key: "$cond", Value: bson.A{
+			expired,
+			1,
+			bson.D{{Key: "$add", Value: bson.A{"$failures", 1}}},
+		}}}},
+		{Key: "lockedUntil", Value: bson.D{{Key: "$cond", Value: bson.A{
+			expired,
+			time.Time{},
+			"$lockedUntil",
+		}}}},
+		{Key: "lastFailure", Value: now},
+		{Key: "expireAt", Value: bson.D{{Key: "$max", Value: bson.A{"$expireAt", expireAt}}}},
+	}}}}
+	res := a.db.Collection("LoginAttempts").FindOneAndUpdate(ctx, bson.M{"key": key}, update,
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After))
+	if err := res.Err(); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorUpdateAttempts, mapError(ctx, err))
+	}
+	attempts := models.Attempts{}
+	if err := res.Decode(&attempts); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorAttemptsDecode, mapError(ctx, err))
+	}
+	return &attempts, nil
+}
+
+func (a *Attempts) LockAttempts(ctx context.Context, key string, until time.Time, expireAt time.Time) error {
+	const operation = "internal.storage.mongo.LockAttempts()"
+	ctx, cancel := storage.WithTimeout(ctx, a.timeout)
+	defer cancel()
+	_, err := a.db.Collection("LoginAttempts").UpdateOne(ctx, bson.M{"key": key},
+		bson.M{"$max": bson.D{{Key: "lockedUntil", Value: until}, {Key: "expireAt", Value: expireAt}}})
+	if err != nil {
+		return errorHelper.WrapError(operation, ErrorUpdateAttempts, mapError(ctx, err))
+	}
+	return nil
+}
+
+func (a *Attempts) ResetAttempts(ctx context.Context, key string) error {
+	const operation = "internal.storage.mongo.ResetAttempts()"
+	ctx, cancel := storage.WithTimeout(ctx, a.timeout)
+	defer cancel()
+	if _, err := a.db.Collection("LoginAttempts").DeleteOne(ctx, bson.M{"key": key}); err != nil {
+		return errorHelper.WrapError(operation, ErrorDeleteAttempts, mapError(ctx, err))
+	}
+	return nil
+}
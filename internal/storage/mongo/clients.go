@@ -0,0 +1,55 @@
+package mongo
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"sso/internal/models"
+	"sso/internal/storage"
+	"sso/pkg/helpers/errorHelper"
+	"time"
+)
+
+type Clients struct {
+	db      *mongo.Database
+	timeout time.Duration
+}
+
+const (
+	ErrorClientNotFound = "Client not found"
+	ErrorClientDecode   = "Error on decode client document"
+	ErrorInsertClient   = "Error on insert client document"
+)
+
+func (c *Clients) GetClient(ctx context.Context, clientId string) (*models.Client, error) {
+	const operation = "internal.storage.mongo.GetClient()"
+	ctx, cancel := storage.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	find := c.db.Collection("Clients").FindOne(ctx, bson.M{"clientId": clientId})
+	if err := find.Err(); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorClientNotFound, mapError(ctx, err))
+	}
+	client := models.Client{}
+	if err := find.Decode(&client); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorClientDecode, mapError(ctx, err))
+	}
+	return &client, nil
+}
+
+func (c *Clients) InsertClient(ctx context.Context, client *models.Client) (string, error) {
+	const operation = "internal.storage.mongo.InsertClient()"
+	ctx, cancel := storage.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	res, err := c.db.Collection("Clients").InsertOne(ctx, bson.D{
+		{Key: "clientId", Value: client.ClientId},
+		{Key: "secret", Value: client.Secret},
+		{Key: "name", Value: client.Name},
+		{Key: "redirectUris", Value: client.RedirectUris},
+		{Key: "scopes", Value: client.Scopes},
+	})
+	if err != nil {
+		return "", errorHelper.WrapError(operation, ErrorInsertClient, mapError(ctx, err))
+	}
+	return res.InsertedID.(primitive.ObjectID).Hex(), nil
+}
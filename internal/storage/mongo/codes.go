@@ -0,0 +1,83 @@
+package mongo
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"sso/internal/models"
+	"sso/internal/storage"
+	"sso/pkg/helpers/errorHelper"
+	"time"
+)
+
+type Codes struct {
+	db      *mongo.Database
+	timeout time.Duration
+}
+
+const (
+	ErrorCodeNotFound = "Authorization code not found"
+	ErrorCodeDecode   = "Error on decode authorization code document"
+	ErrorInsertCode   = "Error on insert authorization code document"
+	ErrorUpdateCode   = "Error on update authorization code document"
+	ErrorBadCodeId    = "Bad authorization code id"
+)
+
+func (c *Codes) InsertCode(ctx context.Context, code *models.AuthCode) (string, error) {
+	const operation = "internal.storage.mongo.InsertCode()"
+	ctx, cancel := storage.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	res, err := c.db.Collection("AuthCodes").InsertOne(ctx, bson.D{
+		{Key: "hash", Value: code.Hash},
+		{Key: "clientId", Value: code.ClientId},
+		{Key: "user", Value: code.User},
+		{Key: "redirectUri", Value: code.RedirectUri},
+		{Key: "scope", Value: code.Scope},
+		{Key: "codeChallenge", Value: code.CodeChallenge},
+		{Key: "family", Value: code.Family},
+		{Key: "nonce", Value: code.Nonce},
+		{Key: "authTime", Value: code.AuthTime},
+		{Key: "acr", Value: code.Acr},
+		{Key: "createAt", Value: code.CreateAt},
+		{Key: "validUntil", Value: code.ValidUntil},
+		{Key: "used", Value: code.Used},
+	})
+	if err != nil {
+		return "", errorHelper.WrapError(operation, ErrorInsertCode, mapError(ctx, err))
+	}
+	return res.InsertedID.(primitive.ObjectID).Hex(), nil
+}
+
+func (c *Codes) GetCode(ctx context.Context, hash string) (*models.AuthCode, error) {
+	const operation = "internal.storage.mongo.GetCode()"
+	ctx, cancel := storage.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	find := c.db.Collection("AuthCodes").FindOne(ctx, bson.M{"hash": hash})
+	if err := find.Err(); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorCodeNotFound, mapError(ctx, err))
+	}
+	code := models.AuthCode{}
+	if err := find.Decode(&code); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorCodeDecode, mapError(ctx, err))
+	}
+	return &code, nil
+}
+
+func (c *Codes) UseCode(ctx context.Context, id string) (bool, error) {
+	const operation = "internal.storage.mongo.UseCode()"
+	ctx, cancel := storage.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, errorHelper.WrapError(operation, ErrorBadCodeId, mapError(ctx, err))
+	}
+	res, err := c.db.Collection("AuthCodes").UpdateOne(ctx,
+		bson.M{"_id": oid, "used": false},
+		bson.M{"$set": bson.M{"used": true, "usedAt": time.Now()}},
+	)
+	if err != nil {
+		return false, errorHelper.WrapError(operation, ErrorUpdateCode, mapError(ctx, err))
+	}
+	return res.ModifiedCount == 1, nil
+}
@@ -0,0 +1,124 @@
+package mongo
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"sso/internal/models"
+	"sso/internal/storage"
+	"sso/pkg/helpers/errorHelper"
+	"time"
+)
+
+type Credentials struct {
+	db      *mongo.Database
+	timeout time.Duration
+}
+
+const (
+	ErrorCredentialNotFound = "Credential not found"
+	ErrorCredentialDecode   = "Error on decode credential document"
+	ErrorFindCredentials    = "Error on find credentials"
+	ErrorInsertCredential   = "Error on insert credential document"
+	ErrorUpdateCredential   = "Error on update credential document"
+	ErrorDeleteCredential   = "Error on delete credential document"
+	ErrorBadCredentialId    = "Bad credential id"
+)
+
+func (c *Credentials) InsertCredential(ctx context.Context, credential *models.Credential) (string, error) {
+	const operation = "internal.storage.mongo.InsertCredential()"
+	ctx, cancel := storage.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	res, err := c.db.Collection("Credentials").InsertOne(ctx, bson.D{
+		{Key: "userId", Value: credential.UserId},
+		{Key: "credentialId", Value: credential.CredentialId},
+		{Key: "publicKey", Value: credential.PublicKey},
+		{Key: "signCount", Value: credential.SignCount},
+		{Key: "aaguid", Value: credential.Aaguid},
+		{Key: "name", Value: credential.Name},
+		{Key: "createdAt", Value: credential.CreatedAt},
+		{Key: "lastUsedAt", Value: credential.LastUsedAt},
+	})
+	if err != nil {
+		return "", errorHelper.WrapError(operation, ErrorInsertCredential, mapError(ctx, err))
+	}
+	return res.InsertedID.(primitive.ObjectID).Hex(), nil
+}
+
+func (c *Credentials) GetCredential(ctx context.Context, credentialId string) (*models.Credential, error) {
+	const operation = "internal.storage.mongo.GetCredential()"
+	ctx, cancel := storage.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	find := c.db.Collection("Credentials").FindOne(ctx, bson.M{"credentialId": credentialId})
+	if err := find.Err(); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorCredentialNotFound, mapError(ctx, err))
+	}
+	credential := models.Credential{}
+	if err := find.Decode(&credential); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorCredentialDecode, mapError(ctx, err))
+	}
+	return &credential, nil
+}
+
+func (c *Credentials) GetUserCredentials(ctx context.Context, userId string) ([]*models.Credential, error) {
+	const operation = "internal.storage.mongo.GetUserCredentials()"
+	ctx, cancel := storage.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	cursor, err := c.db.Collection("Credentials").Find(ctx, bson.M{"userId": userId},
+		options.Find().SetSort(bson.M{"createdAt": 1}))
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorFindCredentials, mapError(ctx, err))
+	}
+	credentials := make([]*models.Credential, 0)
+	if err := cursor.All(ctx, &credentials); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorCredentialDecode, mapError(ctx, err))
+	}
+	return credentials, nil
+}
+
+func (c *Credentials) UseCredential(ctx context.Context, id string, signCount int64, newSignCount int64, usedAt time.Time) (bool, error) {
+	const operation = "internal.storage.mongo.UseCredential()"
+	ctx, cancel := storage.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, errorHelper.New(errorHelper.NotFound, operation, ErrorBadCredentialId)
+	}
+	res, err := c.db.Collection("Credentials").UpdateOne(ctx,
+		bson.M{"_id": oid, "signCount": signCount},
+		bson.M{"$set": bson.D{{Key: "signCount", Value: newSignCount}, {Key: "lastUsedAt", Value: usedAt}}})
+	if err != nil {
+		return false, errorHelper.WrapError(operation, ErrorUpdateCredential, mapError(ctx, err))
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+func (c *Credentials) DeleteCredential(ctx context.Context, userId string, id string) error {
+	const operation = "internal.storage.mongo.DeleteCredential()"
+	ctx, cancel := storage.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errorHelper.New(errorHelper.NotFound, operation, ErrorBadCredentialId)
+	}
+	res, err := c.db.Collection("Credentials").DeleteOne(ctx, bson.M{"_id": oid, "userId": userId})
+	if err != nil {
+		return errorHelper.WrapError(operation, ErrorDeleteCredential, mapError(ctx, err))
+	}
+	if res.DeletedCount == 0 {
+		return errorHelper.New(errorHelper.NotFound, operation, ErrorCredentialNotFound)
+	}
+	return nil
+}
+
+func (c *Credentials) DeleteUserCredentials(ctx context.Context, userId string) error {
+	const operation = "internal.storage.mongo.DeleteUserCredentials()"
+	ctx, cancel := storage.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	if _, err := c.db.Collection("Credentials").DeleteMany(ctx, bson.M{"userId": userId}); err != nil {
+		return errorHelper.WrapError(operation, ErrorDeleteCredential, mapError(ctx, err))
+	}
+	return nil
+}
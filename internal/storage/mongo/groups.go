@@ -0,0 +1,150 @@
+package mongo
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"sso/internal/models"
+	"sso/internal/storage"
+	"sso/pkg/helpers/errorHelper"
+	"time"
+)
+
+type Groups struct {
+	db      *mongo.Database
+	timeout time.Duration
+}
+
+const (
+	ErrorGroupNotFound = "Group not found"
+	ErrorGroupDecode   = "Error on decode group document"
+	ErrorFindGroups    = "Error on find groups"
+	ErrorInsertGroup   = "Error on insert group document"
+	ErrorUpdateGroup   = "Error on update group document"
+	ErrorDeleteGroup   = "Error on delete group document"
+	ErrorBadGroupId    = "Bad group id"
+)
+
+func (g *Groups) GetGroup(ctx context.Context, id string) (*models.Group, error) {
+	const operation = "internal.storage.mongo.GetGroup()"
+	ctx, cancel := storage.WithTimeout(ctx, g.timeout)
+	defer cancel()
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errorHelper.New(errorHelper.NotFound, operation, ErrorBadGroupId)
+	}
+	find := g.db.Collection("Groups").FindOne(ctx, bson.M{"_id": oid})
+	if err := find.Err(); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorGroupNotFound, mapError(ctx, err))
+	}
+	group := models.Group{}
+	if err := find.Decode(&group); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorGroupDecode, mapError(ctx, err))
+	}
+	return &group, nil
+}
+
+func (g *Groups) GetGroups(ctx context.Context, ids []string) ([]*models.Group, error) {
+	const operation = "internal.storage.mongo.GetGroups()"
+	ctx, cancel := storage.WithTimeout(ctx, g.timeout)
+	defer cancel()
+	oids := make([]primitive.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		//bad ids can't match any group, so they are skipped
+		if oid, err := primitive.ObjectIDFromHex(id); err == nil {
+			oids = append(oids, oid)
+		}
+	}
+	if len(oids) == 0 {
+		return []*models.Group{}, nil
+	}
+	return g.find(ctx, operation, bson.M{"_id": bson.M{"$in": oids}})
+}
+
+func (g *Groups) ListGroups(ctx context.Context) ([]*models.Group, error) {
+	const operation = "internal.storage.mongo.ListGroups()"
+	ctx, cancel := storage.WithTimeout(ctx, g.timeout)
+	defer cancel()
+	return g.find(ctx, operation, bson.M{})
+}
+
+func (g *Groups) find(ctx context.Context, operation string, filter bson.M) ([]*models.Group, error) {
+	cursor, err := g.db.Collection("Groups").Find(ctx, filter, options.Find().SetSort(bson.M{"name": 1}))
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorFindGroups, mapError(ctx, err))
+	}
+	groups := make([]*models.Group, 0)
+	if err := cursor.All(ctx, &groups); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorGroupDecode, mapError(ctx, err))
+	}
+	return groups, nil
+}
+
+func (g *Groups) InsertGroup(ctx context.Context, group *models.Group) (string, error) {
+	const operation = "internal.storage.mongo.InsertGroup()"
+	ctx, cancel := storage.WithTimeout(ctx, g.timeout)
+	defer cancel()
+	res, err := g.db.Collection("Groups").InsertOne(ctx, bson.D{
+		{Key: "name", Value: group.Name},
+		{Key: "description", Value: group.Description},
+		{Key: "parents", Value: group.Parents},
+	})
+	if err != nil {
+		return "", errorHelper.WrapError(operation, ErrorInsertGroup, mapError(ctx, err))
+	}
+	return res.InsertedID.(primitive.ObjectID).Hex(), nil
+}
+
+func (g *Groups) UpdateGroup(ctx context.Context, group *models.Group) error {
+	const operation = "internal.storage.mongo.UpdateGroup()"
+	ctx, cancel := storage.WithTimeout(ctx, g.timeout)
+	defer cancel()
+	oid, err := primitive.ObjectIDFromHex(group.Id)
+	if err != nil {
+		return errorHelper.New(errorHelper.NotFound, operation, ErrorBadGroupId)
+	}
+	res, err := g.db.Collection("Groups").UpdateOne(ctx, bson.M{"_id": oid}, bson.M{"$set": bson.D{
+		{Key: "name", Value: group.Name},
+		{Key: "description", Value: group.Description},
+		{Key: "parents", Value: group.Parents},
+	}})
+	if err != nil {
+		return errorHelper.WrapError(operation, ErrorUpdateGroup, mapError(ctx, err))
+	}
+	if res.MatchedCount == 0 {
+		return errorHelper.New(errorHelper.NotFound, operation, ErrorGroupNotFound)
+	}
+	return nil
+}
+
+func (g *Groups) DeleteGroup(ctx context.Context, id string) error {
+	const operation = "internal.storage.mongo.DeleteGroup()"
+	ctx, cancel := storage.WithTimeout(ctx, g.timeout)
+	defer cancel()
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errorHelper.New(errorHelper.NotFound, operation, ErrorBadGroupId)
+	}
+	res, err := g.db.Collection("Groups").DeleteOne(ctx, bson.M{"_id": oid})
+	if err != nil {
+		return errorHelper.WrapError(operation, ErrorDeleteGroup, mapError(ctx, err))
+	}
+	if res.DeletedCount == 0 {
+		return errorHelper.New(errorHelper.NotFound, operation, ErrorGroupNotFound)
+	}
+	return nil
+}
+
+func (g *Groups) RemoveParent(ctx context.Context, parentId string) error {
+	const operation = "internal.storage.mongo.RemoveParent()"
+	ctx, cancel := storage.WithTimeout(ctx, g.timeout)
+	defer cancel()
+	_, err := g.db.Collection("Groups").UpdateMany(ctx, bson.M{"parents": parentId},
+		bson.M{"$pull": bson.M{"parents": parentId}})
+	if err != nil {
+		return errorHelper.WrapError(operation, ErrorUpdateGroup, mapError(ctx, err))
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package mongo
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"sso/internal/models"
+	"sso/internal/storage"
+	"sso/pkg/helpers/errorHelper"
+	"time"
+)
+
+type Permissions struct {
+	db      *mongo.Database
+	timeout time.Duration
+}
+
+const (
+	ErrorPermissionNotFound = "Permission not found"
+	ErrorPermissionDecode   = "Error on decode permission document"
+	ErrorFindPermissions    = "Error on find permissions"
+	ErrorInsertPermission   = "Error on insert permission document"
+	ErrorDeletePermission   = "Error on delete permission document"
+)
+
+func (p *Permissions) GetPermissions(ctx context.Context, names []string) ([]*models.Permission, error) {
+	const operation = "internal.storage.mongo.GetPermissions()"
+	ctx, cancel := storage.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	if len(names) == 0 {
+		return []*models.Permission{}, nil
+	}
+	return p.find(ctx, operation, bson.M{"name": bson.M{"$in": names}})
+}
+
+func (p *Permissions) ListPermissions(ctx context.Context) ([]*models.Permission, error) {
+	const operation = "internal.storage.mongo.ListPermissions()"
+	ctx, cancel := storage.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	return p.find(ctx, operation, bson.M{})
+}
+
+func (p *Permissions) find(ctx context.Context, operation string, filter bson.M) ([]*models.Permission, error) {
+	cursor, err := p.db.Collection("Permissions").Find(ctx, filter, options.Find().SetSort(bson.M{"name": 1}))
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorFindPermissions, mapError(ctx, err))
+	}
+	permissions := make([]*models.Permission, 0)
+	if err := cursor.All(ctx, &permissions); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorPermissionDecode, mapError(ctx, err))
+	}
+	return permissions, nil
+}
+
+func (p *Permissions) InsertPermission(ctx context.Context, permission *models.Permission) (string, error) {
+	const operation = "internal.storage.mongo.InsertPermission()"
+	ctx, cancel := storage.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	res, err := p.db.Collection("Permissions").InsertOne(ctx, bson.D{
+		{Key: "name", Value: permission.Name},
+		{Key: "description", Value: permission.Description},
+	})
+	if err != nil {
+		return "", errorHelper.WrapError(operation, ErrorInsertPermission, mapError(ctx, err))
+	}
+	return res.InsertedID.(primitive.ObjectID).Hex(), nil
+}
+
+func (p *Permissions) DeletePermission(ctx context.Context, name string) error {
+	const operation = "internal.storage.mongo.DeletePermission()"
+	ctx, cancel := storage.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	res, err := p.db.Collection("Permissions").DeleteOne(ctx, bson.M{"name": name})
+	if err != nil {
+		return errorHelper.WrapError(operation, ErrorDeletePermission, mapError(ctx, err))
+	}
+	if res.DeletedCount == 0 {
+		return errorHelper.New(errorHelper.NotFound, operation, ErrorPermissionNotFound)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package mongo
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"sso/internal/models"
+	"sso/internal/storage"
+	"sso/pkg/helpers/errorHelper"
+	"time"
+)
+
+type Revocations struct {
+	db      *mongo.Database
+	timeout time.Duration
+}
+
+const (
+	ErrorInsertRevocation = "Error on insert revocation document"
+	ErrorFindRevocations  = "Error on find revocations"
+	ErrorRevocationDecode = "Error on decode revocation document"
+)
+
+func (r *Revocations) Revoke(ctx context.Context, jti string, exp time.Time) error {
+	const operation = "internal.storage.mongo.Revoke()"
+	ctx, cancel := storage.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	//upsert keeps revocation idempotent
+	_, err := r.db.Collection("Revocations").UpdateOne(ctx,
+		bson.M{"jti": jti},
+		bson.M{"$setOnInsert": bson.M{"jti": jti, "revokedAt": time.Now(), "exp": exp}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return errorHelper.WrapError(operation, ErrorInsertRevocation, mapError(ctx, err))
+	}
+	return nil
+}
+
+func (r *Revocations) GetRevoked(ctx context.Context, since time.Time) ([]*models.Revocation, error) {
+	const operation = "internal.storage.mongo.GetRevoked()"
+	ctx, cancel := storage.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	cursor, err := r.db.Collection("Revocations").Find(ctx, bson.M{
+		"revokedAt": bson.M{"$gte": since},
+		"exp":       bson.M{"$gt": time.Now()},
+	})
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorFindRevocations, mapError(ctx, err))
+	}
+	var list []*models.Revocation
+	if err := cursor.All(ctx, &list); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorRevocationDecode, mapError(ctx, err))
+	}
+	return list, nil
+}
@@ -0,0 +1,129 @@
+package mongo
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"sso/internal/models"
+	"sso/internal/storage"
+	"sso/pkg/helpers/errorHelper"
+	"time"
+)
+
+type Roles struct {
+	db      *mongo.Database
+	timeout time.Duration
+}
+
+const (
+	ErrorRoleNotFound = "Role not found"
+	ErrorRoleDecode   = "Error on decode role document"
+	ErrorFindRoles    = "Error on find roles"
+	ErrorInsertRole   = "Error on insert role document"
+	ErrorUpdateRole   = "Error on update role document"
+	ErrorDeleteRole   = "Error on delete role document"
+)
+
+func (r *Roles) GetRole(ctx context.Context, name string) (*models.Role, error) {
+	const operation = "internal.storage.mongo.GetRole()"
+	ctx, cancel := storage.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	find := r.db.Collection("Roles").FindOne(ctx, bson.M{"name": name})
+	if err := find.Err(); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorRoleNotFound, mapError(ctx, err))
+	}
+	role := models.Role{}
+	if err := find.Decode(&role); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorRoleDecode, mapError(ctx, err))
+	}
+	return &role, nil
+}
+
+func (r *Roles) GetRoles(ctx context.Context, names []string) ([]*models.Role, error) {
+	const operation = "internal.storage.mongo.GetRoles()"
+	ctx, cancel := storage.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	if len(names) == 0 {
+		return []*models.Role{}, nil
+	}
+	return r.find(ctx, operation, bson.M{"name": bson.M{"$in": names}})
+}
+
+func (r *Roles) ListRoles(ctx context.Context) ([]*models.Role, error) {
+	const operation = "internal.storage.mongo.ListRoles()"
+	ctx, cancel := storage.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	return r.find(ctx, operation, bson.M{})
+}
+
+func (r *Roles) find(ctx context.Context, operation string, filter bson.M) ([]*models.Role, error) {
+	cursor, err := r.db.Collection("Roles").Find(ctx, filter, options.Find().SetSort(bson.M{"name": 1}))
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorFindRoles, mapError(ctx, err))
+	}
+	roles := make([]*models.Role, 0)
+	if err := cursor.All(ctx, &roles); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorRoleDecode, mapError(ctx, err))
+	}
+	return roles, nil
+}
+
+func (r *Roles) InsertRole(ctx context.Context, role *models.Role) (string, error) {
+	const operation = "internal.storage.mongo.InsertRole()"
+	ctx, cancel := storage.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	res, err := r.db.Collection("Roles").InsertOne(ctx, bson.D{
+		{Key: "name", Value: role.Name},
+		{Key: "description", Value: role.Description},
+		{Key: "permissions", Value: role.Permissions},
+	})
+	if err != nil {
+		return "", errorHelper.WrapError(operation, ErrorInsertRole, mapError(ctx, err))
+	}
+	return res.InsertedID.(primitive.ObjectID).Hex(), nil
+}
+
+func (r *Roles) UpdateRole(ctx context.Context, role *models.Role) error {
+	const operation = "internal.storage.mongo.UpdateRole()"
+	ctx, cancel := storage.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	res, err := r.db.Collection("Roles").UpdateOne(ctx, bson.M{"name": role.Name}, bson.M{"$set": bson.D{
+		{Key: "description", Value: role.Description},
+		{Key: "permissions", Value: role.Permissions},
+	}})
+	if err != nil {
+		return errorHelper.WrapError(operation, ErrorUpdateRole, mapError(ctx, err))
+	}
+	if res.MatchedCount == 0 {
+		return errorHelper.New(errorHelper.NotFound, operation, ErrorRoleNotFound)
+	}
+	return nil
+}
+
+func (r *Roles) DeleteRole(ctx context.Context, name string) error {
+	const operation = "internal.storage.mongo.DeleteRole()"
+	ctx, cancel := storage.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	res, err := r.db.Collection("Roles").DeleteOne(ctx, bson.M{"name": name})
+	if err != nil {
+		return errorHelper.WrapError(operation, ErrorDeleteRole, mapError(ctx, err))
+	}
+	if res.DeletedCount == 0 {
+		return errorHelper.New(errorHelper.NotFound, operation, ErrorRoleNotFound)
+	}
+	return nil
+}
+
+func (r *Roles) RemovePermission(ctx context.Context, permission string) error {
+	const operation = "internal.storage.mongo.RemovePermission()"
+	ctx, cancel := storage.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	_, err := r.db.Collection("Roles").UpdateMany(ctx, bson.M{"permissions": permission},
+		bson.M{"$pull": bson.M{"permissions": permission}})
+	if err != nil {
+		return errorHelper.WrapError(operation, ErrorUpdateRole, mapError(ctx, err))
+	}
+	return nil
+}
@@ -7,11 +7,11 @@ import (
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/mongodb"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 	"log/slog"
-	"sso/internal/models"
-	"sso/internal/services"
 	"sso/internal/storage"
 	"sso/pkg/helpers/errorHelper"
 	"sso/pkg/helpers/slogHelper"
@@ -22,23 +22,31 @@ const (
 	ErrorCreateClient            = "Error on create mongoDB client"
 	ErrorCreateMigrationInstance = "Error on create migration instance"
 	ErrorCloseConnections        = "Error on close client connections"
-	ErrorCreateRoot              = "Error on insert root user into DB"
-	ErrorInternal                = "Internal mongo server error"
+	ErrorPing                    = "Error on ping MongoDB"
+	ErrorMigrate                 = "Error on migrate MongoDB database"
 )
 
 type Storage struct {
-	client *mongo.Client
-	db     *mongo.Database
+	client  *mongo.Client
+	db      *mongo.Database
+	timeout time.Duration
+	//migrations error on start, Mongo creates collections on demand, so the service starts anyway
+	migrated error
 }
 
 type Config struct {
-	Server       string
-	User         string
-	Password     string
-	Database     string
-	RootPassword string
+	Server     string
+	User       string //no authentication if empty
+	Password   string
+	Database   string
+	Migrations string                //migrations source URL, DefaultMigrations if empty
+	Timeout    time.Duration         //deadline of a single operation, no deadline if zero
+	Monitor    *event.CommandMonitor //observes commands, for example to collect metrics and traces, may be nil
 }
 
+// DefaultMigrations are the migrations copied next to the binary
+const DefaultMigrations = "file://migrations/mongo"
+
 func New(logger *slog.Logger, config Config) (*Storage, error) {
 	const operation = "internal.storage.mongo.new()"
 	log := slogHelper.AddOperation(logger, operation)
@@ -48,10 +56,15 @@ func New(logger *slog.Logger, config Config) (*Storage, error) {
 	//
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
 	defer cancel()
+	uri := "mongodb://" + config.Server
+	if config.User != "" {
+		uri = fmt.Sprintf("mongodb://%s:%s@%s", config.User, config.Password, config.Server)
+	}
 	client, err := mongo.Connect(ctx, options.Client().
-		ApplyURI(fmt.Sprintf("mongodb://%s:%s@%s", config.User, config.Password, config.Server)).
+		ApplyURI(uri).
 		SetMaxConnecting(50). //максимально количество одновременных соединений
-		SetMaxPoolSize(100),  //размер пула соединений
+		SetMaxPoolSize(100).  //размер пула соединений
+		SetMonitor(config.Monitor),
 	)
 	if err != nil {
 		return nil, errorHelper.WrapError(operation, ErrorCreateClient, err)
@@ -59,58 +72,85 @@ func New(logger *slog.Logger, config Config) (*Storage, error) {
 	//
 	//migrations
 	//
+	//indexes are created in the database the data is stored in, unique indexes are relied on by storage
 	driver, _ := mongodb.WithInstance(client, &mongodb.Config{
-		DatabaseName: "sso",
+		DatabaseName: config.Database,
 	})
-	m, err := migrate.NewWithDatabaseInstance("file://migrations/mongo", "mongo", driver)
+	source := config.Migrations
+	if source == "" {
+		source = DefaultMigrations
+	}
+	m, err := migrate.NewWithDatabaseInstance(source, "mongo", driver)
 	if err != nil {
 		return nil, errorHelper.WrapError(operation, ErrorCreateMigrationInstance, err)
 	}
-	err = m.Up()
-	if err != nil {
+	var migrated error
+	if err = m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
 		log.Warn("Migration", slogHelper.GetErrAttr(err))
+		migrated = errorHelper.WrapError(operation, ErrorMigrate, err)
 	}
-	s := &Storage{
-		client: client,
-		db:     client.Database(config.Database),
-	}
-	//
-	//add root user
-	//
-	_, err = s.Users().GetUser("root")
-	if err != nil {
-		if errors.Is(err, mongo.ErrNoDocuments) {
-			root := &models.User{
-				Login:    "root",
-				Password: config.RootPassword,
+	return &Storage{
+		client:   client,
+		db:       client.Database(config.Database),
+		timeout:  config.Timeout,
+		migrated: migrated,
+	}, nil
+}
+
+// Monitors combines command monitors into one, every monitor gets all events it handles
+func Monitors(monitors ...*event.CommandMonitor) *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			for _, m := range monitors {
+				if m.Started != nil {
+					m.Started(ctx, e)
+				}
 			}
-			insertId, err := services.Users(s).Add(root)
-			if err != nil {
-				log.Error(ErrorCreateRoot, slogHelper.GetErrAttr(err))
-			} else {
-				logger.Info("------------------------------------------------------------------------------------------")
-				logger.Info("Successfully created user",
-					slog.String("uid", insertId),
-					slog.String("login", "root"),
-					slog.String("password", config.RootPassword),
-				)
-				logger.Info("------------------------------------------------------------------------------------------")
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			for _, m := range monitors {
+				if m.Succeeded != nil {
+					m.Succeeded(ctx, e)
+				}
 			}
-		} else {
-			logger.Error(ErrorInternal, slogHelper.GetErrAttr(err))
-		}
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			for _, m := range monitors {
+				if m.Failed != nil {
+					m.Failed(ctx, e)
+				}
+			}
+		},
 	}
-	return s, nil
 }
 
-func (s *Storage) Ping() bool {
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+// mapError classifies driver errors with errorHelper kinds, other driver errors are Internal
+func mapError(ctx context.Context, err error) error {
+	if e := storage.Canceled(ctx, err); e != nil {
+		return e
+	}
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return errorHelper.NotFound
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		return errorHelper.Conflict
+	}
+	return err
+}
+
+func (s *Storage) Ping(ctx context.Context) error {
+	const operation = "internal.storage.mongo.Ping()"
+	ctx, cancel := storage.WithTimeout(ctx, s.timeout)
 	defer cancel()
-	err := s.client.Ping(ctx, nil)
-	if err != nil {
-		return false
+	if err := s.client.Ping(ctx, readpref.Primary()); err != nil {
+		return errorHelper.WrapError(operation, ErrorPing, mapError(ctx, err))
 	}
-	return true
+	return nil
+}
+
+// Migrated returns the error of migrations applied on start
+func (s *Storage) Migrated(ctx context.Context) error {
+	return s.migrated
 }
 
 func (s *Storage) Shutdown(ctx context.Context) error {
@@ -124,6 +164,77 @@ func (s *Storage) Shutdown(ctx context.Context) error {
 
 func (s *Storage) Users() storage.Users {
 	return &Users{
-		db: s.db,
+		db:      s.db,
+		timeout: s.timeout,
+	}
+}
+
+func (s *Storage) Keys() storage.Keys {
+	return &Keys{
+		db:      s.db,
+		timeout: s.timeout,
+	}
+}
+
+func (s *Storage) Tokens() storage.Tokens {
+	return &Tokens{
+		db:      s.db,
+		timeout: s.timeout,
+	}
+}
+
+func (s *Storage) Revocations() storage.Revocations {
+	return &Revocations{
+		db:      s.db,
+		timeout: s.timeout,
+	}
+}
+
+func (s *Storage) Clients() storage.Clients {
+	return &Clients{
+		db:      s.db,
+		timeout: s.timeout,
+	}
+}
+
+func (s *Storage) Codes() storage.Codes {
+	return &Codes{
+		db:      s.db,
+		timeout: s.timeout,
+	}
+}
+
+func (s *Storage) Roles() storage.Roles {
+	return &Roles{
+		db:      s.db,
+		timeout: s.timeout,
+	}
+}
+
+func (s *Storage) Permissions() storage.Permissions {
+	return &Permissions{
+		db:      s.db,
+		timeout: s.timeout,
+	}
+}
+
+func (s *Storage) Groups() storage.Groups {
+	return &Groups{
+		db:      s.db,
+		timeout: s.timeout,
+	}
+}
+
+func (s *Storage) Credentials() storage.Credentials {
+	return &Credentials{
+		db:      s.db,
+		timeout: s.timeout,
+	}
+}
+
+func (s *Storage) Attempts() storage.Attempts {
+	return &Attempts{
+		db:      s.db,
+		timeout: s.timeout,
 	}
 }
@@ -0,0 +1,68 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"sso/internal/storage"
+	"sso/internal/storage/storagetest"
+	"testing"
+	"time"
+)
+
+// testServer returns address of MongoDB for tests: SSO_TEST_MONGO if set,
+// otherwise a throwaway mongod started from PATH. The test is skipped if there is neither.
+func testServer(t *testing.T) string {
+	if server := os.Getenv("SSO_TEST_MONGO"); server != "" {
+		return server
+	}
+	mongod, err := exec.LookPath("mongod")
+	if err != nil {
+		t.Skip("neither SSO_TEST_MONGO is set nor mongod is found")
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := l.Addr().(*net.TCPAddr).Port
+	require.NoError(t, l.Close())
+
+	cmd := exec.Command(mongod, "--dbpath", t.TempDir(), "--bind_ip", "127.0.0.1", "--port", fmt.Sprint(port), "--quiet")
+	require.NoError(t, cmd.Start())
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+	server := fmt.Sprintf("127.0.0.1:%d", port)
+	for start := time.Now(); time.Since(start) < 30*time.Second; time.Sleep(100 * time.Millisecond) {
+		if conn, err := net.Dial("tcp", server); err == nil {
+			_ = conn.Close()
+			return server
+		}
+	}
+	t.Fatal("mongod has not started")
+	return ""
+}
+
+func TestConformance(t *testing.T) {
+	server := testServer(t)
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	n := 0
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		n++
+		s, err := New(log, Config{
+			Server:     server,
+			Database:   fmt.Sprintf("sso_test_%d_%d", os.Getpid(), n),
+			Migrations: "file://../../../build/migrations/mongo",
+		})
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = s.db.Drop(context.Background())
+			_ = s.Shutdown(context.Background())
+		})
+		return s
+	})
+}
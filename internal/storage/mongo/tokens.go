@@ -0,0 +1,96 @@
+package mongo
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"sso/internal/models"
+	"sso/internal/storage"
+	"sso/pkg/helpers/errorHelper"
+	"time"
+)
+
+type Tokens struct {
+	db      *mongo.Database
+	timeout time.Duration
+}
+
+const (
+	ErrorTokenNotFound = "Token not found"
+	ErrorTokenDecode   = "Error on decode token document"
+	ErrorInsertToken   = "Error on insert token document"
+	ErrorUpdateToken   = "Error on update token document"
+	ErrorBadTokenId    = "Bad token id"
+)
+
+func (t *Tokens) InsertToken(ctx context.Context, token *models.Token) (string, error) {
+	const operation = "internal.storage.mongo.InsertToken()"
+	ctx, cancel := storage.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	res, err := t.db.Collection("Tokens").InsertOne(ctx, bson.D{
+		{Key: "hash", Value: token.Hash},
+		{Key: "user", Value: token.User},
+		{Key: "clientId", Value: token.ClientId},
+		{Key: "scope", Value: token.Scope},
+		{Key: "family", Value: token.Family},
+		{Key: "authTime", Value: token.AuthTime},
+		{Key: "acr", Value: token.Acr},
+		{Key: "createAt", Value: token.CreateAt},
+		{Key: "validUntil", Value: token.ValidUntil},
+		{Key: "used", Value: token.Used},
+		{Key: "revoked", Value: token.Revoked},
+	})
+	if err != nil {
+		return "", errorHelper.WrapError(operation, ErrorInsertToken, mapError(ctx, err))
+	}
+	return res.InsertedID.(primitive.ObjectID).Hex(), nil
+}
+
+func (t *Tokens) GetToken(ctx context.Context, hash string) (*models.Token, error) {
+	const operation = "internal.storage.mongo.GetToken()"
+	ctx, cancel := storage.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	find := t.db.Collection("Tokens").FindOne(ctx, bson.M{"hash": hash})
+	if err := find.Err(); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorTokenNotFound, mapError(ctx, err))
+	}
+	token := models.Token{}
+	if err := find.Decode(&token); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorTokenDecode, mapError(ctx, err))
+	}
+	return &token, nil
+}
+
+func (t *Tokens) UseToken(ctx context.Context, id string) (bool, error) {
+	const operation = "internal.storage.mongo.UseToken()"
+	ctx, cancel := storage.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, errorHelper.WrapError(operation, ErrorBadTokenId, mapError(ctx, err))
+	}
+	//filter by "used" makes check-and-set atomic, only one of concurrent requests wins
+	res, err := t.db.Collection("Tokens").UpdateOne(ctx,
+		bson.M{"_id": oid, "used": false},
+		bson.M{"$set": bson.M{"used": true, "usedAt": time.Now()}},
+	)
+	if err != nil {
+		return false, errorHelper.WrapError(operation, ErrorUpdateToken, mapError(ctx, err))
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+func (t *Tokens) RevokeFamily(ctx context.Context, family string) error {
+	const operation = "internal.storage.mongo.RevokeFamily()"
+	ctx, cancel := storage.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	_, err := t.db.Collection("Tokens").UpdateMany(ctx,
+		bson.M{"family": family},
+		bson.M{"$set": bson.M{"revoked": true}},
+	)
+	if err != nil {
+		return errorHelper.WrapError(operation, ErrorUpdateToken, mapError(ctx, err))
+	}
+	return nil
+}
@@ -5,43 +5,218 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"regexp"
 	"sso/internal/models"
+	"sso/internal/storage"
 	"sso/pkg/helpers/errorHelper"
+	"time"
 )
 
 type Users struct {
-	db *mongo.Database
+	db      *mongo.Database
+	timeout time.Duration
 }
 
 const (
 	ErrorUserNotFound = "User not found"
 	ErrorUserDecode   = "Error on decode user document"
 	ErrorInsertUser   = "Error on insert user document"
+	ErrorUpdateUser   = "Error on update user document"
+	ErrorDeleteUser   = "Error on delete user document"
+	ErrorFindUsers    = "Error on find users"
+	ErrorBadUserId    = "Bad user id"
 )
 
-func (s *Users) GetUser(login string) (*models.User, error) {
+func (s *Users) GetUser(ctx context.Context, login string) (*models.User, error) {
 	const operation = "internal.storage.mongo.GetUser()"
-	find := s.db.Collection("Users").FindOne(context.TODO(), bson.M{"login": login})
+	ctx, cancel := storage.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.findOne(ctx, operation, bson.M{"login": login})
+}
+
+func (s *Users) GetUserById(ctx context.Context, id string) (*models.User, error) {
+	const operation = "internal.storage.mongo.GetUserById()"
+	ctx, cancel := storage.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errorHelper.New(errorHelper.NotFound, operation, ErrorBadUserId)
+	}
+	return s.findOne(ctx, operation, bson.M{"_id": oid})
+}
+
+func (s *Users) findOne(ctx context.Context, operation string, filter bson.M) (*models.User, error) {
+	find := s.db.Collection("Users").FindOne(ctx, filter)
 	if err := find.Err(); err != nil {
-		return nil, errorHelper.WrapError(operation, ErrorUserNotFound, err)
+		return nil, errorHelper.WrapError(operation, ErrorUserNotFound, mapError(ctx, err))
 	}
 	user := models.User{}
-	err := find.Decode(&user)
-	if err != nil {
-		return nil, errorHelper.WrapError(operation, ErrorUserDecode, err)
+	if err := find.Decode(&user); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorUserDecode, mapError(ctx, err))
 	}
 	return &user, nil
 }
 
-func (s *Users) InsertUser(user *models.User) (string, error) {
+func (s *Users) ListUsers(ctx context.Context, filter models.UserFilter) ([]*models.User, int64, error) {
+	const operation = "internal.storage.mongo.ListUsers()"
+	ctx, cancel := storage.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	query := bson.M{}
+	if filter.Search != "" {
+		re := primitive.Regex{Pattern: regexp.QuoteMeta(filter.Search), Options: "i"}
+		query["$or"] = bson.A{bson.M{"login": re}, bson.M{"name": re}, bson.M{"email": re}}
+	}
+	total, err := s.db.Collection("Users").CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, errorHelper.WrapError(operation, ErrorFindUsers, mapError(ctx, err))
+	}
+	opts := options.Find().SetSort(bson.M{"login": 1}).SetSkip(filter.Offset).SetLimit(filter.Limit)
+	cursor, err := s.db.Collection("Users").Find(ctx, query, opts)
+	if err != nil {
+		return nil, 0, errorHelper.WrapError(operation, ErrorFindUsers, mapError(ctx, err))
+	}
+	users := make([]*models.User, 0, filter.Limit)
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, 0, errorHelper.WrapError(operation, ErrorUserDecode, mapError(ctx, err))
+	}
+	return users, total, nil
+}
+
+func (s *Users) InsertUser(ctx context.Context, user *models.User) (string, error) {
 	const operation = "internal.storage.mongo.InsertUser()"
-	res, err := s.db.Collection("Users").InsertOne(context.TODO(), bson.D{
-		{"login", user.Login},
-		{"password", user.Password},
+	ctx, cancel := storage.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	res, err := s.db.Collection("Users").InsertOne(ctx, bson.D{
+		{Key: "login", Value: user.Login},
+		{Key: "password", Value: user.Password},
+		{Key: "name", Value: user.Name},
+		{Key: "email", Value: user.Email},
+		{Key: "emailVerified", Value: user.EmailVerified},
+		{Key: "roles", Value: user.Roles},
+		{Key: "groups", Value: user.Groups},
+		{Key: "disabled", Value: user.Disabled},
+		{Key: "mfa", Value: user.Mfa},
 	})
 	if err != nil {
-		return "", errorHelper.WrapError(operation, ErrorInsertUser, err)
+		return "", errorHelper.WrapError(operation, ErrorInsertUser, mapError(ctx, err))
 	}
 
 	return res.InsertedID.(primitive.ObjectID).Hex(), nil
 }
+
+func (s *Users) UpdateUser(ctx context.Context, id string, update models.UserUpdate) error {
+	const operation = "internal.storage.mongo.UpdateUser()"
+	ctx, cancel := storage.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errorHelper.New(errorHelper.NotFound, operation, ErrorBadUserId)
+	}
+	//only the set fields are written, so a concurrent UseMfaCode is not rolled back
+	set := bson.D{}
+	add := func(key string, value any) {
+		set = append(set, bson.E{Key: key, Value: value})
+	}
+	if update.Login != nil {
+		add("login", *update.Login)
+	}
+	if update.Password != nil {
+		add("password", *update.Password)
+	}
+	if update.Name != nil {
+		add("name", *update.Name)
+	}
+	if update.Email != nil {
+		add("email", *update.Email)
+	}
+	if update.EmailVerified != nil {
+		add("emailVerified", *update.EmailVerified)
+	}
+	if update.Roles != nil {
+		add("roles", *update.Roles)
+	}
+	if update.Groups != nil {
+		add("groups", *update.Groups)
+	}
+	if update.Disabled != nil {
+		add("disabled", *update.Disabled)
+	}
+	if update.Mfa != nil {
+		add("mfa", *update.Mfa)
+	}
+	if len(set) == 0 {
+		//empty $set is rejected by the server, only the user is checked to exist
+		add("_id", oid)
+	}
+	res, err := s.db.Collection("Users").UpdateOne(ctx, bson.M{"_id": oid}, bson.M{"$set": set})
+	if err != nil {
+		return errorHelper.WrapError(operation, ErrorUpdateUser, mapError(ctx, err))
+	}
+	if res.MatchedCount == 0 {
+		return errorHelper.New(errorHelper.NotFound, operation, ErrorUserNotFound)
+	}
+	return nil
+}
+
+func (s *Users) UseMfaCode(ctx context.Context, id string, step int64, recoveryCode string) (bool, error) {
+	const operation = "internal.storage.mongo.UseMfaCode()"
+	ctx, cancel := storage.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, errorHelper.New(errorHelper.NotFound, operation, ErrorBadUserId)
+	}
+	//the condition and the change are one write, concurrent requests with the same code can't both match
+	filter := bson.M{"_id": oid, "mfa.totpstep": bson.M{"$lt": step}}
+	update := bson.M{"$set": bson.M{"mfa.totpstep": step}}
+	if recoveryCode != "" {
+		filter = bson.M{"_id": oid, "mfa.recoverycodes": recoveryCode}
+		update = bson.M{"$pull": bson.M{"mfa.recoverycodes": recoveryCode}}
+	}
+	res, err := s.db.Collection("Users").UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, errorHelper.WrapError(operation, ErrorUpdateUser, mapError(ctx, err))
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+func (s *Users) DeleteUser(ctx context.Context, id string) error {
+	const operation = "internal.storage.mongo.DeleteUser()"
+	ctx, cancel := storage.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errorHelper.New(errorHelper.NotFound, operation, ErrorBadUserId)
+	}
+	res, err := s.db.Collection("Users").DeleteOne(ctx, bson.M{"_id": oid})
+	if err != nil {
+		return errorHelper.WrapError(operation, ErrorDeleteUser, mapError(ctx, err))
+	}
+	if res.DeletedCount == 0 {
+		return errorHelper.New(errorHelper.NotFound, operation, ErrorUserNotFound)
+	}
+	return nil
+}
+
+func (s *Users) RemoveRole(ctx context.Context, role string) error {
+	const operation = "internal.storage.mongo.RemoveRole()"
+	ctx, cancel := storage.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	_, err := s.db.Collection("Users").UpdateMany(ctx, bson.M{"roles": role}, bson.M{"$pull": bson.M{"roles": role}})
+	if err != nil {
+		return errorHelper.WrapError(operation, ErrorUpdateUser, mapError(ctx, err))
+	}
+	return nil
+}
+
+func (s *Users) RemoveGroup(ctx context.Context, groupId string) error {
+	const operation = "internal.storage.mongo.RemoveGroup()"
+	ctx, cancel := storage.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	_, err := s.db.Collection("Users").UpdateMany(ctx, bson.M{"groups": groupId}, bson.M{"$pull": bson.M{"groups": groupId}})
+	if err != nil {
+		return errorHelper.WrapError(operation, ErrorUpdateUser, mapError(ctx, err))
+	}
+	return nil
+}
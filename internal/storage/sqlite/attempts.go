@@ -0,0 +1,87 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"sso/internal/models"
+	"sso/internal/storage"
+	"sso/pkg/helpers/errorHelper"
+	"time"
+)
+
+type Attempts struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+const (
+	ErrorAttemptsNotFound = "Login attempts not found"
+	ErrorUpdateAttempts   = "Error on update login attempts row"
+	ErrorDeleteAttempts   = "Error on delete login attempts row"
+)
+
+const attemptsColumns = `key, failures, last_failure, locked_until, expire_at`
+
+func scanAttempts(row scanner) (*models.Attempts, error) {
+	attempts := models.Attempts{}
+	var lastFailure, lockedUntil, expireAt int64
+	if err := row.Scan(&attempts.Key, &attempts.Failures, &lastFailure, &lockedUntil, &expireAt); err != nil {
+		return nil, err
+	}
+	attempts.LastFailure, attempts.LockedUntil, attempts.ExpireAt = fromMillis(lastFailure), fromMillis(lockedUntil), fromMillis(expireAt)
+	return &attempts, nil
+}
+
+func (a *Attempts) GetAttempts(ctx context.Context, key string) (*models.Attempts, error) {
+	const operation = "internal.storage.sqlite.GetAttempts()"
+	ctx, cancel := storage.WithTimeout(ctx, a.timeout)
+	defer cancel()
+	//there is no TTL cleanup, expired rows are skipped here and restarted by AddFailure
+	attempts, err := scanAttempts(a.db.QueryRowContext(ctx, `SELECT `+attemptsColumns+` FROM login_attempts WHERE key = ? AND expire_at > ?`,
+		key, toMillis(time.Now())))
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorAttemptsNotFound, mapError(ctx, err))
+	}
+	return attempts, nil
+}
+
+func (a *Attempts) AddFailure(ctx context.Context, key string, now time.Time, expireAt time.Time) (*models.Attempts, error) {
+	const operation = "internal.storage.sqlite.AddFailure()"
+	ctx, cancel := storage.WithTimeout(ctx, a.timeout)
+	defer cancel()
+	//upsert restarts expired counter in the same atomic statement
+	attempts, err := scanAttempts(a.db.QueryRowContext(ctx, `INSERT INTO login_attempts (`+attemptsColumns+`) VALUES (?1, 1, ?2, ?3, ?4)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN expire_at <= ?2 THEN 1 ELSE failures + 1 END,
+			locked_until = CASE WHEN expire_at <= ?2 THEN ?3 ELSE locked_until END,
+			last_failure = ?2,
+			expire_at = max(expire_at, ?4)
+		RETURNING `+attemptsColumns,
+		key, toMillis(now), toMillis(time.Time{}), toMillis(expireAt)))
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorUpdateAttempts, mapError(ctx, err))
+	}
+	return attempts, nil
+}
+
+func (a *Attempts) LockAttempts(ctx context.Context, key string, until time.Time, expireAt time.Time) error {
+	const operation = "internal.storage.sqlite.LockAttempts()"
+	ctx, cancel := storage.WithTimeout(ctx, a.timeout)
+	defer cancel()
+	_, err := a.db.ExecContext(ctx, `UPDATE login_attempts SET locked_until = max(locked_until, ?), expire_at = max(expire_at, ?) WHERE key = ?`,
+		toMillis(until), toMillis(expireAt), key)
+	if err != nil {
+		return errorHelper.WrapError(operation, ErrorUpdateAttempts, mapError(ctx, err))
+	}
+	return nil
+}
+
+func (a *Attempts) ResetAttempts(ctx context.Context, key string) error {
+	const operation = "internal.storage.sqlite.ResetAttempts()"
+	ctx, cancel := storage.WithTimeout(ctx, a.timeout)
+	defer cancel()
+	if _, err := a.db.ExecContext(ctx, `DELETE FROM login_attempts WHERE key = ?`, key); err != nil {
+		return errorHelper.WrapError(operation, ErrorDeleteAttempts, mapError(ctx, err))
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"sso/internal/models"
+	"sso/internal/storage"
+	"sso/pkg/helpers/errorHelper"
+	"time"
+)
+
+type Clients struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+const (
+	ErrorClientNotFound = "Client not found"
+	ErrorClientDecode   = "Error on decode client row"
+	ErrorInsertClient   = "Error on insert client row"
+)
+
+func (c *Clients) GetClient(ctx context.Context, clientId string) (*models.Client, error) {
+	const operation = "internal.storage.sqlite.GetClient()"
+	ctx, cancel := storage.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	client := models.Client{}
+	var redirectUris, scopes string
+	err := c.db.QueryRowContext(ctx, `SELECT id, client_id, secret, name, redirect_uris, scopes FROM clients WHERE client_id = ?`, clientId).
+		Scan(&client.Id, &client.ClientId, &client.Secret, &client.Name, &redirectUris, &scopes)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorClientNotFound, mapError(ctx, err))
+	}
+	if client.RedirectUris, err = fromJson(redirectUris); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorClientDecode, mapError(ctx, err))
+	}
+	if client.Scopes, err = fromJson(scopes); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorClientDecode, mapError(ctx, err))
+	}
+	return &client, nil
+}
+
+func (c *Clients) InsertClient(ctx context.Context, client *models.Client) (string, error) {
+	const operation = "internal.storage.sqlite.InsertClient()"
+	ctx, cancel := storage.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	id := newId()
+	_, err := c.db.ExecContext(ctx, `INSERT INTO clients (id, client_id, secret, name, redirect_uris, scopes) VALUES (?, ?, ?, ?, ?, ?)`,
+		id, client.ClientId, client.Secret, client.Name, toJson(client.RedirectUris), toJson(client.Scopes))
+	if err != nil {
+		return "", errorHelper.WrapError(operation, ErrorInsertClient, mapError(ctx, err))
+	}
+	return id, nil
+}
@@ -0,0 +1,68 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"sso/internal/models"
+	"sso/internal/storage"
+	"sso/pkg/helpers/errorHelper"
+	"time"
+)
+
+type Codes struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+const (
+	ErrorCodeNotFound = "Authorization code not found"
+	ErrorInsertCode   = "Error on insert authorization code row"
+	ErrorUpdateCode   = "Error on update authorization code row"
+)
+
+func (c *Codes) InsertCode(ctx context.Context, code *models.AuthCode) (string, error) {
+	const operation = "internal.storage.sqlite.InsertCode()"
+	ctx, cancel := storage.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	id := newId()
+	_, err := c.db.ExecContext(ctx, `INSERT INTO auth_codes (id, hash, client_id, user_id, redirect_uri, scope, code_challenge, family,
+		nonce, auth_time, acr, create_at, valid_until, used) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		id, code.Hash, code.ClientId, code.User, code.RedirectUri, code.Scope, code.CodeChallenge, code.Family,
+		code.Nonce, toMillis(code.AuthTime), code.Acr, toMillis(code.CreateAt), toMillis(code.ValidUntil), code.Used)
+	if err != nil {
+		return "", errorHelper.WrapError(operation, ErrorInsertCode, mapError(ctx, err))
+	}
+	return id, nil
+}
+
+func (c *Codes) GetCode(ctx context.Context, hash string) (*models.AuthCode, error) {
+	const operation = "internal.storage.sqlite.GetCode()"
+	ctx, cancel := storage.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	code := models.AuthCode{}
+	var authTime, createAt, validUntil int64
+	err := c.db.QueryRowContext(ctx, `SELECT id, hash, client_id, user_id, redirect_uri, scope, code_challenge, family,
+		nonce, auth_time, acr, create_at, valid_until, used FROM auth_codes WHERE hash = ?`, hash).
+		Scan(&code.Id, &code.Hash, &code.ClientId, &code.User, &code.RedirectUri, &code.Scope, &code.CodeChallenge, &code.Family,
+			&code.Nonce, &authTime, &code.Acr, &createAt, &validUntil, &code.Used)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorCodeNotFound, mapError(ctx, err))
+	}
+	code.AuthTime, code.CreateAt, code.ValidUntil = fromMillis(authTime), fromMillis(createAt), fromMillis(validUntil)
+	return &code, nil
+}
+
+func (c *Codes) UseCode(ctx context.Context, id string) (bool, error) {
+	const operation = "internal.storage.sqlite.UseCode()"
+	ctx, cancel := storage.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	res, err := c.db.ExecContext(ctx, `UPDATE auth_codes SET used = 1, used_at = ? WHERE id = ? AND used = 0`, toMillis(time.Now()), id)
+	if err != nil {
+		return false, errorHelper.WrapError(operation, ErrorUpdateCode, mapError(ctx, err))
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, errorHelper.WrapError(operation, ErrorUpdateCode, mapError(ctx, err))
+	}
+	return n == 1, nil
+}
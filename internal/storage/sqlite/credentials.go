@@ -0,0 +1,126 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"sso/internal/models"
+	"sso/internal/storage"
+	"sso/pkg/helpers/errorHelper"
+	"time"
+)
+
+type Credentials struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+const (
+	ErrorCredentialNotFound = "Credential not found"
+	ErrorCredentialDecode   = "Error on decode credential row"
+	ErrorFindCredentials    = "Error on find credentials"
+	ErrorInsertCredential   = "Error on insert credential row"
+	ErrorUpdateCredential   = "Error on update credential row"
+	ErrorDeleteCredential   = "Error on delete credential row"
+)
+
+const credentialColumns = `id, user_id, credential_id, public_key, sign_count, aaguid, name, created_at, last_used_at`
+
+func scanCredential(row scanner) (*models.Credential, error) {
+	credential := models.Credential{}
+	var createdAt, lastUsedAt int64
+	err := row.Scan(&credential.Id, &credential.UserId, &credential.CredentialId, &credential.PublicKey,
+		&credential.SignCount, &credential.Aaguid, &credential.Name, &createdAt, &lastUsedAt)
+	if err != nil {
+		return nil, err
+	}
+	credential.CreatedAt, credential.LastUsedAt = fromMillis(createdAt), fromMillis(lastUsedAt)
+	return &credential, nil
+}
+
+func (c *Credentials) InsertCredential(ctx context.Context, credential *models.Credential) (string, error) {
+	const operation = "internal.storage.sqlite.InsertCredential()"
+	ctx, cancel := storage.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	id := newId()
+	_, err := c.db.ExecContext(ctx, `INSERT INTO credentials (`+credentialColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		id, credential.UserId, credential.CredentialId, credential.PublicKey, credential.SignCount, credential.Aaguid,
+		credential.Name, toMillis(credential.CreatedAt), toMillis(credential.LastUsedAt))
+	if err != nil {
+		return "", errorHelper.WrapError(operation, ErrorInsertCredential, mapError(ctx, err))
+	}
+	return id, nil
+}
+
+func (c *Credentials) GetCredential(ctx context.Context, credentialId string) (*models.Credential, error) {
+	const operation = "internal.storage.sqlite.GetCredential()"
+	ctx, cancel := storage.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	credential, err := scanCredential(c.db.QueryRowContext(ctx, `SELECT `+credentialColumns+` FROM credentials WHERE credential_id = ?`, credentialId))
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorCredentialNotFound, mapError(ctx, err))
+	}
+	return credential, nil
+}
+
+func (c *Credentials) GetUserCredentials(ctx context.Context, userId string) ([]*models.Credential, error) {
+	const operation = "internal.storage.sqlite.GetUserCredentials()"
+	ctx, cancel := storage.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	rows, err := c.db.QueryContext(ctx, `SELECT `+credentialColumns+` FROM credentials WHERE user_id = ? ORDER BY created_at`, userId)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorFindCredentials, mapError(ctx, err))
+	}
+	defer rows.Close()
+	credentials := make([]*models.Credential, 0)
+	for rows.Next() {
+		credential, err := scanCredential(rows)
+		if err != nil {
+			return nil, errorHelper.WrapError(operation, ErrorCredentialDecode, mapError(ctx, err))
+		}
+		credentials = append(credentials, credential)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorFindCredentials, mapError(ctx, err))
+	}
+	return credentials, nil
+}
+
+func (c *Credentials) UseCredential(ctx context.Context, id string, signCount int64, newSignCount int64, usedAt time.Time) (bool, error) {
+	const operation = "internal.storage.sqlite.UseCredential()"
+	ctx, cancel := storage.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	res, err := c.db.ExecContext(ctx, `UPDATE credentials SET sign_count = ?, last_used_at = ? WHERE id = ? AND sign_count = ?`,
+		newSignCount, toMillis(usedAt), id, signCount)
+	if err != nil {
+		return false, errorHelper.WrapError(operation, ErrorUpdateCredential, mapError(ctx, err))
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, errorHelper.WrapError(operation, ErrorUpdateCredential, mapError(ctx, err))
+	}
+	return n == 1, nil
+}
+
+func (c *Credentials) DeleteCredential(ctx context.Context, userId string, id string) error {
+	const operation = "internal.storage.sqlite.DeleteCredential()"
+	ctx, cancel := storage.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	res, err := c.db.ExecContext(ctx, `DELETE FROM credentials WHERE id = ? AND user_id = ?`, id, userId)
+	if err != nil {
+		return errorHelper.WrapError(operation, ErrorDeleteCredential, mapError(ctx, err))
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errorHelper.New(errorHelper.NotFound, operation, ErrorCredentialNotFound)
+	}
+	return nil
+}
+
+func (c *Credentials) DeleteUserCredentials(ctx context.Context, userId string) error {
+	const operation = "internal.storage.sqlite.DeleteUserCredentials()"
+	ctx, cancel := storage.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	if _, err := c.db.ExecContext(ctx, `DELETE FROM credentials WHERE user_id = ?`, userId); err != nil {
+		return errorHelper.WrapError(operation, ErrorDeleteCredential, mapError(ctx, err))
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"sso/internal/models"
+	"sso/internal/storage"
+	"sso/pkg/helpers/errorHelper"
+	"time"
+)
+
+type Groups struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+const (
+	ErrorGroupNotFound = "Group not found"
+	ErrorGroupDecode   = "Error on decode group row"
+	ErrorFindGroups    = "Error on find groups"
+	ErrorInsertGroup   = "Error on insert group row"
+	ErrorUpdateGroup   = "Error on update group row"
+	ErrorDeleteGroup   = "Error on delete group row"
+)
+
+func scanGroup(row scanner) (*models.Group, error) {
+	group := models.Group{}
+	var parents string
+	if err := row.Scan(&group.Id, &group.Name, &group.Description, &parents); err != nil {
+		return nil, err
+	}
+	var err error
+	if group.Parents, err = fromJson(parents); err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+func (g *Groups) GetGroup(ctx context.Context, id string) (*models.Group, error) {
+	const operation = "internal.storage.sqlite.GetGroup()"
+	ctx, cancel := storage.WithTimeout(ctx, g.timeout)
+	defer cancel()
+	group, err := scanGroup(g.db.QueryRowContext(ctx, `SELECT id, name, description, parents FROM groups WHERE id = ?`, id))
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorGroupNotFound, mapError(ctx, err))
+	}
+	return group, nil
+}
+
+func (g *Groups) GetGroups(ctx context.Context, ids []string) ([]*models.Group, error) {
+	const operation = "internal.storage.sqlite.GetGroups()"
+	ctx, cancel := storage.WithTimeout(ctx, g.timeout)
+	defer cancel()
+	if len(ids) == 0 {
+		return []*models.Group{}, nil
+	}
+	return g.find(ctx, operation, ` WHERE `+inJson("id"), toJson(ids))
+}
+
+func (g *Groups) ListGroups(ctx context.Context) ([]*models.Group, error) {
+	const operation = "internal.storage.sqlite.ListGroups()"
+	ctx, cancel := storage.WithTimeout(ctx, g.timeout)
+	defer cancel()
+	return g.find(ctx, operation, "")
+}
+
+func (g *Groups) find(ctx context.Context, operation string, where string, args ...any) ([]*models.Group, error) {
+	rows, err := g.db.QueryContext(ctx, `SELECT id, name, description, parents FROM groups`+where+` ORDER BY name`, args...)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorFindGroups, mapError(ctx, err))
+	}
+	defer rows.Close()
+	groups := make([]*models.Group, 0)
+	for rows.Next() {
+		group, err := scanGroup(rows)
+		if err != nil {
+			return nil, errorHelper.WrapError(operation, ErrorGroupDecode, mapError(ctx, err))
+		}
+		groups = append(groups, group)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorFindGroups, mapError(ctx, err))
+	}
+	return groups, nil
+}
+
+func (g *Groups) InsertGroup(ctx context.Context, group *models.Group) (string, error) {
+	const operation = "internal.storage.sqlite.InsertGroup()"
+	ctx, cancel := storage.WithTimeout(ctx, g.timeout)
+	defer cancel()
+	id := newId()
+	_, err := g.db.ExecContext(ctx, `INSERT INTO groups (id, name, description, parents) VALUES (?, ?, ?, ?)`,
+		id, group.Name, group.Description, toJson(group.Parents))
+	if err != nil {
+		return "", errorHelper.WrapError(operation, ErrorInsertGroup, mapError(ctx, err))
+	}
+	return id, nil
+}
+
+func (g *Groups) UpdateGroup(ctx context.Context, group *models.Group) error {
+	const operation = "internal.storage.sqlite.UpdateGroup()"
+	ctx, cancel := storage.WithTimeout(ctx, g.timeout)
+	defer cancel()
+	res, err := g.db.ExecContext(ctx, `UPDATE groups SET name = ?, description = ?, parents = ? WHERE id = ?`,
+		group.Name, group.Description, toJson(group.Parents), group.Id)
+	if err != nil {
+		return errorHelper.WrapError(operation, ErrorUpdateGroup, mapError(ctx, err))
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errorHelper.New(errorHelper.NotFound, operation, ErrorGroupNotFound)
+	}
+	return nil
+}
+
+func (g *Groups) DeleteGroup(ctx context.Context, id string) error {
+	const operation = "internal.storage.sqlite.DeleteGroup()"
+	ctx, cancel := storage.WithTimeout(ctx, g.timeout)
+	defer cancel()
+	res, err := g.db.ExecContext(ctx, `DELETE FROM groups WHERE id = ?`, id)
+	if err != nil {
+		return errorHelper.WrapError(operation, ErrorDeleteGroup, mapError(ctx, err))
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errorHelper.New(errorHelper.NotFound, operation, ErrorGroupNotFound)
+	}
+	return nil
+}
+
+func (g *Groups) RemoveParent(ctx context.Context, parentId string) error {
+	const operation = "internal.storage.sqlite.RemoveParent()"
+	ctx, cancel := storage.WithTimeout(ctx, g.timeout)
+	defer cancel()
+	if _, err := g.db.ExecContext(ctx, pullQuery("groups", "parents"), parentId); err != nil {
+		return errorHelper.WrapError(operation, ErrorUpdateGroup, mapError(ctx, err))
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"sso/internal/models"
+	"sso/internal/storage"
+	"sso/pkg/helpers/errorHelper"
+	"time"
+)
+
+type Keys struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+const (
+	ErrorKeyDecode = "Error on decode key row"
+	ErrorSaveKey   = "error on save private key to DB"
+	ErrorFindKeys  = "error on find keys"
+)
+
+func (k *Keys) GetKeys(ctx context.Context) ([]*models.Key, error) {
+	const operation = "internal.storage.sqlite.GetKeys()"
+	ctx, cancel := storage.WithTimeout(ctx, k.timeout)
+	defer cancel()
+	rows, err := k.db.QueryContext(ctx, `SELECT id, kid, pem, created_at, activate_at, retire_at, exp FROM keys
+		WHERE exp > ? ORDER BY activate_at`, toMillis(time.Now()))
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorFindKeys, mapError(ctx, err))
+	}
+	defer rows.Close()
+	var keys []*models.Key
+	for rows.Next() {
+		key := models.Key{}
+		var createdAt, activateAt, retireAt, exp int64
+		if err := rows.Scan(&key.Id, &key.Kid, &key.PEM, &createdAt, &activateAt, &retireAt, &exp); err != nil {
+			return nil, errorHelper.WrapError(operation, ErrorKeyDecode, mapError(ctx, err))
+		}
+		key.CreatedAt, key.ActivateAt, key.RetireAt, key.Exp = fromMillis(createdAt), fromMillis(activateAt), fromMillis(retireAt), fromMillis(exp)
+		keys = append(keys, &key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorFindKeys, mapError(ctx, err))
+	}
+	return keys, nil
+}
+
+func (k *Keys) InsertKey(ctx context.Context, key *models.Key) (string, error) {
+	const operation = "internal.storage.sqlite.InsertKey()"
+	ctx, cancel := storage.WithTimeout(ctx, k.timeout)
+	defer cancel()
+	id := newId()
+	_, err := k.db.ExecContext(ctx, `INSERT INTO keys (id, kid, pem, created_at, activate_at, retire_at, exp) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		id, key.Kid, key.PEM, toMillis(key.CreatedAt), toMillis(key.ActivateAt), toMillis(key.RetireAt), toMillis(key.Exp))
+	if err != nil {
+		return "", errorHelper.WrapError(operation, ErrorSaveKey, mapError(ctx, err))
+	}
+	return id, nil
+}
@@ -0,0 +1,87 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"sso/internal/models"
+	"sso/internal/storage"
+	"sso/pkg/helpers/errorHelper"
+	"time"
+)
+
+type Permissions struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+const (
+	ErrorPermissionNotFound = "Permission not found"
+	ErrorPermissionDecode   = "Error on decode permission row"
+	ErrorFindPermissions    = "Error on find permissions"
+	ErrorInsertPermission   = "Error on insert permission row"
+	ErrorDeletePermission   = "Error on delete permission row"
+)
+
+func (p *Permissions) GetPermissions(ctx context.Context, names []string) ([]*models.Permission, error) {
+	const operation = "internal.storage.sqlite.GetPermissions()"
+	ctx, cancel := storage.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	if len(names) == 0 {
+		return []*models.Permission{}, nil
+	}
+	return p.find(ctx, operation, ` WHERE `+inJson("name"), toJson(names))
+}
+
+func (p *Permissions) ListPermissions(ctx context.Context) ([]*models.Permission, error) {
+	const operation = "internal.storage.sqlite.ListPermissions()"
+	ctx, cancel := storage.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	return p.find(ctx, operation, "")
+}
+
+func (p *Permissions) find(ctx context.Context, operation string, where string, args ...any) ([]*models.Permission, error) {
+	rows, err := p.db.QueryContext(ctx, `SELECT id, name, description FROM permissions`+where+` ORDER BY name`, args...)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorFindPermissions, mapError(ctx, err))
+	}
+	defer rows.Close()
+	permissions := make([]*models.Permission, 0)
+	for rows.Next() {
+		permission := models.Permission{}
+		if err := rows.Scan(&permission.Id, &permission.Name, &permission.Description); err != nil {
+			return nil, errorHelper.WrapError(operation, ErrorPermissionDecode, mapError(ctx, err))
+		}
+		permissions = append(permissions, &permission)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorFindPermissions, mapError(ctx, err))
+	}
+	return permissions, nil
+}
+
+func (p *Permissions) InsertPermission(ctx context.Context, permission *models.Permission) (string, error) {
+	const operation = "internal.storage.sqlite.InsertPermission()"
+	ctx, cancel := storage.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	id := newId()
+	_, err := p.db.ExecContext(ctx, `INSERT INTO permissions (id, name, description) VALUES (?, ?, ?)`,
+		id, permission.Name, permission.Description)
+	if err != nil {
+		return "", errorHelper.WrapError(operation, ErrorInsertPermission, mapError(ctx, err))
+	}
+	return id, nil
+}
+
+func (p *Permissions) DeletePermission(ctx context.Context, name string) error {
+	const operation = "internal.storage.sqlite.DeletePermission()"
+	ctx, cancel := storage.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	res, err := p.db.ExecContext(ctx, `DELETE FROM permissions WHERE name = ?`, name)
+	if err != nil {
+		return errorHelper.WrapError(operation, ErrorDeletePermission, mapError(ctx, err))
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errorHelper.New(errorHelper.NotFound, operation, ErrorPermissionNotFound)
+	}
+	return nil
+}
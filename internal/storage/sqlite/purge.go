@@ -0,0 +1,72 @@
+package sqlite
+
+import (
+	"context"
+	"log/slog"
+	"sso/internal/storage"
+	"sso/pkg/helpers/errorHelper"
+	"sso/pkg/helpers/slogHelper"
+	"time"
+)
+
+const (
+	ErrorPurge = "Error on purge expired rows"
+)
+
+// DefaultPurgeInterval is how often expired rows are deleted, the period of the Mongo TTL monitor
+const DefaultPurgeInterval = time.Minute
+
+// codeRetention keeps expired authorization codes like the Mongo TTL index does,
+// so a code replayed soon after expiry is still found and revokes its tokens
+const codeRetention = time.Hour
+
+// purgeQueries delete rows Mongo removes with TTL indexes, the parameter is the expiry time
+var purgeQueries = []struct {
+	query     string
+	retention time.Duration
+}{
+	{`DELETE FROM tokens WHERE valid_until <= ?`, 0},
+	{`DELETE FROM auth_codes WHERE valid_until <= ?`, codeRetention},
+	{`DELETE FROM revocations WHERE exp <= ?`, 0},
+	{`DELETE FROM login_attempts WHERE expire_at <= ?`, 0},
+}
+
+// purge deletes rows expired at now and returns their count
+func (s *Storage) purge(ctx context.Context, now time.Time) (int64, error) {
+	const operation = "internal.storage.sqlite.purge()"
+	ctx, cancel := storage.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	var total int64
+	for _, q := range purgeQueries {
+		res, err := s.db.ExecContext(ctx, q.query, toMillis(now.Add(-q.retention)))
+		if err != nil {
+			return total, errorHelper.WrapError(operation, ErrorPurge, mapError(ctx, err))
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return total, errorHelper.WrapError(operation, ErrorPurge, mapError(ctx, err))
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// purgeLoop purges expired rows every interval until Shutdown
+func (s *Storage) purgeLoop(logger *slog.Logger, interval time.Duration) {
+	defer close(s.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			n, err := s.purge(context.Background(), now)
+			if err != nil {
+				logger.Error(ErrorPurge, slogHelper.GetErrAttr(err))
+			} else if n > 0 {
+				logger.Debug("Purged expired rows", slog.Int64("rows", n))
+			}
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"sso/internal/models"
+	"sso/internal/storage"
+	"sso/pkg/helpers/errorHelper"
+	"time"
+)
+
+type Revocations struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+const (
+	ErrorInsertRevocation = "Error on insert revocation row"
+	ErrorFindRevocations  = "Error on find revocations"
+	ErrorRevocationDecode = "Error on decode revocation row"
+)
+
+func (r *Revocations) Revoke(ctx context.Context, jti string, exp time.Time) error {
+	const operation = "internal.storage.sqlite.Revoke()"
+	ctx, cancel := storage.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	//ignoring existing row keeps revocation idempotent
+	_, err := r.db.ExecContext(ctx, `INSERT INTO revocations (jti, revoked_at, exp) VALUES (?, ?, ?) ON CONFLICT (jti) DO NOTHING`,
+		jti, toMillis(time.Now()), toMillis(exp))
+	if err != nil {
+		return errorHelper.WrapError(operation, ErrorInsertRevocation, mapError(ctx, err))
+	}
+	return nil
+}
+
+func (r *Revocations) GetRevoked(ctx context.Context, since time.Time) ([]*models.Revocation, error) {
+	const operation = "internal.storage.sqlite.GetRevoked()"
+	ctx, cancel := storage.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	rows, err := r.db.QueryContext(ctx, `SELECT jti, revoked_at, exp FROM revocations WHERE revoked_at >= ? AND exp > ?`,
+		toMillis(since), toMillis(time.Now()))
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorFindRevocations, mapError(ctx, err))
+	}
+	defer rows.Close()
+	var list []*models.Revocation
+	for rows.Next() {
+		revocation := models.Revocation{}
+		var revokedAt, exp int64
+		if err := rows.Scan(&revocation.Jti, &revokedAt, &exp); err != nil {
+			return nil, errorHelper.WrapError(operation, ErrorRevocationDecode, mapError(ctx, err))
+		}
+		revocation.RevokedAt, revocation.Exp = fromMillis(revokedAt), fromMillis(exp)
+		list = append(list, &revocation)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorFindRevocations, mapError(ctx, err))
+	}
+	return list, nil
+}
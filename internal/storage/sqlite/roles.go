@@ -0,0 +1,137 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"sso/internal/models"
+	"sso/internal/storage"
+	"sso/pkg/helpers/errorHelper"
+	"time"
+)
+
+type Roles struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+const (
+	ErrorRoleNotFound = "Role not found"
+	ErrorRoleDecode   = "Error on decode role row"
+	ErrorFindRoles    = "Error on find roles"
+	ErrorInsertRole   = "Error on insert role row"
+	ErrorUpdateRole   = "Error on update role row"
+	ErrorDeleteRole   = "Error on delete role row"
+)
+
+func scanRole(row scanner) (*models.Role, error) {
+	role := models.Role{}
+	var permissions string
+	if err := row.Scan(&role.Id, &role.Name, &role.Description, &permissions); err != nil {
+		return nil, err
+	}
+	var err error
+	if role.Permissions, err = fromJson(permissions); err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (r *Roles) GetRole(ctx context.Context, name string) (*models.Role, error) {
+	const operation = "internal.storage.sqlite.GetRole()"
+	ctx, cancel := storage.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	role, err := scanRole(r.db.QueryRowContext(ctx, `SELECT id, name, description, permissions FROM roles WHERE name = ?`, name))
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorRoleNotFound, mapError(ctx, err))
+	}
+	return role, nil
+}
+
+func (r *Roles) GetRoles(ctx context.Context, names []string) ([]*models.Role, error) {
+	const operation = "internal.storage.sqlite.GetRoles()"
+	ctx, cancel := storage.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	if len(names) == 0 {
+		return []*models.Role{}, nil
+	}
+	return r.find(ctx, operation, ` WHERE `+inJson("name"), toJson(names))
+}
+
+func (r *Roles) ListRoles(ctx context.Context) ([]*models.Role, error) {
+	const operation = "internal.storage.sqlite.ListRoles()"
+	ctx, cancel := storage.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	return r.find(ctx, operation, "")
+}
+
+func (r *Roles) find(ctx context.Context, operation string, where string, args ...any) ([]*models.Role, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT id, name, description, permissions FROM roles`+where+` ORDER BY name`, args...)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorFindRoles, mapError(ctx, err))
+	}
+	defer rows.Close()
+	roles := make([]*models.Role, 0)
+	for rows.Next() {
+		role, err := scanRole(rows)
+		if err != nil {
+			return nil, errorHelper.WrapError(operation, ErrorRoleDecode, mapError(ctx, err))
+		}
+		roles = append(roles, role)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorFindRoles, mapError(ctx, err))
+	}
+	return roles, nil
+}
+
+func (r *Roles) InsertRole(ctx context.Context, role *models.Role) (string, error) {
+	const operation = "internal.storage.sqlite.InsertRole()"
+	ctx, cancel := storage.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	id := newId()
+	_, err := r.db.ExecContext(ctx, `INSERT INTO roles (id, name, description, permissions) VALUES (?, ?, ?, ?)`,
+		id, role.Name, role.Description, toJson(role.Permissions))
+	if err != nil {
+		return "", errorHelper.WrapError(operation, ErrorInsertRole, mapError(ctx, err))
+	}
+	return id, nil
+}
+
+func (r *Roles) UpdateRole(ctx context.Context, role *models.Role) error {
+	const operation = "internal.storage.sqlite.UpdateRole()"
+	ctx, cancel := storage.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	res, err := r.db.ExecContext(ctx, `UPDATE roles SET description = ?, permissions = ? WHERE name = ?`,
+		role.Description, toJson(role.Permissions), role.Name)
+	if err != nil {
+		return errorHelper.WrapError(operation, ErrorUpdateRole, mapError(ctx, err))
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errorHelper.New(errorHelper.NotFound, operation, ErrorRoleNotFound)
+	}
+	return nil
+}
+
+func (r *Roles) DeleteRole(ctx context.Context, name string) error {
+	const operation = "internal.storage.sqlite.DeleteRole()"
+	ctx, cancel := storage.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	res, err := r.db.ExecContext(ctx, `DELETE FROM roles WHERE name = ?`, name)
+	if err != nil {
+		return errorHelper.WrapError(operation, ErrorDeleteRole, mapError(ctx, err))
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errorHelper.New(errorHelper.NotFound, operation, ErrorRoleNotFound)
+	}
+	return nil
+}
+
+func (r *Roles) RemovePermission(ctx context.Context, permission string) error {
+	const operation = "internal.storage.sqlite.RemovePermission()"
+	ctx, cancel := storage.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	if _, err := r.db.ExecContext(ctx, pullQuery("roles", "permissions"), permission); err != nil {
+		return errorHelper.WrapError(operation, ErrorUpdateRole, mapError(ctx, err))
+	}
+	return nil
+}
@@ -0,0 +1,264 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/golang-migrate/migrate/v4"
+	migrateSqlite "github.com/golang-migrate/migrate/v4/database/sqlite"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/google/uuid"
+	"log/slog"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+	"sso/internal/storage"
+	"sso/pkg/helpers/errorHelper"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ErrorOpenDatabase            = "Error on open SQLite database"
+	ErrorCreateMigrationInstance = "Error on create migration instance"
+	ErrorMigrate                 = "Error on migrate SQLite database"
+	ErrorCloseConnections        = "Error on close database connections"
+	ErrorPing                    = "Error on ping SQLite database"
+)
+
+type Storage struct {
+	db      *sql.DB
+	timeout time.Duration
+	stop    chan struct{} //closed on Shutdown to stop purgeLoop
+	done    chan struct{} //closed by purgeLoop on return
+	once    sync.Once
+}
+
+type Config struct {
+	Path       string        //database file, ":memory:" for a database living until shutdown
+	Migrations string        //migrations source URL, DefaultMigrations if empty
+	Timeout    time.Duration //deadline of a single operation, no deadline if zero
+	//how often expired tokens, codes, revocations and login attempts are deleted, DefaultPurgeInterval if zero
+	PurgeInterval time.Duration
+}
+
+// DefaultMigrations are the migrations copied next to the binary
+const DefaultMigrations = "file://migrations/sqlite"
+
+func New(logger *slog.Logger, config Config) (*Storage, error) {
+	const operation = "internal.storage.sqlite.new()"
+	logger.Info("Opening SQLite database and init it...", slog.String("path", config.Path))
+	//
+	//database
+	//
+	dsn := "file:" + config.Path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorOpenDatabase, err)
+	}
+	//single connection serializes writers, so there are no "database is locked" errors,
+	//an in-memory database also exists only within its connection
+	db.SetMaxOpenConns(1)
+	//
+	//migrations
+	//
+	driver, err := migrateSqlite.WithInstance(db, &migrateSqlite.Config{})
+	if err != nil {
+		_ = db.Close()
+		return nil, errorHelper.WrapError(operation, ErrorCreateMigrationInstance, err)
+	}
+	source := config.Migrations
+	if source == "" {
+		source = DefaultMigrations
+	}
+	m, err := migrate.NewWithDatabaseInstance(source, "sqlite", driver)
+	if err != nil {
+		_ = db.Close()
+		return nil, errorHelper.WrapError(operation, ErrorCreateMigrationInstance, err)
+	}
+	//unlike Mongo collections, tables are required, so a failed migration is fatal
+	if err = m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		_ = db.Close()
+		return nil, errorHelper.WrapError(operation, ErrorMigrate, err)
+	}
+	//
+	//expired rows, Mongo removes them with TTL indexes
+	//
+	interval := config.PurgeInterval
+	if interval <= 0 {
+		interval = DefaultPurgeInterval
+	}
+	s := &Storage{db: db, timeout: config.Timeout, stop: make(chan struct{}), done: make(chan struct{})}
+	go s.purgeLoop(logger, interval)
+	return s, nil
+}
+
+// mapError classifies driver errors with errorHelper kinds, other driver errors are Internal
+func mapError(ctx context.Context, err error) error {
+	if e := storage.Canceled(ctx, err); e != nil {
+		return e
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return errorHelper.NotFound
+	}
+	var e *sqlite.Error
+	if errors.As(err, &e) && (e.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || e.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY) {
+		return errorHelper.Conflict
+	}
+	return err
+}
+
+// scanner is a row of QueryRow or Query result
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func newId() string {
+	return uuid.NewString()
+}
+
+// toMillis stores time with the same millisecond precision as Mongo does
+func toMillis(t time.Time) int64 {
+	return t.UnixMilli()
+}
+
+// fromMillis restores time, zero time survives the round trip
+func fromMillis(ms int64) time.Time {
+	return time.UnixMilli(ms).UTC()
+}
+
+// toJson encodes list column, nil list is stored as null like in Mongo
+func toJson(list []string) string {
+	b, _ := json.Marshal(list)
+	return string(b)
+}
+
+func fromJson(value string) ([]string, error) {
+	var list []string
+	if err := json.Unmarshal([]byte(value), &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// likePattern matches substring, wildcards of the search are escaped with backslash
+func likePattern(search string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return "%" + r.Replace(search) + "%"
+}
+
+func (s *Storage) Ping(ctx context.Context) error {
+	const operation = "internal.storage.sqlite.Ping()"
+	ctx, cancel := storage.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	if err := s.db.PingContext(ctx); err != nil {
+		return errorHelper.WrapError(operation, ErrorPing, mapError(ctx, err))
+	}
+	return nil
+}
+
+// Migrated always succeeds, New fails if migrations are not applied
+func (s *Storage) Migrated(ctx context.Context) error {
+	return nil
+}
+
+func (s *Storage) Shutdown(ctx context.Context) error {
+	const operation = "internal.storage.sqlite.Shutdown()"
+	s.once.Do(func() { close(s.stop) })
+	<-s.done
+	if err := s.db.Close(); err != nil {
+		return errorHelper.WrapError(operation, ErrorCloseConnections, err)
+	}
+	return nil
+}
+
+func (s *Storage) Users() storage.Users {
+	return &Users{
+		db:      s.db,
+		timeout: s.timeout,
+	}
+}
+
+func (s *Storage) Keys() storage.Keys {
+	return &Keys{
+		db:      s.db,
+		timeout: s.timeout,
+	}
+}
+
+func (s *Storage) Tokens() storage.Tokens {
+	return &Tokens{
+		db:      s.db,
+		timeout: s.timeout,
+	}
+}
+
+func (s *Storage) Revocations() storage.Revocations {
+	return &Revocations{
+		db:      s.db,
+		timeout: s.timeout,
+	}
+}
+
+func (s *Storage) Clients() storage.Clients {
+	return &Clients{
+		db:      s.db,
+		timeout: s.timeout,
+	}
+}
+
+func (s *Storage) Codes() storage.Codes {
+	return &Codes{
+		db:      s.db,
+		timeout: s.timeout,
+	}
+}
+
+func (s *Storage) Roles() storage.Roles {
+	return &Roles{
+		db:      s.db,
+		timeout: s.timeout,
+	}
+}
+
+func (s *Storage) Permissions() storage.Permissions {
+	return &Permissions{
+		db:      s.db,
+		timeout: s.timeout,
+	}
+}
+
+func (s *Storage) Groups() storage.Groups {
+	return &Groups{
+		db:      s.db,
+		timeout: s.timeout,
+	}
+}
+
+func (s *Storage) Credentials() storage.Credentials {
+	return &Credentials{
+		db:      s.db,
+		timeout: s.timeout,
+	}
+}
+
+func (s *Storage) Attempts() storage.Attempts {
+	return &Attempts{
+		db:      s.db,
+		timeout: s.timeout,
+	}
+}
+
+// pullQuery removes a value from JSON list column of all rows containing it, like $pull in Mongo.
+// The value is the only parameter of the query.
+func pullQuery(table string, column string) string {
+	return `UPDATE ` + table + ` SET ` + column + ` = (
+		SELECT json_group_array(value) FROM json_each(` + table + `.` + column + `) WHERE value != ?1
+	) WHERE EXISTS (SELECT 1 FROM json_each(` + table + `.` + column + `) WHERE value = ?1)`
+}
+
+// inJson is a filter of rows whose column is in the list bound as JSON array
+func inJson(column string) string {
+	return column + ` IN (SELECT value FROM json_each(?))`
+}
@@ -0,0 +1,164 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"github.com/golang-migrate/migrate/v4"
+	migrateSqlite "github.com/golang-migrate/migrate/v4/database/sqlite"
+	"github.com/stretchr/testify/require"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"sso/internal/models"
+	"sso/internal/storage"
+	"sso/internal/storage/storagetest"
+	"sso/pkg/helpers/errorHelper"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const testMigrations = "file://../../../build/migrations/sqlite"
+
+func newTestStorage(t *testing.T, path string) *Storage {
+	t.Helper()
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	s, err := New(log, Config{Path: path, Migrations: testMigrations})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Shutdown(context.Background()) })
+	return s
+}
+
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return newTestStorage(t, ":memory:")
+	})
+}
+
+// tables returns names of tables or indexes created by migrations, bookkeeping of golang-migrate is skipped
+func tables(t *testing.T, db *sql.DB, typ string) []string {
+	t.Helper()
+	rows, err := db.Query(`SELECT name FROM sqlite_master
+		WHERE type = ? AND name NOT LIKE 'sqlite_%' AND tbl_name <> 'schema_migrations' ORDER BY name`, typ)
+	require.NoError(t, err)
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		require.NoError(t, rows.Scan(&name))
+		names = append(names, name)
+	}
+	require.NoError(t, rows.Err())
+	return names
+}
+
+func TestMigrations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sso.db")
+	s := newTestStorage(t, path)
+	require.Equal(t, []string{"auth_codes", "clients", "credentials", "groups", "keys", "login_attempts", "permissions",
+		"revocations", "roles", "tokens", "users"}, tables(t, s.db, "table"))
+	require.Subset(t, tables(t, s.db, "index"), []string{"tokens_valid_until", "auth_codes_valid_until", "revocations_exp", "login_attempts_expire_at"})
+	//the admin role is seeded
+	role, err := s.Roles().GetRole(context.Background(), models.RoleAdmin)
+	require.NoError(t, err)
+	require.Equal(t, []string{}, role.Permissions)
+	require.NoError(t, s.Shutdown(context.Background()))
+
+	//applied migrations are skipped on the next start
+	s = newTestStorage(t, path)
+	_, err = s.Roles().GetRole(context.Background(), models.RoleAdmin)
+	require.NoError(t, err)
+
+	//down migrations drop everything up migrations created
+	driver, err := migrateSqlite.WithInstance(s.db, &migrateSqlite.Config{})
+	require.NoError(t, err)
+	m, err := migrate.NewWithDatabaseInstance(testMigrations, "sqlite", driver)
+	require.NoError(t, err)
+	require.NoError(t, m.Down())
+	require.Empty(t, tables(t, s.db, "table"))
+	require.Empty(t, tables(t, s.db, "index"))
+	require.NoError(t, m.Up())
+	require.Contains(t, tables(t, s.db, "table"), "users")
+}
+
+func TestJsonColumns(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t, ":memory:")
+	column := func(query string, args ...any) string {
+		var value string
+		require.NoError(t, s.db.QueryRow(query, args...).Scan(&value))
+		return value
+	}
+
+	//nil and empty lists are distinguished like in Mongo, values are escaped
+	for _, roles := range [][]string{nil, {}, {"admin", `quote " and \ backslash`, "юникод"}} {
+		id, err := s.Users().InsertUser(ctx, &models.User{Login: "user" + toJson(roles), Roles: roles})
+		require.NoError(t, err)
+		user, err := s.Users().GetUserById(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, roles, user.Roles)
+		require.Equal(t, toJson(roles), column(`SELECT roles FROM users WHERE id = ?`, id))
+	}
+	require.Equal(t, "null", toJson(nil))
+	require.Equal(t, "[]", toJson([]string{}))
+
+	//rows written without list columns get the null default
+	_, err := s.db.Exec(`INSERT INTO users (id, login) VALUES ('raw', 'raw')`)
+	require.NoError(t, err)
+	user, err := s.Users().GetUserById(ctx, "raw")
+	require.NoError(t, err)
+	require.Nil(t, user.Roles)
+	require.Nil(t, user.Groups)
+	require.Nil(t, user.Mfa.RecoveryCodes)
+
+	//broken JSON is reported, not read as an empty list
+	_, err = s.db.Exec(`UPDATE users SET roles = 'not json' WHERE id = 'raw'`)
+	require.NoError(t, err)
+	_, err = s.Users().GetUserById(ctx, "raw")
+	require.Error(t, err)
+	require.NotEqual(t, errorHelper.NotFound, errorHelper.KindOf(err))
+}
+
+func TestPurge(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t, ":memory:")
+	now := time.Now()
+	for i, validUntil := range []time.Time{now.Add(-time.Minute), now.Add(time.Minute)} {
+		key := strconv.Itoa(i)
+		_, err := s.Tokens().InsertToken(ctx, &models.Token{Hash: key, Family: "family", ValidUntil: validUntil})
+		require.NoError(t, err)
+		require.NoError(t, s.Revocations().Revoke(ctx, key, validUntil))
+		_, err = s.Attempts().AddFailure(ctx, key, now.Add(-time.Hour), validUntil)
+		require.NoError(t, err)
+	}
+	//codes are kept an hour after expiry for replay detection
+	for i, validUntil := range []time.Time{now.Add(-2 * time.Hour), now.Add(-time.Minute), now.Add(time.Minute)} {
+		_, err := s.Codes().InsertCode(ctx, &models.AuthCode{Hash: strconv.Itoa(i), ClientId: "app", User: "user", ValidUntil: validUntil})
+		require.NoError(t, err)
+	}
+
+	n, err := s.purge(ctx, now)
+	require.NoError(t, err)
+	require.Equal(t, int64(4), n)
+	for table, want := range map[string]int{"tokens": 1, "auth_codes": 2, "revocations": 1, "login_attempts": 1} {
+		var count int
+		require.NoError(t, s.db.QueryRow(`SELECT count(*) FROM `+table).Scan(&count))
+		require.Equal(t, want, count, table)
+	}
+	n, err = s.purge(ctx, now)
+	require.NoError(t, err)
+	require.Zero(t, n)
+}
+
+func TestPurgeLoop(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	s, err := New(log, Config{Path: ":memory:", Migrations: testMigrations, PurgeInterval: 10 * time.Millisecond})
+	require.NoError(t, err)
+	require.NoError(t, s.Revocations().Revoke(context.Background(), "jti", time.Now().Add(-time.Second)))
+	require.Eventually(t, func() bool {
+		var count int
+		require.NoError(t, s.db.QueryRow(`SELECT count(*) FROM revocations`).Scan(&count))
+		return count == 0
+	}, time.Second, 10*time.Millisecond)
+	require.NoError(t, s.Shutdown(context.Background()))
+}
@@ -0,0 +1,79 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"sso/internal/models"
+	"sso/internal/storage"
+	"sso/pkg/helpers/errorHelper"
+	"time"
+)
+
+type Tokens struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+const (
+	ErrorTokenNotFound = "Token not found"
+	ErrorInsertToken   = "Error on insert token row"
+	ErrorUpdateToken   = "Error on update token row"
+)
+
+func (t *Tokens) InsertToken(ctx context.Context, token *models.Token) (string, error) {
+	const operation = "internal.storage.sqlite.InsertToken()"
+	ctx, cancel := storage.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	id := newId()
+	_, err := t.db.ExecContext(ctx, `INSERT INTO tokens (id, hash, user_id, client_id, scope, family, auth_time, acr, create_at, valid_until, used, revoked)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		id, token.Hash, token.User, token.ClientId, token.Scope, token.Family, toMillis(token.AuthTime), token.Acr,
+		toMillis(token.CreateAt), toMillis(token.ValidUntil), token.Used, token.Revoked)
+	if err != nil {
+		return "", errorHelper.WrapError(operation, ErrorInsertToken, mapError(ctx, err))
+	}
+	return id, nil
+}
+
+func (t *Tokens) GetToken(ctx context.Context, hash string) (*models.Token, error) {
+	const operation = "internal.storage.sqlite.GetToken()"
+	ctx, cancel := storage.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	token := models.Token{}
+	var authTime, createAt, validUntil int64
+	err := t.db.QueryRowContext(ctx, `SELECT id, hash, user_id, client_id, scope, family, auth_time, acr, create_at, valid_until, used, revoked
+		FROM tokens WHERE hash = ?`, hash).
+		Scan(&token.Id, &token.Hash, &token.User, &token.ClientId, &token.Scope, &token.Family, &authTime, &token.Acr,
+			&createAt, &validUntil, &token.Used, &token.Revoked)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorTokenNotFound, mapError(ctx, err))
+	}
+	token.AuthTime, token.CreateAt, token.ValidUntil = fromMillis(authTime), fromMillis(createAt), fromMillis(validUntil)
+	return &token, nil
+}
+
+func (t *Tokens) UseToken(ctx context.Context, id string) (bool, error) {
+	const operation = "internal.storage.sqlite.UseToken()"
+	ctx, cancel := storage.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	//filter by "used" makes check-and-set atomic, only one of concurrent requests wins
+	res, err := t.db.ExecContext(ctx, `UPDATE tokens SET used = 1, used_at = ? WHERE id = ? AND used = 0`, toMillis(time.Now()), id)
+	if err != nil {
+		return false, errorHelper.WrapError(operation, ErrorUpdateToken, mapError(ctx, err))
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, errorHelper.WrapError(operation, ErrorUpdateToken, mapError(ctx, err))
+	}
+	return n == 1, nil
+}
+
+func (t *Tokens) RevokeFamily(ctx context.Context, family string) error {
+	const operation = "internal.storage.sqlite.RevokeFamily()"
+	ctx, cancel := storage.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	if _, err := t.db.ExecContext(ctx, `UPDATE tokens SET revoked = 1 WHERE family = ?`, family); err != nil {
+		return errorHelper.WrapError(operation, ErrorUpdateToken, mapError(ctx, err))
+	}
+	return nil
+}
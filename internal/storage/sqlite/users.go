@@ -0,0 +1,232 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"sso/internal/models"
+	"sso/internal/storage"
+	"sso/pkg/helpers/errorHelper"
+	"strings"
+	"time"
+)
+
+type Users struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+const (
+	ErrorUserNotFound = "User not found"
+	ErrorUserDecode   = "Error on decode user row"
+	ErrorInsertUser   = "Error on insert user row"
+	ErrorUpdateUser   = "Error on update user row"
+	ErrorDeleteUser   = "Error on delete user row"
+	ErrorFindUsers    = "Error on find users"
+)
+
+const userColumns = `id, login, password, name, email, email_verified, roles, groups, disabled,
+	totp_secret, totp_enabled, totp_step, recovery_codes`
+
+func scanUser(row scanner) (*models.User, error) {
+	user := models.User{}
+	var roles, groups, recoveryCodes string
+	err := row.Scan(&user.Id, &user.Login, &user.Password, &user.Name, &user.Email, &user.EmailVerified,
+		&roles, &groups, &user.Disabled,
+		&user.Mfa.TotpSecret, &user.Mfa.TotpEnabled, &user.Mfa.TotpStep, &recoveryCodes)
+	if err != nil {
+		return nil, err
+	}
+	if user.Roles, err = fromJson(roles); err != nil {
+		return nil, err
+	}
+	if user.Groups, err = fromJson(groups); err != nil {
+		return nil, err
+	}
+	if user.Mfa.RecoveryCodes, err = fromJson(recoveryCodes); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (s *Users) GetUser(ctx context.Context, login string) (*models.User, error) {
+	const operation = "internal.storage.sqlite.GetUser()"
+	ctx, cancel := storage.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.findOne(ctx, operation, `SELECT `+userColumns+` FROM users WHERE login = ?`, login)
+}
+
+func (s *Users) GetUserById(ctx context.Context, id string) (*models.User, error) {
+	const operation = "internal.storage.sqlite.GetUserById()"
+	ctx, cancel := storage.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.findOne(ctx, operation, `SELECT `+userColumns+` FROM users WHERE id = ?`, id)
+}
+
+func (s *Users) findOne(ctx context.Context, operation string, query string, args ...any) (*models.User, error) {
+	user, err := scanUser(s.db.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorUserNotFound, mapError(ctx, err))
+	}
+	return user, nil
+}
+
+func (s *Users) ListUsers(ctx context.Context, filter models.UserFilter) ([]*models.User, int64, error) {
+	const operation = "internal.storage.sqlite.ListUsers()"
+	ctx, cancel := storage.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	where, args := "", []any{}
+	if filter.Search != "" {
+		pattern := likePattern(filter.Search)
+		where = ` WHERE login LIKE ? ESCAPE '\' OR name LIKE ? ESCAPE '\' OR email LIKE ? ESCAPE '\'`
+		args = append(args, pattern, pattern, pattern)
+	}
+	var total int64
+	if err := s.db.QueryRowContext(ctx, `SELECT count(*) FROM users`+where, args...).Scan(&total); err != nil {
+		return nil, 0, errorHelper.WrapError(operation, ErrorFindUsers, mapError(ctx, err))
+	}
+	//LIMIT -1 is no limit like zero limit in Mongo
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = -1
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT `+userColumns+` FROM users`+where+` ORDER BY login LIMIT ? OFFSET ?`,
+		append(args, limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, errorHelper.WrapError(operation, ErrorFindUsers, mapError(ctx, err))
+	}
+	defer rows.Close()
+	users := make([]*models.User, 0, max(filter.Limit, 0))
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, errorHelper.WrapError(operation, ErrorUserDecode, mapError(ctx, err))
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, errorHelper.WrapError(operation, ErrorFindUsers, mapError(ctx, err))
+	}
+	return users, total, nil
+}
+
+func (s *Users) InsertUser(ctx context.Context, user *models.User) (string, error) {
+	const operation = "internal.storage.sqlite.InsertUser()"
+	ctx, cancel := storage.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	id := newId()
+	_, err := s.db.ExecContext(ctx, `INSERT INTO users (`+userColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		id, user.Login, user.Password, user.Name, user.Email, user.EmailVerified,
+		toJson(user.Roles), toJson(user.Groups), user.Disabled,
+		user.Mfa.TotpSecret, user.Mfa.TotpEnabled, user.Mfa.TotpStep, toJson(user.Mfa.RecoveryCodes))
+	if err != nil {
+		return "", errorHelper.WrapError(operation, ErrorInsertUser, mapError(ctx, err))
+	}
+	return id, nil
+}
+
+func (s *Users) UpdateUser(ctx context.Context, id string, update models.UserUpdate) error {
+	const operation = "internal.storage.sqlite.UpdateUser()"
+	ctx, cancel := storage.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	//only the set columns are written, so a concurrent UseMfaCode is not rolled back
+	columns := []string{"id = id"}
+	args := []any{}
+	add := func(column string, value any) {
+		columns = append(columns, column+" = ?")
+		args = append(args, value)
+	}
+	if update.Login != nil {
+		add("login", *update.Login)
+	}
+	if update.Password != nil {
+		add("password", *update.Password)
+	}
+	if update.Name != nil {
+		add("name", *update.Name)
+	}
+	if update.Email != nil {
+		add("email", *update.Email)
+	}
+	if update.EmailVerified != nil {
+		add("email_verified", *update.EmailVerified)
+	}
+	if update.Roles != nil {
+		add("roles", toJson(*update.Roles))
+	}
+	if update.Groups != nil {
+		add("groups", toJson(*update.Groups))
+	}
+	if update.Disabled != nil {
+		add("disabled", *update.Disabled)
+	}
+	if update.Mfa != nil {
+		add("totp_secret", update.Mfa.TotpSecret)
+		add("totp_enabled", update.Mfa.TotpEnabled)
+		add("totp_step", update.Mfa.TotpStep)
+		add("recovery_codes", toJson(update.Mfa.RecoveryCodes))
+	}
+	res, err := s.db.ExecContext(ctx, `UPDATE users SET `+strings.Join(columns, ", ")+` WHERE id = ?`, append(args, id)...)
+	if err != nil {
+		return errorHelper.WrapError(operation, ErrorUpdateUser, mapError(ctx, err))
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errorHelper.New(errorHelper.NotFound, operation, ErrorUserNotFound)
+	}
+	return nil
+}
+
+func (s *Users) UseMfaCode(ctx context.Context, id string, step int64, recoveryCode string) (bool, error) {
+	const operation = "internal.storage.sqlite.UseMfaCode()"
+	ctx, cancel := storage.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	//the condition and the change are one statement, concurrent requests with the same code can't both match
+	var res sql.Result
+	var err error
+	if recoveryCode != "" {
+		res, err = s.db.ExecContext(ctx, pullQuery("users", "recovery_codes")+` AND id = ?2`, recoveryCode, id)
+	} else {
+		res, err = s.db.ExecContext(ctx, `UPDATE users SET totp_step = ?1 WHERE id = ?2 AND totp_step < ?1`, step, id)
+	}
+	if err != nil {
+		return false, errorHelper.WrapError(operation, ErrorUpdateUser, mapError(ctx, err))
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, errorHelper.WrapError(operation, ErrorUpdateUser, mapError(ctx, err))
+	}
+	return n == 1, nil
+}
+
+func (s *Users) DeleteUser(ctx context.Context, id string) error {
+	const operation = "internal.storage.sqlite.DeleteUser()"
+	ctx, cancel := storage.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	res, err := s.db.ExecContext(ctx, `DELETE FROM users WHERE id = ?`, id)
+	if err != nil {
+		return errorHelper.WrapError(operation, ErrorDeleteUser, mapError(ctx, err))
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errorHelper.New(errorHelper.NotFound, operation, ErrorUserNotFound)
+	}
+	return nil
+}
+
+func (s *Users) RemoveRole(ctx context.Context, role string) error {
+	const operation = "internal.storage.sqlite.RemoveRole()"
+	ctx, cancel := storage.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	if _, err := s.db.ExecContext(ctx, pullQuery("users", "roles"), role); err != nil {
+		return errorHelper.WrapError(operation, ErrorUpdateUser, mapError(ctx, err))
+	}
+	return nil
+}
+
+func (s *Users) RemoveGroup(ctx context.Context, groupId string) error {
+	const operation = "internal.storage.sqlite.RemoveGroup()"
+	ctx, cancel := storage.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	if _, err := s.db.ExecContext(ctx, pullQuery("users", "groups"), groupId); err != nil {
+		return errorHelper.WrapError(operation, ErrorUpdateUser, mapError(ctx, err))
+	}
+	return nil
+}
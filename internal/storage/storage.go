@@ -1,20 +1,142 @@
 package storage
 
 import (
-	"crypto/rsa"
+	"context"
 	"sso/internal/models"
+	"time"
 )
 
 type Storage interface {
 	Users() Users
-	GetRsaKey() (*rsa.PrivateKey, error)
+	Keys() Keys
+	Tokens() Tokens
+	Revocations() Revocations
+	Clients() Clients
+	Codes() Codes
+	Roles() Roles
+	Permissions() Permissions
+	Groups() Groups
+	Credentials() Credentials
+	Attempts() Attempts
+}
+
+// Health is implemented by backends to report whether they can serve requests
+type Health interface {
+	// Ping checks the database is reachable
+	Ping(ctx context.Context) error
+	// Migrated returns an error if schema migrations failed on start
+	Migrated(ctx context.Context) error
 }
 
 type Users interface {
-	GetUser(login string) (*models.User, error)
-	InsertUser(user *models.User) (string, error)
+	GetUser(ctx context.Context, login string) (*models.User, error)
+	GetUserById(ctx context.Context, id string) (*models.User, error)
+	// ListUsers returns a page of users ordered by login and total count of matched users
+	ListUsers(ctx context.Context, filter models.UserFilter) ([]*models.User, int64, error)
+	InsertUser(ctx context.Context, user *models.User) (string, error)
+	// UpdateUser writes only the fields set in the update
+	UpdateUser(ctx context.Context, id string, update models.UserUpdate) error
+	// UseMfaCode atomically marks second factor code as used: recovery code hash is removed if present,
+	// otherwise TOTP step is stored if it is later than the stored one. Returns false if the code was already used.
+	UseMfaCode(ctx context.Context, id string, step int64, recoveryCode string) (bool, error)
+	DeleteUser(ctx context.Context, id string) error
+	// RemoveRole unassigns role from all users
+	RemoveRole(ctx context.Context, role string) error
+	// RemoveGroup removes group membership from all users
+	RemoveGroup(ctx context.Context, groupId string) error
+}
+
+type Keys interface {
+	// GetKeys returns all not retired keys ordered by activation time
+	GetKeys(ctx context.Context) ([]*models.Key, error)
+	InsertKey(ctx context.Context, key *models.Key) (string, error)
+}
+
+type Tokens interface {
+	InsertToken(ctx context.Context, token *models.Token) (string, error)
+	// GetToken returns refresh token by hash
+	GetToken(ctx context.Context, hash string) (*models.Token, error)
+	// UseToken marks not used token as used, returns false if the token was already used
+	UseToken(ctx context.Context, id string) (bool, error)
+	// RevokeFamily revokes all tokens of the family
+	RevokeFamily(ctx context.Context, family string) error
+}
+
+type Revocations interface {
+	// Revoke adds access token id to the denylist until exp
+	Revoke(ctx context.Context, jti string, exp time.Time) error
+	// GetRevoked returns not expired revocations made at or after since
+	GetRevoked(ctx context.Context, since time.Time) ([]*models.Revocation, error)
+}
+
+type Clients interface {
+	GetClient(ctx context.Context, clientId string) (*models.Client, error)
+	InsertClient(ctx context.Context, client *models.Client) (string, error)
+}
+
+type Codes interface {
+	InsertCode(ctx context.Context, code *models.AuthCode) (string, error)
+	// GetCode returns authorization code by hash
+	GetCode(ctx context.Context, hash string) (*models.AuthCode, error)
+	// UseCode marks not used code as used, returns false if the code was already used
+	UseCode(ctx context.Context, id string) (bool, error)
+}
+
+type Roles interface {
+	GetRole(ctx context.Context, name string) (*models.Role, error)
+	// GetRoles returns existing roles out of names
+	GetRoles(ctx context.Context, names []string) ([]*models.Role, error)
+	// ListRoles returns all roles ordered by name
+	ListRoles(ctx context.Context) ([]*models.Role, error)
+	InsertRole(ctx context.Context, role *models.Role) (string, error)
+	UpdateRole(ctx context.Context, role *models.Role) error
+	DeleteRole(ctx context.Context, name string) error
+	// RemovePermission removes permission from all roles
+	RemovePermission(ctx context.Context, permission string) error
+}
+
+type Permissions interface {
+	// GetPermissions returns existing permissions out of names
+	GetPermissions(ctx context.Context, names []string) ([]*models.Permission, error)
+	// ListPermissions returns all permissions ordered by name
+	ListPermissions(ctx context.Context) ([]*models.Permission, error)
+	InsertPermission(ctx context.Context, permission *models.Permission) (string, error)
+	DeletePermission(ctx context.Context, name string) error
+}
+
+type Groups interface {
+	GetGroup(ctx context.Context, id string) (*models.Group, error)
+	// GetGroups returns existing groups out of ids
+	GetGroups(ctx context.Context, ids []string) ([]*models.Group, error)
+	// ListGroups returns all groups ordered by name
+	ListGroups(ctx context.Context) ([]*models.Group, error)
+	InsertGroup(ctx context.Context, group *models.Group) (string, error)
+	UpdateGroup(ctx context.Context, group *models.Group) error
+	DeleteGroup(ctx context.Context, id string) error
+	// RemoveParent removes parent from all groups
+	RemoveParent(ctx context.Context, parentId string) error
+}
+
+type Credentials interface {
+	InsertCredential(ctx context.Context, credential *models.Credential) (string, error)
+	// GetCredential returns credential by its WebAuthn credential id
+	GetCredential(ctx context.Context, credentialId string) (*models.Credential, error)
+	GetUserCredentials(ctx context.Context, userId string) ([]*models.Credential, error)
+	// UseCredential sets new sign count if it is still signCount, returns false if it was changed concurrently
+	UseCredential(ctx context.Context, id string, signCount int64, newSignCount int64, usedAt time.Time) (bool, error)
+	DeleteCredential(ctx context.Context, userId string, id string) error
+	DeleteUserCredentials(ctx context.Context, userId string) error
+}
+
+type Attempts interface {
+	// GetAttempts returns not expired counter or errorHelper.NotFound error
+	GetAttempts(ctx context.Context, key string) (*models.Attempts, error)
+	// AddFailure atomically counts failed login, expired counter starts from one
+	AddFailure(ctx context.Context, key string, now time.Time, expireAt time.Time) (*models.Attempts, error)
+	LockAttempts(ctx context.Context, key string, until time.Time, expireAt time.Time) error
+	ResetAttempts(ctx context.Context, key string) error
 }
 
 type Migrations interface {
-	Migrate(rootPassword string) error
+	Migrate(ctx context.Context, rootPassword string) error
 }
@@ -0,0 +1,971 @@
+// Package storagetest is the conformance suite of storage.Storage implementations.
+// Every backend runs it from its own tests, so all backends are proven to behave the same.
+package storagetest
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"sso/internal/models"
+	"sso/internal/storage"
+	"sso/pkg/helpers/errorHelper"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Factory returns new empty storage, the factory registers its cleanup on t
+type Factory func(t *testing.T) storage.Storage
+
+// unknownId is a well-formed id no backend generates
+const unknownId = "000000000000000000000000"
+
+// concurrency is the number of goroutines racing in concurrent tests
+const concurrency = 16
+
+// Run runs the whole suite, each test gets its own storage
+func Run(t *testing.T, factory Factory) {
+	t.Run("Users", func(t *testing.T) { testUsers(t, factory(t)) })
+	t.Run("ListUsers", func(t *testing.T) { testListUsers(t, factory(t)) })
+	t.Run("Keys", func(t *testing.T) { testKeys(t, factory(t)) })
+	t.Run("Tokens", func(t *testing.T) { testTokens(t, factory(t)) })
+	t.Run("ConcurrentInserts", func(t *testing.T) { testConcurrentInserts(t, factory(t)) })
+	t.Run("ConcurrentUse", func(t *testing.T) { testConcurrentUse(t, factory(t)) })
+	t.Run("UseMfaCode", func(t *testing.T) { testUseMfaCode(t, factory(t)) })
+	t.Run("Roles", func(t *testing.T) { testRoles(t, factory(t)) })
+	t.Run("Permissions", func(t *testing.T) { testPermissions(t, factory(t)) })
+	t.Run("Groups", func(t *testing.T) { testGroups(t, factory(t)) })
+	t.Run("Credentials", func(t *testing.T) { testCredentials(t, factory(t)) })
+	t.Run("ConcurrentUseCredential", func(t *testing.T) { testConcurrentUseCredential(t, factory(t)) })
+	t.Run("Attempts", func(t *testing.T) { testAttempts(t, factory(t)) })
+	t.Run("ConcurrentAddFailure", func(t *testing.T) { testConcurrentAddFailure(t, factory(t)) })
+	t.Run("Codes", func(t *testing.T) { testCodes(t, factory(t)) })
+	t.Run("ConcurrentUseCode", func(t *testing.T) { testConcurrentUseCode(t, factory(t)) })
+	t.Run("Revocations", func(t *testing.T) { testRevocations(t, factory(t)) })
+	t.Run("Clients", func(t *testing.T) { testClients(t, factory(t)) })
+	t.Run("Canceled", func(t *testing.T) { testCanceled(t, factory(t)) })
+	t.Run("Health", func(t *testing.T) { testHealth(t, factory(t)) })
+}
+
+// now is truncated to milliseconds, the precision all backends keep
+func now() time.Time {
+	return time.Now().Truncate(time.Millisecond)
+}
+
+func requireTime(t *testing.T, want time.Time, got time.Time) {
+	t.Helper()
+	require.True(t, want.Equal(got), "want %s, got %s", want, got)
+}
+
+func testUsers(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	users := s.Users()
+	_, err := users.GetUser(ctx, "alice")
+	require.ErrorIs(t, err, errorHelper.NotFound)
+	_, err = users.GetUserById(ctx, unknownId)
+	require.ErrorIs(t, err, errorHelper.NotFound)
+	_, err = users.GetUserById(ctx, "unknown")
+	require.ErrorIs(t, err, errorHelper.NotFound)
+
+	alice := &models.User{
+		Login:         "alice",
+		Password:      "hash",
+		Name:          "Alice",
+		Email:         "alice@example.com",
+		EmailVerified: true,
+		Roles:         []string{"admin", "editor"},
+		Groups:        []string{"dev"},
+		Mfa:           models.Mfa{TotpSecret: "secret", TotpEnabled: true, TotpStep: 42, RecoveryCodes: []string{"code"}},
+	}
+	id, err := users.InsertUser(ctx, alice)
+	require.NoError(t, err)
+	require.NotEmpty(t, id)
+	alice.Id = id
+
+	got, err := users.GetUser(ctx, "alice")
+	require.NoError(t, err)
+	require.Equal(t, alice, got)
+	got, err = users.GetUserById(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, alice, got)
+
+	_, err = users.InsertUser(ctx, &models.User{Login: "alice", Password: "other"})
+	require.ErrorIs(t, err, errorHelper.Conflict)
+	bobId, err := users.InsertUser(ctx, &models.User{Login: "bob", Password: "hash", Roles: []string{"editor"}})
+	require.NoError(t, err)
+	require.NotEqual(t, id, bobId)
+
+	alice.Name, alice.Disabled, alice.Roles = "Alice Liddell", true, []string{"admin"}
+	require.NoError(t, users.UpdateUser(ctx, id, models.UserUpdate{Name: &alice.Name, Disabled: &alice.Disabled, Roles: &alice.Roles}))
+	got, err = users.GetUserById(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, alice, got)
+	require.NoError(t, users.UpdateUser(ctx, id, models.UserUpdate{}))
+	alice.Mfa = models.Mfa{TotpSecret: "new"}
+	require.NoError(t, users.UpdateUser(ctx, id, models.UserUpdate{Mfa: &alice.Mfa}))
+	got, err = users.GetUserById(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, alice.Mfa.TotpSecret, got.Mfa.TotpSecret)
+	require.False(t, got.Mfa.TotpEnabled)
+	require.Zero(t, got.Mfa.TotpStep)
+	require.Empty(t, got.Mfa.RecoveryCodes)
+
+	login := "alice"
+	require.ErrorIs(t, users.UpdateUser(ctx, bobId, models.UserUpdate{Login: &login}), errorHelper.Conflict)
+	require.NoError(t, users.UpdateUser(ctx, id, models.UserUpdate{Login: &login}))
+	require.ErrorIs(t, users.UpdateUser(ctx, unknownId, models.UserUpdate{Login: &login}), errorHelper.NotFound)
+	require.ErrorIs(t, users.UpdateUser(ctx, unknownId, models.UserUpdate{}), errorHelper.NotFound)
+
+	require.NoError(t, users.RemoveRole(ctx, "editor"))
+	bob, err := users.GetUserById(ctx, bobId)
+	require.NoError(t, err)
+	require.Empty(t, bob.Roles)
+	require.NoError(t, users.RemoveGroup(ctx, "dev"))
+	got, err = users.GetUserById(ctx, id)
+	require.NoError(t, err)
+	require.Empty(t, got.Groups)
+	require.Equal(t, []string{"admin"}, got.Roles)
+
+	require.NoError(t, users.DeleteUser(ctx, id))
+	_, err = users.GetUserById(ctx, id)
+	require.ErrorIs(t, err, errorHelper.NotFound)
+	_, err = users.GetUser(ctx, "alice")
+	require.ErrorIs(t, err, errorHelper.NotFound)
+	require.ErrorIs(t, users.DeleteUser(ctx, id), errorHelper.NotFound)
+	require.ErrorIs(t, users.DeleteUser(ctx, "unknown"), errorHelper.NotFound)
+}
+
+func testListUsers(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	users := s.Users()
+	list, total, err := users.ListUsers(ctx, models.UserFilter{})
+	require.NoError(t, err)
+	require.Empty(t, list)
+	require.Zero(t, total)
+
+	for _, u := range []*models.User{
+		{Login: "dave", Email: "dave@example.org"},
+		{Login: "alice", Name: "Alice"},
+		{Login: "carol", Email: "carol@EXAMPLE.com"},
+		{Login: "bob", Name: "Bob Example"},
+		{Login: "100%_sure"},
+	} {
+		_, err := users.InsertUser(ctx, u)
+		require.NoError(t, err)
+	}
+	logins := func(list []*models.User) []string {
+		res := make([]string, 0, len(list))
+		for _, u := range list {
+			res = append(res, u.Login)
+		}
+		return res
+	}
+
+	//zero limit is no limit
+	list, total, err = users.ListUsers(ctx, models.UserFilter{})
+	require.NoError(t, err)
+	require.Equal(t, int64(5), total)
+	require.Equal(t, []string{"100%_sure", "alice", "bob", "carol", "dave"}, logins(list))
+
+	list, total, err = users.ListUsers(ctx, models.UserFilter{Offset: 1, Limit: 2})
+	require.NoError(t, err)
+	require.Equal(t, int64(5), total)
+	require.Equal(t, []string{"alice", "bob"}, logins(list))
+
+	list, total, err = users.ListUsers(ctx, models.UserFilter{Offset: 10, Limit: 2})
+	require.NoError(t, err)
+	require.Equal(t, int64(5), total)
+	require.Empty(t, list)
+
+	//search is a case-insensitive substring of login, name or email
+	list, total, err = users.ListUsers(ctx, models.UserFilter{Search: "example"})
+	require.NoError(t, err)
+	require.Equal(t, int64(3), total)
+	require.Equal(t, []string{"bob", "carol", "dave"}, logins(list))
+
+	list, total, err = users.ListUsers(ctx, models.UserFilter{Search: "ALI", Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), total)
+	require.Equal(t, []string{"alice"}, logins(list))
+
+	//special characters of the search are matched literally
+	list, _, err = users.ListUsers(ctx, models.UserFilter{Search: "%_"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"100%_sure"}, logins(list))
+	list, _, err = users.ListUsers(ctx, models.UserFilter{Search: ".*"})
+	require.NoError(t, err)
+	require.Empty(t, list)
+}
+
+func testKeys(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	keys := s.Keys()
+	list, err := keys.GetKeys(ctx)
+	require.NoError(t, err)
+	require.Empty(t, list)
+
+	t0 := now()
+	insert := func(kid string, activateAt time.Time, exp time.Time) *models.Key {
+		key := &models.Key{
+			Kid:        kid,
+			PEM:        []byte("pem of " + kid),
+			CreatedAt:  t0,
+			ActivateAt: activateAt,
+			RetireAt:   activateAt.Add(time.Hour),
+			Exp:        exp,
+		}
+		id, err := keys.InsertKey(ctx, key)
+		require.NoError(t, err)
+		require.NotEmpty(t, id)
+		key.Id = id
+		return key
+	}
+	next := insert("next", t0.Add(time.Hour), t0.Add(3*time.Hour))
+	insert("expired", t0.Add(-3*time.Hour), t0.Add(-time.Hour))
+	active := insert("active", t0.Add(-time.Hour), t0.Add(2*time.Hour))
+
+	//expired keys are not returned, the rest are ordered by activation
+	list, err = keys.GetKeys(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	for i, want := range []*models.Key{active, next} {
+		got := list[i]
+		require.Equal(t, want.Id, got.Id)
+		require.Equal(t, want.Kid, got.Kid)
+		require.Equal(t, want.PEM, got.PEM)
+		requireTime(t, want.CreatedAt, got.CreatedAt)
+		requireTime(t, want.ActivateAt, got.ActivateAt)
+		requireTime(t, want.RetireAt, got.RetireAt)
+		requireTime(t, want.Exp, got.Exp)
+	}
+}
+
+func testTokens(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	tokens := s.Tokens()
+	_, err := tokens.GetToken(ctx, "unknown")
+	require.ErrorIs(t, err, errorHelper.NotFound)
+
+	t0 := now()
+	token := &models.Token{
+		Hash:       "hash1",
+		User:       "user",
+		ClientId:   "client",
+		Scope:      "openid profile",
+		Family:     "family1",
+		AuthTime:   t0.Add(-time.Minute),
+		Acr:        "mfa",
+		CreateAt:   t0,
+		ValidUntil: t0.Add(time.Hour),
+	}
+	id, err := tokens.InsertToken(ctx, token)
+	require.NoError(t, err)
+	require.NotEmpty(t, id)
+
+	got, err := tokens.GetToken(ctx, "hash1")
+	require.NoError(t, err)
+	require.Equal(t, id, got.Id)
+	require.Equal(t, token.Hash, got.Hash)
+	require.Equal(t, token.User, got.User)
+	require.Equal(t, token.ClientId, got.ClientId)
+	require.Equal(t, token.Scope, got.Scope)
+	require.Equal(t, token.Family, got.Family)
+	require.Equal(t, token.Acr, got.Acr)
+	requireTime(t, token.AuthTime, got.AuthTime)
+	requireTime(t, token.CreateAt, got.CreateAt)
+	requireTime(t, token.ValidUntil, got.ValidUntil)
+	require.False(t, got.Used)
+	require.False(t, got.Revoked)
+
+	dup := *token
+	_, err = tokens.InsertToken(ctx, &dup)
+	require.ErrorIs(t, err, errorHelper.Conflict)
+
+	//a token is used once
+	used, err := tokens.UseToken(ctx, id)
+	require.NoError(t, err)
+	require.True(t, used)
+	used, err = tokens.UseToken(ctx, id)
+	require.NoError(t, err)
+	require.False(t, used)
+	got, err = tokens.GetToken(ctx, "hash1")
+	require.NoError(t, err)
+	require.True(t, got.Used)
+
+	//revocation affects the family only
+	sibling := *token
+	sibling.Hash = "hash2"
+	_, err = tokens.InsertToken(ctx, &sibling)
+	require.NoError(t, err)
+	other := *token
+	other.Hash, other.Family = "hash3", "family2"
+	_, err = tokens.InsertToken(ctx, &other)
+	require.NoError(t, err)
+	require.NoError(t, tokens.RevokeFamily(ctx, "family1"))
+	for hash, revoked := range map[string]bool{"hash1": true, "hash2": true, "hash3": false} {
+		got, err := tokens.GetToken(ctx, hash)
+		require.NoError(t, err)
+		require.Equal(t, revoked, got.Revoked, hash)
+	}
+}
+
+func testConcurrentInserts(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	users := s.Users()
+	var wg sync.WaitGroup
+	ids := make([]string, concurrency)
+	errs := make([]error, concurrency)
+	//the same login, exactly one insert wins
+	for i := range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ids[i], errs[i] = users.InsertUser(ctx, &models.User{Login: "same", Password: fmt.Sprint(i)})
+		}()
+	}
+	wg.Wait()
+	inserted := 0
+	for i, err := range errs {
+		if err == nil {
+			inserted++
+			require.NotEmpty(t, ids[i])
+			continue
+		}
+		require.ErrorIs(t, err, errorHelper.Conflict)
+	}
+	require.Equal(t, 1, inserted)
+
+	//distinct logins, all inserts win with distinct ids
+	for i := range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ids[i], errs[i] = users.InsertUser(ctx, &models.User{Login: fmt.Sprintf("user%02d", i)})
+		}()
+	}
+	wg.Wait()
+	seen := make(map[string]bool)
+	for i, err := range errs {
+		require.NoError(t, err)
+		require.False(t, seen[ids[i]], "duplicate id %s", ids[i])
+		seen[ids[i]] = true
+	}
+	_, total, err := users.ListUsers(ctx, models.UserFilter{})
+	require.NoError(t, err)
+	require.Equal(t, int64(concurrency+1), total)
+}
+
+func testConcurrentUse(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	tokens := s.Tokens()
+	id, err := tokens.InsertToken(ctx, &models.Token{Hash: "hash", Family: "family", ValidUntil: now().Add(time.Hour)})
+	require.NoError(t, err)
+	var wg sync.WaitGroup
+	used := make([]bool, concurrency)
+	errs := make([]error, concurrency)
+	for i := range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			used[i], errs[i] = tokens.UseToken(ctx, id)
+		}()
+	}
+	wg.Wait()
+	wins := 0
+	for i, err := range errs {
+		require.NoError(t, err)
+		if used[i] {
+			wins++
+		}
+	}
+	require.Equal(t, 1, wins, "a token must be used once")
+}
+
+func testUseMfaCode(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	users := s.Users()
+	id, err := users.InsertUser(ctx, &models.User{
+		Login: "alice",
+		Mfa:   models.Mfa{TotpSecret: "secret", TotpEnabled: true, TotpStep: 42, RecoveryCodes: []string{"a", "b"}},
+	})
+	require.NoError(t, err)
+
+	//steps are accepted only in increasing order
+	for _, step := range []int64{41, 42} {
+		ok, err := users.UseMfaCode(ctx, id, step, "")
+		require.NoError(t, err)
+		require.False(t, ok, "step %d", step)
+	}
+	ok, err := users.UseMfaCode(ctx, id, 44, "")
+	require.NoError(t, err)
+	require.True(t, ok)
+	ok, err = users.UseMfaCode(ctx, id, 43, "")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	ok, err = users.UseMfaCode(ctx, id, 0, "c")
+	require.NoError(t, err)
+	require.False(t, ok)
+	for _, code := range []string{"a", "b"} {
+		ok, err = users.UseMfaCode(ctx, id, 0, code)
+		require.NoError(t, err)
+		require.True(t, ok)
+		ok, err = users.UseMfaCode(ctx, id, 0, code)
+		require.NoError(t, err)
+		require.False(t, ok)
+	}
+	got, err := users.GetUserById(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, int64(44), got.Mfa.TotpStep)
+	require.Empty(t, got.Mfa.RecoveryCodes)
+	require.True(t, got.Mfa.TotpEnabled)
+
+	//an update of other fields does not restore used codes
+	name, roles := "Alice", []string{"editor"}
+	require.NoError(t, users.UpdateUser(ctx, id, models.UserUpdate{Name: &name, Roles: &roles}))
+	got, err = users.GetUserById(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, name, got.Name)
+	require.Equal(t, int64(44), got.Mfa.TotpStep)
+	require.Empty(t, got.Mfa.RecoveryCodes)
+
+	//one of concurrent requests with the same code wins
+	var wg sync.WaitGroup
+	used := make([]bool, concurrency)
+	errs := make([]error, concurrency)
+	for i := range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			used[i], errs[i] = users.UseMfaCode(ctx, id, 45, "")
+		}()
+	}
+	wg.Wait()
+	wins := 0
+	for i, err := range errs {
+		require.NoError(t, err)
+		if used[i] {
+			wins++
+		}
+	}
+	require.Equal(t, 1, wins, "a code must be used once")
+}
+
+func testRoles(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	roles := s.Roles()
+	_, err := roles.GetRole(ctx, "editor")
+	require.ErrorIs(t, err, errorHelper.NotFound)
+	//every backend starts with the admin role
+	admin, err := roles.GetRole(ctx, models.RoleAdmin)
+	require.NoError(t, err)
+	require.Equal(t, models.RoleAdmin, admin.Name)
+
+	editor := &models.Role{Name: "editor", Description: "Edits", Permissions: []string{"read", "write"}}
+	id, err := roles.InsertRole(ctx, editor)
+	require.NoError(t, err)
+	require.NotEmpty(t, id)
+	editor.Id = id
+	got, err := roles.GetRole(ctx, "editor")
+	require.NoError(t, err)
+	require.Equal(t, editor, got)
+	_, err = roles.InsertRole(ctx, &models.Role{Name: "editor"})
+	require.ErrorIs(t, err, errorHelper.Conflict)
+	_, err = roles.InsertRole(ctx, &models.Role{Name: "viewer", Permissions: []string{"read"}})
+	require.NoError(t, err)
+
+	names := func(list []*models.Role) []string {
+		res := make([]string, 0, len(list))
+		for _, r := range list {
+			res = append(res, r.Name)
+		}
+		return res
+	}
+	list, err := roles.ListRoles(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"admin", "editor", "viewer"}, names(list))
+	//unknown names are skipped
+	list, err = roles.GetRoles(ctx, []string{"viewer", "unknown", "editor"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"editor", "viewer"}, names(list))
+	list, err = roles.GetRoles(ctx, nil)
+	require.NoError(t, err)
+	require.Empty(t, list)
+
+	//the role is found by name
+	require.NoError(t, roles.UpdateRole(ctx, &models.Role{Name: "editor", Description: "Writes", Permissions: []string{"write"}}))
+	got, err = roles.GetRole(ctx, "editor")
+	require.NoError(t, err)
+	require.Equal(t, &models.Role{Id: id, Name: "editor", Description: "Writes", Permissions: []string{"write"}}, got)
+	require.ErrorIs(t, roles.UpdateRole(ctx, &models.Role{Name: "unknown"}), errorHelper.NotFound)
+
+	require.NoError(t, roles.RemovePermission(ctx, "write"))
+	got, err = roles.GetRole(ctx, "editor")
+	require.NoError(t, err)
+	require.Empty(t, got.Permissions)
+	got, err = roles.GetRole(ctx, "viewer")
+	require.NoError(t, err)
+	require.Equal(t, []string{"read"}, got.Permissions)
+
+	require.NoError(t, roles.DeleteRole(ctx, "editor"))
+	_, err = roles.GetRole(ctx, "editor")
+	require.ErrorIs(t, err, errorHelper.NotFound)
+	require.ErrorIs(t, roles.DeleteRole(ctx, "editor"), errorHelper.NotFound)
+}
+
+func testPermissions(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	permissions := s.Permissions()
+	list, err := permissions.ListPermissions(ctx)
+	require.NoError(t, err)
+	require.Empty(t, list)
+
+	write := &models.Permission{Name: "write", Description: "Writes"}
+	id, err := permissions.InsertPermission(ctx, write)
+	require.NoError(t, err)
+	require.NotEmpty(t, id)
+	write.Id = id
+	_, err = permissions.InsertPermission(ctx, &models.Permission{Name: "write"})
+	require.ErrorIs(t, err, errorHelper.Conflict)
+	readId, err := permissions.InsertPermission(ctx, &models.Permission{Name: "read", Description: "Reads"})
+	require.NoError(t, err)
+	read := &models.Permission{Id: readId, Name: "read", Description: "Reads"}
+
+	list, err = permissions.ListPermissions(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []*models.Permission{read, write}, list)
+	//unknown names are skipped
+	list, err = permissions.GetPermissions(ctx, []string{"write", "unknown"})
+	require.NoError(t, err)
+	require.Equal(t, []*models.Permission{write}, list)
+
+	require.NoError(t, permissions.DeletePermission(ctx, "write"))
+	list, err = permissions.ListPermissions(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []*models.Permission{read}, list)
+	require.ErrorIs(t, permissions.DeletePermission(ctx, "write"), errorHelper.NotFound)
+}
+
+func testGroups(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	groups := s.Groups()
+	_, err := groups.GetGroup(ctx, unknownId)
+	require.ErrorIs(t, err, errorHelper.NotFound)
+	_, err = groups.GetGroup(ctx, "unknown")
+	require.ErrorIs(t, err, errorHelper.NotFound)
+
+	orgId, err := groups.InsertGroup(ctx, &models.Group{Name: "org", Description: "Everyone"})
+	require.NoError(t, err)
+	dev := &models.Group{Name: "dev", Description: "Developers", Parents: []string{orgId}}
+	devId, err := groups.InsertGroup(ctx, dev)
+	require.NoError(t, err)
+	require.NotEqual(t, orgId, devId)
+	dev.Id = devId
+	got, err := groups.GetGroup(ctx, devId)
+	require.NoError(t, err)
+	require.Equal(t, dev, got)
+	_, err = groups.InsertGroup(ctx, &models.Group{Name: "dev"})
+	require.ErrorIs(t, err, errorHelper.Conflict)
+	opsId, err := groups.InsertGroup(ctx, &models.Group{Name: "ops", Parents: []string{orgId}})
+	require.NoError(t, err)
+
+	names := func(list []*models.Group) []string {
+		res := make([]string, 0, len(list))
+		for _, g := range list {
+			res = append(res, g.Name)
+		}
+		return res
+	}
+	list, err := groups.ListGroups(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"dev", "ops", "org"}, names(list))
+	//unknown ids are skipped
+	list, err = groups.GetGroups(ctx, []string{opsId, unknownId, devId})
+	require.NoError(t, err)
+	require.Equal(t, []string{"dev", "ops"}, names(list))
+
+	//groups are referenced by id, so a group is renamed freely
+	dev.Name, dev.Description = "developers", "Renamed"
+	require.NoError(t, groups.UpdateGroup(ctx, dev))
+	got, err = groups.GetGroup(ctx, devId)
+	require.NoError(t, err)
+	require.Equal(t, dev, got)
+	require.ErrorIs(t, groups.UpdateGroup(ctx, &models.Group{Id: opsId, Name: "developers"}), errorHelper.Conflict)
+	require.ErrorIs(t, groups.UpdateGroup(ctx, &models.Group{Id: unknownId, Name: "qa"}), errorHelper.NotFound)
+
+	require.NoError(t, groups.RemoveParent(ctx, orgId))
+	list, err = groups.ListGroups(ctx)
+	require.NoError(t, err)
+	for _, g := range list {
+		require.Empty(t, g.Parents, g.Name)
+	}
+
+	require.NoError(t, groups.DeleteGroup(ctx, devId))
+	_, err = groups.GetGroup(ctx, devId)
+	require.ErrorIs(t, err, errorHelper.NotFound)
+	require.ErrorIs(t, groups.DeleteGroup(ctx, devId), errorHelper.NotFound)
+}
+
+func testCredentials(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	credentials := s.Credentials()
+	_, err := credentials.GetCredential(ctx, "unknown")
+	require.ErrorIs(t, err, errorHelper.NotFound)
+
+	t0 := now()
+	insert := func(userId string, credentialId string, createdAt time.Time) *models.Credential {
+		credential := &models.Credential{
+			UserId:       userId,
+			CredentialId: credentialId,
+			PublicKey:    []byte("key of " + credentialId),
+			SignCount:    1,
+			Aaguid:       []byte("aaguid"),
+			Name:         "key " + credentialId,
+			CreatedAt:    createdAt,
+		}
+		id, err := credentials.InsertCredential(ctx, credential)
+		require.NoError(t, err)
+		require.NotEmpty(t, id)
+		credential.Id = id
+		return credential
+	}
+	second := insert("alice", "cred2", t0)
+	first := insert("alice", "cred1", t0.Add(-time.Hour))
+	insert("bob", "cred3", t0)
+	_, err = credentials.InsertCredential(ctx, &models.Credential{UserId: "bob", CredentialId: "cred1", PublicKey: []byte("other key")})
+	require.ErrorIs(t, err, errorHelper.Conflict)
+
+	got, err := credentials.GetCredential(ctx, "cred1")
+	require.NoError(t, err)
+	require.Equal(t, first.Id, got.Id)
+	require.Equal(t, first.UserId, got.UserId)
+	require.Equal(t, first.PublicKey, got.PublicKey)
+	require.Equal(t, first.SignCount, got.SignCount)
+	require.Equal(t, first.Aaguid, got.Aaguid)
+	require.Equal(t, first.Name, got.Name)
+	requireTime(t, first.CreatedAt, got.CreatedAt)
+
+	//credentials of the user are ordered by creation
+	list, err := credentials.GetUserCredentials(ctx, "alice")
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	require.Equal(t, first.Id, list[0].Id)
+	require.Equal(t, second.Id, list[1].Id)
+	list, err = credentials.GetUserCredentials(ctx, "carol")
+	require.NoError(t, err)
+	require.Empty(t, list)
+
+	//the sign count is compared and set
+	t1 := t0.Add(time.Minute)
+	ok, err := credentials.UseCredential(ctx, first.Id, 0, 5, t1)
+	require.NoError(t, err)
+	require.False(t, ok)
+	ok, err = credentials.UseCredential(ctx, first.Id, 1, 5, t1)
+	require.NoError(t, err)
+	require.True(t, ok)
+	ok, err = credentials.UseCredential(ctx, first.Id, 1, 6, t1)
+	require.NoError(t, err)
+	require.False(t, ok)
+	ok, err = credentials.UseCredential(ctx, unknownId, 1, 2, t1)
+	require.NoError(t, err)
+	require.False(t, ok)
+	got, err = credentials.GetCredential(ctx, "cred1")
+	require.NoError(t, err)
+	require.Equal(t, int64(5), got.SignCount)
+	requireTime(t, t1, got.LastUsedAt)
+
+	//a credential is deleted by its owner only
+	require.ErrorIs(t, credentials.DeleteCredential(ctx, "bob", first.Id), errorHelper.NotFound)
+	require.NoError(t, credentials.DeleteCredential(ctx, "alice", first.Id))
+	_, err = credentials.GetCredential(ctx, "cred1")
+	require.ErrorIs(t, err, errorHelper.NotFound)
+	require.ErrorIs(t, credentials.DeleteCredential(ctx, "alice", first.Id), errorHelper.NotFound)
+
+	require.NoError(t, credentials.DeleteUserCredentials(ctx, "alice"))
+	list, err = credentials.GetUserCredentials(ctx, "alice")
+	require.NoError(t, err)
+	require.Empty(t, list)
+	list, err = credentials.GetUserCredentials(ctx, "bob")
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+}
+
+func testConcurrentUseCredential(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	credentials := s.Credentials()
+	id, err := credentials.InsertCredential(ctx, &models.Credential{UserId: "alice", CredentialId: "cred", PublicKey: []byte("key"), SignCount: 1, CreatedAt: now()})
+	require.NoError(t, err)
+	var wg sync.WaitGroup
+	used := make([]bool, concurrency)
+	errs := make([]error, concurrency)
+	for i := range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			used[i], errs[i] = credentials.UseCredential(ctx, id, 1, int64(i+2), now())
+		}()
+	}
+	wg.Wait()
+	wins := 0
+	for i, err := range errs {
+		require.NoError(t, err)
+		if used[i] {
+			wins++
+		}
+	}
+	require.Equal(t, 1, wins, "a sign count must be used once")
+}
+
+func testAttempts(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	attempts := s.Attempts()
+	_, err := attempts.GetAttempts(ctx, "account:alice")
+	require.ErrorIs(t, err, errorHelper.NotFound)
+	//locking a missing counter does nothing
+	t0 := now()
+	require.NoError(t, attempts.LockAttempts(ctx, "account:alice", t0.Add(time.Minute), t0.Add(time.Hour)))
+	_, err = attempts.GetAttempts(ctx, "account:alice")
+	require.ErrorIs(t, err, errorHelper.NotFound)
+
+	got, err := attempts.AddFailure(ctx, "account:alice", t0, t0.Add(time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, 1, got.Failures)
+	t1 := t0.Add(time.Second)
+	got, err = attempts.AddFailure(ctx, "account:alice", t1, t1.Add(time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, 2, got.Failures)
+	requireTime(t, t1, got.LastFailure)
+	requireTime(t, t1.Add(time.Hour), got.ExpireAt)
+
+	//the lock is never shortened
+	require.NoError(t, attempts.LockAttempts(ctx, "account:alice", t0.Add(2*time.Minute), t0.Add(2*time.Hour)))
+	require.NoError(t, attempts.LockAttempts(ctx, "account:alice", t0.Add(time.Minute), t0.Add(time.Hour)))
+	got, err = attempts.GetAttempts(ctx, "account:alice")
+	require.NoError(t, err)
+	require.Equal(t, "account:alice", got.Key)
+	require.Equal(t, 2, got.Failures)
+	requireTime(t, t1, got.LastFailure)
+	requireTime(t, t0.Add(2*time.Minute), got.LockedUntil)
+	requireTime(t, t0.Add(2*time.Hour), got.ExpireAt)
+
+	//counters are independent
+	got, err = attempts.AddFailure(ctx, "ip:127.0.0.1", t0, t0.Add(time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, 1, got.Failures)
+
+	require.NoError(t, attempts.ResetAttempts(ctx, "account:alice"))
+	_, err = attempts.GetAttempts(ctx, "account:alice")
+	require.ErrorIs(t, err, errorHelper.NotFound)
+	require.NoError(t, attempts.ResetAttempts(ctx, "account:alice"))
+	_, err = attempts.GetAttempts(ctx, "ip:127.0.0.1")
+	require.NoError(t, err)
+
+	//an expired counter is not returned and starts from one again
+	past := t0.Add(-2 * time.Hour)
+	_, err = attempts.AddFailure(ctx, "account:bob", past, past.Add(time.Hour))
+	require.NoError(t, err)
+	_, err = attempts.GetAttempts(ctx, "account:bob")
+	require.ErrorIs(t, err, errorHelper.NotFound)
+	got, err = attempts.AddFailure(ctx, "account:bob", t0, t0.Add(time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, 1, got.Failures)
+}
+
+func testConcurrentAddFailure(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	attempts := s.Attempts()
+	var wg sync.WaitGroup
+	errs := make([]error, concurrency)
+	for i := range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			t0 := now()
+			_, errs[i] = attempts.AddFailure(ctx, "account:alice", t0, t0.Add(time.Hour))
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+	got, err := attempts.GetAttempts(ctx, "account:alice")
+	require.NoError(t, err)
+	require.Equal(t, concurrency, got.Failures, "no failure may be lost")
+}
+
+func testCodes(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	codes := s.Codes()
+	_, err := codes.GetCode(ctx, "unknown")
+	require.ErrorIs(t, err, errorHelper.NotFound)
+
+	t0 := now()
+	code := &models.AuthCode{
+		Hash:          "hash",
+		ClientId:      "client",
+		User:          "user",
+		RedirectUri:   "https://app.example.com/callback",
+		Scope:         "openid",
+		CodeChallenge: "challenge",
+		Family:        "family",
+		Nonce:         "nonce",
+		AuthTime:      t0.Add(-time.Minute),
+		Acr:           "mfa",
+		CreateAt:      t0,
+		ValidUntil:    t0.Add(time.Minute),
+	}
+	id, err := codes.InsertCode(ctx, code)
+	require.NoError(t, err)
+	require.NotEmpty(t, id)
+	dup := *code
+	_, err = codes.InsertCode(ctx, &dup)
+	require.ErrorIs(t, err, errorHelper.Conflict)
+
+	got, err := codes.GetCode(ctx, "hash")
+	require.NoError(t, err)
+	require.Equal(t, id, got.Id)
+	require.Equal(t, code.ClientId, got.ClientId)
+	require.Equal(t, code.User, got.User)
+	require.Equal(t, code.RedirectUri, got.RedirectUri)
+	require.Equal(t, code.Scope, got.Scope)
+	require.Equal(t, code.CodeChallenge, got.CodeChallenge)
+	require.Equal(t, code.Family, got.Family)
+	require.Equal(t, code.Nonce, got.Nonce)
+	require.Equal(t, code.Acr, got.Acr)
+	requireTime(t, code.AuthTime, got.AuthTime)
+	requireTime(t, code.CreateAt, got.CreateAt)
+	requireTime(t, code.ValidUntil, got.ValidUntil)
+	require.False(t, got.Used)
+
+	//a code is used once
+	used, err := codes.UseCode(ctx, id)
+	require.NoError(t, err)
+	require.True(t, used)
+	used, err = codes.UseCode(ctx, id)
+	require.NoError(t, err)
+	require.False(t, used)
+	used, err = codes.UseCode(ctx, unknownId)
+	require.NoError(t, err)
+	require.False(t, used)
+	got, err = codes.GetCode(ctx, "hash")
+	require.NoError(t, err)
+	require.True(t, got.Used)
+}
+
+func testConcurrentUseCode(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	codes := s.Codes()
+	id, err := codes.InsertCode(ctx, &models.AuthCode{Hash: "hash", ValidUntil: now().Add(time.Minute)})
+	require.NoError(t, err)
+	var wg sync.WaitGroup
+	used := make([]bool, concurrency)
+	errs := make([]error, concurrency)
+	for i := range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			used[i], errs[i] = codes.UseCode(ctx, id)
+		}()
+	}
+	wg.Wait()
+	wins := 0
+	for i, err := range errs {
+		require.NoError(t, err)
+		if used[i] {
+			wins++
+		}
+	}
+	require.Equal(t, 1, wins, "a code must be used once")
+}
+
+func testRevocations(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	revocations := s.Revocations()
+	t0 := now()
+	list, err := revocations.GetRevoked(ctx, t0.Add(-time.Hour))
+	require.NoError(t, err)
+	require.Empty(t, list)
+
+	require.NoError(t, revocations.Revoke(ctx, "jti1", t0.Add(time.Hour)))
+	require.NoError(t, revocations.Revoke(ctx, "expired", t0.Add(-time.Second)))
+	//revocation is idempotent
+	require.NoError(t, revocations.Revoke(ctx, "jti1", t0.Add(2*time.Hour)))
+
+	//expired revocations are not returned
+	list, err = revocations.GetRevoked(ctx, t0.Add(-time.Hour))
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	require.Equal(t, "jti1", list[0].Jti)
+	requireTime(t, t0.Add(time.Hour), list[0].Exp)
+	require.False(t, list[0].RevokedAt.Before(t0), "revoked at %s", list[0].RevokedAt)
+
+	//only revocations since the time are returned
+	list, err = revocations.GetRevoked(ctx, t0.Add(time.Minute))
+	require.NoError(t, err)
+	require.Empty(t, list)
+}
+
+func testClients(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	clients := s.Clients()
+	_, err := clients.GetClient(ctx, "app")
+	require.ErrorIs(t, err, errorHelper.NotFound)
+
+	app := &models.Client{
+		ClientId:     "app",
+		Secret:       "hash",
+		Name:         "App",
+		RedirectUris: []string{"https://app.example.com/callback", "http://localhost:8080/callback"},
+		Scopes:       []string{"openid", "profile"},
+	}
+	id, err := clients.InsertClient(ctx, app)
+	require.NoError(t, err)
+	require.NotEmpty(t, id)
+	app.Id = id
+	got, err := clients.GetClient(ctx, "app")
+	require.NoError(t, err)
+	require.Equal(t, app, got)
+	require.False(t, got.IsPublic())
+
+	_, err = clients.InsertClient(ctx, &models.Client{ClientId: "app", Name: "Other"})
+	require.ErrorIs(t, err, errorHelper.Conflict)
+	spaId, err := clients.InsertClient(ctx, &models.Client{ClientId: "spa", Name: "SPA", RedirectUris: []string{"https://spa.example.com"}, Scopes: []string{"openid"}})
+	require.NoError(t, err)
+	require.NotEqual(t, id, spaId)
+	got, err = clients.GetClient(ctx, "spa")
+	require.NoError(t, err)
+	require.True(t, got.IsPublic())
+}
+
+func testCanceled(t *testing.T, s storage.Storage) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := s.Users().InsertUser(ctx, &models.User{Login: "alice"})
+	require.Equal(t, errorHelper.Canceled, errorHelper.KindOf(err))
+	require.ErrorIs(t, err, context.Canceled)
+	_, err = s.Users().GetUser(ctx, "alice")
+	require.Equal(t, errorHelper.Canceled, errorHelper.KindOf(err))
+	_, err = s.Keys().GetKeys(ctx)
+	require.Equal(t, errorHelper.Canceled, errorHelper.KindOf(err))
+
+	ctx, cancel = context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	_, err = s.Tokens().GetToken(ctx, "hash")
+	require.Equal(t, errorHelper.Timeout, errorHelper.KindOf(err))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	//nothing was written by canceled operations
+	_, err = s.Users().GetUser(context.Background(), "alice")
+	require.ErrorIs(t, err, errorHelper.NotFound)
+}
+
+func testHealth(t *testing.T, s storage.Storage) {
+	health, ok := s.(storage.Health)
+	require.True(t, ok, "storage must report its health")
+	require.NoError(t, health.Ping(context.Background()))
+	require.NoError(t, health.Migrated(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.Equal(t, errorHelper.Canceled, errorHelper.KindOf(health.Ping(ctx)))
+}
@@ -0,0 +1,62 @@
+package tracing
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"sync"
+)
+
+// commandKey identifies a command in flight, request ids are unique within a connection only
+type commandKey struct {
+	connection string
+	request    int64
+}
+
+// MongoMonitor creates client span of every MongoDB command of a traced operation.
+// Commands outside of traces, like background revocation sync, are not traced to avoid a root span per command.
+func MongoMonitor() *event.CommandMonitor {
+	var spans sync.Map //commandKey -> trace.Span
+	finish := func(e event.CommandFinishedEvent, failure string) {
+		key := commandKey{connection: e.ConnectionID, request: e.RequestID}
+		value, ok := spans.LoadAndDelete(key)
+		if !ok {
+			return
+		}
+		span := value.(trace.Span)
+		if failure != "" {
+			span.SetStatus(codes.Error, failure)
+		}
+		span.End()
+	}
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			if !trace.SpanContextFromContext(ctx).IsValid() {
+				return
+			}
+			attrs := []attribute.KeyValue{
+				semconv.DBSystemMongoDB,
+				semconv.DBNamespace(e.DatabaseName),
+				semconv.DBOperationName(e.CommandName),
+			}
+			name := e.CommandName
+			//the first element of a command is its name with the collection as value
+			if collection, ok := e.Command.Lookup(e.CommandName).StringValueOK(); ok {
+				attrs = append(attrs, semconv.DBCollectionName(collection))
+				name += " " + collection
+			}
+			_, span := otel.Tracer(instrumentation).Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+			spans.Store(commandKey{connection: e.ConnectionID, request: e.RequestID}, span)
+		},
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			finish(e.CommandFinishedEvent, "")
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			finish(e.CommandFinishedEvent, e.Failure)
+		},
+	}
+}
@@ -0,0 +1,100 @@
+// Package tracing configures OpenTelemetry tracing and holds the helpers services and storage create spans with.
+// Spans are no-op until Setup installs the exporting provider, trace context is propagated anyway.
+package tracing
+
+import (
+	"context"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/url"
+	"sso/internal/config"
+	"sso/pkg/helpers/errorHelper"
+)
+
+const (
+	ErrorParseEndpoint  = "Error on parse tracing endpoint"
+	ErrorCreateExporter = "Error on create OTLP exporter"
+	ErrorCreateResource = "Error on create tracing resource"
+)
+
+// instrumentation is the name of the tracer of the service spans
+const instrumentation = "sso"
+
+// tracesPath is the OTLP/HTTP path used if the endpoint has none
+const tracesPath = "/v1/traces"
+
+// KindKey is the attribute with errorHelper kind of the span error
+const KindKey = attribute.Key("error.kind")
+
+// Setup installs W3C trace context propagator and, if the endpoint is configured, the provider
+// exporting spans to OTLP collector. The returned function flushes and stops the exporter.
+func Setup(ctx context.Context, config config.TracingConfig) (func(context.Context) error, error) {
+	const operation = "internal.tracing.Setup()"
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if config.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	u, err := url.Parse(config.Endpoint)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorParseEndpoint, err)
+	}
+	options := []otlptracehttp.Option{otlptracehttp.WithEndpointURL(config.Endpoint)}
+	if u.Path == "" || u.Path == "/" {
+		options = append(options, otlptracehttp.WithURLPath(tracesPath))
+	}
+	exporter, err := otlptracehttp.New(ctx, options...)
+	if err != nil {
+		return nil, errorHelper.WrapError(operation, ErrorCreateExporter, err)
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(config.ServiceName)))
+	if err != nil {
+		_ = exporter.Shutdown(ctx)
+		return nil, errorHelper.WrapError(operation, ErrorCreateResource, err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Start starts span named after the operation as a child of the span in ctx
+func Start(ctx context.Context, operation string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	//the tracer is taken from the global provider on every call, so spans follow the provider installed by Setup
+	return otel.Tracer(instrumentation).Start(ctx, operation, trace.WithAttributes(attrs...))
+}
+
+// End ends span, err is recorded with its kind
+func End(span trace.Span, err error) {
+	Fail(span, err)
+	span.End()
+}
+
+// Fail marks span as failed with err, nil err is ignored
+func Fail(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	record(span, err)
+	span.SetStatus(codes.Error, errorHelper.KindOf(err).String())
+}
+
+// RecordError adds err with its kind to the span in ctx, the span status is left to the span owner.
+// Handlers use it for errors which may be client errors, the server span fails on 5xx responses only.
+func RecordError(ctx context.Context, err error) {
+	record(trace.SpanFromContext(ctx), err)
+}
+
+func record(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetAttributes(KindKey.String(errorHelper.KindOf(err).String()))
+}
@@ -0,0 +1,104 @@
+package tracing
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"net/http"
+	"net/http/httptest"
+	"sso/internal/config"
+	"sso/pkg/helpers/errorHelper"
+	"sync/atomic"
+	"testing"
+)
+
+// recordSpans installs provider recording ended spans for the test
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	previous := otel.GetTracerProvider()
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func TestSetup(t *testing.T) {
+	//the collector stand-in accepts OTLP/HTTP export requests
+	var exports atomic.Int32
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Path == tracesPath && r.Header.Get("Content-Type") == "application/x-protobuf" {
+			exports.Add(1)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer collector.Close()
+	previous := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(previous)
+
+	ctx := context.Background()
+	shutdown, err := Setup(ctx, config.TracingConfig{Endpoint: collector.URL, ServiceName: "sso", SampleRatio: 1})
+	require.NoError(t, err)
+	_, span := Start(ctx, "internal.services.auth")
+	End(span, errorHelper.New(errorHelper.InvalidCredentials, "test", "wrong password"))
+	//shutdown flushes the batch
+	require.NoError(t, shutdown(ctx))
+	require.Equal(t, int32(1), exports.Load())
+
+	shutdown, err = Setup(ctx, config.TracingConfig{})
+	require.NoError(t, err)
+	require.NoError(t, shutdown(ctx))
+}
+
+func TestEnd(t *testing.T) {
+	recorder := recordSpans(t)
+	ctx := context.Background()
+	_, span := Start(ctx, "ok")
+	End(span, nil)
+	_, span = Start(ctx, "failed")
+	End(span, errorHelper.WrapError("test", "failed get user", errorHelper.NotFound))
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	require.Equal(t, codes.Unset, spans[0].Status().Code)
+	require.Equal(t, codes.Error, spans[1].Status().Code)
+	require.Equal(t, errorHelper.NotFound.String(), spans[1].Status().Description)
+	require.Contains(t, spans[1].Attributes(), KindKey.String(errorHelper.NotFound.String()))
+	require.Len(t, spans[1].Events(), 1)
+}
+
+func TestMongoMonitor(t *testing.T) {
+	recorder := recordSpans(t)
+	monitor := MongoMonitor()
+	command, err := bson.Marshal(bson.D{{Key: "find", Value: "users"}})
+	require.NoError(t, err)
+	started := func(ctx context.Context, request int64) {
+		monitor.Started(ctx, &event.CommandStartedEvent{Command: command, DatabaseName: "SSO", CommandName: "find", RequestID: request, ConnectionID: "c1"})
+	}
+	finished := event.CommandFinishedEvent{CommandName: "find", DatabaseName: "SSO", ConnectionID: "c1"}
+
+	//commands outside of traces are not traced
+	started(context.Background(), 1)
+	finished.RequestID = 1
+	monitor.Succeeded(context.Background(), &event.CommandSucceededEvent{CommandFinishedEvent: finished})
+	require.Empty(t, recorder.Ended())
+
+	ctx, parent := Start(context.Background(), "parent")
+	defer parent.End()
+	started(ctx, 2)
+	started(ctx, 3)
+	finished.RequestID = 3
+	monitor.Failed(ctx, &event.CommandFailedEvent{CommandFinishedEvent: finished, Failure: "connection reset"})
+	finished.RequestID = 2
+	monitor.Succeeded(ctx, &event.CommandSucceededEvent{CommandFinishedEvent: finished})
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	require.Equal(t, "find users", spans[0].Name())
+	require.Equal(t, codes.Error, spans[0].Status().Code)
+	require.Equal(t, codes.Unset, spans[1].Status().Code)
+	require.Equal(t, parent.SpanContext().SpanID(), spans[1].Parent().SpanID())
+}
@@ -1,7 +1,139 @@
 package errorHelper
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
+// Kind classifies errors, callers branch on kinds instead of storage driver or message specific errors
+type Kind uint8
+
+const (
+	Internal           Kind = iota //unexpected failure, the default of unclassified errors
+	NotFound                       //requested entity does not exist
+	Conflict                       //entity already exists or is in a conflicting state
+	Invalid                        //input data is not valid
+	InvalidCredentials             //wrong login, password, code or token
+	Locked                         //temporarily locked after failed attempts
+	Expired                        //token, code or challenge is expired
+	Canceled                       //the caller gave up, context is canceled
+	Timeout                        //operation deadline is exceeded
+)
+
+var kindNames = [...]string{
+	Internal:           "internal error",
+	NotFound:           "not found",
+	Conflict:           "already exists",
+	Invalid:            "invalid data",
+	InvalidCredentials: "invalid credentials",
+	Locked:             "temporarily locked",
+	Expired:            "expired",
+	Canceled:           "canceled",
+	Timeout:            "timed out",
+}
+
+func (k Kind) String() string {
+	if int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return kindNames[Internal]
+}
+
+// Error makes Kind usable as an error without details and as errors.Is target
+func (k Kind) Error() string {
+	return k.String()
+}
+
+// Error is an error of Kind raised or passed through Operation
+type Error struct {
+	Kind      Kind
+	Operation string
+	Message   string
+	Err       error
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("%s in %s", e.Message, e.Operation)
+	}
+	return fmt.Sprintf("%s in %s -> `%s`", e.Message, e.Operation, e.Err)
+}
+
+// Unwrap returns the cause hidden from kind matching, errors.Is of a kind sees only the outermost kind
+func (e *Error) Unwrap() error {
+	if e.Err == nil {
+		return nil
+	}
+	return cause{e.Err}
+}
+
+// Is reports whether the error is of the target kind
+func (e *Error) Is(target error) bool {
+	kind, ok := target.(Kind)
+	return ok && kind == e.Kind
+}
+
+// cause is the error wrapped by Error. Kinds of the cause chain don't match,
+// a NotFound reclassified as InvalidCredentials is not NotFound anymore. Other targets do.
+type cause struct {
+	err error
+}
+
+func (c cause) Error() string {
+	return c.err.Error()
+}
+
+func (c cause) Is(target error) bool {
+	if _, ok := target.(Kind); ok {
+		return false
+	}
+	return errors.Is(c.err, target)
+}
+
+func (c cause) As(target any) bool {
+	return errors.As(c.err, target)
+}
+
+// New creates error of the kind without a cause
+func New(kind Kind, operation string, message string) error {
+	return &Error{Kind: kind, Operation: operation, Message: message}
+}
+
+// Wrap classifies err as the kind, the cause is kept for logs
+func Wrap(kind Kind, operation string, message string, err error) error {
+	return &Error{Kind: kind, Operation: operation, Message: message, Err: err}
+}
+
+// WrapError adds operation to the stack of err, the kind of err is kept
 func WrapError(operation string, message string, err error) error {
-	return fmt.Errorf("%s in %s -> `%w`", message, operation, err)
+	return &Error{Kind: KindOf(err), Operation: operation, Message: message, Err: err}
+}
+
+// KindOf returns the kind of the outermost classified error in the chain, unclassified errors are Internal
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	var kind Kind
+	if errors.As(err, &kind) {
+		return kind
+	}
+	return Internal
+}
+
+// Stack returns operations err has passed through, the outermost first
+func Stack(err error) []string {
+	var stack []string
+	for err != nil {
+		if e, ok := err.(*Error); ok {
+			stack = append(stack, e.Operation)
+		}
+		if c, ok := err.(cause); ok {
+			err = c.err
+		} else {
+			err = errors.Unwrap(err)
+		}
+	}
+	return stack
 }
@@ -0,0 +1,65 @@
+package errorHelper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"net"
+	"testing"
+)
+
+func TestKindOf(t *testing.T) {
+	require.Equal(t, Internal, KindOf(errors.New("driver error")))
+	require.Equal(t, Internal, KindOf(nil))
+	require.Equal(t, NotFound, KindOf(NotFound))
+
+	//WrapError keeps the kind of the cause
+	err := WrapError("storage.GetUser()", "user not found", NotFound)
+	err = WrapError("services.Get()", "failed get user", err)
+	require.Equal(t, NotFound, KindOf(err))
+	require.ErrorIs(t, err, NotFound)
+
+	//Wrap reclassifies, the outermost kind wins
+	err = Wrap(InvalidCredentials, "services.Login()", "wrong login", err)
+	err = WrapError("handlers.Auth()", "failed login", err)
+	require.Equal(t, InvalidCredentials, KindOf(err))
+	require.ErrorIs(t, err, InvalidCredentials)
+	require.NotErrorIs(t, err, NotFound)
+
+	err = WrapError("services.Get()", "failed get user", Wrap(Timeout, "storage.Canceled()", "timed out", context.DeadlineExceeded))
+	require.Equal(t, Timeout, KindOf(err))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestIsOutermostKind(t *testing.T) {
+	//only the kind KindOf returns matches, kinds of causes don't
+	err := Wrap(InvalidCredentials, "services.Login()", "wrong login", New(NotFound, "storage.GetUser()", "user not found"))
+	require.ErrorIs(t, err, InvalidCredentials)
+	require.NotErrorIs(t, err, NotFound)
+	err = Wrap(InvalidCredentials, "services.Login()", "wrong login", NotFound)
+	require.NotErrorIs(t, err, NotFound)
+	err = fmt.Errorf("login: %w", err)
+	require.ErrorIs(t, err, InvalidCredentials)
+	require.NotErrorIs(t, err, NotFound)
+
+	//causes other than kinds are still matched and found
+	driver := &net.OpError{Op: "dial", Err: context.Canceled}
+	err = Wrap(Internal, "services.Login()", "failed login", WrapError("storage.GetUser()", "user not found", driver))
+	require.ErrorIs(t, err, context.Canceled)
+	var opErr *net.OpError
+	require.ErrorAs(t, err, &opErr)
+	require.Same(t, driver, opErr)
+	var inner *Error
+	require.ErrorAs(t, err, &inner)
+	require.Equal(t, "services.Login()", inner.Operation)
+}
+
+func TestStack(t *testing.T) {
+	require.Empty(t, Stack(errors.New("driver error")))
+	err := New(Conflict, "storage.InsertUser()", "user exists")
+	err = WrapError("services.Add()", "failed add user", err)
+	err = WrapError("handlers.AddUser()", "failed add user", err)
+	require.Equal(t, []string{"handlers.AddUser()", "services.Add()", "storage.InsertUser()"}, Stack(err))
+	require.Equal(t, "failed add user in handlers.AddUser() -> `failed add user in services.Add() -> `user exists in storage.InsertUser()``", err.Error())
+}
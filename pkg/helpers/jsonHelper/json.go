@@ -33,12 +33,25 @@ func Encode(payload any) ([]byte, error) {
 }
 
 func WriteResponse(resp any, w http.ResponseWriter) error {
+	return WriteResponseCode(resp, http.StatusOK, w)
+}
+
+func WriteResponseCode(resp any, code int, w http.ResponseWriter) error {
+	return write(resp, code, "application/json", w)
+}
+
+// WriteProblem writes RFC 7807 problem details
+func WriteProblem(problem any, code int, w http.ResponseWriter) error {
+	return write(problem, code, "application/problem+json", w)
+}
+
+func write(resp any, code int, contentType string, w http.ResponseWriter) error {
 	const op = "pkg.helper.jsonHelper.WriteJsonResponse()"
 	if encode, err := Encode(resp); err != nil {
 		return errorHelper.WrapError(op, ErrorJsonEncode, err)
 	} else {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(code)
 		if _, err = w.Write(encode); err != nil {
 			return errorHelper.WrapError(op, ErrorWriteResponse, err)
 		}
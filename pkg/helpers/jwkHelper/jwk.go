@@ -0,0 +1,54 @@
+package jwkHelper
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"math/big"
+)
+
+const (
+	KeyTypeRSA   = "RSA"
+	UseSignature = "sig"
+	AlgRS256     = "RS256"
+)
+
+// Key is a public RSA key in RFC 7517 JWK format
+type Key struct {
+	Kty string `json:"kty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Kid string `json:"kid,omitempty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// Set is a RFC 7517 JWK Set
+type Set struct {
+	Keys []Key `json:"keys"`
+}
+
+func FromPublicKey(key *rsa.PublicKey) Key {
+	return Key{
+		Kty: KeyTypeRSA,
+		Use: UseSignature,
+		Alg: AlgRS256,
+		Kid: Thumbprint(key),
+		N:   encode(key.N.Bytes()),
+		E:   encode(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+// Thumbprint returns RFC 7638 SHA-256 thumbprint of the key, used as "kid"
+func Thumbprint(key *rsa.PublicKey) string {
+	//members must be in lexicographic order and without whitespaces
+	json := `{"e":"` + encode(big.NewInt(int64(key.E)).Bytes()) +
+		`","kty":"` + KeyTypeRSA +
+		`","n":"` + encode(key.N.Bytes()) + `"}`
+	hash := sha256.Sum256([]byte(json))
+	return encode(hash[:])
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
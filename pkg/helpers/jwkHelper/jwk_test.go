@@ -0,0 +1,36 @@
+package jwkHelper
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"testing"
+)
+
+// example key from RFC 7638 section 3.1
+const (
+	rfcModulus    = "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw"
+	rfcExponent   = "AQAB"
+	rfcThumbprint = "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"
+)
+
+func rfcKey(t *testing.T) *rsa.PublicKey {
+	n, err := base64.RawURLEncoding.DecodeString(rfcModulus)
+	require.NoError(t, err)
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: 65537}
+}
+
+func TestThumbprint(t *testing.T) {
+	require.Equal(t, rfcThumbprint, Thumbprint(rfcKey(t)))
+}
+
+func TestFromPublicKey(t *testing.T) {
+	k := FromPublicKey(rfcKey(t))
+	require.Equal(t, KeyTypeRSA, k.Kty)
+	require.Equal(t, UseSignature, k.Use)
+	require.Equal(t, AlgRS256, k.Alg)
+	require.Equal(t, rfcThumbprint, k.Kid)
+	require.Equal(t, rfcModulus, k.N)
+	require.Equal(t, rfcExponent, k.E)
+}
@@ -2,9 +2,11 @@ package jwtHelper
 
 import (
 	"crypto/rsa"
+	"errors"
 	"fmt"
 	"github.com/golang-jwt/jwt/v5"
 	errorHelper "sso/pkg/helpers/errorHelper"
+	"sso/pkg/helpers/jwkHelper"
 )
 
 const (
@@ -13,11 +15,15 @@ const (
 	ErrorInvalidToken = "token is invalid"
 	ErrorGetClaim     = "errorHelper on get claim"
 	ErrorParseToken   = "errorHelper on parse token"
+	ErrorGetKid       = "errorHelper on get kid"
+	ErrorTokenExpired = "token is expired"
 )
 
 func Create(key *rsa.PrivateKey, claim map[string]any) (string, error) {
 	const op = "pkg.helpers.jwtHelper.create()"
 	j := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims(claim))
+	//kid lets token consumers pick the right key from JWKS
+	j.Header["kid"] = jwkHelper.Thumbprint(&key.PublicKey)
 	if t, err := j.SignedString(key); err != nil {
 		return "", errorHelper.WrapError(op, ErrorCreateToken, err)
 	} else {
@@ -36,13 +42,15 @@ func GetClaim(key *rsa.PublicKey, tokenString string) (*jwt.MapClaims, error) {
 		// возвращаем указатель на публичный ключ
 		return key, nil
 
-	}); err != nil {
-		return nil, errorHelper.WrapError(op, ErrorParseToken, err)
+	}); errors.Is(err, jwt.ErrTokenExpired) {
+		return nil, errorHelper.Wrap(errorHelper.Expired, op, ErrorTokenExpired, err)
+	} else if err != nil {
+		return nil, errorHelper.Wrap(errorHelper.InvalidCredentials, op, ErrorParseToken, err)
 	} else {
 		if claims, ok := token.Claims.(jwt.MapClaims); ok {
 			return &claims, nil
 		} else {
-			return nil, errorHelper.WrapError(op, ErrorGetClaim, err)
+			return nil, errorHelper.New(errorHelper.InvalidCredentials, op, ErrorGetClaim)
 		}
 	}
 }
@@ -54,3 +62,19 @@ func Check(key *rsa.PublicKey, tokenString string) error {
 	}
 	return nil
 }
+
+// GetKid returns "kid" header of the token without signature verification
+func GetKid(tokenString string) (string, error) {
+	const op = "pkg.helpers.jwtHelper.getKid()"
+	token, _, err := jwt.NewParser().ParseUnverified(tokenString, jwt.MapClaims{})
+	if err != nil {
+		return "", errorHelper.Wrap(errorHelper.InvalidCredentials, op, ErrorParseToken, err)
+	}
+	if kid, ok := token.Header["kid"]; ok {
+		if s, ok := kid.(string); ok {
+			return s, nil
+		}
+		return "", errorHelper.Wrap(errorHelper.InvalidCredentials, op, ErrorGetKid, fmt.Errorf("unexpected kid type: %T", kid))
+	}
+	return "", nil
+}
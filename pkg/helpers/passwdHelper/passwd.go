@@ -1,7 +1,6 @@
 package passwdHelper
 
 import (
-	"fmt"
 	"golang.org/x/crypto/bcrypt"
 	"sso/pkg/helpers/errorHelper"
 )
@@ -17,7 +16,6 @@ func HashPassword(password string) (string, error) {
 	if err != nil {
 		return "", errorHelper.WrapError(op, ErrorCreateHash, err)
 	}
-	fmt.Println("password hashed", string(bytes))
 	return string(bytes), nil
 }
 
@@ -2,6 +2,7 @@ package slogHelper
 
 import (
 	"context"
+	"go.opentelemetry.io/otel/trace"
 	"log/slog"
 	"os"
 )
@@ -22,7 +23,11 @@ func AddOperation(log *slog.Logger, op string) *slog.Logger {
 	return log.With(slog.String("op", op))
 }
 
+// AddRequestId adds request id and, if the request is traced, trace and span ids to correlate logs with traces
 func AddRequestId(log *slog.Logger, context context.Context) *slog.Logger {
+	if span := trace.SpanContextFromContext(context); span.IsValid() {
+		log = log.With(slog.String("traceId", span.TraceID().String()), slog.String("spanId", span.SpanID().String()))
+	}
 	if id, ok := context.Value("X-Request-Id").(string); ok {
 		return log.With("requestId", id)
 	}
@@ -0,0 +1,29 @@
+package tokenHelper
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"sso/pkg/helpers/errorHelper"
+)
+
+const (
+	ErrorGenerate = "error on generate random token"
+)
+
+// Generate returns url safe random string made of n random bytes
+func Generate(n int) (string, error) {
+	const op = "pkg.helpers.tokenHelper.Generate()"
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", errorHelper.WrapError(op, ErrorGenerate, err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Hash returns SHA-256 hex digest of the token, opaque tokens are stored only hashed
+func Hash(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,24 @@
+package tokenHelper
+
+import (
+	"encoding/base64"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	a, err := Generate(32)
+	require.NoError(t, err)
+	b, err := Generate(32)
+	require.NoError(t, err)
+	require.NotEqual(t, a, b)
+	raw, err := base64.RawURLEncoding.DecodeString(a)
+	require.NoError(t, err)
+	require.Len(t, raw, 32)
+}
+
+func TestHash(t *testing.T) {
+	require.Equal(t, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", Hash(""))
+	require.Equal(t, Hash("token"), Hash("token"))
+	require.NotEqual(t, Hash("token"), Hash("token2"))
+}
@@ -0,0 +1,107 @@
+package totpHelper
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"github.com/skip2/go-qrcode"
+	"net/url"
+	"sso/pkg/helpers/errorHelper"
+	"strings"
+	"time"
+)
+
+const (
+	ErrorGenerate     = "error on generate totp secret"
+	ErrorDecodeSecret = "error on decode totp secret"
+	ErrorQR           = "error on render qr code"
+)
+
+// Parameters of generated codes, the ones supported by every authenticator app
+const (
+	Digits      = 6
+	Period      = 30 * time.Second
+	SecretBytes = 20 //RFC 4226 section 4 recommends 160 bits
+	Skew        = 1  //accepted steps before and after the current one, compensates clock drift
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret returns random base32 encoded secret
+func GenerateSecret() (string, error) {
+	const op = "pkg.helpers.totpHelper.GenerateSecret()"
+	b := make([]byte, SecretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", errorHelper.WrapError(op, ErrorGenerate, err)
+	}
+	return encoding.EncodeToString(b), nil
+}
+
+// Step returns RFC 6238 time step number of t
+func Step(t time.Time) int64 {
+	return t.Unix() / int64(Period/time.Second)
+}
+
+// Code returns RFC 4226 HOTP value of key for counter with given number of digits
+func Code(key []byte, counter int64, digits int) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(counter))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, value%mod)
+}
+
+// Validate checks code against steps around now and returns the matched step.
+// Callers must reject steps not greater than the last accepted one, so a code can't be replayed.
+func Validate(secret string, code string, now time.Time) (int64, bool, error) {
+	const op = "pkg.helpers.totpHelper.Validate()"
+	key, err := encoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return 0, false, errorHelper.WrapError(op, ErrorDecodeSecret, err)
+	}
+	code = strings.ReplaceAll(code, " ", "")
+	if len(code) != Digits {
+		return 0, false, nil
+	}
+	current := Step(now)
+	for step := current - Skew; step <= current+Skew; step++ {
+		if subtle.ConstantTimeCompare([]byte(Code(key, step, Digits)), []byte(code)) == 1 {
+			return step, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// URI returns otpauth:// key URI understood by authenticator apps
+func URI(issuer string, account string, secret string) string {
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	params := url.Values{
+		"secret":    {secret},
+		"issuer":    {issuer},
+		"algorithm": {"SHA1"},
+		"digits":    {fmt.Sprint(Digits)},
+		"period":    {fmt.Sprint(int(Period / time.Second))},
+	}
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// QR returns PNG image of the key URI to scan with authenticator app
+func QR(uri string, size int) ([]byte, error) {
+	const op = "pkg.helpers.totpHelper.QR()"
+	png, err := qrcode.Encode(uri, qrcode.Medium, size)
+	if err != nil {
+		return nil, errorHelper.WrapError(op, ErrorQR, err)
+	}
+	return png, nil
+}
@@ -0,0 +1,66 @@
+package totpHelper
+
+import (
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCode(t *testing.T) {
+	//SHA1 test vectors from RFC 6238 appendix B
+	key := []byte("12345678901234567890")
+	tests := []struct {
+		time int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.code, Code(key, Step(time.Unix(tt.time, 0)), 8))
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+	key, err := encoding.DecodeString(secret)
+	require.NoError(t, err)
+	now := time.Unix(1700000000, 0)
+
+	step, ok, err := Validate(secret, Code(key, Step(now), Digits), now)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, Step(now), step)
+
+	//previous step is accepted because of clock drift, older ones are not
+	_, ok, _ = Validate(secret, Code(key, Step(now)-1, Digits), now)
+	require.True(t, ok)
+	_, ok, _ = Validate(secret, Code(key, Step(now)-2, Digits), now)
+	require.False(t, ok)
+
+	_, ok, _ = Validate(secret, "12345", now)
+	require.False(t, ok)
+	_, _, err = Validate("not base32!", "123456", now)
+	require.Error(t, err)
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("DM SSO", "root", "JBSWY3DPEHPK3PXP")
+	require.True(t, strings.HasPrefix(uri, "otpauth://totp/DM%20SSO:root?"))
+	require.Contains(t, uri, "secret=JBSWY3DPEHPK3PXP")
+	require.Contains(t, uri, "issuer=DM+SSO")
+	require.Contains(t, uri, "digits=6")
+	require.Contains(t, uri, "period=30")
+}
+
+func TestQR(t *testing.T) {
+	png, err := QR(URI("DM SSO", "root", "JBSWY3DPEHPK3PXP"), 256)
+	require.NoError(t, err)
+	require.Equal(t, "\x89PNG\r\n\x1a\n", string(png[:8]))
+}
@@ -0,0 +1,152 @@
+package webauthnHelper
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+const (
+	ErrorCborTruncated = "cbor data is truncated"
+	ErrorCborType      = "unsupported cbor type"
+	ErrorCborDepth     = "cbor data is nested too deep"
+)
+
+const cborMaxDepth = 16
+
+// decodeCbor decodes one CBOR item (RFC 8949) and returns it with the number of consumed bytes.
+// Only types used by WebAuthn are supported: integers, byte and text strings, arrays, maps, booleans and null.
+// Integers are int64, maps are map[any]any with int64 or string keys.
+func decodeCbor(data []byte) (any, int, error) {
+	d := &cborDecoder{data: data}
+	v, err := d.item(0)
+	if err != nil {
+		return nil, 0, err
+	}
+	return v, d.pos, nil
+}
+
+type cborDecoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *cborDecoder) item(depth int) (any, error) {
+	if depth > cborMaxDepth {
+		return nil, errors.New(ErrorCborDepth)
+	}
+	if d.pos >= len(d.data) {
+		return nil, errors.New(ErrorCborTruncated)
+	}
+	major, info := d.data[d.pos]>>5, d.data[d.pos]&0x1f
+	d.pos++
+	if major == 7 {
+		switch info {
+		case 20:
+			return false, nil
+		case 21:
+			return true, nil
+		case 22:
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%s: simple %d", ErrorCborType, info)
+	}
+	arg, err := d.argument(info)
+	if err != nil {
+		return nil, err
+	}
+	switch major {
+	case 0:
+		if arg > math.MaxInt64 {
+			return nil, fmt.Errorf("%s: integer overflow", ErrorCborType)
+		}
+		return int64(arg), nil
+	case 1:
+		if arg > math.MaxInt64 {
+			return nil, fmt.Errorf("%s: integer overflow", ErrorCborType)
+		}
+		return -1 - int64(arg), nil
+	case 2, 3:
+		b, err := d.bytes(arg)
+		if err != nil {
+			return nil, err
+		}
+		if major == 3 {
+			return string(b), nil
+		}
+		return b, nil
+	case 4:
+		if arg > uint64(len(d.data)) {
+			return nil, errors.New(ErrorCborTruncated)
+		}
+		res := make([]any, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			v, err := d.item(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, v)
+		}
+		return res, nil
+	case 5:
+		if arg > uint64(len(d.data)) {
+			return nil, errors.New(ErrorCborTruncated)
+		}
+		res := make(map[any]any, arg)
+		for i := uint64(0); i < arg; i++ {
+			k, err := d.item(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			switch k.(type) {
+			case int64, string:
+			default:
+				return nil, fmt.Errorf("%s: map key %T", ErrorCborType, k)
+			}
+			v, err := d.item(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			res[k] = v
+		}
+		return res, nil
+	}
+	return nil, fmt.Errorf("%s: major %d", ErrorCborType, major)
+}
+
+// argument reads the integer following the initial byte, indefinite lengths are not supported
+func (d *cborDecoder) argument(info byte) (uint64, error) {
+	if info < 24 {
+		return uint64(info), nil
+	}
+	size := 0
+	switch info {
+	case 24:
+		size = 1
+	case 25:
+		size = 2
+	case 26:
+		size = 4
+	case 27:
+		size = 8
+	default:
+		return 0, fmt.Errorf("%s: additional info %d", ErrorCborType, info)
+	}
+	b, err := d.bytes(uint64(size))
+	if err != nil {
+		return 0, err
+	}
+	buf := make([]byte, 8)
+	copy(buf[8-size:], b)
+	return binary.BigEndian.Uint64(buf), nil
+}
+
+func (d *cborDecoder) bytes(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.pos) {
+		return nil, errors.New(ErrorCborTruncated)
+	}
+	b := d.data[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return b, nil
+}
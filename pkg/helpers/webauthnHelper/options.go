@@ -0,0 +1,66 @@
+package webauthnHelper
+
+// Ceremony options sent to navigator.credentials.create() and get(), WebAuthn section 5.4 and 5.5.
+// Binary values are base64url encoded as in PublicKeyCredential.parseCreationOptionsFromJSON().
+
+type RelyingParty struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type UserEntity struct {
+	Id          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type CredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+type CredentialDescriptor struct {
+	Type string `json:"type"`
+	Id   string `json:"id"`
+}
+
+type AuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey,omitempty"`
+	UserVerification string `json:"userVerification,omitempty"`
+}
+
+type CreationOptions struct {
+	Rp                     RelyingParty           `json:"rp"`
+	User                   UserEntity             `json:"user"`
+	Challenge              string                 `json:"challenge"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout,omitempty"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+type RequestOptions struct {
+	Challenge        string                 `json:"challenge"`
+	Timeout          int64                  `json:"timeout,omitempty"`
+	RpId             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+// Option values
+const (
+	CredentialTypePublicKey = "public-key"
+	Required                = "required"
+	Preferred               = "preferred"
+	Discouraged             = "discouraged"
+	AttestationNone         = "none"
+	AttestationDirect       = "direct"
+)
+
+// SupportedAlgorithms are credential algorithms in order of preference
+var SupportedAlgorithms = []CredentialParameter{
+	{Type: CredentialTypePublicKey, Alg: AlgES256},
+	{Type: CredentialTypePublicKey, Alg: AlgEdDSA},
+	{Type: CredentialTypePublicKey, Alg: AlgRS256},
+}
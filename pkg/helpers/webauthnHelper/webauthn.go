@@ -0,0 +1,328 @@
+package webauthnHelper
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sso/pkg/helpers/errorHelper"
+)
+
+const (
+	ErrorAuthData        = "bad authenticator data"
+	ErrorClientData      = "bad client data"
+	ErrorPublicKey       = "bad credential public key"
+	ErrorAttestation     = "bad attestation"
+	ErrorAttestationFmt  = "unsupported attestation format"
+	ErrorSignature       = "signature is invalid"
+	ErrorUnsupportedAlg  = "unsupported algorithm"
+	ErrorNoCredentialKey = "authenticator data has no attested credential"
+)
+
+// Authenticator data flags, WebAuthn section 6.1
+const (
+	FlagUserPresent        = 0x01
+	FlagUserVerified       = 0x04
+	FlagAttestedCredential = 0x40
+	FlagExtensions         = 0x80
+)
+
+// COSE algorithms, RFC 9053
+const (
+	AlgES256 int64 = -7
+	AlgEdDSA int64 = -8
+	AlgRS256 int64 = -257
+)
+
+// Attestation statement formats, WebAuthn section 8
+const (
+	FormatNone   = "none"
+	FormatPacked = "packed"
+)
+
+// Client data types, WebAuthn section 5.8.1
+const (
+	TypeCreate = "webauthn.create"
+	TypeGet    = "webauthn.get"
+)
+
+// oidAAGUID is id-fido-gen-ce-aaguid certificate extension
+var oidAAGUID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 45724, 1, 1, 4}
+
+// AuthenticatorData is parsed WebAuthn section 6.1 structure, credential fields are set only at registration
+type AuthenticatorData struct {
+	RpIdHash     []byte
+	Flags        byte
+	SignCount    uint32
+	AAGUID       []byte
+	CredentialId []byte
+	PublicKey    []byte //COSE_Key
+}
+
+func (a *AuthenticatorData) UserPresent() bool {
+	return a.Flags&FlagUserPresent != 0
+}
+
+func (a *AuthenticatorData) UserVerified() bool {
+	return a.Flags&FlagUserVerified != 0
+}
+
+// ClientData is collected client data, WebAuthn section 5.8.1
+type ClientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+func ParseAuthenticatorData(data []byte) (*AuthenticatorData, error) {
+	const op = "pkg.helpers.webauthnHelper.ParseAuthenticatorData()"
+	if len(data) < 37 {
+		return nil, errorHelper.WrapError(op, ErrorAuthData, errors.New("too short"))
+	}
+	res := &AuthenticatorData{
+		RpIdHash:  data[:32],
+		Flags:     data[32],
+		SignCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	rest := data[37:]
+	if res.Flags&FlagAttestedCredential != 0 {
+		if len(rest) < 18 {
+			return nil, errorHelper.WrapError(op, ErrorAuthData, errors.New("truncated attested credential"))
+		}
+		res.AAGUID = rest[:16]
+		n := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if len(rest) < n {
+			return nil, errorHelper.WrapError(op, ErrorAuthData, errors.New("truncated credential id"))
+		}
+		res.CredentialId, rest = rest[:n], rest[n:]
+		_, size, err := decodeCbor(rest)
+		if err != nil {
+			return nil, errorHelper.WrapError(op, ErrorAuthData, err)
+		}
+		res.PublicKey, rest = rest[:size], rest[size:]
+	}
+	if res.Flags&FlagExtensions != 0 {
+		_, size, err := decodeCbor(rest)
+		if err != nil {
+			return nil, errorHelper.WrapError(op, ErrorAuthData, err)
+		}
+		rest = rest[size:]
+	}
+	if len(rest) != 0 {
+		return nil, errorHelper.WrapError(op, ErrorAuthData, errors.New("trailing bytes"))
+	}
+	return res, nil
+}
+
+// CheckRpId checks that authenticator data is scoped to the relying party
+func (a *AuthenticatorData) CheckRpId(rpId string) bool {
+	hash := sha256.Sum256([]byte(rpId))
+	return bytes.Equal(a.RpIdHash, hash[:])
+}
+
+// ParseClientData parses clientDataJSON and checks its type, challenge and origin
+func ParseClientData(data []byte, typ string, challenge string, origins []string) (*ClientData, error) {
+	const op = "pkg.helpers.webauthnHelper.ParseClientData()"
+	res := &ClientData{}
+	if err := json.Unmarshal(data, res); err != nil {
+		return nil, errorHelper.WrapError(op, ErrorClientData, err)
+	}
+	if res.Type != typ {
+		return nil, errorHelper.WrapError(op, ErrorClientData, fmt.Errorf("type %q", res.Type))
+	}
+	if res.Challenge != challenge {
+		return nil, errorHelper.WrapError(op, ErrorClientData, errors.New("challenge mismatch"))
+	}
+	originOk := false
+	for _, o := range origins {
+		originOk = originOk || res.Origin == o
+	}
+	if !originOk || res.CrossOrigin {
+		return nil, errorHelper.WrapError(op, ErrorClientData, fmt.Errorf("origin %q", res.Origin))
+	}
+	return res, nil
+}
+
+// ParsePublicKey converts COSE_Key to public key and returns its algorithm
+func ParsePublicKey(cose []byte) (crypto.PublicKey, int64, error) {
+	const op = "pkg.helpers.webauthnHelper.ParsePublicKey()"
+	v, size, err := decodeCbor(cose)
+	if err != nil {
+		return nil, 0, errorHelper.WrapError(op, ErrorPublicKey, err)
+	}
+	key, ok := v.(map[any]any)
+	if !ok || size != len(cose) {
+		return nil, 0, errorHelper.WrapError(op, ErrorPublicKey, errors.New("not a map"))
+	}
+	kty, _ := key[int64(1)].(int64)
+	alg, _ := key[int64(3)].(int64)
+	switch {
+	case kty == 2 && alg == AlgES256:
+		crv, _ := key[int64(-1)].(int64)
+		x, _ := key[int64(-2)].([]byte)
+		y, _ := key[int64(-3)].([]byte)
+		if crv != 1 || len(x) != 32 || len(y) != 32 {
+			return nil, 0, errorHelper.WrapError(op, ErrorPublicKey, errors.New("bad P-256 key"))
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, 0, errorHelper.WrapError(op, ErrorPublicKey, errors.New("point is not on curve"))
+		}
+		return pub, alg, nil
+	case kty == 1 && alg == AlgEdDSA:
+		crv, _ := key[int64(-1)].(int64)
+		x, _ := key[int64(-2)].([]byte)
+		if crv != 6 || len(x) != ed25519.PublicKeySize {
+			return nil, 0, errorHelper.WrapError(op, ErrorPublicKey, errors.New("bad Ed25519 key"))
+		}
+		return ed25519.PublicKey(x), alg, nil
+	case kty == 3 && alg == AlgRS256:
+		n, _ := key[int64(-1)].([]byte)
+		e, _ := key[int64(-2)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, 0, errorHelper.WrapError(op, ErrorPublicKey, errors.New("bad RSA key"))
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, alg, nil
+	}
+	return nil, 0, errorHelper.WrapError(op, ErrorUnsupportedAlg, fmt.Errorf("kty %d alg %d", kty, alg))
+}
+
+// VerifySignature checks signature over data made with alg
+func VerifySignature(pub crypto.PublicKey, alg int64, data []byte, sig []byte) error {
+	const op = "pkg.helpers.webauthnHelper.VerifySignature()"
+	hash := sha256.Sum256(data)
+	ok := false
+	switch key := pub.(type) {
+	case *ecdsa.PublicKey:
+		ok = alg == AlgES256 && ecdsa.VerifyASN1(key, hash[:], sig)
+	case ed25519.PublicKey:
+		ok = alg == AlgEdDSA && ed25519.Verify(key, data, sig)
+	case *rsa.PublicKey:
+		ok = alg == AlgRS256 && rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], sig) == nil
+	default:
+		return errorHelper.WrapError(op, ErrorUnsupportedAlg, fmt.Errorf("%T", pub))
+	}
+	if !ok {
+		return errorHelper.WrapError(op, ErrorSignature, fmt.Errorf("alg %d", alg))
+	}
+	return nil
+}
+
+// VerifyAttestation parses attestationObject and verifies "none" or "packed" attestation statement.
+// Packed attestation certificates are checked for WebAuthn section 8.2.1 requirements only,
+// the chain is not validated against vendor roots, so attestation proves key possession, not authenticator model.
+func VerifyAttestation(attestationObject []byte, clientDataHash []byte) (*AuthenticatorData, error) {
+	const op = "pkg.helpers.webauthnHelper.VerifyAttestation()"
+	v, size, err := decodeCbor(attestationObject)
+	if err != nil {
+		return nil, errorHelper.WrapError(op, ErrorAttestation, err)
+	}
+	obj, ok := v.(map[any]any)
+	if !ok || size != len(attestationObject) {
+		return nil, errorHelper.WrapError(op, ErrorAttestation, errors.New("not a map"))
+	}
+	format, _ := obj["fmt"].(string)
+	rawAuthData, _ := obj["authData"].([]byte)
+	stmt, _ := obj["attStmt"].(map[any]any)
+	if stmt == nil {
+		return nil, errorHelper.WrapError(op, ErrorAttestation, errors.New("no attStmt"))
+	}
+	authData, err := ParseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, errorHelper.WrapError(op, ErrorAttestation, err)
+	}
+	if authData.PublicKey == nil {
+		return nil, errorHelper.WrapError(op, ErrorAttestation, errors.New(ErrorNoCredentialKey))
+	}
+	credentialKey, credentialAlg, err := ParsePublicKey(authData.PublicKey)
+	if err != nil {
+		return nil, errorHelper.WrapError(op, ErrorAttestation, err)
+	}
+	switch format {
+	case FormatNone:
+		if len(stmt) != 0 {
+			return nil, errorHelper.WrapError(op, ErrorAttestation, errors.New("none attestation with statement"))
+		}
+	case FormatPacked:
+		alg, _ := stmt["alg"].(int64)
+		sig, _ := stmt["sig"].([]byte)
+		signed := append(append([]byte{}, rawAuthData...), clientDataHash...)
+		if x5c, ok := stmt["x5c"].([]any); ok && len(x5c) > 0 {
+			der, _ := x5c[0].([]byte)
+			cert, err := x509.ParseCertificate(der)
+			if err != nil {
+				return nil, errorHelper.WrapError(op, ErrorAttestation, err)
+			}
+			if err := checkPackedCertificate(cert, authData.AAGUID); err != nil {
+				return nil, errorHelper.WrapError(op, ErrorAttestation, err)
+			}
+			if err := VerifySignature(cert.PublicKey, alg, signed, sig); err != nil {
+				return nil, errorHelper.WrapError(op, ErrorAttestation, err)
+			}
+		} else {
+			//self attestation is signed by the credential key itself
+			if alg != credentialAlg {
+				return nil, errorHelper.WrapError(op, ErrorAttestation, errors.New("self attestation alg mismatch"))
+			}
+			if err := VerifySignature(credentialKey, alg, signed, sig); err != nil {
+				return nil, errorHelper.WrapError(op, ErrorAttestation, err)
+			}
+		}
+	default:
+		return nil, errorHelper.WrapError(op, ErrorAttestationFmt, errors.New(format))
+	}
+	return authData, nil
+}
+
+// checkPackedCertificate checks attestation certificate requirements of WebAuthn section 8.2.1
+func checkPackedCertificate(cert *x509.Certificate, aaguid []byte) error {
+	if cert.Version != 3 {
+		return errors.New("attestation certificate must be version 3")
+	}
+	if cert.IsCA {
+		return errors.New("attestation certificate must not be CA")
+	}
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidAAGUID) {
+			continue
+		}
+		var value []byte
+		if _, err := asn1.Unmarshal(ext.Value, &value); err != nil {
+			return err
+		}
+		if !bytes.Equal(value, aaguid) {
+			return errors.New("attestation certificate aaguid mismatch")
+		}
+	}
+	return nil
+}
+
+// Encode is base64url without padding used for challenges, credential and user ids
+func Encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Decode accepts base64url with or without padding
+func Decode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(trimPadding(s))
+}
+
+func trimPadding(s string) string {
+	for len(s) > 0 && s[len(s)-1] == '=' {
+		s = s[:len(s)-1]
+	}
+	return s
+}
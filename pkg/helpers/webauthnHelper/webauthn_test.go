@@ -0,0 +1,96 @@
+package webauthnHelper
+
+import (
+	"crypto/sha256"
+	"github.com/stretchr/testify/require"
+	"sso/pkg/helpers/webauthnHelper/webauthntest"
+	"testing"
+)
+
+const (
+	testRpId   = "sso.example.com"
+	testOrigin = "https://sso.example.com"
+)
+
+func TestDecodeCbor(t *testing.T) {
+	v, n, err := decodeCbor(webauthntest.CborEncode(map[any]any{"a": []any{1, -2, true}, 3: []byte{1, 2}}))
+	require.NoError(t, err)
+	require.Equal(t, map[any]any{"a": []any{int64(1), int64(-2), true}, int64(3): []byte{1, 2}}, v)
+	require.Equal(t, 11, n)
+
+	//truncated, indefinite length and huge length items are rejected
+	_, _, err = decodeCbor([]byte{0x62, 'a'})
+	require.Error(t, err)
+	_, _, err = decodeCbor([]byte{0x9f})
+	require.Error(t, err)
+	_, _, err = decodeCbor([]byte{0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	require.Error(t, err)
+}
+
+func TestRegistration(t *testing.T) {
+	a := webauthntest.New(t)
+	data := webauthntest.ClientData(TypeCreate, "challenge", testOrigin)
+	hash := sha256.Sum256(data)
+
+	for _, format := range []string{FormatNone, FormatPacked} {
+		authData, err := VerifyAttestation(a.Create(testRpId, data, format, nil), hash[:])
+		require.NoError(t, err, format)
+		require.True(t, authData.CheckRpId(testRpId))
+		require.False(t, authData.CheckRpId("evil.example.com"))
+		require.True(t, authData.UserPresent())
+		require.Equal(t, a.CredentialId, authData.CredentialId)
+		_, alg, err := ParsePublicKey(authData.PublicKey)
+		require.NoError(t, err)
+		require.Equal(t, AlgES256, alg)
+	}
+
+	//packed attestation with certificate
+	cert := webauthntest.NewAttestationCert(t, a.Aaguid, false)
+	_, err := VerifyAttestation(a.Create(testRpId, data, FormatPacked, cert), hash[:])
+	require.NoError(t, err)
+
+	//CA certificate and other aaguid are rejected
+	_, err = VerifyAttestation(a.Create(testRpId, data, FormatPacked, webauthntest.NewAttestationCert(t, a.Aaguid, true)), hash[:])
+	require.Error(t, err)
+	_, err = VerifyAttestation(a.Create(testRpId, data, FormatPacked, webauthntest.NewAttestationCert(t, make([]byte, 16)[1:], false)), hash[:])
+	require.Error(t, err)
+
+	//signature over other client data is rejected
+	other := sha256.Sum256(webauthntest.ClientData(TypeCreate, "other", testOrigin))
+	_, err = VerifyAttestation(a.Create(testRpId, data, FormatPacked, nil), other[:])
+	require.Error(t, err)
+
+	//unknown formats are rejected
+	_, err = VerifyAttestation(a.Create(testRpId, data, "tpm", nil), hash[:])
+	require.Error(t, err)
+}
+
+func TestAssertion(t *testing.T) {
+	a := webauthntest.New(t)
+	pub, alg, err := ParsePublicKey(a.CoseKey())
+	require.NoError(t, err)
+
+	data := webauthntest.ClientData(TypeGet, "challenge", testOrigin)
+	rawAuthData, sig := a.Get(testRpId, data, FlagUserPresent)
+	authData, err := ParseAuthenticatorData(rawAuthData)
+	require.NoError(t, err)
+	require.Equal(t, uint32(1), authData.SignCount)
+	require.False(t, authData.UserVerified())
+
+	hash := sha256.Sum256(data)
+	require.NoError(t, VerifySignature(pub, alg, append(rawAuthData, hash[:]...), sig))
+	sig[len(sig)-1] ^= 1
+	require.Error(t, VerifySignature(pub, alg, append(rawAuthData, hash[:]...), sig))
+}
+
+func TestParseClientData(t *testing.T) {
+	origins := []string{testOrigin}
+	_, err := ParseClientData(webauthntest.ClientData(TypeGet, "c", testOrigin), TypeGet, "c", origins)
+	require.NoError(t, err)
+	_, err = ParseClientData(webauthntest.ClientData(TypeCreate, "c", testOrigin), TypeGet, "c", origins)
+	require.Error(t, err)
+	_, err = ParseClientData(webauthntest.ClientData(TypeGet, "other", testOrigin), TypeGet, "c", origins)
+	require.Error(t, err)
+	_, err = ParseClientData(webauthntest.ClientData(TypeGet, "c", "https://evil.example.com"), TypeGet, "c", origins)
+	require.Error(t, err)
+}
@@ -0,0 +1,202 @@
+// Package webauthntest is a software authenticator for tests of WebAuthn ceremonies.
+// It does not import webauthnHelper, so the tests of webauthnHelper itself can use it.
+package webauthntest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/json"
+	"math/big"
+	"sort"
+	"testing"
+	"time"
+)
+
+// Authenticator data flags, WebAuthn section 6.1
+const (
+	FlagUserPresent        = 0x01
+	FlagUserVerified       = 0x04
+	FlagAttestedCredential = 0x40
+)
+
+// formatPacked is the attestation statement format signed by the credential or certificate key
+const formatPacked = "packed"
+
+// oidAAGUID is id-fido-gen-ce-aaguid certificate extension
+var oidAAGUID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 45724, 1, 1, 4}
+
+// CborEncode encodes the subset of CBOR used by authenticators, map keys are sorted canonically
+func CborEncode(v any) []byte {
+	head := func(major byte, n uint64) []byte {
+		switch {
+		case n < 24:
+			return []byte{major<<5 | byte(n)}
+		case n < 1<<8:
+			return []byte{major<<5 | 24, byte(n)}
+		case n < 1<<16:
+			return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(n))
+		default:
+			return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(n))
+		}
+	}
+	switch x := v.(type) {
+	case int:
+		if x < 0 {
+			return head(1, uint64(-1-x))
+		}
+		return head(0, uint64(x))
+	case int64:
+		return CborEncode(int(x))
+	case []byte:
+		return append(head(2, uint64(len(x))), x...)
+	case string:
+		return append(head(3, uint64(len(x))), x...)
+	case []any:
+		res := head(4, uint64(len(x)))
+		for _, item := range x {
+			res = append(res, CborEncode(item)...)
+		}
+		return res
+	case map[any]any:
+		keys := make([][]byte, 0, len(x))
+		values := map[string][]byte{}
+		for k, item := range x {
+			ek := CborEncode(k)
+			keys = append(keys, ek)
+			values[string(ek)] = CborEncode(item)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if len(keys[i]) != len(keys[j]) {
+				return len(keys[i]) < len(keys[j])
+			}
+			return string(keys[i]) < string(keys[j])
+		})
+		res := head(5, uint64(len(x)))
+		for _, k := range keys {
+			res = append(append(res, k...), values[string(k)]...)
+		}
+		return res
+	case bool:
+		if x {
+			return []byte{0xf5}
+		}
+		return []byte{0xf4}
+	}
+	panic("unsupported type")
+}
+
+// Authenticator is a software P-256 authenticator holding a single credential
+type Authenticator struct {
+	t            testing.TB
+	Key          *ecdsa.PrivateKey
+	CredentialId []byte
+	Aaguid       []byte
+	SignCount    uint32 //incremented by each assertion
+}
+
+func New(t testing.TB) *Authenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return &Authenticator{t: t, Key: key, CredentialId: id, Aaguid: make([]byte, 16)}
+}
+
+// CoseKey is the credential public key in COSE_Key format
+func (a *Authenticator) CoseKey() []byte {
+	x, y := make([]byte, 32), make([]byte, 32)
+	a.Key.X.FillBytes(x)
+	a.Key.Y.FillBytes(y)
+	return CborEncode(map[any]any{1: 2, 3: -7, -1: 1, -2: x, -3: y})
+}
+
+// AuthData is authenticator data with the current sign count, attested data is added for registration
+func (a *Authenticator) AuthData(rpId string, flags byte, attested bool) []byte {
+	hash := sha256.Sum256([]byte(rpId))
+	res := append(hash[:], flags)
+	res = binary.BigEndian.AppendUint32(res, a.SignCount)
+	if attested {
+		res = append(res, a.Aaguid...)
+		res = binary.BigEndian.AppendUint16(res, uint16(len(a.CredentialId)))
+		res = append(res, a.CredentialId...)
+		res = append(res, a.CoseKey()...)
+	}
+	return res
+}
+
+// ClientData is clientDataJSON a browser passes to the authenticator
+func ClientData(typ string, challenge string, origin string) []byte {
+	b, _ := json.Marshal(map[string]any{"type": typ, "challenge": challenge, "origin": origin, "crossOrigin": false})
+	return b
+}
+
+func (a *Authenticator) sign(key *ecdsa.PrivateKey, data ...[]byte) []byte {
+	h := sha256.New()
+	for _, d := range data {
+		h.Write(d)
+	}
+	sig, err := ecdsa.SignASN1(rand.Reader, key, h.Sum(nil))
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return sig
+}
+
+// Create makes attestation object of the given format, x5c is used for packed format when set
+func (a *Authenticator) Create(rpId string, clientDataJSON []byte, format string, x5c *AttestationCert) []byte {
+	authData := a.AuthData(rpId, FlagUserPresent|FlagUserVerified|FlagAttestedCredential, true)
+	stmt := map[any]any{}
+	if format == formatPacked {
+		hash := sha256.Sum256(clientDataJSON)
+		if x5c != nil {
+			stmt = map[any]any{"alg": -7, "sig": a.sign(x5c.Key, authData, hash[:]), "x5c": []any{x5c.Der}}
+		} else {
+			stmt = map[any]any{"alg": -7, "sig": a.sign(a.Key, authData, hash[:])}
+		}
+	}
+	return CborEncode(map[any]any{"fmt": format, "authData": authData, "attStmt": stmt})
+}
+
+// Get makes assertion: authenticator data and signature
+func (a *Authenticator) Get(rpId string, clientDataJSON []byte, flags byte) ([]byte, []byte) {
+	a.SignCount++
+	authData := a.AuthData(rpId, flags, false)
+	hash := sha256.Sum256(clientDataJSON)
+	return authData, a.sign(a.Key, authData, hash[:])
+}
+
+// AttestationCert is a self-signed certificate of packed attestation
+type AttestationCert struct {
+	Key *ecdsa.PrivateKey
+	Der []byte
+}
+
+func NewAttestationCert(t testing.TB, aaguid []byte, isCA bool) *AttestationCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ext, _ := asn1.Marshal(aaguid)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Authenticator Attestation", OrganizationalUnit: []string{"Authenticator Attestation"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		ExtraExtensions:       []pkix.Extension{{Id: oidAAGUID, Value: ext}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &AttestationCert{Key: key, Der: der}
+}
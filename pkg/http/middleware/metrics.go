@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"log/slog"
+	"net/http"
+	"sso/pkg/helpers/slogHelper"
+	"sso/pkg/http/routing"
+	"strconv"
+	"time"
+)
+
+// RouteFunc returns the route pattern of the request or empty string if no route matches
+type RouteFunc func(r *http.Request) string
+
+// unmatched is the route and method label of requests matching no route, so that
+// arbitrary paths and methods don't create new series
+const unmatched = "unmatched"
+
+// Metrics counts requests and observes their latency per route pattern, method and status.
+// The collectors are registered in registerer.
+func Metrics(log *slog.Logger, registerer prometheus.Registerer, route RouteFunc) routing.MiddlewareFunc {
+	log = slogHelper.ConfigureForMiddleware(log, "Metrics")
+	labels := []string{"method", "route", "status"}
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by method, route and status.",
+	}, labels)
+	duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by method, route and status.",
+		Buckets: prometheus.DefBuckets,
+	}, labels)
+	registerer.MustRegister(requests, duration)
+	return func(next http.Handler) http.HandlerFunc {
+		return func(w http.ResponseWriter, req *http.Request) {
+			start := time.Now()
+			recorder := &statusRecorder{
+				ResponseWriter: w,
+				Status:         http.StatusOK,
+			}
+			next.ServeHTTP(recorder, req)
+			method, pattern := req.Method, route(req)
+			if pattern == "" {
+				method, pattern = unmatched, unmatched
+			}
+			values := []string{method, pattern, strconv.Itoa(recorder.Status)}
+			requests.WithLabelValues(values...).Inc()
+			duration.WithLabelValues(values...).Observe(time.Since(start).Seconds())
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sso/pkg/http/routing"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	registry := prometheus.NewRegistry()
+	routes := routing.New()
+	routes.
+		Handle("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}).
+		UseMiddleware(Metrics(log, registry, routes.Pattern))
+	for _, path := range []string{"/users/1", "/users/2", "/unknown/1", "/unknown/2"} {
+		routes.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	routes.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("PROPFIND", "/users/1", nil))
+
+	//paths of a route share one series, unmatched requests share another one
+	expected := `
+# HELP http_requests_total HTTP requests by method, route and status.
+# TYPE http_requests_total counter
+http_requests_total{method="GET",route="GET /users/{id}",status="404"} 2
+http_requests_total{method="unmatched",route="unmatched",status="404"} 2
+http_requests_total{method="unmatched",route="unmatched",status="405"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "http_requests_total"); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := testutil.GatherAndCount(registry, "http_request_duration_seconds"); err != nil || n != 3 {
+		t.Fatalf("duration series: %d %v", n, err)
+	}
+}
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"net/http"
+	"sso/pkg/helpers/jsonHelper"
+)
+
+// problem is RFC 7807 response of middlewares, they don't know problem types of the application and use about:blank
+type problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Code   string `json:"code"`
+}
+
+// writeProblem answers with problem details, title is the status text
+func writeProblem(w http.ResponseWriter, status int, code string, detail string) {
+	_ = jsonHelper.WriteProblem(&problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}, status, w)
+}
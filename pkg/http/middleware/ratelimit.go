@@ -0,0 +1,207 @@
+package middleware
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"sso/pkg/helpers/slogHelper"
+	"sso/pkg/http/routing"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// KeyFunc returns the key requests are counted by, requests with empty key are not limited
+type KeyFunc func(r *http.Request) string
+
+// RateLimit is a token bucket per key: Burst requests at once, refilled at Rate requests per second.
+// Several limits may share a pattern, the request must pass all of them.
+type RateLimit struct {
+	Pattern string //ServeMux pattern of limited routes, the most specific pattern wins like in routing
+	Rate    float64
+	Burst   int
+	Key     KeyFunc //ByIp if nil
+}
+
+// sweepInterval is how often full buckets are dropped
+const sweepInterval = time.Minute
+
+// RateLimiter rejects requests over the limit of their route with 429 and Retry-After.
+// RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers are set on limited routes.
+func RateLimiter(log *slog.Logger, limits ...RateLimit) routing.MiddlewareFunc {
+	log = slogHelper.ConfigureForMiddleware(log, "RateLimiter")
+	//patterns are matched by the same mux as routes
+	mux := http.NewServeMux()
+	rules := make(map[string][]*limiter)
+	for _, l := range limits {
+		if l.Key == nil {
+			l.Key = ByIp
+		}
+		if _, ok := rules[l.Pattern]; !ok {
+			mux.Handle(l.Pattern, http.NotFoundHandler())
+		}
+		rules[l.Pattern] = append(rules[l.Pattern], newLimiter(l))
+	}
+	return func(next http.Handler) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			_, pattern := mux.Handler(r)
+			limiters := make([]*limiter, 0, len(rules[pattern]))
+			keys := make([]string, 0, len(rules[pattern]))
+			for _, l := range rules[pattern] {
+				if key := l.limit.Key(r); key != "" {
+					limiters = append(limiters, l)
+					keys = append(keys, key)
+				}
+			}
+			var headers *decision
+			for i, d := range allow(limiters, keys, time.Now()) {
+				if headers == nil || !d.allowed || (headers.allowed && d.remaining < headers.remaining) {
+					headers = &d
+				}
+				if !d.allowed {
+					logger := slogHelper.AddRequestId(log, r.Context())
+					logger.Warn(http.StatusText(http.StatusTooManyRequests), slog.String("pattern", pattern), slog.String("key", keys[i]))
+				}
+			}
+			if headers != nil {
+				w.Header().Set("RateLimit-Limit", strconv.Itoa(headers.limit))
+				w.Header().Set("RateLimit-Remaining", strconv.Itoa(headers.remaining))
+				w.Header().Set("RateLimit-Reset", seconds(headers.reset))
+				if !headers.allowed {
+					w.Header().Set("Retry-After", seconds(headers.retry))
+					writeProblem(w, http.StatusTooManyRequests, "rate_limited", "")
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		}
+	}
+}
+
+// ByIp keys requests by RemoteAddr, use RealIp middleware before the limiter behind proxies
+func ByIp(r *http.Request) string {
+	if ip := parseAddr(r.RemoteAddr); ip.IsValid() {
+		return "ip:" + ip.String()
+	}
+	return "ip:" + r.RemoteAddr
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+type limiter struct {
+	limit   RateLimit
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+type decision struct {
+	allowed   bool
+	limit     int
+	remaining int
+	reset     time.Duration //until the bucket is full
+	retry     time.Duration //until the next token if not allowed
+}
+
+func newLimiter(limit RateLimit) *limiter {
+	return &limiter{
+		limit:   limit,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// take removes a token from the bucket of the key
+func (l *limiter) take(key string, now time.Time) decision {
+	return allow([]*limiter{l}, []string{key}, now)[0]
+}
+
+// allow takes a token from the bucket of each key only if every bucket has one, so a request
+// rejected by one limit does not use up the others. Limiters are locked in the order of rules,
+// the same for all requests.
+func allow(limiters []*limiter, keys []string, now time.Time) []decision {
+	for _, l := range limiters {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+	}
+	buckets := make([]*bucket, len(limiters))
+	allowed := true
+	for i, l := range limiters {
+		buckets[i] = l.bucket(keys[i], now)
+		allowed = allowed && buckets[i].tokens >= 1
+	}
+	decisions := make([]decision, len(limiters))
+	for i, l := range limiters {
+		if allowed {
+			buckets[i].tokens--
+		}
+		decisions[i] = l.decide(buckets[i], allowed)
+	}
+	return decisions
+}
+
+// bucket returns refilled bucket of the key, l.mu must be held
+func (l *limiter) bucket(key string, now time.Time) *bucket {
+	l.sweep(now)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.limit.Burst), updated: now}
+		l.buckets[key] = b
+	}
+	b.tokens = l.refill(b, now)
+	b.updated = now
+	return b
+}
+
+// decide reports the bucket state, a bucket with a token allows the request even if another limit rejected it
+func (l *limiter) decide(b *bucket, taken bool) decision {
+	d := decision{
+		allowed:   taken || b.tokens >= 1,
+		limit:     l.limit.Burst,
+		remaining: int(b.tokens),
+		reset:     l.duration(float64(l.limit.Burst) - b.tokens),
+	}
+	if !d.allowed {
+		d.retry = l.duration(1 - b.tokens)
+	}
+	return d
+}
+
+func (l *limiter) refill(b *bucket, now time.Time) float64 {
+	elapsed := now.Sub(b.updated).Seconds()
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	return math.Min(float64(l.limit.Burst), b.tokens+elapsed*l.limit.Rate)
+}
+
+// duration is the time to refill the tokens
+func (l *limiter) duration(tokens float64) time.Duration {
+	if l.limit.Rate <= 0 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(tokens / l.limit.Rate * float64(time.Second))
+}
+
+// sweep drops full buckets, they are the same as missing ones
+func (l *limiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < sweepInterval {
+		return
+	}
+	l.swept = now
+	for key, b := range l.buckets {
+		if l.refill(b, now) >= float64(l.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// seconds rounds duration up to whole seconds for headers
+func seconds(d time.Duration) string {
+	if d > 24*time.Hour*365 {
+		d = 24 * time.Hour * 365
+	}
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
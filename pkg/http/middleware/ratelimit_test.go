@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLimiterTake(t *testing.T) {
+	l := newLimiter(RateLimit{Rate: 2, Burst: 3})
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		if d := l.take("k", now); !d.allowed || d.remaining != 2-i {
+			t.Fatalf("request %d: %+v", i, d)
+		}
+	}
+	d := l.take("k", now)
+	if d.allowed || d.retry != 500*time.Millisecond || d.reset != 1500*time.Millisecond {
+		t.Fatalf("over burst: %+v", d)
+	}
+	if d := l.take("other", now); !d.allowed {
+		t.Fatal("keys must have separate buckets")
+	}
+	if d := l.take("k", now.Add(500*time.Millisecond)); !d.allowed || d.remaining != 0 {
+		t.Fatalf("after refill: %+v", d)
+	}
+	//full buckets are dropped by sweep
+	l.take("k", now.Add(time.Hour))
+	if len(l.buckets) != 1 {
+		t.Fatalf("buckets after sweep: %d", len(l.buckets))
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler := RateLimiter(log,
+		RateLimit{Pattern: "POST /token", Rate: 0.1, Burst: 1},
+		RateLimit{Pattern: "/", Rate: 100, Burst: 100},
+	)(next)
+	call := func(method string, path string, ip string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, nil)
+		r.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+	if w := call(http.MethodPost, "/token", "10.0.0.1"); w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "1" {
+		t.Fatalf("first request: %d %v", w.Code, w.Header())
+	}
+	w := call(http.MethodPost, "/token", "10.0.0.1")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request: %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "10" || w.Header().Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("headers: %v", w.Header())
+	}
+	if w := call(http.MethodPost, "/token", "10.0.0.2"); w.Code != http.StatusOK {
+		t.Fatalf("another address: %d", w.Code)
+	}
+	if w := call(http.MethodGet, "/token", "10.0.0.1"); w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "100" {
+		t.Fatalf("other route: %d %v", w.Code, w.Header())
+	}
+}
+
+func TestRateLimiterRejectedTakesNothing(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler := RateLimiter(log,
+		RateLimit{Pattern: "POST /token", Rate: 0.001, Burst: 2, Key: func(r *http.Request) string { return "all" }},
+		RateLimit{Pattern: "POST /token", Rate: 0.001, Burst: 1},
+	)(next)
+	call := func(ip string) int {
+		r := httptest.NewRequest(http.MethodPost, "/token", nil)
+		r.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w.Code
+	}
+	//the second request of 10.0.0.1 is rejected by its address limit and must not use the shared one
+	for i, want := range []struct {
+		ip   string
+		code int
+	}{
+		{"10.0.0.1", http.StatusOK},
+		{"10.0.0.1", http.StatusTooManyRequests},
+		{"10.0.0.1", http.StatusTooManyRequests},
+		{"10.0.0.2", http.StatusOK},
+		{"10.0.0.3", http.StatusTooManyRequests},
+	} {
+		if code := call(want.ip); code != want.code {
+			t.Fatalf("request %d from %s: %d, want %d", i, want.ip, code, want.code)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"sso/pkg/helpers/slogHelper"
+	"sso/pkg/http/routing"
+	"strings"
+)
+
+// RealIp replaces RemoteAddr with the client address. X-Forwarded-For and X-Real-IP are honoured only
+// when the request comes from a trusted proxy, so clients can't choose their address by sending the headers.
+func RealIp(log *slog.Logger, trusted []netip.Prefix) routing.MiddlewareFunc {
+	log = slogHelper.ConfigureForMiddleware(log, "RealIp")
+	return func(next http.Handler) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if ip := ClientIp(r, trusted); ip.IsValid() {
+				r.RemoteAddr = ip.String()
+			}
+			next.ServeHTTP(w, r)
+		}
+	}
+}
+
+// ClientIp returns the rightmost address of X-Forwarded-For chain that is not a trusted proxy
+func ClientIp(r *http.Request, trusted []netip.Prefix) netip.Addr {
+	remote := parseAddr(r.RemoteAddr)
+	if !remote.IsValid() || !isTrusted(remote, trusted) {
+		return remote
+	}
+	var chain []string
+	for _, h := range r.Header.Values("X-Forwarded-For") {
+		chain = append(chain, strings.Split(h, ",")...)
+	}
+	if len(chain) == 0 {
+		if ip := parseAddr(r.Header.Get("X-Real-IP")); ip.IsValid() {
+			return ip
+		}
+		return remote
+	}
+	client := remote
+	for i := len(chain) - 1; i >= 0; i-- {
+		ip := parseAddr(chain[i])
+		if !ip.IsValid() {
+			//garbage in the chain was not added by our proxies, the last good hop is the client
+			break
+		}
+		client = ip
+		if !isTrusted(ip, trusted) {
+			break
+		}
+	}
+	return client
+}
+
+// ParseTrustedProxies parses addresses and CIDR prefixes of trusted proxies
+func ParseTrustedProxies(list []string) ([]netip.Prefix, error) {
+	res := make([]netip.Prefix, 0, len(list))
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if strings.Contains(s, "/") {
+			p, err := netip.ParsePrefix(s)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, p.Masked())
+			continue
+		}
+		ip, err := netip.ParseAddr(s)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, netip.PrefixFrom(ip.Unmap(), ip.Unmap().BitLen()))
+	}
+	return res, nil
+}
+
+func isTrusted(ip netip.Addr, trusted []netip.Prefix) bool {
+	for _, p := range trusted {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseAddr accepts address with or without port
+func parseAddr(s string) netip.Addr {
+	s = strings.TrimSpace(s)
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	ip, err := netip.ParseAddr(strings.Trim(s, "[]"))
+	if err != nil {
+		return netip.Addr{}
+	}
+	return ip.Unmap()
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIp(t *testing.T) {
+	trusted, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		remote  string
+		forward []string
+		realIp  string
+		want    string
+	}{
+		{"direct", "203.0.113.5:1000", nil, "", "203.0.113.5"},
+		{"untrusted remote ignores headers", "203.0.113.5:1000", []string{"1.2.3.4"}, "5.6.7.8", "203.0.113.5"},
+		{"trusted proxy", "10.1.2.3:1000", []string{"198.51.100.7"}, "", "198.51.100.7"},
+		{"spoofed left part is skipped", "10.1.2.3:1000", []string{"1.1.1.1, 198.51.100.7, 192.168.1.1"}, "", "198.51.100.7"},
+		{"several header lines", "10.1.2.3:1000", []string{"1.1.1.1", "198.51.100.7"}, "", "198.51.100.7"},
+		{"only proxies", "10.1.2.3:1000", []string{"10.9.9.9"}, "", "10.9.9.9"},
+		{"garbage", "10.1.2.3:1000", []string{"198.51.100.7, bogus"}, "", "10.1.2.3"},
+		{"real ip header", "192.168.1.1:1000", nil, "198.51.100.9", "198.51.100.9"},
+		{"ipv6", "[2001:db8::1]:1000", nil, "", "2001:db8::1"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tt.remote
+		for _, h := range tt.forward {
+			r.Header.Add("X-Forwarded-For", h)
+		}
+		if tt.realIp != "" {
+			r.Header.Set("X-Real-IP", tt.realIp)
+		}
+		if got := ClientIp(r, trusted).String(); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+	if _, err := ParseTrustedProxies([]string{"not an ip"}); err == nil {
+		t.Error("bad proxy must be rejected")
+	}
+}
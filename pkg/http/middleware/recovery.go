@@ -16,9 +16,9 @@ func Recovery(log *slog.Logger, debugLevel string) routing.MiddlewareFunc {
 				if err := recover(); err != nil {
 					log.Error(http.StatusText(http.StatusInternalServerError), slogHelper.GetErrAttr(err.(error)), slog.String("stack", string(debug.Stack())))
 					if debugLevel == "prod" {
-						http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+						writeProblem(w, http.StatusInternalServerError, "internal_error", "")
 					} else {
-						http.Error(w, http.StatusText(http.StatusInternalServerError)+"\r\n\r\n"+string(debug.Stack()), http.StatusInternalServerError)
+						writeProblem(w, http.StatusInternalServerError, "internal_error", string(debug.Stack()))
 					}
 
 				}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+	"net/http"
+	"sso/pkg/helpers/slogHelper"
+	"sso/pkg/http/routing"
+)
+
+// traceContext extracts W3C traceparent and tracestate headers
+var traceContext = propagation.TraceContext{}
+
+// Tracing starts server span of every request named after its route pattern. The span continues the trace
+// of traceparent header, requests without it start a new trace. Spans are created by provider.
+func Tracing(log *slog.Logger, provider trace.TracerProvider, route RouteFunc) routing.MiddlewareFunc {
+	log = slogHelper.ConfigureForMiddleware(log, "Tracing")
+	tracer := provider.Tracer("sso/pkg/http/middleware")
+	return func(next http.Handler) http.HandlerFunc {
+		return func(w http.ResponseWriter, req *http.Request) {
+			ctx := traceContext.Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+			pattern := route(req)
+			name := pattern
+			if name == "" {
+				name = unmatched
+			}
+			attrs := []attribute.KeyValue{
+				attribute.String("http.request.method", req.Method),
+				attribute.String("http.route", pattern),
+				attribute.String("url.path", req.URL.Path),
+			}
+			if id, ok := req.Context().Value("X-Request-Id").(string); ok {
+				attrs = append(attrs, attribute.String("http.request.id", id))
+			}
+			ctx, span := tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attrs...))
+			defer span.End()
+			recorder := &statusRecorder{
+				ResponseWriter: w,
+				Status:         http.StatusOK,
+			}
+			next.ServeHTTP(recorder, req.WithContext(ctx))
+			span.SetAttributes(attribute.Int("http.response.status_code", recorder.Status))
+			//client errors are not errors of the server span
+			if recorder.Status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(recorder.Status))
+			}
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sso/pkg/http/routing"
+	"testing"
+)
+
+func TestTracing(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	var handlerSpan trace.SpanContext
+	routes := routing.New()
+	routes.
+		Handle("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+			handlerSpan = trace.SpanContextFromContext(r.Context())
+			w.WriteHeader(http.StatusInternalServerError)
+		}).
+		UseMiddleware(Tracing(log, provider, routes.Pattern))
+
+	//the trace of traceparent header is continued
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	routes.ServeHTTP(httptest.NewRecorder(), req)
+	routes.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/unknown", nil))
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "GET /users/{id}" || span.SpanKind() != trace.SpanKindServer {
+		t.Errorf("got span %q of kind %v", span.Name(), span.SpanKind())
+	}
+	if got := span.SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("trace id %s is not taken from traceparent", got)
+	}
+	if got := span.Parent().SpanID().String(); got != "00f067aa0ba902b7" {
+		t.Errorf("parent span id %s is not taken from traceparent", got)
+	}
+	if handlerSpan.SpanID() != span.SpanContext().SpanID() {
+		t.Error("handler context has no server span")
+	}
+	if span.Status().Code != codes.Error {
+		t.Errorf("5xx response must fail the span, got status %v", span.Status())
+	}
+	if !hasAttribute(span.Attributes(), attribute.Int("http.response.status_code", http.StatusInternalServerError)) {
+		t.Errorf("no status code attribute in %v", span.Attributes())
+	}
+
+	//requests without traceparent start new traces, unmatched paths share one span name
+	span = spans[1]
+	if span.Name() != unmatched || span.Parent().IsValid() {
+		t.Errorf("got span %q with parent %v", span.Name(), span.Parent())
+	}
+	if span.Status().Code == codes.Error {
+		t.Error("404 response must not fail the span")
+	}
+}
+
+func hasAttribute(attrs []attribute.KeyValue, want attribute.KeyValue) bool {
+	for _, a := range attrs {
+		if a == want {
+			return true
+		}
+	}
+	return false
+}
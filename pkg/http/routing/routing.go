@@ -8,12 +8,15 @@ type HandlerFunc = http.HandlerFunc
 type MiddlewareFunc = func(http.Handler) HandlerFunc
 
 type RouterHandler struct {
+	mux     *http.ServeMux
 	handler http.Handler
 }
 
 func New() *RouterHandler {
+	mux := http.NewServeMux()
 	return &RouterHandler{
-		handler: http.NewServeMux(),
+		mux:     mux,
+		handler: mux,
 	}
 }
 
@@ -27,6 +30,12 @@ func (r *RouterHandler) UseMiddleware(f MiddlewareFunc) *RouterHandler {
 }
 
 func (r *RouterHandler) Handle(pattern string, handler HandlerFunc) *RouterHandler {
-	r.handler.(*http.ServeMux).Handle(pattern, handler)
+	r.mux.Handle(pattern, handler)
 	return r
 }
+
+// Pattern returns the pattern of the route matching the request, empty if there is none
+func (r *RouterHandler) Pattern(req *http.Request) string {
+	_, pattern := r.mux.Handler(req)
+	return pattern
+}